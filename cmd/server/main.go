@@ -0,0 +1,31 @@
+// Command server runs the rgc API.
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/igorfelipeduca/rgc/pkg/rgc"
+)
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "doctor":
+			os.Exit(rgc.RunDoctor())
+		case "scan":
+			os.Exit(rgc.RunScan(os.Args[2:]))
+		case "demo-fixture":
+			os.Exit(rgc.RunDemoFixture(os.Args[2:]))
+		}
+	}
+	cleanup, err := rgc.Setup(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer cleanup()
+	if err := rgc.Serve(rgc.NewRouter(), ":8080"); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,255 @@
+// Package graph implements the directed graph algorithms used to analyze
+// component import relationships.
+package graph
+
+import (
+	"errors"
+	"sort"
+)
+
+// ErrCycle is returned by TopoSort when the graph is not a DAG.
+var ErrCycle = errors.New("graph contains a cycle")
+
+// Graph is a directed graph keyed by string node IDs. Nodes and edges are
+// returned in insertion order so results are deterministic.
+type Graph struct {
+	nodes []string
+	out   map[string][]string
+	in    map[string][]string
+	edges map[[2]string]bool
+}
+
+// New returns an empty graph.
+func New() *Graph {
+	return &Graph{
+		out:   make(map[string][]string),
+		in:    make(map[string][]string),
+		edges: make(map[[2]string]bool),
+	}
+}
+
+// AddNode adds id to the graph. Adding an existing node is a no-op.
+func (g *Graph) AddNode(id string) {
+	if _, ok := g.out[id]; ok {
+		return
+	}
+	g.nodes = append(g.nodes, id)
+	g.out[id] = nil
+	g.in[id] = nil
+}
+
+// AddEdge adds an edge from -> to, adding missing nodes. Duplicate edges are ignored.
+func (g *Graph) AddEdge(from, to string) {
+	g.AddNode(from)
+	g.AddNode(to)
+	key := [2]string{from, to}
+	if g.edges[key] {
+		return
+	}
+	g.edges[key] = true
+	g.out[from] = append(g.out[from], to)
+	g.in[to] = append(g.in[to], from)
+}
+
+// HasNode reports whether id is in the graph.
+func (g *Graph) HasNode(id string) bool {
+	_, ok := g.out[id]
+	return ok
+}
+
+// Nodes returns all nodes in insertion order.
+func (g *Graph) Nodes() []string {
+	return append([]string(nil), g.nodes...)
+}
+
+// Successors returns the nodes id has an edge to.
+func (g *Graph) Successors(id string) []string {
+	return g.out[id]
+}
+
+// Predecessors returns the nodes with an edge to id.
+func (g *Graph) Predecessors(id string) []string {
+	return g.in[id]
+}
+
+// InDegree returns the number of edges into id.
+func (g *Graph) InDegree(id string) int {
+	return len(g.in[id])
+}
+
+// OutDegree returns the number of edges out of id.
+func (g *Graph) OutDegree(id string) int {
+	return len(g.out[id])
+}
+
+// Subgraph returns the graph induced by the nodes for which keep returns true.
+func (g *Graph) Subgraph(keep func(id string) bool) *Graph {
+	sub := New()
+	for _, id := range g.nodes {
+		if keep(id) {
+			sub.AddNode(id)
+		}
+	}
+	for _, from := range sub.nodes {
+		for _, to := range g.out[from] {
+			if sub.HasNode(to) {
+				sub.AddEdge(from, to)
+			}
+		}
+	}
+	return sub
+}
+
+// Reachable returns every node reachable from roots, roots included. Roots
+// that aren't in the graph are ignored.
+func (g *Graph) Reachable(roots ...string) map[string]bool {
+	seen := make(map[string]bool)
+	var stack []string
+	for _, root := range roots {
+		if g.HasNode(root) && !seen[root] {
+			seen[root] = true
+			stack = append(stack, root)
+		}
+	}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, next := range g.out[id] {
+			if !seen[next] {
+				seen[next] = true
+				stack = append(stack, next)
+			}
+		}
+	}
+	return seen
+}
+
+// StronglyConnectedComponents returns the SCCs of the graph using Tarjan's
+// algorithm. Components come out in reverse topological order: a component
+// is listed before any component that has an edge into it.
+func (g *Graph) StronglyConnectedComponents() [][]string {
+	index := make(map[string]int)
+	low := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var sccs [][]string
+	next := 0
+
+	// frame is one level of the explicit DFS stack, so deep import chains
+	// can't overflow the goroutine stack.
+	type frame struct {
+		id   string
+		edge int
+	}
+
+	for _, start := range g.nodes {
+		if _, visited := index[start]; visited {
+			continue
+		}
+
+		index[start], low[start] = next, next
+		next++
+		stack = append(stack, start)
+		onStack[start] = true
+		dfs := []frame{{id: start}}
+
+		for len(dfs) > 0 {
+			top := &dfs[len(dfs)-1]
+			if top.edge < len(g.out[top.id]) {
+				to := g.out[top.id][top.edge]
+				top.edge++
+				if _, visited := index[to]; !visited {
+					index[to], low[to] = next, next
+					next++
+					stack = append(stack, to)
+					onStack[to] = true
+					dfs = append(dfs, frame{id: to})
+				} else if onStack[to] && index[to] < low[top.id] {
+					low[top.id] = index[to]
+				}
+				continue
+			}
+
+			id := top.id
+			dfs = dfs[:len(dfs)-1]
+			if len(dfs) > 0 {
+				parent := dfs[len(dfs)-1].id
+				if low[id] < low[parent] {
+					low[parent] = low[id]
+				}
+			}
+
+			if low[id] == index[id] {
+				var scc []string
+				for {
+					n := stack[len(stack)-1]
+					stack = stack[:len(stack)-1]
+					onStack[n] = false
+					scc = append(scc, n)
+					if n == id {
+						break
+					}
+				}
+				sccs = append(sccs, scc)
+			}
+		}
+	}
+
+	return sccs
+}
+
+// Cycles returns every strongly connected component that contains a cycle,
+// i.e. has more than one node or a self-loop. Each cycle is sorted.
+func (g *Graph) Cycles() [][]string {
+	var cycles [][]string
+	for _, scc := range g.StronglyConnectedComponents() {
+		if len(scc) == 1 && !g.edges[[2]string{scc[0], scc[0]}] {
+			continue
+		}
+		sort.Strings(scc)
+		cycles = append(cycles, scc)
+	}
+	return cycles
+}
+
+// TopoSort returns the nodes ordered so that for every edge u -> v, u comes
+// before v. It returns ErrCycle if the graph has a cycle.
+func (g *Graph) TopoSort() ([]string, error) {
+	inDegree := make(map[string]int, len(g.nodes))
+	var queue []string
+	for _, id := range g.nodes {
+		inDegree[id] = len(g.in[id])
+		if inDegree[id] == 0 {
+			queue = append(queue, id)
+		}
+	}
+
+	order := make([]string, 0, len(g.nodes))
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		order = append(order, id)
+		for _, to := range g.out[id] {
+			inDegree[to]--
+			if inDegree[to] == 0 {
+				queue = append(queue, to)
+			}
+		}
+	}
+
+	if len(order) != len(g.nodes) {
+		return nil, ErrCycle
+	}
+	return order, nil
+}
+
+// CondensedOrder returns all nodes ordered like TopoSort, except that the
+// members of each cycle are kept together instead of failing.
+func (g *Graph) CondensedOrder() []string {
+	sccs := g.StronglyConnectedComponents()
+	order := make([]string, 0, len(g.nodes))
+	for i := len(sccs) - 1; i >= 0; i-- {
+		order = append(order, sccs[i]...)
+	}
+	return order
+}
@@ -0,0 +1,230 @@
+package graph
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// build returns a graph with the given nodes, then edges as from, to pairs.
+func build(nodes []string, edges ...[2]string) *Graph {
+	g := New()
+	for _, id := range nodes {
+		g.AddNode(id)
+	}
+	for _, e := range edges {
+		g.AddEdge(e[0], e[1])
+	}
+	return g
+}
+
+func keys(set map[string]bool) []string {
+	var out []string
+	for id := range set {
+		out = append(out, id)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestReachable(t *testing.T) {
+	chain := build(nil, [2]string{"a", "b"}, [2]string{"b", "c"}, [2]string{"d", "c"})
+	tests := []struct {
+		name  string
+		g     *Graph
+		roots []string
+		want  []string
+	}{
+		{"chain", chain, []string{"a"}, []string{"a", "b", "c"}},
+		{"leaf", chain, []string{"c"}, []string{"c"}},
+		{"several roots", chain, []string{"a", "d"}, []string{"a", "b", "c", "d"}},
+		{"unknown root", chain, []string{"x"}, nil},
+		{"unknown and known roots", chain, []string{"x", "d"}, []string{"c", "d"}},
+		{"no roots", chain, nil, nil},
+		{"cycle", build(nil, [2]string{"a", "b"}, [2]string{"b", "a"}), []string{"b"}, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := keys(tt.g.Reachable(tt.roots...)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Reachable(%v) = %v, want %v", tt.roots, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCycles(t *testing.T) {
+	tests := []struct {
+		name string
+		g    *Graph
+		want [][]string
+	}{
+		{"acyclic", build(nil, [2]string{"a", "b"}, [2]string{"b", "c"}), nil},
+		{"lone node", build([]string{"a"}), nil},
+		{"self-loop", build(nil, [2]string{"a", "a"}, [2]string{"a", "b"}), [][]string{{"a"}}},
+		{"two nodes", build(nil, [2]string{"b", "a"}, [2]string{"a", "b"}), [][]string{{"a", "b"}}},
+		{
+			"two disjoint cycles",
+			build(nil,
+				[2]string{"a", "b"}, [2]string{"b", "c"}, [2]string{"c", "a"},
+				[2]string{"x", "y"}, [2]string{"y", "x"}),
+			[][]string{{"a", "b", "c"}, {"x", "y"}},
+		},
+		{
+			"cycles linked by an edge",
+			build(nil,
+				[2]string{"a", "b"}, [2]string{"b", "a"}, [2]string{"b", "x"},
+				[2]string{"x", "y"}, [2]string{"y", "x"}),
+			[][]string{{"a", "b"}, {"x", "y"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.g.Cycles()
+			sort.Slice(got, func(i, j int) bool { return got[i][0] < got[j][0] })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Cycles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStronglyConnectedComponents(t *testing.T) {
+	g := build([]string{"lone"},
+		[2]string{"a", "b"}, [2]string{"b", "a"}, [2]string{"b", "c"},
+		[2]string{"c", "c"}, [2]string{"x", "y"}, [2]string{"y", "x"})
+
+	var got [][]string
+	position := make(map[string]int)
+	for i, scc := range g.StronglyConnectedComponents() {
+		for _, id := range scc {
+			position[id] = i
+		}
+		scc = append([]string(nil), scc...)
+		sort.Strings(scc)
+		got = append(got, scc)
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i][0] < got[j][0] })
+	want := [][]string{{"a", "b"}, {"c"}, {"lone"}, {"x", "y"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("StronglyConnectedComponents() = %v, want %v", got, want)
+	}
+	// Reverse topological order: c, which {a, b} imports, comes first.
+	if position["c"] >= position["a"] {
+		t.Errorf("component of c at %d, want before the one of a at %d", position["c"], position["a"])
+	}
+}
+
+func TestTopoSort(t *testing.T) {
+	tests := []struct {
+		name    string
+		g       *Graph
+		want    []string
+		wantErr error
+	}{
+		{"empty", New(), []string{}, nil},
+		{"chain", build(nil, [2]string{"a", "b"}, [2]string{"b", "c"}), []string{"a", "b", "c"}, nil},
+		{"diamond", build(nil, [2]string{"a", "b"}, [2]string{"a", "c"}, [2]string{"b", "d"}, [2]string{"c", "d"}), []string{"a", "b", "c", "d"}, nil},
+		{"cycle", build(nil, [2]string{"a", "b"}, [2]string{"b", "c"}, [2]string{"c", "b"}), nil, ErrCycle},
+		{"self-loop", build(nil, [2]string{"a", "a"}), nil, ErrCycle},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.g.TopoSort()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TopoSort() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TopoSort() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCondensedOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		g    *Graph
+	}{
+		{"acyclic", build(nil, [2]string{"a", "b"}, [2]string{"b", "c"}, [2]string{"a", "c"})},
+		{"cycle in the middle", build(nil, [2]string{"a", "b"}, [2]string{"b", "c"}, [2]string{"c", "b"}, [2]string{"c", "d"})},
+		{"cycles importing each other", build(nil,
+			[2]string{"x", "y"}, [2]string{"y", "x"}, [2]string{"y", "a"},
+			[2]string{"a", "b"}, [2]string{"b", "a"}, [2]string{"root", "x"})},
+		{"self-loop", build(nil, [2]string{"a", "a"}, [2]string{"a", "b"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := tt.g.CondensedOrder()
+			if len(order) != len(tt.g.Nodes()) {
+				t.Fatalf("CondensedOrder() = %v, want every node once", order)
+			}
+			position := make(map[string]int)
+			for i, id := range order {
+				position[id] = i
+			}
+			scc := make(map[string]int)
+			for i, members := range tt.g.StronglyConnectedComponents() {
+				for _, id := range members {
+					scc[id] = i
+				}
+			}
+			for _, from := range tt.g.Nodes() {
+				for _, to := range tt.g.Successors(from) {
+					if scc[from] != scc[to] && position[from] > position[to] {
+						t.Errorf("CondensedOrder() = %v, want %s before %s, which it imports", order, from, to)
+					}
+				}
+			}
+			// Members of a component stay together.
+			for _, members := range tt.g.StronglyConnectedComponents() {
+				lo, hi := len(order), -1
+				for _, id := range members {
+					lo, hi = min(lo, position[id]), max(hi, position[id])
+				}
+				if hi-lo+1 != len(members) {
+					t.Errorf("CondensedOrder() = %v, want %v together", order, members)
+				}
+			}
+		})
+	}
+}
+
+func TestSubgraph(t *testing.T) {
+	g := build([]string{"lone"}, [2]string{"a", "b"}, [2]string{"b", "c"}, [2]string{"a", "c"}, [2]string{"c", "a"})
+	tests := []struct {
+		name      string
+		keep      func(string) bool
+		wantNodes []string
+		wantEdges map[string][]string
+	}{
+		{"all", func(string) bool { return true }, []string{"lone", "a", "b", "c"},
+			map[string][]string{"a": {"b", "c"}, "b": {"c"}, "c": {"a"}}},
+		{"drop a node", func(id string) bool { return id != "b" }, []string{"lone", "a", "c"},
+			map[string][]string{"a": {"c"}, "c": {"a"}}},
+		{"none", func(string) bool { return false }, nil, map[string][]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := g.Subgraph(tt.keep)
+			if got := sub.Nodes(); !reflect.DeepEqual(got, tt.wantNodes) {
+				t.Errorf("Nodes() = %v, want %v", got, tt.wantNodes)
+			}
+			edges := make(map[string][]string)
+			for _, id := range sub.Nodes() {
+				if next := sub.Successors(id); len(next) > 0 {
+					edges[id] = next
+				}
+			}
+			if !reflect.DeepEqual(edges, tt.wantEdges) {
+				t.Errorf("edges = %v, want %v", edges, tt.wantEdges)
+			}
+			if sub.HasNode("b") != tt.keep("b") {
+				t.Errorf("HasNode(b) = %v", sub.HasNode("b"))
+			}
+		})
+	}
+	if g.OutDegree("a") != 2 {
+		t.Errorf("Subgraph changed the original graph: OutDegree(a) = %d", g.OutDegree("a"))
+	}
+}
@@ -0,0 +1,72 @@
+package jsparse
+
+import sitter "github.com/smacker/go-tree-sitter"
+
+// Export is a name a module exports from its own code, as opposed to a
+// re-export of another module.
+type Export struct {
+	// Name is "default" for the default export.
+	Name     string `json:"name"`
+	TypeOnly bool   `json:"type_only,omitempty"`
+	Line     int    `json:"line"`
+	// Wraps is the binding of the module the export stands for when it
+	// isn't declared by the export itself: the component wrapper calls
+	// wrap (Button for memo(forwardRef(Button)) or withRouter(Button)), or
+	// an imported or aliased name exported as is.
+	Wraps string `json:"wraps,omitempty"`
+}
+
+// parseExports reads the names an export statement without a source exports.
+func parseExports(n *sitter.Node, src []byte) []Export {
+	if hasToken(n, "default") {
+		e := Export{Name: "default", Line: line(n)}
+		if v := n.ChildByFieldName("value"); v != nil {
+			if v.Type() == "identifier" {
+				e.Wraps = v.Content(src)
+			} else {
+				e.Wraps = wrappedIdentifier(v, src)
+			}
+		}
+		return []Export{e}
+	}
+
+	var exports []Export
+	if decl := n.ChildByFieldName("declaration"); decl != nil {
+		switch decl.Type() {
+		case "function_declaration", "generator_function_declaration", "class_declaration",
+			"abstract_class_declaration", "enum_declaration":
+			if name := decl.ChildByFieldName("name"); name != nil {
+				exports = append(exports, Export{Name: name.Content(src), Line: line(decl)})
+			}
+		case "interface_declaration", "type_alias_declaration":
+			if name := decl.ChildByFieldName("name"); name != nil {
+				exports = append(exports, Export{Name: name.Content(src), TypeOnly: true, Line: line(decl)})
+			}
+		case "lexical_declaration", "variable_declaration":
+			for i := 0; i < int(decl.NamedChildCount()); i++ {
+				v := decl.NamedChild(i)
+				if v.Type() != "variable_declarator" {
+					continue
+				}
+				// Destructuring exports (export const { a } = o) are rare and skipped.
+				if name := v.ChildByFieldName("name"); name != nil && name.Type() == "identifier" {
+					exports = append(exports, Export{Name: name.Content(src), Line: line(v)})
+				}
+			}
+		}
+		return exports
+	}
+
+	typeOnly := hasToken(n, "type")
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		c := n.NamedChild(i)
+		if c.Type() != "export_clause" {
+			continue
+		}
+		// export { a as b } exports b, which parseSpecifiers calls the local name.
+		for _, b := range parseSpecifiers(c, "export_specifier", src) {
+			exports = append(exports, Export{Name: b.Local, TypeOnly: typeOnly || b.TypeOnly, Line: line(n)})
+		}
+	}
+	return exports
+}
@@ -0,0 +1,428 @@
+// Package jsparse extracts the module structure of JavaScript and TypeScript
+// sources (imports, exports, re-exports, dynamic imports and requires) from a
+// real syntax tree built with tree-sitter, so comments, strings, multiline
+// statements and type-only imports are handled correctly.
+package jsparse
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/smacker/go-tree-sitter/javascript"
+	"github.com/smacker/go-tree-sitter/typescript/tsx"
+	"github.com/smacker/go-tree-sitter/typescript/typescript"
+)
+
+// ImportKind tells how a module is pulled in.
+type ImportKind string
+
+const (
+	// Static is an `import ... from` statement.
+	Static ImportKind = "static"
+	// SideEffect is a bare `import './x'`.
+	SideEffect ImportKind = "side-effect"
+	// ReExport is an `export ... from` statement.
+	ReExport ImportKind = "re-export"
+	// Dynamic is an `import('./x')` expression.
+	Dynamic ImportKind = "dynamic"
+	// Require is a CommonJS `require('./x')` call.
+	Require ImportKind = "require"
+)
+
+// Binding is one name brought in by an import or re-export. Imported is
+// "default" for default imports and "*" for namespace imports and
+// `export * from`.
+type Binding struct {
+	Imported string `json:"imported"`
+	Local    string `json:"local"`
+	TypeOnly bool   `json:"type_only,omitempty"`
+}
+
+// Import is a reference from one module to another.
+type Import struct {
+	Specifier string     `json:"specifier"`
+	Kind      ImportKind `json:"kind"`
+	Bindings  []Binding  `json:"bindings,omitempty"`
+	// TypeOnly is set for `import type` and `export type ... from`, which
+	// disappear at runtime.
+	TypeOnly bool `json:"type_only,omitempty"`
+	// Loader names the code-splitting helper wrapping a dynamic import, e.g.
+	// "React.lazy" or "dynamic" (next/dynamic).
+	Loader string `json:"loader,omitempty"`
+	Line   int    `json:"line"`
+}
+
+// ValueBindings returns the bindings that survive compilation.
+func (imp Import) ValueBindings() []Binding {
+	if imp.TypeOnly {
+		return nil
+	}
+	var bindings []Binding
+	for _, b := range imp.Bindings {
+		if !b.TypeOnly {
+			bindings = append(bindings, b)
+		}
+	}
+	return bindings
+}
+
+// IsRuntime reports whether the import still exists once types are erased.
+func (imp Import) IsRuntime() bool {
+	if imp.TypeOnly {
+		return false
+	}
+	if len(imp.Bindings) == 0 {
+		return true
+	}
+	return len(imp.ValueBindings()) > 0
+}
+
+// File is the parsed module structure of a source file.
+type File struct {
+	Imports []Import `json:"imports,omitempty"`
+	Exports []Export `json:"exports,omitempty"`
+	// ComputedImports are the static prefixes of the imports that can't be
+	// resolved statically: "./pages/" for import(`./pages/${name}`),
+	// require.context('./pages') or import.meta.glob('./pages/*.tsx'), and
+	// "" when nothing of the path is known, as in import(name).
+	ComputedImports []string `json:"computed_imports,omitempty"`
+}
+
+// Supported reports whether Parse knows the language of the file at p.
+func Supported(p string) bool {
+	return language(p) != nil
+}
+
+func language(p string) *sitter.Language {
+	switch path.Ext(p) {
+	case ".tsx":
+		return tsx.GetLanguage()
+	case ".ts", ".mts", ".cts":
+		return typescript.GetLanguage()
+	case ".js", ".jsx", ".mjs", ".cjs":
+		return javascript.GetLanguage()
+	}
+	return nil
+}
+
+// Parse parses src, picking the grammar from the extension of p. Syntax
+// errors don't fail the parse: tree-sitter recovers and whatever could be
+// understood is returned.
+func Parse(ctx context.Context, p string, src []byte) (*File, error) {
+	tree, err := parseTree(ctx, p, src)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+
+	f := &File{}
+	walk(tree.RootNode(), src, f)
+	resolveWrappers(tree.RootNode(), src, f)
+	return f, nil
+}
+
+// parseTree builds the syntax tree of src, falling back to the TSX grammar
+// for unknown extensions. The caller closes the tree.
+func parseTree(ctx context.Context, p string, src []byte) (*sitter.Tree, error) {
+	lang := language(p)
+	if lang == nil {
+		lang = tsx.GetLanguage()
+	}
+
+	parser := sitter.NewParser()
+	defer parser.Close()
+	parser.SetLanguage(lang)
+
+	return parser.ParseCtx(ctx, nil, src)
+}
+
+func walk(n *sitter.Node, src []byte, f *File) {
+	switch n.Type() {
+	case "import_statement":
+		if imp, ok := parseImportStatement(n, src); ok {
+			f.Imports = append(f.Imports, imp)
+		}
+		return
+	case "export_statement":
+		if n.ChildByFieldName("source") != nil {
+			if imp, ok := parseReExport(n, src); ok {
+				f.Imports = append(f.Imports, imp)
+			}
+			return
+		}
+		f.Exports = append(f.Exports, parseExports(n, src)...)
+	case "call_expression":
+		if imp, ok := parseCall(n, src); ok {
+			f.Imports = append(f.Imports, imp)
+		} else if prefix, ok := computedImport(n, src); ok {
+			f.ComputedImports = append(f.ComputedImports, prefix)
+		}
+	}
+
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		walk(n.NamedChild(i), src, f)
+	}
+}
+
+// stringValue returns the unquoted value of a string literal node.
+func stringValue(n *sitter.Node, src []byte) (string, bool) {
+	if n == nil || n.Type() != "string" {
+		return "", false
+	}
+	raw := n.Content(src)
+	if len(raw) < 2 {
+		return "", false
+	}
+	return raw[1 : len(raw)-1], true
+}
+
+// hasToken reports whether n has a direct anonymous child with the given text,
+// e.g. the `type` keyword of `import type`.
+func hasToken(n *sitter.Node, token string) bool {
+	for i := 0; i < int(n.ChildCount()); i++ {
+		c := n.Child(i)
+		if !c.IsNamed() && c.Type() == token {
+			return true
+		}
+	}
+	return false
+}
+
+func line(n *sitter.Node) int {
+	return int(n.StartPoint().Row) + 1
+}
+
+func parseImportStatement(n *sitter.Node, src []byte) (Import, bool) {
+	specifier, ok := stringValue(n.ChildByFieldName("source"), src)
+	if !ok {
+		return Import{}, false
+	}
+
+	imp := Import{
+		Specifier: specifier,
+		Kind:      SideEffect,
+		TypeOnly:  hasToken(n, "type") || hasToken(n, "typeof"),
+		Line:      line(n),
+	}
+
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		clause := n.NamedChild(i)
+		if clause.Type() != "import_clause" {
+			continue
+		}
+		imp.Kind = Static
+		for j := 0; j < int(clause.NamedChildCount()); j++ {
+			c := clause.NamedChild(j)
+			switch c.Type() {
+			case "identifier":
+				imp.Bindings = append(imp.Bindings, Binding{Imported: "default", Local: c.Content(src)})
+			case "namespace_import":
+				if id := firstNamed(c, "identifier"); id != nil {
+					imp.Bindings = append(imp.Bindings, Binding{Imported: "*", Local: id.Content(src)})
+				}
+			case "named_imports":
+				imp.Bindings = append(imp.Bindings, parseSpecifiers(c, "import_specifier", src)...)
+			}
+		}
+	}
+
+	return imp, true
+}
+
+func parseReExport(n *sitter.Node, src []byte) (Import, bool) {
+	specifier, ok := stringValue(n.ChildByFieldName("source"), src)
+	if !ok {
+		return Import{}, false
+	}
+
+	imp := Import{
+		Specifier: specifier,
+		Kind:      ReExport,
+		TypeOnly:  hasToken(n, "type"),
+		Line:      line(n),
+	}
+
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		c := n.NamedChild(i)
+		switch c.Type() {
+		case "export_clause":
+			imp.Bindings = append(imp.Bindings, parseSpecifiers(c, "export_specifier", src)...)
+		case "namespace_export":
+			// export * as ns from './x'
+			local := ""
+			if id := firstNamed(c, "identifier"); id != nil {
+				local = id.Content(src)
+			} else if s := firstNamed(c, "string"); s != nil {
+				local, _ = stringValue(s, src)
+			}
+			imp.Bindings = append(imp.Bindings, Binding{Imported: "*", Local: local})
+		}
+	}
+	if len(imp.Bindings) == 0 && hasToken(n, "*") {
+		// export * from './x'
+		imp.Bindings = append(imp.Bindings, Binding{Imported: "*", Local: "*"})
+	}
+
+	return imp, true
+}
+
+// parseSpecifiers reads the `a`, `a as b` and `type a` entries of an
+// import or export clause.
+func parseSpecifiers(clause *sitter.Node, kind string, src []byte) []Binding {
+	var bindings []Binding
+	for i := 0; i < int(clause.NamedChildCount()); i++ {
+		spec := clause.NamedChild(i)
+		if spec.Type() != kind {
+			continue
+		}
+		name := spec.ChildByFieldName("name")
+		if name == nil {
+			continue
+		}
+		b := Binding{
+			Imported: specifierName(name, src),
+			TypeOnly: hasToken(spec, "type") || hasToken(spec, "typeof"),
+		}
+		b.Local = b.Imported
+		if alias := spec.ChildByFieldName("alias"); alias != nil {
+			b.Local = specifierName(alias, src)
+		}
+		bindings = append(bindings, b)
+	}
+	return bindings
+}
+
+// specifierName handles both identifiers and the string names allowed in
+// `export { "a-b" as c }`.
+func specifierName(n *sitter.Node, src []byte) string {
+	if v, ok := stringValue(n, src); ok {
+		return v
+	}
+	return n.Content(src)
+}
+
+func parseCall(n *sitter.Node, src []byte) (Import, bool) {
+	fn := n.ChildByFieldName("function")
+	args := n.ChildByFieldName("arguments")
+	if fn == nil || args == nil || args.NamedChildCount() == 0 {
+		return Import{}, false
+	}
+
+	var kind ImportKind
+	switch {
+	case fn.Type() == "import":
+		kind = Dynamic
+	case fn.Type() == "identifier" && fn.Content(src) == "require":
+		kind = Require
+	default:
+		return Import{}, false
+	}
+
+	arg := args.NamedChild(0)
+	specifier, ok := stringValue(arg, src)
+	if !ok {
+		// import(`./pages/${name}`) can't be resolved statically.
+		if arg.Type() != "template_string" || strings.Contains(arg.Content(src), "${") {
+			return Import{}, false
+		}
+		raw := arg.Content(src)
+		specifier = raw[1 : len(raw)-1]
+	}
+
+	imp := Import{Specifier: specifier, Kind: kind, Line: line(n)}
+	if kind == Dynamic {
+		imp.Loader = loader(n, src)
+	}
+	return imp, true
+}
+
+// computedImport returns the static prefix of a dynamic import, require or
+// glob import whose path is computed at runtime.
+func computedImport(n *sitter.Node, src []byte) (string, bool) {
+	fn := n.ChildByFieldName("function")
+	args := n.ChildByFieldName("arguments")
+	if fn == nil || args == nil || args.NamedChildCount() == 0 {
+		return "", false
+	}
+	arg := args.NamedChild(0)
+
+	switch fn.Content(src) {
+	case "require.context":
+		dir, ok := stringValue(arg, src)
+		if !ok {
+			return "", true
+		}
+		return strings.TrimSuffix(dir, "/") + "/", true
+	case "import.meta.glob", "import.meta.globEager":
+		pattern, ok := stringValue(arg, src)
+		if !ok {
+			return "", true
+		}
+		if i := strings.IndexAny(pattern, "*{["); i >= 0 {
+			pattern = pattern[:i]
+		}
+		return pattern, true
+	}
+	if fn.Type() != "import" && !(fn.Type() == "identifier" && fn.Content(src) == "require") {
+		return "", false
+	}
+
+	switch arg.Type() {
+	case "template_string":
+		raw := arg.Content(src)
+		if i := strings.Index(raw, "${"); i >= 0 {
+			return raw[1:i], true
+		}
+		return "", false
+	case "binary_expression":
+		// import('./pages/' + name)
+		if prefix, ok := stringValue(arg.ChildByFieldName("left"), src); ok {
+			return prefix, true
+		}
+	}
+	return "", true
+}
+
+// loaders are the helpers that lazily load a component from a dynamic import.
+var loaders = map[string]bool{
+	"lazy":                 true,
+	"React.lazy":           true,
+	"dynamic":              true,
+	"loadable":             true,
+	"defineAsyncComponent": true,
+}
+
+// loader returns the helper a dynamic import is handed to, as in
+// React.lazy(() => import('./Modal')), or "" if there is none.
+func loader(n *sitter.Node, src []byte) string {
+	// Climb out of the arrow function (and a block body's return statement)
+	// up to the call receiving it, but no further.
+	for p, depth := n.Parent(), 0; p != nil && depth < 8; p, depth = p.Parent(), depth+1 {
+		switch p.Type() {
+		case "call_expression":
+			fn := p.ChildByFieldName("function")
+			if fn != nil && loaders[fn.Content(src)] {
+				return fn.Content(src)
+			}
+			if fn != nil && fn.Type() == "member_expression" {
+				// import('./x').then(...) is still part of the same expression.
+				continue
+			}
+			return ""
+		case "program", "class_body":
+			return ""
+		}
+	}
+	return ""
+}
+
+func firstNamed(n *sitter.Node, kind string) *sitter.Node {
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		if c := n.NamedChild(i); c.Type() == kind {
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package jsparse
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// JSXElement is a call site of a component in JSX, such as
+// <Button size="sm" {...rest}>Save</Button>.
+type JSXElement struct {
+	// Name is the element's tag: Button, or Form.Field for a member.
+	Name string `json:"name"`
+	// Props are the attributes passed, "children" included when the
+	// element has content.
+	Props []string `json:"props,omitempty"`
+	// Spread is set when props are spread in ({...rest}), so any prop may
+	// be passed.
+	Spread bool `json:"spread,omitempty"`
+	Line   int  `json:"line"`
+}
+
+// JSXElements returns the component elements a module renders, those whose
+// tag starts with an uppercase letter or is a member expression. Intrinsic
+// elements such as <div> are left out.
+func JSXElements(ctx context.Context, p string, src []byte) ([]JSXElement, error) {
+	tree, err := parseTree(ctx, p, src)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+
+	var elements []JSXElement
+	var visit func(n *sitter.Node)
+	visit = func(n *sitter.Node) {
+		switch n.Type() {
+		case "jsx_element":
+			if open := n.ChildByFieldName("open_tag"); open != nil {
+				if el, ok := jsxElement(open, src); ok {
+					if jsxHasContent(n, src) {
+						el.Props = append(el.Props, "children")
+					}
+					elements = append(elements, el)
+				}
+			}
+		case "jsx_self_closing_element":
+			if el, ok := jsxElement(n, src); ok {
+				elements = append(elements, el)
+			}
+		}
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			visit(n.NamedChild(i))
+		}
+	}
+	visit(tree.RootNode())
+	return elements, nil
+}
+
+// jsxElement reads the tag and attributes of an opening or self-closing
+// element, reporting false for intrinsic elements and fragments.
+func jsxElement(n *sitter.Node, src []byte) (JSXElement, bool) {
+	name := n.ChildByFieldName("name")
+	if name == nil {
+		return JSXElement{}, false
+	}
+	tag := name.Content(src)
+	if name.Type() != "member_expression" && name.Type() != "nested_identifier" &&
+		(tag == "" || tag[0] < 'A' || tag[0] > 'Z') {
+		return JSXElement{}, false
+	}
+	el := JSXElement{Name: tag, Line: line(n)}
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		attr := n.NamedChild(i)
+		switch attr.Type() {
+		case "jsx_attribute":
+			if attr.NamedChildCount() > 0 {
+				prop := attr.NamedChild(0).Content(src)
+				if !slices.Contains(el.Props, prop) {
+					el.Props = append(el.Props, prop)
+				}
+			}
+		case "jsx_expression":
+			// {...rest}
+			el.Spread = true
+		}
+	}
+	return el, true
+}
+
+// jsxHasContent reports whether an element has children other than
+// whitespace.
+func jsxHasContent(n *sitter.Node, src []byte) bool {
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		child := n.NamedChild(i)
+		switch child.Type() {
+		case "jsx_opening_element", "jsx_closing_element":
+		case "jsx_text":
+			if strings.TrimSpace(child.Content(src)) != "" {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// JSXDepth returns how deeply the JSX elements of a module nest, 0 when it
+// renders none. Fragments count as a level, as they do when reading it.
+func JSXDepth(ctx context.Context, p string, src []byte) (int, error) {
+	tree, err := parseTree(ctx, p, src)
+	if err != nil {
+		return 0, err
+	}
+	defer tree.Close()
+
+	deepest := 0
+	var visit func(n *sitter.Node, depth int)
+	visit = func(n *sitter.Node, depth int) {
+		if t := n.Type(); t == "jsx_element" || t == "jsx_self_closing_element" {
+			depth++
+			deepest = max(deepest, depth)
+		}
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			visit(n.NamedChild(i), depth)
+		}
+	}
+	visit(tree.RootNode(), 0)
+	return deepest, nil
+}
@@ -0,0 +1,278 @@
+package jsparse
+
+import (
+	"context"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Prop is a property of a component's props type.
+type Prop struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+}
+
+// Props extracts the props of the named component from its TypeScript
+// definition. It finds the component's declaration (a function, an arrow
+// function, a React.FC annotation, a memo or forwardRef call, or a class
+// component), then follows its props type through the interfaces and type
+// aliases declared in the same file, including extends clauses and
+// intersections. Props of types imported from elsewhere can't be seen and
+// are left out. When the declaration can't be found, a type named
+// <component>Props is used.
+func Props(ctx context.Context, p string, src []byte, component string) ([]Prop, error) {
+	tree, err := parseTree(ctx, p, src)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+
+	e := &propsExtractor{src: src, types: make(map[string]*sitter.Node), decls: make(map[string]*sitter.Node)}
+	root := tree.RootNode()
+	e.collectTypes(root)
+
+	propsType := e.findPropsType(root, component)
+	if propsType == nil {
+		propsType = e.types[component+"Props"]
+	}
+	if propsType == nil {
+		return nil, nil
+	}
+	return e.resolve(propsType, make(map[string]bool)), nil
+}
+
+type propsExtractor struct {
+	src   []byte
+	types map[string]*sitter.Node
+	// decls maps the functions, classes and variables of the module to
+	// their declaration, for the components wrapper calls refer to by name.
+	decls map[string]*sitter.Node
+	depth int
+}
+
+func (e *propsExtractor) collectTypes(n *sitter.Node) {
+	switch n.Type() {
+	case "interface_declaration", "type_alias_declaration":
+		if name := n.ChildByFieldName("name"); name != nil {
+			e.types[name.Content(e.src)] = n
+		}
+		return
+	case "function_declaration", "class_declaration", "variable_declarator":
+		if name := n.ChildByFieldName("name"); name != nil && name.Type() == "identifier" {
+			if _, ok := e.decls[name.Content(e.src)]; !ok {
+				e.decls[name.Content(e.src)] = n
+			}
+		}
+	}
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		e.collectTypes(n.NamedChild(i))
+	}
+}
+
+// findPropsType returns the type node of the named component's props.
+func (e *propsExtractor) findPropsType(root *sitter.Node, component string) *sitter.Node {
+	var defaultExport *sitter.Node
+	for i := 0; i < int(root.NamedChildCount()); i++ {
+		decl := root.NamedChild(i)
+		if decl.Type() == "export_statement" {
+			if d := decl.ChildByFieldName("declaration"); d != nil {
+				if hasToken(decl, "default") {
+					defaultExport = d
+				}
+				decl = d
+			} else if v := decl.ChildByFieldName("value"); v != nil && hasToken(decl, "default") {
+				defaultExport = v
+				continue
+			}
+		}
+
+		switch decl.Type() {
+		case "function_declaration", "class_declaration":
+			if name := decl.ChildByFieldName("name"); name != nil && name.Content(e.src) == component {
+				return e.declarationProps(decl)
+			}
+		case "lexical_declaration", "variable_declaration":
+			for j := 0; j < int(decl.NamedChildCount()); j++ {
+				v := decl.NamedChild(j)
+				if v.Type() != "variable_declarator" {
+					continue
+				}
+				if name := v.ChildByFieldName("name"); name != nil && name.Content(e.src) == component {
+					return e.declaratorProps(v)
+				}
+			}
+		}
+	}
+	if defaultExport != nil {
+		// export default function (props: Props) {} names the component after its file.
+		return e.declarationProps(defaultExport)
+	}
+	return nil
+}
+
+func (e *propsExtractor) declaratorProps(v *sitter.Node) *sitter.Node {
+	// const Button: React.FC<ButtonProps> = ...
+	if t := v.ChildByFieldName("type"); t != nil {
+		if args := typeArguments(t.NamedChild(0)); len(args) > 0 {
+			return args[0]
+		}
+	}
+	if value := v.ChildByFieldName("value"); value != nil {
+		return e.declarationProps(value)
+	}
+	return nil
+}
+
+// declarationProps returns the props type of a function, class, or
+// memo/forwardRef call.
+func (e *propsExtractor) declarationProps(n *sitter.Node) *sitter.Node {
+	switch n.Type() {
+	case "function_declaration", "function_expression", "function", "arrow_function":
+		params := n.ChildByFieldName("parameters")
+		if params == nil {
+			// x => ... has a single untyped parameter.
+			return nil
+		}
+		for i := 0; i < int(params.NamedChildCount()); i++ {
+			param := params.NamedChild(i)
+			if param.Type() == "required_parameter" || param.Type() == "optional_parameter" {
+				if t := param.ChildByFieldName("type"); t != nil {
+					return t.NamedChild(0)
+				}
+				return nil
+			}
+		}
+	case "class_declaration", "class":
+		// class Button extends React.Component<ButtonProps>
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			heritage := n.NamedChild(i)
+			if heritage.Type() != "class_heritage" {
+				continue
+			}
+			if extends := firstNamed(heritage, "extends_clause"); extends != nil {
+				if args := extends.ChildByFieldName("type_arguments"); args != nil && args.NamedChildCount() > 0 {
+					return args.NamedChild(0)
+				}
+			}
+		}
+	case "call_expression":
+		// forwardRef<Ref, Props>(...) names the props second, memo<Props>(...) first.
+		if args := n.ChildByFieldName("type_arguments"); args != nil && args.NamedChildCount() > 0 {
+			fn := n.ChildByFieldName("function")
+			if fn != nil && isForwardRef(fn.Content(e.src)) && args.NamedChildCount() > 1 {
+				return args.NamedChild(1)
+			}
+			return args.NamedChild(0)
+		}
+		if callArgs := n.ChildByFieldName("arguments"); callArgs != nil && callArgs.NamedChildCount() > 0 {
+			return e.declarationProps(callArgs.NamedChild(0))
+		}
+	case "parenthesized_expression":
+		if n.NamedChildCount() > 0 {
+			return e.declarationProps(n.NamedChild(0))
+		}
+	case "identifier":
+		// memo(forwardRef(ButtonBase)) wraps a component declared apart.
+		decl := e.decls[n.Content(e.src)]
+		if decl == nil || e.depth >= maxWrapperDepth {
+			return nil
+		}
+		e.depth++
+		defer func() { e.depth-- }()
+		if decl.Type() == "variable_declarator" {
+			return e.declaratorProps(decl)
+		}
+		return e.declarationProps(decl)
+	}
+	return nil
+}
+
+func isForwardRef(fn string) bool {
+	return fn == "forwardRef" || fn == "React.forwardRef"
+}
+
+// typeArguments returns the type arguments of a generic type such as
+// React.FC<Props>.
+func typeArguments(n *sitter.Node) []*sitter.Node {
+	if n == nil || n.Type() != "generic_type" {
+		return nil
+	}
+	args := n.ChildByFieldName("type_arguments")
+	if args == nil {
+		return nil
+	}
+	var nodes []*sitter.Node
+	for i := 0; i < int(args.NamedChildCount()); i++ {
+		nodes = append(nodes, args.NamedChild(i))
+	}
+	return nodes
+}
+
+// resolve lists the properties of a type, following local type names.
+func (e *propsExtractor) resolve(n *sitter.Node, visited map[string]bool) []Prop {
+	switch n.Type() {
+	case "type_identifier":
+		name := n.Content(e.src)
+		decl, ok := e.types[name]
+		if !ok || visited[name] {
+			return nil
+		}
+		visited[name] = true
+		return e.resolve(decl, visited)
+	case "interface_declaration":
+		var props []Prop
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			c := n.NamedChild(i)
+			if c.Type() == "extends_type_clause" {
+				for j := 0; j < int(c.NamedChildCount()); j++ {
+					props = append(props, e.resolve(c.NamedChild(j), visited)...)
+				}
+			}
+		}
+		if body := n.ChildByFieldName("body"); body != nil {
+			props = append(props, e.properties(body)...)
+		}
+		return props
+	case "type_alias_declaration":
+		if value := n.ChildByFieldName("value"); value != nil {
+			return e.resolve(value, visited)
+		}
+	case "object_type":
+		return e.properties(n)
+	case "intersection_type", "parenthesized_type":
+		var props []Prop
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			props = append(props, e.resolve(n.NamedChild(i), visited)...)
+		}
+		return props
+	case "generic_type":
+		// PropsWithChildren<Props> and the like wrap the real props.
+		var props []Prop
+		for _, arg := range typeArguments(n) {
+			props = append(props, e.resolve(arg, visited)...)
+		}
+		return props
+	}
+	return nil
+}
+
+func (e *propsExtractor) properties(body *sitter.Node) []Prop {
+	var props []Prop
+	for i := 0; i < int(body.NamedChildCount()); i++ {
+		sig := body.NamedChild(i)
+		if sig.Type() != "property_signature" {
+			continue
+		}
+		name := sig.ChildByFieldName("name")
+		if name == nil {
+			continue
+		}
+		prop := Prop{Name: specifierName(name, e.src), Required: !hasToken(sig, "?")}
+		if t := sig.ChildByFieldName("type"); t != nil && t.NamedChildCount() > 0 {
+			prop.Type = t.NamedChild(0).Content(e.src)
+		}
+		props = append(props, prop)
+	}
+	return props
+}
@@ -0,0 +1,78 @@
+package jsparse
+
+import (
+	"context"
+	"unicode"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// routeKeys are the route object keys and <Route> attributes of React
+// Router that name the component rendered for a route.
+var routeKeys = map[string]bool{
+	"element":       true,
+	"errorElement":  true,
+	"Component":     true,
+	"component":     true,
+	"ErrorBoundary": true,
+}
+
+// RouteComponents returns the local names of the components a file mounts
+// through React Router route definitions: `element: <Home />` and
+// `Component: Home` in route objects (createBrowserRouter, useRoutes and
+// friends) and the same props on <Route> elements.
+func RouteComponents(ctx context.Context, p string, src []byte) ([]string, error) {
+	tree, err := parseTree(ctx, p, src)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	var walkRoutes func(n *sitter.Node)
+	walkRoutes = func(n *sitter.Node) {
+		switch n.Type() {
+		case "pair":
+			key, value := n.ChildByFieldName("key"), n.ChildByFieldName("value")
+			if key != nil && value != nil && routeKeys[specifierName(key, src)] {
+				componentRefs(value, src, add)
+			}
+		case "jsx_attribute":
+			if n.NamedChildCount() == 2 && routeKeys[n.NamedChild(0).Content(src)] {
+				componentRefs(n.NamedChild(1), src, add)
+			}
+		}
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			walkRoutes(n.NamedChild(i))
+		}
+	}
+	walkRoutes(tree.RootNode())
+	return names, nil
+}
+
+// componentRefs reports the components referenced by a route value: a bare
+// identifier, or every capitalized element of a JSX tree.
+func componentRefs(n *sitter.Node, src []byte, add func(string)) {
+	switch n.Type() {
+	case "identifier":
+		add(n.Content(src))
+	case "jsx_opening_element", "jsx_self_closing_element":
+		if name := n.ChildByFieldName("name"); name != nil && name.Type() == "identifier" {
+			if tag := name.Content(src); unicode.IsUpper([]rune(tag)[0]) {
+				add(tag)
+			}
+		}
+	case "jsx_expression", "jsx_element", "parenthesized_expression":
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			componentRefs(n.NamedChild(i), src, add)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package jsparse
+
+import (
+	"context"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// StyledComponent is a component a CSS-in-JS library derives from an
+// element or another component, such as styled.button`...`.
+type StyledComponent struct {
+	Name string `json:"name"`
+	// Base is the element or component styled: "button" for styled.button,
+	// "Button" for styled(Button).
+	Base string `json:"base"`
+	// Library is "styled-components" or "emotion".
+	Library string `json:"library"`
+	Line    int    `json:"line"`
+	// Exported is set when the module exports it, Default when as its
+	// default export.
+	Exported bool `json:"exported,omitempty"`
+	Default  bool `json:"default,omitempty"`
+	// LocalUses counts the references to it in its own module.
+	LocalUses int `json:"local_uses,omitempty"`
+}
+
+// styledLibrary returns the library whose styled factory the module
+// specifier exports by default, or "".
+func styledLibrary(specifier string) string {
+	switch {
+	case specifier == "styled-components" || strings.HasPrefix(specifier, "styled-components/"):
+		return "styled-components"
+	case specifier == "@emotion/styled" || strings.HasPrefix(specifier, "@emotion/styled/"):
+		return "emotion"
+	}
+	return ""
+}
+
+// StyledComponents returns the styled components a module declares at its
+// top level with the default export of styled-components or
+// @emotion/styled: tagged templates (styled.div`...`, styled(Button)`...`,
+// styled.a.attrs({...})`...`) and object styles (styled.div({...}),
+// styled('div')(props => ...)).
+func StyledComponents(ctx context.Context, p string, src []byte) ([]StyledComponent, error) {
+	tree, err := parseTree(ctx, p, src)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+	root := tree.RootNode()
+
+	factories := make(map[string]string)
+	for i := 0; i < int(root.NamedChildCount()); i++ {
+		n := root.NamedChild(i)
+		if n.Type() != "import_statement" {
+			continue
+		}
+		imp, ok := parseImportStatement(n, src)
+		if !ok || imp.TypeOnly {
+			continue
+		}
+		if lib := styledLibrary(imp.Specifier); lib != "" {
+			for _, b := range imp.Bindings {
+				if b.Imported == "default" {
+					factories[b.Local] = lib
+				}
+			}
+		}
+	}
+	if len(factories) == 0 {
+		return nil, nil
+	}
+
+	var styled []StyledComponent
+	declared := make(map[string]*sitter.Node)
+	for i := 0; i < int(root.NamedChildCount()); i++ {
+		decl, exported := root.NamedChild(i), false
+		if decl.Type() == "export_statement" {
+			if d := decl.ChildByFieldName("declaration"); d != nil {
+				decl, exported = d, true
+			}
+		}
+		if decl.Type() != "lexical_declaration" && decl.Type() != "variable_declaration" {
+			continue
+		}
+		for j := 0; j < int(decl.NamedChildCount()); j++ {
+			v := decl.NamedChild(j)
+			name, value := v.ChildByFieldName("name"), v.ChildByFieldName("value")
+			if v.Type() != "variable_declarator" || name == nil || value == nil || name.Type() != "identifier" {
+				continue
+			}
+			for value.Type() == "parenthesized_expression" || value.Type() == "as_expression" {
+				value = value.NamedChild(0)
+			}
+			if value.Type() != "call_expression" {
+				continue
+			}
+			fn := value.ChildByFieldName("function")
+			base, lib := styledBase(fn, src, factories)
+			if lib == "" {
+				continue
+			}
+			styled = append(styled, StyledComponent{
+				Name:     name.Content(src),
+				Base:     base,
+				Library:  lib,
+				Line:     line(v),
+				Exported: exported,
+			})
+			declared[name.Content(src)] = name
+		}
+	}
+	if len(styled) == 0 {
+		return nil, nil
+	}
+
+	uses := make(map[string]int)
+	exports := make(map[string]bool)
+	defaults := make(map[string]bool)
+	var count func(n *sitter.Node)
+	count = func(n *sitter.Node) {
+		switch n.Type() {
+		case "export_statement":
+			if v := n.ChildByFieldName("value"); v != nil && v.Type() == "identifier" && hasToken(n, "default") {
+				defaults[v.Content(src)] = true
+				return
+			}
+		case "export_specifier":
+			if name := n.ChildByFieldName("name"); name != nil {
+				exports[name.Content(src)] = true
+			}
+			return
+		case "identifier", "shorthand_property_identifier":
+			name := n.Content(src)
+			if decl, ok := declared[name]; ok && !decl.Equal(n) {
+				uses[name]++
+			}
+			return
+		}
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			count(n.NamedChild(i))
+		}
+	}
+	count(root)
+	for i := range styled {
+		s := &styled[i]
+		s.LocalUses = uses[s.Name]
+		s.Exported = s.Exported || exports[s.Name] || defaults[s.Name]
+		s.Default = defaults[s.Name]
+	}
+	return styled, nil
+}
+
+// styledBase returns what the styled factory call fn styles and the
+// library of the factory, or "" when fn isn't one.
+func styledBase(fn *sitter.Node, src []byte, factories map[string]string) (base, lib string) {
+	if fn == nil {
+		return "", ""
+	}
+	switch fn.Type() {
+	case "member_expression":
+		object, property := fn.ChildByFieldName("object"), fn.ChildByFieldName("property")
+		if object == nil || property == nil {
+			return "", ""
+		}
+		if object.Type() == "identifier" && factories[object.Content(src)] != "" {
+			// styled.div
+			return property.Content(src), factories[object.Content(src)]
+		}
+		if p := property.Content(src); p == "attrs" || p == "withConfig" {
+			return styledBase(object, src, factories)
+		}
+	case "call_expression":
+		inner, args := fn.ChildByFieldName("function"), fn.ChildByFieldName("arguments")
+		if inner == nil {
+			return "", ""
+		}
+		if inner.Type() == "identifier" && factories[inner.Content(src)] != "" {
+			// styled(Button), styled('div')
+			if args == nil || args.NamedChildCount() == 0 {
+				return "", ""
+			}
+			arg := args.NamedChild(0)
+			if s, ok := stringValue(arg, src); ok {
+				return s, factories[inner.Content(src)]
+			}
+			return arg.Content(src), factories[inner.Content(src)]
+		}
+		// styled.a.attrs({...}) and styled(Button).withConfig({...})
+		if inner.Type() == "member_expression" {
+			if p := inner.ChildByFieldName("property"); p != nil && (p.Content(src) == "attrs" || p.Content(src) == "withConfig") {
+				return styledBase(inner.ChildByFieldName("object"), src, factories)
+			}
+		}
+	}
+	return "", ""
+}
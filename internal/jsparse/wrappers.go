@@ -0,0 +1,140 @@
+package jsparse
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// maxWrapperDepth bounds how many local aliases are followed to find the
+// component behind an export.
+const maxWrapperDepth = 8
+
+// wrapperFuncs are the functions wrapping a component in another one with
+// the same identity, besides the with* higher-order components.
+var wrapperFuncs = map[string]bool{
+	"memo":       true,
+	"forwardRef": true,
+	"observer":   true,
+	"connect":    true,
+	"compose":    true,
+	"inject":     true,
+	"hot":        true,
+	"injectIntl": true,
+	"track":      true,
+}
+
+// isWrapperFunc reports whether fn, the callee of a call, wraps the
+// component it's given: memo, React.forwardRef, mobx's observer, redux's
+// connect(...)(...), or a higher-order component named with*, such as
+// withRouter or withStyles(styles)(...).
+func isWrapperFunc(fn *sitter.Node, src []byte) bool {
+	switch fn.Type() {
+	case "call_expression":
+		// Curried: connect(mapState)(Profile), withStyles(styles)(Card).
+		if inner := fn.ChildByFieldName("function"); inner != nil {
+			return isWrapperFunc(inner, src)
+		}
+		return false
+	case "member_expression":
+		if prop := fn.ChildByFieldName("property"); prop != nil {
+			return isWrapperName(prop.Content(src))
+		}
+		return false
+	case "identifier":
+		return isWrapperName(fn.Content(src))
+	}
+	return false
+}
+
+func isWrapperName(name string) bool {
+	if wrapperFuncs[name] {
+		return true
+	}
+	rest, ok := strings.CutPrefix(name, "with")
+	return ok && rest != "" && rest[0] >= 'A' && rest[0] <= 'Z'
+}
+
+// wrappedIdentifier returns the identifier a chain of wrapper calls wraps,
+// e.g. Button for memo(forwardRef(Button)). It returns "" when n isn't a
+// wrapper call around an identifier, as for memo(function Button() {}).
+func wrappedIdentifier(n *sitter.Node, src []byte) string {
+	for n != nil {
+		switch n.Type() {
+		case "parenthesized_expression", "as_expression", "satisfies_expression", "non_null_expression":
+			n = n.NamedChild(0)
+		case "call_expression":
+			fn := n.ChildByFieldName("function")
+			args := n.ChildByFieldName("arguments")
+			if fn == nil || args == nil || args.NamedChildCount() == 0 || !isWrapperFunc(fn, src) {
+				return ""
+			}
+			// compose(a, b)(X) and memo(X, areEqual) alike wrap one component.
+			n = args.NamedChild(0)
+			if n.Type() == "identifier" {
+				return n.Content(src)
+			}
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// resolveWrappers sets Wraps on the exports of a file standing for another
+// binding, following the wrapped components declared at the top of the
+// module: const Inner = forwardRef(Base); export default memo(Inner) wraps
+// Base.
+func resolveWrappers(root *sitter.Node, src []byte, f *File) {
+	wrapped := make(map[string]string)
+	for i := 0; i < int(root.NamedChildCount()); i++ {
+		decl := root.NamedChild(i)
+		if decl.Type() == "export_statement" {
+			if d := decl.ChildByFieldName("declaration"); d != nil {
+				decl = d
+			}
+		}
+		if decl.Type() != "lexical_declaration" && decl.Type() != "variable_declaration" {
+			continue
+		}
+		for j := 0; j < int(decl.NamedChildCount()); j++ {
+			v := decl.NamedChild(j)
+			name, value := v.ChildByFieldName("name"), v.ChildByFieldName("value")
+			if v.Type() != "variable_declarator" || name == nil || value == nil || name.Type() != "identifier" {
+				continue
+			}
+			if inner := wrappedIdentifier(value, src); inner != "" {
+				wrapped[name.Content(src)] = inner
+			}
+		}
+	}
+
+	imported := make(map[string]bool)
+	for _, imp := range f.Imports {
+		if imp.Kind == Static {
+			for _, b := range imp.ValueBindings() {
+				imported[b.Local] = true
+			}
+		}
+	}
+
+	for i := range f.Exports {
+		e := &f.Exports[i]
+		if e.TypeOnly {
+			continue
+		}
+		inner := e.Wraps
+		switch {
+		case inner != "":
+		case wrapped[e.Name] != "":
+			inner = wrapped[e.Name]
+		case imported[e.Name]:
+			// import { Button } from './Button'; export { Button }
+			inner = e.Name
+		}
+		for depth := 0; inner != "" && depth < maxWrapperDepth; depth++ {
+			e.Wraps = inner
+			inner = wrapped[inner]
+		}
+	}
+}
@@ -0,0 +1,261 @@
+// Package client calls the rgc API. It shares its types with package rgc,
+// so results decode into the same structs the server encodes.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/igorfelipeduca/rgc/pkg/rgc"
+)
+
+// Client calls an rgc server.
+type Client struct {
+	// BaseURL is the server's address, e.g. "http://localhost:8080".
+	BaseURL string
+	// APIKey is sent in the X-API-Key header when set.
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+// New returns a client of the server at baseURL.
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), HTTPClient: http.DefaultClient}
+}
+
+// ScanResult is the outcome of a scan: the result and the ID it's
+// recorded under in the repository's history.
+type ScanResult struct {
+	AnalysisID string                `json:"analysis_id"`
+	Components *rgc.ComponentsResult `json:"components"`
+}
+
+// DiffResult lists the changes between a repository at two refs.
+type DiffResult struct {
+	Base    *rgc.RefScan    `json:"base"`
+	Head    *rgc.RefScan    `json:"head"`
+	Changes *rgc.ResultDiff `json:"changes"`
+}
+
+// JobStatus is a background scan and how far along it is.
+type JobStatus struct {
+	Job      *rgc.Job              `json:"job"`
+	Progress *rgc.ProgressSnapshot `json:"progress"`
+}
+
+// Scan scans a repository and waits for the result.
+func (c *Client) Scan(ctx context.Context, req rgc.RequestPayload) (*ScanResult, error) {
+	var res ScanResult
+	if err := c.do(ctx, http.MethodPost, "/garbage", req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// StartScan scans a repository in the background, returning its job.
+func (c *Client) StartScan(ctx context.Context, req rgc.RequestPayload) (*rgc.Job, error) {
+	var res JobStatus
+	if err := c.do(ctx, http.MethodPost, "/jobs", req, &res); err != nil {
+		return nil, err
+	}
+	return res.Job, nil
+}
+
+// GetJob returns a background scan.
+func (c *Client) GetJob(ctx context.Context, id string) (*JobStatus, error) {
+	var res JobStatus
+	if err := c.do(ctx, http.MethodGet, "/jobs/"+url.PathEscape(id), nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// WaitJob polls a background scan every interval until it's finished. A
+// failed job returns its error.
+func (c *Client) WaitJob(ctx context.Context, id string, interval time.Duration) (*rgc.Job, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		status, err := c.GetJob(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if status.Job.Status != "running" {
+			if status.Job.Error != nil {
+				return status.Job, status.Job.Error
+			}
+			return status.Job, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// GetScan returns a past analysis of a repository with its full result.
+func (c *Client) GetScan(ctx context.Context, owner, repo, id string) (*rgc.Analysis, error) {
+	var res rgc.Analysis
+	if err := c.do(ctx, http.MethodGet, repoPath(owner, repo)+"/scans/"+url.PathEscape(id), nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListScans returns up to limit past analyses of a repository, newest
+// first, without their results. A limit of 0 uses the server's default.
+func (c *Client) ListScans(ctx context.Context, owner, repo string, limit int) ([]rgc.ScanSummary, error) {
+	path := repoPath(owner, repo) + "/scans"
+	if limit > 0 {
+		path += fmt.Sprintf("?limit=%d", limit)
+	}
+	var res struct {
+		Scans []rgc.ScanSummary `json:"scans"`
+	}
+	if err := c.do(ctx, http.MethodGet, path, nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Scans, nil
+}
+
+// ComponentsQuery selects a page of an analysis' components. Zero fields
+// use the server's defaults.
+type ComponentsQuery struct {
+	// Status keeps one bucket: "used", "unused", "test_only",
+	// "storybook_only", "e2e_only" or "possibly_used".
+	Status     string
+	PathPrefix string
+	// Sort is "path", "name", "usage", "size", "last_modified" or
+	// "confidence", prefixed with "-" to reverse.
+	Sort    string
+	Page    int
+	PerPage int
+}
+
+func (q ComponentsQuery) values() url.Values {
+	v := url.Values{}
+	for name, s := range map[string]string{"status": q.Status, "path_prefix": q.PathPrefix, "sort": q.Sort} {
+		if s != "" {
+			v.Set(name, s)
+		}
+	}
+	if q.Page > 0 {
+		v.Set("page", strconv.Itoa(q.Page))
+	}
+	if q.PerPage > 0 {
+		v.Set("per_page", strconv.Itoa(q.PerPage))
+	}
+	return v
+}
+
+// ListComponents returns a page of the components of a past analysis.
+func (c *Client) ListComponents(ctx context.Context, owner, repo, id string, q ComponentsQuery) (*rgc.ComponentsPage, error) {
+	path := repoPath(owner, repo) + "/scans/" + url.PathEscape(id) + "/components"
+	if v := q.values(); len(v) > 0 {
+		path += "?" + v.Encode()
+	}
+	var res rgc.ComponentsPage
+	if err := c.do(ctx, http.MethodGet, path, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// OpenCleanupPR opens a pull request deleting unused components of a past
+// analysis.
+func (c *Client) OpenCleanupPR(ctx context.Context, owner, repo, id string, req rgc.CleanupRequest) (*rgc.CleanupPullRequest, error) {
+	var res rgc.CleanupPullRequest
+	if err := c.do(ctx, http.MethodPost, repoPath(owner, repo)+"/scans/"+url.PathEscape(id)+"/cleanup", req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// CleanupPatch returns a patch deleting unused components of a past
+// analysis, for git apply. Without components it deletes every unused
+// component at least minConfidence sure.
+func (c *Client) CleanupPatch(ctx context.Context, owner, repo, id string, components []string, minConfidence float64) ([]byte, error) {
+	q := url.Values{"components": components}
+	if minConfidence > 0 {
+		q.Set("min_confidence", strconv.FormatFloat(minConfidence, 'f', -1, 64))
+	}
+	var patch []byte
+	if err := c.do(ctx, http.MethodGet, repoPath(owner, repo)+"/scans/"+url.PathEscape(id)+"/cleanup.patch?"+q.Encode(), nil, &patch); err != nil {
+		return nil, err
+	}
+	return patch, nil
+}
+
+// Diff compares a repository at two refs, e.g. the base and head of a pull
+// request.
+func (c *Client) Diff(ctx context.Context, owner, repo, base, head string) (*DiffResult, error) {
+	q := url.Values{"owner": {owner}, "repo": {repo}, "base": {base}, "head": {head}}
+	var res DiffResult
+	if err := c.do(ctx, http.MethodGet, "/diff?"+q.Encode(), nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func repoPath(owner, repo string) string {
+	return "/repos/" + url.PathEscape(owner) + "/" + url.PathEscape(repo)
+}
+
+// do sends a request with body encoded as JSON, when not nil, and decodes
+// the response into out. Error responses are returned as *rgc.APIError.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var res struct {
+			Error *rgc.APIError `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil || res.Error == nil {
+			return fmt.Errorf("rgc: %s %s: %s", method, path, resp.Status)
+		}
+		res.Error.Status = resp.StatusCode
+		return res.Error
+	}
+	if raw, ok := out.(*[]byte); ok {
+		*raw, err = io.ReadAll(resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,236 @@
+package rgc
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// githubTokenHeader carries the caller's own GitHub token, proving its access
+// to the repositories whose history it reads.
+const githubTokenHeader = "X-GitHub-Token"
+
+// repoAccessTTL is how long GitHub's answer about a caller's access to a
+// repository is trusted.
+const repoAccessTTL = 5 * time.Minute
+
+// repoRole is what a caller's GitHub permissions let it do with the stored
+// scans of a repository.
+type repoRole int
+
+const (
+	roleNone repoRole = iota
+	// roleRead sees the scans: the caller can pull the repository.
+	roleRead
+	// roleWrite also opens cleanup pull requests: the caller can push.
+	roleWrite
+)
+
+func (r repoRole) String() string {
+	switch r {
+	case roleRead:
+		return "read"
+	case roleWrite:
+		return "write"
+	}
+	return "no"
+}
+
+// repoAccessEnforced reports whether the scans of each repository are
+// limited to callers who can access it on GitHub: with
+// RGC_REPO_ACCESS_CONTROL=true, and always when users log in with GitHub or
+// the vault keeps tenants' tokens, since scans then read repositories with
+// tokens other callers don't hold.
+func repoAccessEnforced() bool {
+	if os.Getenv("RGC_REPO_ACCESS_CONTROL") == "true" {
+		return true
+	}
+	if _, ok := loadOAuthConfig(); ok {
+		return true
+	}
+	return os.Getenv("RGC_VAULT_KEY") != "" || os.Getenv("RGC_VAULT_KEY_FILE") != ""
+}
+
+var repoAccess = struct {
+	mu      sync.Mutex
+	entries map[string]repoAccessEntry
+}{entries: make(map[string]repoAccessEntry)}
+
+type repoAccessEntry struct {
+	role    repoRole
+	expires time.Time
+}
+
+type githubTokenContextKey struct{}
+
+type adminContextKey struct{}
+
+// accessContext returns the request's context carrying what checkRepoAccess
+// looks at: the GitHub token the caller passed in X-GitHub-Token, and
+// whether it bears the admin token.
+func accessContext(c *gin.Context) context.Context {
+	ctx := withGitHubToken(c.Request.Context(), c.GetHeader(githubTokenHeader))
+	if isAdminRequest(c) {
+		ctx = context.WithValue(ctx, adminContextKey{}, true)
+	}
+	return ctx
+}
+
+// withGitHubToken returns ctx carrying the GitHub token the caller passed
+// to prove its access, if any.
+func withGitHubToken(ctx context.Context, token string) context.Context {
+	if token == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, githubTokenContextKey{}, token)
+}
+
+// callerToken returns the GitHub token speaking for the caller on
+// owner/repo: the one it passed, the logged in user's, or its tenant's when
+// the tenant registered the repository. It's "" for anyone else.
+func callerToken(ctx context.Context, owner, repo string) (string, error) {
+	token, _, err := callerTokenSource(ctx, owner, repo)
+	return token, err
+}
+
+// callerTokenSource is callerToken, also telling where the token came
+// from: "request", "session" or "tenant".
+func callerTokenSource(ctx context.Context, owner, repo string) (string, string, error) {
+	p := RequestPayload{Username: owner, Repo: repo}
+	if p.Token, _ = ctx.Value(githubTokenContextKey{}).(string); p.Token != "" {
+		return p.Token, "request", nil
+	}
+	if err := p.withStoredToken(ctx); err != nil {
+		return "", "", err
+	}
+	return p.Token, p.tokenSource, nil
+}
+
+// actingToken returns the token to act on owner/repo with for the caller,
+// the one access was checked against: given, the caller's, or the server's
+// GITHUB_TOKEN when the caller has none. source tells which, as audit
+// events record it.
+func actingToken(ctx context.Context, owner, repo, given string) (token, source string, err error) {
+	if given != "" {
+		return given, "request", nil
+	}
+	token, source, err = callerTokenSource(ctx, owner, repo)
+	if err != nil || token != "" {
+		return token, source, err
+	}
+	if token = os.Getenv("GITHUB_TOKEN"); token == "" {
+		return "", "", errTokenNotSet
+	}
+	return token, "server", nil
+}
+
+// checkScanAccess is checkRepoAccess for a scan of owner/repo, which
+// returns and records the full result: the caller needs read access, proven
+// by token when the scan request brings one.
+func checkScanAccess(ctx context.Context, owner, repo, token string) error {
+	return checkRepoAccess(withGitHubToken(ctx, token), owner, repo, roleRead)
+}
+
+// checkRepoAccess answers 403 unless the caller has at least need access to
+// owner/repo on GitHub, when RGC_REPO_ACCESS_CONTROL is on. Callers without
+// a token of their own only see public repositories; the admin's requests
+// go through.
+func checkRepoAccess(ctx context.Context, owner, repo string, need repoRole) error {
+	if !repoAccessEnforced() {
+		return nil
+	}
+	if admin, _ := ctx.Value(adminContextKey{}).(bool); admin {
+		return nil
+	}
+	token, err := callerToken(ctx, owner, repo)
+	if err != nil {
+		return err
+	}
+	role, err := repoRoleOf(ctx, token, owner, repo)
+	if err != nil {
+		return err
+	}
+	if role < need {
+		return &APIError{Status: http.StatusForbidden, Code: codeForbidden,
+			Message: fmt.Sprintf("%s access to %s/%s on GitHub is required", need, owner, repo),
+			Details: gin.H{"required": need.String(), "granted": role.String()}}
+	}
+	return nil
+}
+
+// repoRoleOf asks GitHub what token may do with owner/repo, remembering the
+// answer for repoAccessTTL. Without a token, the server's tells whether the
+// repository is public.
+func repoRoleOf(ctx context.Context, token, owner, repo string) (repoRole, error) {
+	sum := sha256.Sum256([]byte(token))
+	key := fmt.Sprintf("%x:%s/%s", sum, strings.ToLower(owner), strings.ToLower(repo))
+	repoAccess.mu.Lock()
+	entry, ok := repoAccess.entries[key]
+	repoAccess.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.role, nil
+	}
+
+	lookup := token
+	if lookup == "" {
+		lookup = os.Getenv("GITHUB_TOKEN")
+	}
+	r, resp, err := newGitHubClient(lookup).Repositories.Get(ctx, owner, repo)
+	role := roleNone
+	switch {
+	case err != nil && resp != nil && resp.StatusCode == http.StatusNotFound:
+		// The token can't see the repository at all.
+	case err != nil:
+		return roleNone, fmt.Errorf("error checking access to %s/%s: %w", owner, repo, err)
+	case token == "":
+		if !r.GetPrivate() {
+			role = roleRead
+		}
+	default:
+		perms := r.GetPermissions()
+		switch {
+		case perms["admin"] || perms["maintain"] || perms["push"]:
+			role = roleWrite
+		case perms["pull"] || perms["triage"] || !r.GetPrivate():
+			role = roleRead
+		}
+	}
+
+	repoAccess.mu.Lock()
+	defer repoAccess.mu.Unlock()
+	if len(repoAccess.entries) >= maxCachedClients {
+		repoAccess.entries = make(map[string]repoAccessEntry)
+	}
+	repoAccess.entries[key] = repoAccessEntry{role: role, expires: time.Now().Add(repoAccessTTL)}
+	return role, nil
+}
+
+// isAdminRequest reports whether the request bears the admin token.
+func isAdminRequest(c *gin.Context) bool {
+	adminToken := os.Getenv("RGC_ADMIN_TOKEN")
+	given, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return ok && adminToken != "" && subtle.ConstantTimeCompare([]byte(given), []byte(adminToken)) == 1
+}
+
+// requireRepoAccess lets through the requests for the stored scans of
+// :owner/:repo of callers with at least need access to it, and those
+// bearing the admin token.
+func requireRepoAccess(need repoRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := accessContext(c)
+		if err := checkRepoAccess(ctx, c.Param("owner"), c.Param("repo"), need); err != nil {
+			respondError(c, err)
+			return
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,120 @@
+package rgc
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// maxSummaryComponents bounds the unused components listed in the step
+// summary, which GitHub caps at 1 MiB.
+const maxSummaryComponents = 1000
+
+// inGitHubActions reports whether the process runs in a GitHub Actions job.
+func inGitHubActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// writeAnnotations emits a workflow command per unused component, so they
+// show up on the files in the run and the pull request. Components failing
+// --fail-on-new-unused are errors and the others warnings; without it every
+// unused component is an error.
+func (r *cliReport) writeAnnotations(w io.Writer) {
+	isNew := make(map[string]bool, len(r.NewUnused))
+	for _, p := range r.NewUnused {
+		isNew[p] = true
+	}
+	for _, p := range r.NewUnused {
+		fmt.Fprintf(w, "::error file=%s,title=%s::%s\n", escapeProperty(p), escapeProperty("New unused component"),
+			escapeData(fmt.Sprintf("%s is unused at %s but wasn't at %s.", p, r.refName(), r.Base)))
+	}
+	level := "error"
+	if r.Base != "" {
+		level = "warning"
+	}
+	for _, p := range r.UnusedComponents {
+		if isNew[p] {
+			continue
+		}
+		fmt.Fprintf(w, "::%s file=%s,title=%s::%s\n", level, escapeProperty(p), escapeProperty("Unused component"),
+			escapeData(fmt.Sprintf("%s isn't imported anywhere and can likely be deleted.", p)))
+	}
+}
+
+func (r *cliReport) refName() string {
+	if r.Ref != "" {
+		return r.Ref
+	}
+	return "the default branch"
+}
+
+// escapeData escapes the message of a workflow command.
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a property value of a workflow command.
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// writeStepSummary appends the report in Markdown to the job summary at
+// GITHUB_STEP_SUMMARY, if set.
+func (r *cliReport) writeStepSummary() error {
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error writing step summary: %v", err)
+	}
+	defer f.Close()
+	if _, err := io.WriteString(f, r.markdown()); err != nil {
+		return fmt.Errorf("error writing step summary: %v", err)
+	}
+	return nil
+}
+
+func (r *cliReport) markdown() string {
+	var b strings.Builder
+	icon := "✅"
+	if len(r.Failures) > 0 {
+		icon = "❌"
+	}
+	fmt.Fprintf(&b, "## %s Unused components in %s\n\n", icon, r.Repository)
+	fmt.Fprintf(&b, "| Ref | Used | Unused |\n| --- | --: | --: |\n| %s | %d | %d |\n\n", r.refName(), r.Used, r.Unused)
+	for _, f := range r.Failures {
+		fmt.Fprintf(&b, "- **Failed:** %s\n", f)
+	}
+	if len(r.Failures) > 0 {
+		b.WriteString("\n")
+	}
+	if r.Base != "" {
+		if len(r.NewUnused) == 0 {
+			fmt.Fprintf(&b, "No component became unused since `%s`.\n\n", r.Base)
+		} else {
+			fmt.Fprintf(&b, "### New since `%s`\n\n", r.Base)
+			for _, p := range r.NewUnused {
+				fmt.Fprintf(&b, "- `%s`\n", p)
+			}
+			b.WriteString("\n")
+		}
+	}
+	if len(r.Baselined) > 0 || len(r.Fixed) > 0 {
+		fmt.Fprintf(&b, "The baseline accepts %d more unused components; %d of its entries are no longer unused.\n\n", len(r.Baselined), len(r.Fixed))
+	}
+	if len(r.UnusedComponents) > 0 {
+		fmt.Fprintf(&b, "<details><summary>All %d unused components</summary>\n\n", len(r.UnusedComponents))
+		for i, p := range r.UnusedComponents {
+			if i == maxSummaryComponents {
+				fmt.Fprintf(&b, "\n…and %d more\n", len(r.UnusedComponents)-i)
+				break
+			}
+			fmt.Fprintf(&b, "- `%s`\n", p)
+		}
+		b.WriteString("\n</details>\n\n")
+	}
+	return b.String()
+}
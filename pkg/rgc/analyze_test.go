@@ -0,0 +1,133 @@
+package rgc
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"testing/fstest"
+)
+
+// project is a small React app with a component in every bucket.
+var project = fstest.MapFS{
+	"src/main.tsx":   {Data: []byte("import { createRoot } from 'react-dom/client'\nimport { App } from './App'\ncreateRoot(document.body).render(<App />)\n")},
+	"src/App.tsx":    {Data: []byte("import { Header } from './Header'\nexport function App() { return <Header /> }\n")},
+	"src/Header.tsx": {Data: []byte("export function Header() { return <header /> }\n")},
+	"src/Legacy.tsx": {Data: []byte("export function Legacy() { return <div /> }\n")},
+	// Admin imports AdminPanel, but no entry point leads to it.
+	"src/Admin.tsx":      {Data: []byte("import { AdminPanel } from './AdminPanel'\nexport function Admin() { return <AdminPanel /> }\n")},
+	"src/AdminPanel.tsx": {Data: []byte("export function AdminPanel() { return <div /> }\n")},
+	// Island and Shore only import each other.
+	"src/Island.tsx": {Data: []byte("import { Shore } from './Shore'\nexport function Island() { return <Shore /> }\n")},
+	"src/Shore.tsx":  {Data: []byte("import { Island } from './Island'\nexport function Shore() { return <Island /> }\n")},
+
+	"src/Tested.tsx":      {Data: []byte("export function Tested() { return <div /> }\n")},
+	"src/Tested.test.tsx": {Data: []byte("import { Tested } from './Tested'\ntest('renders', () => render(<Tested />))\n")},
+	"src/Card.tsx":        {Data: []byte("export function Card() { return <div /> }\n")},
+	"src/Card.stories.tsx": {Data: []byte("import { Card } from './Card'\nexport default { component: Card }\n" +
+		"export const Featured = { args: { icon: 'Hero' } }\n")},
+	"src/Banner.tsx":            {Data: []byte("export function Banner() { return <div /> }\n")},
+	"cypress/e2e/Banner.cy.tsx": {Data: []byte("import { Banner } from '../../src/Banner'\nit('mounts', () => cy.mount(<Banner />))\n")},
+
+	// A shipped registry quotes Hero; only dead files quote Ghost.
+	"src/Hero.tsx":        {Data: []byte("export function Hero() { return <div /> }\n")},
+	"src/registry.ts":     {Data: []byte("export const blocks = { hero: 'Hero' }\n")},
+	"src/Ghost.tsx":       {Data: []byte("export function Ghost() { return <div /> }\n")},
+	"src/Haunted.tsx":     {Data: []byte("const names = ['Ghost']\nexport function Haunted() { return <div /> }\n")},
+	"src/Spooky.tsx":      {Data: []byte("const names = ['Ghost']\nexport function Spooky() { return <div /> }\n")},
+	"src/Spooky.test.tsx": {Data: []byte("import { Spooky } from './Spooky'\ntest('renders', () => render(<Spooky />))\n")},
+
+	// Ignored by a comment, and left out as vendored and generated code.
+	"src/Experiment.tsx":     {Data: []byte("// rgc-ignore: kept for marketing experiments\nexport function Experiment() { return <div /> }\n")},
+	"vendor/Widget.tsx":      {Data: []byte("export function Widget() { return <div /> }\n")},
+	"src/Icon.generated.tsx": {Data: []byte("export function Icon() { return <svg /> }\n")},
+}
+
+func nodePaths(nodes []*ComponentNode) []string {
+	var out []string
+	for _, node := range nodes {
+		out = append(out, node.Component.Path)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestAnalyzeSource(t *testing.T) {
+	type buckets struct {
+		used, unused, testOnly, storybookOnly, e2eOnly, possiblyUsed []string
+	}
+	tests := []struct {
+		name string
+		opts ScanOptions
+		want buckets
+	}{
+		{
+			"roots",
+			ScanOptions{},
+			buckets{
+				used:          []string{"src/Admin.tsx", "src/AdminPanel.tsx", "src/App.tsx", "src/Header.tsx", "src/main.tsx"},
+				unused:        []string{"src/Ghost.tsx", "src/Haunted.tsx", "src/Hero.tsx", "src/Island.tsx", "src/Legacy.tsx", "src/Shore.tsx"},
+				testOnly:      []string{"src/Spooky.tsx", "src/Tested.tsx"},
+				storybookOnly: []string{"src/Card.tsx"},
+				e2eOnly:       []string{"src/Banner.tsx"},
+			},
+		},
+		{
+			"entry points",
+			ScanOptions{EntryPoints: []string{"src/main.tsx"}},
+			buckets{
+				used:          []string{"src/App.tsx", "src/Header.tsx", "src/main.tsx"},
+				unused:        []string{"src/Admin.tsx", "src/AdminPanel.tsx", "src/Ghost.tsx", "src/Haunted.tsx", "src/Hero.tsx", "src/Island.tsx", "src/Legacy.tsx", "src/Shore.tsx"},
+				testOnly:      []string{"src/Spooky.tsx", "src/Tested.tsx"},
+				storybookOnly: []string{"src/Card.tsx"},
+				e2eOnly:       []string{"src/Banner.tsx"},
+			},
+		},
+		{
+			"vendored and generated included",
+			ScanOptions{EntryPoints: []string{"src/main.tsx"}, IncludeVendorDirs: true, IncludeGenerated: true},
+			buckets{
+				used:          []string{"src/App.tsx", "src/Header.tsx", "src/main.tsx"},
+				unused:        []string{"src/Admin.tsx", "src/AdminPanel.tsx", "src/Ghost.tsx", "src/Haunted.tsx", "src/Hero.tsx", "src/Icon.generated.tsx", "src/Island.tsx", "src/Legacy.tsx", "src/Shore.tsx", "vendor/Widget.tsx"},
+				testOnly:      []string{"src/Spooky.tsx", "src/Tested.tsx"},
+				storybookOnly: []string{"src/Card.tsx"},
+				e2eOnly:       []string{"src/Banner.tsx"},
+			},
+		},
+		{
+			"string references",
+			ScanOptions{EntryPoints: []string{"src/main.tsx"}, StringReferences: true},
+			buckets{
+				used:          []string{"src/App.tsx", "src/Header.tsx", "src/main.tsx"},
+				unused:        []string{"src/Admin.tsx", "src/AdminPanel.tsx", "src/Ghost.tsx", "src/Haunted.tsx", "src/Island.tsx", "src/Legacy.tsx", "src/Shore.tsx"},
+				testOnly:      []string{"src/Spooky.tsx", "src/Tested.tsx"},
+				storybookOnly: []string{"src/Card.tsx"},
+				e2eOnly:       []string{"src/Banner.tsx"},
+				possiblyUsed:  []string{"src/Hero.tsx"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := analyzeSource(context.Background(), &fsSource{fsys: project}, 4, tt.opts)
+			if err != nil {
+				t.Fatalf("analyzeSource() error = %v", err)
+			}
+			got := buckets{
+				used:          nodePaths(result.Used),
+				unused:        nodePaths(result.Unused),
+				testOnly:      nodePaths(result.TestOnly),
+				storybookOnly: nodePaths(result.StorybookOnly),
+				e2eOnly:       nodePaths(result.E2EOnly),
+				possiblyUsed:  nodePaths(result.PossiblyUsed),
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("analyzeSource() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+			ignored := []IgnoredComponent{{Name: "Experiment", Path: "src/Experiment.tsx", Reason: "kept for marketing experiments"}}
+			if !reflect.DeepEqual(result.Ignored, ignored) {
+				t.Errorf("Ignored = %+v, want %+v", result.Ignored, ignored)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package rgc
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+var (
+	ngComponentRe       = regexp.MustCompile(`(?s)@Component\s*\(\s*\{(.*?)\}\s*\)\s*(?:export\s+)?(?:default\s+)?class\s+(\w+)`)
+	ngSelectorRe        = regexp.MustCompile("selector\\s*:\\s*['\"`]([^'\"`]+)['\"`]")
+	ngTemplateUrlRe     = regexp.MustCompile(`templateUrl\s*:\s*['"]([^'"]+)['"]`)
+	ngTemplateRe        = regexp.MustCompile("(?s)template\\s*:\\s*`(.*?)`")
+	ngBootstrapRe       = regexp.MustCompile(`(?s)bootstrap\s*:\s*\[([^\]]*)\]`)
+	ngBootstrapAppRe    = regexp.MustCompile(`bootstrapApplication\s*\(\s*(\w+)`)
+	ngRouteRe           = regexp.MustCompile(`\bcomponent\s*:\s*(\w+)`)
+	ngLazyRouteRe       = regexp.MustCompile(`(?s)loadComponent\s*:.*?\.then\(\s*\(?\s*(\w+)\s*\)?\s*=>\s*(\w+)\.(\w+)`)
+	ngIdentifierRe      = regexp.MustCompile(`\w+`)
+	ngTemplateCommentRe = regexp.MustCompile(`(?s)<!--.*?-->`)
+)
+
+// angularComponent is a class decorated with @Component.
+type angularComponent struct {
+	component Component
+	className string
+	selectors []string
+	template  string
+}
+
+func isAngularComponent(p string) bool {
+	return strings.HasSuffix(p, ".component.ts")
+}
+
+// isAngularBootstrapFile reports whether p conventionally declares NgModules,
+// routes or the application bootstrap, where root components are referenced.
+func isAngularBootstrapFile(p string) bool {
+	base := path.Base(p)
+	return strings.HasSuffix(base, ".module.ts") ||
+		strings.HasSuffix(base, ".routes.ts") ||
+		strings.HasSuffix(base, "-routing.module.ts") ||
+		base == "main.ts" ||
+		base == "app.config.ts"
+}
+
+// linkAngularComponents links Angular components through their templates: a
+// component is a child of every component whose template uses one of its
+// selectors. Components bootstrapped by an NgModule or bootstrapApplication,
+// or mounted by a route, are marked as framework roots.
+func (sc *scan) linkAngularComponents(ctx context.Context, files []string) error {
+	var paths []string
+	for _, p := range files {
+		if isAngularComponent(p) && sc.isComponent(p) {
+			paths = append(paths, p)
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	parsed := make([]*angularComponent, len(paths))
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.SetLimit(sc.concurrency)
+	for i, p := range paths {
+		i, p := i, p
+		eg.Go(func() error {
+			ng, err := sc.parseAngularComponent(egCtx, p)
+			if err != nil {
+				return err
+			}
+			sc.progress.fileParsed()
+			parsed[i] = ng
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+
+	byClass := make(map[string]Component)
+	var components []*angularComponent
+	for _, ng := range parsed {
+		if ng != nil {
+			components = append(components, ng)
+			byClass[ng.className] = ng.component
+		}
+	}
+
+	for _, parent := range components {
+		node := &ComponentNode{Component: parent.component, Owners: sc.codeowners.owners(parent.component.Path)}
+		for _, child := range components {
+			if child != parent && angularTemplateUses(parent.template, child.selectors) {
+				node.Children = append(node.Children, &ComponentNode{Component: child.component, Parent: node})
+			}
+		}
+		sc.mu.Lock()
+		sc.rootComponents = append(sc.rootComponents, node)
+		sc.mu.Unlock()
+	}
+
+	for _, p := range files {
+		if !isAngularBootstrapFile(p) {
+			continue
+		}
+		content, err := sc.src.ReadFile(ctx, p)
+		if err != nil {
+			if err == errFileNotFound {
+				continue
+			}
+			return err
+		}
+		for _, className := range angularRootClasses(content) {
+			if component, ok := byClass[className]; ok {
+				sc.markFrameworkRoot(component.Path)
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseAngularComponent reads a .component.ts file and its external
+// template. It returns nil if the file has no @Component class.
+func (sc *scan) parseAngularComponent(ctx context.Context, p string) (*angularComponent, error) {
+	content, err := sc.src.ReadFile(ctx, p)
+	if err != nil {
+		if err == errFileNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	match := ngComponentRe.FindStringSubmatch(content)
+	if match == nil {
+		return nil, nil
+	}
+	if reason, ok := ignoreReason(content); ok {
+		sc.markIgnored(p, reason)
+	}
+	metadata := match[1]
+
+	ng := &angularComponent{
+		component: sc.createdComponents[p],
+		className: match[2],
+	}
+
+	if m := ngSelectorRe.FindStringSubmatch(metadata); m != nil {
+		for _, selector := range strings.Split(m[1], ",") {
+			if selector = strings.TrimSpace(selector); selector != "" {
+				ng.selectors = append(ng.selectors, selector)
+			}
+		}
+	}
+
+	if m := ngTemplateRe.FindStringSubmatch(metadata); m != nil {
+		ng.template = m[1]
+	} else if m := ngTemplateUrlRe.FindStringSubmatch(metadata); m != nil {
+		templatePath := path.Join(path.Dir(p), m[1])
+		template, err := sc.src.ReadFile(ctx, templatePath)
+		if err != nil && err != errFileNotFound {
+			return nil, err
+		}
+		ng.template = template
+	}
+	ng.template = ngTemplateCommentRe.ReplaceAllString(ng.template, "")
+
+	return ng, nil
+}
+
+// angularTemplateUses reports whether template renders any of the selectors.
+// Element selectors (app-card) and attribute selectors ([appTooltip]) are
+// supported; class selectors are ignored.
+func angularTemplateUses(template string, selectors []string) bool {
+	for _, selector := range selectors {
+		var re *regexp.Regexp
+		switch {
+		case strings.HasPrefix(selector, "[") && strings.HasSuffix(selector, "]"):
+			attr := strings.TrimSuffix(strings.TrimPrefix(selector, "["), "]")
+			if i := strings.Index(attr, "="); i >= 0 {
+				attr = attr[:i]
+			}
+			re = regexp.MustCompile(`<[\w-]+[^>]*\s\[?` + regexp.QuoteMeta(attr) + `\]?[\s=/>]`)
+		case ngIdentifierRe.MatchString(selector[:1]):
+			element := selector
+			if i := strings.IndexAny(element, "[.:"); i >= 0 {
+				element = element[:i]
+			}
+			re = regexp.MustCompile(`<` + regexp.QuoteMeta(element) + `[\s/>]`)
+		default:
+			continue
+		}
+		if re.MatchString(template) {
+			return true
+		}
+	}
+	return false
+}
+
+// angularRootClasses returns the component classes that content bootstraps
+// or mounts as routes.
+func angularRootClasses(content string) []string {
+	var classes []string
+	for _, m := range ngBootstrapRe.FindAllStringSubmatch(content, -1) {
+		classes = append(classes, ngIdentifierRe.FindAllString(m[1], -1)...)
+	}
+	for _, m := range ngBootstrapAppRe.FindAllStringSubmatch(content, -1) {
+		classes = append(classes, m[1])
+	}
+	for _, m := range ngRouteRe.FindAllStringSubmatch(content, -1) {
+		classes = append(classes, m[1])
+	}
+	for _, m := range ngLazyRouteRe.FindAllStringSubmatch(content, -1) {
+		if m[1] == m[2] {
+			classes = append(classes, m[3])
+		}
+	}
+	return classes
+}
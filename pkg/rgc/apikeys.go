@@ -0,0 +1,389 @@
+package rgc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyHeader carries the API key of a request.
+const apiKeyHeader = "X-API-Key"
+
+// defaultRateLimit is how many scans per minute a key may start unless it
+// or RGC_API_RATE_LIMIT says otherwise.
+const defaultRateLimit = 60
+
+var apiKeyNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// APIKey lets a client call the API. Only the hash of the key is kept, the
+// key itself is shown once, when it's created.
+type APIKey struct {
+	Name string `json:"name"`
+	// Prefix is the start of a generated key, to tell keys apart.
+	Prefix string `json:"prefix,omitempty"`
+	Hash   string `json:"-"`
+	// RateLimit is how many scans per minute the key may start, 0 meaning
+	// the server's default.
+	RateLimit int `json:"rate_limit,omitempty"`
+	// Tenant is the tenant the key belongs to, if any. Its scans of the
+	// tenant's repositories use the tenant's stored token.
+	Tenant    string     `json:"tenant,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	// Source is "config" for the keys of RGC_API_KEYS, which can't be
+	// changed through the API, and "database" for the others.
+	Source string `json:"source"`
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// configuredAPIKeys parses RGC_API_KEYS, a comma-separated list of
+// name:key or name:key:rate entries.
+func configuredAPIKeys() ([]*APIKey, error) {
+	var keys []*APIKey
+	for _, entry := range strings.Split(os.Getenv("RGC_API_KEYS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 || !apiKeyNamePattern.MatchString(parts[0]) || parts[1] == "" {
+			return nil, fmt.Errorf("RGC_API_KEYS entry of %q must be name:key or name:key:rate", strings.SplitN(entry, ":", 2)[0])
+		}
+		key := &APIKey{Name: parts[0], Hash: hashAPIKey(parts[1]), Source: "config"}
+		if len(parts) == 3 {
+			rate, err := strconv.Atoi(parts[2])
+			if err != nil || rate <= 0 {
+				return nil, fmt.Errorf("RGC_API_KEYS rate of %s %q is not a positive number", parts[0], parts[2])
+			}
+			key.RateLimit = rate
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// apiKeysRequired reports whether requests need an API key: when keys are
+// configured, or RGC_REQUIRE_API_KEY is "true" for keys only kept in the
+// database.
+func apiKeysRequired() bool {
+	return os.Getenv("RGC_API_KEYS") != "" || os.Getenv("RGC_REQUIRE_API_KEY") == "true"
+}
+
+// serverRateLimit returns RGC_API_RATE_LIMIT, or defaultRateLimit when it
+// isn't a positive number.
+func serverRateLimit() int {
+	if n, err := strconv.Atoi(os.Getenv("RGC_API_RATE_LIMIT")); err == nil && n > 0 {
+		return n
+	}
+	return defaultRateLimit
+}
+
+// findAPIKey returns the key whose secret is key, or nil.
+func findAPIKey(ctx context.Context, key string) (*APIKey, error) {
+	hash := hashAPIKey(key)
+	configured, err := configuredAPIKeys()
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range configured {
+		if k.Hash == hash {
+			return k, nil
+		}
+	}
+	return analyses.storage.FindAPIKey(ctx, hash)
+}
+
+type apiKeyContextKey struct{}
+
+// requireAPIKey only lets through requests bearing a known API key in the
+// X-API-Key header, or the session cookie of a user logged in with GitHub,
+// when keys are required. Browsers can't set headers on
+// WebSocket connections, so those may pass the key as ?api_key= instead.
+func requireAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, err := requestSession(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		if session != nil {
+			c.Request = c.Request.WithContext(withSession(c.Request.Context(), session))
+			if c.GetHeader(apiKeyHeader) == "" {
+				// Logged in users don't need a key.
+				c.Next()
+				return
+			}
+		}
+		if !apiKeysRequired() {
+			c.Next()
+			return
+		}
+		given := c.GetHeader(apiKeyHeader)
+		if given == "" && isWebSocketUpgrade(c.Request) {
+			given = c.Query("api_key")
+		}
+		if given == "" {
+			respondError(c, &APIError{Status: http.StatusUnauthorized, Code: codeUnauthorized,
+				Message: "an API key is required, pass it in the " + apiKeyHeader + " header"})
+			return
+		}
+		key, err := findAPIKey(c.Request.Context(), given)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		if key == nil {
+			respondError(c, &APIError{Status: http.StatusUnauthorized, Code: codeUnauthorized, Message: "invalid API key"})
+			return
+		}
+		c.Request = c.Request.WithContext(withAPIKey(c.Request.Context(), key))
+		c.Next()
+	}
+}
+
+// withAPIKey returns ctx carrying the API key of its request.
+func withAPIKey(ctx context.Context, key *APIKey) context.Context {
+	ctx = context.WithValue(ctx, apiKeyContextKey{}, key)
+	return context.WithValue(ctx, loggerKey{}, loggerFrom(ctx).With("api_key", key.Name))
+}
+
+// limitScans refuses the scans of an API key, or of a logged in user, past
+// its rate limit, with a 429 telling when to retry. Anonymous requests
+// aren't limited.
+func limitScans() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := rateLimitKey(c.Request.Context())
+		if key == nil {
+			c.Next()
+			return
+		}
+		limit, remaining, apiErr := takeScan(key)
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		if apiErr != nil {
+			c.Header("Retry-After", strconv.Itoa(apiErr.Details["retry_after_seconds"].(int)))
+			respondError(c, apiErr)
+			return
+		}
+		c.Next()
+	}
+}
+
+// takeRepoScan counts one repository of a batch or organization scan
+// against the rate limit of ctx's caller, returning the error to report for
+// it past the limit.
+func takeRepoScan(ctx context.Context) error {
+	key := rateLimitKey(ctx)
+	if key == nil {
+		return nil
+	}
+	if _, _, apiErr := takeScan(key); apiErr != nil {
+		return apiErr
+	}
+	return nil
+}
+
+// rateLimitKey returns the key the scans of ctx's caller count against:
+// its API key, or a bucket of its own for a logged in user. It's nil for
+// anonymous callers, when API keys aren't required.
+func rateLimitKey(ctx context.Context) *APIKey {
+	if key, ok := ctx.Value(apiKeyContextKey{}).(*APIKey); ok {
+		return key
+	}
+	if session, ok := ctx.Value(sessionContextKey{}).(*Session); ok {
+		return &APIKey{Name: "user:" + session.Login, Source: "session"}
+	}
+	return nil
+}
+
+// takeScan counts a scan against the rate limit of key. It returns the
+// limit, the scans left, and the error to answer when there are none.
+func takeScan(key *APIKey) (int, int, *APIError) {
+	limit := key.RateLimit
+	if limit == 0 {
+		limit = serverRateLimit()
+	}
+	remaining, retryAfter := scanLimiter.take(key.Name, limit)
+	if retryAfter <= 0 {
+		return limit, remaining, nil
+	}
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	who := "API key " + key.Name
+	if key.Source == "session" {
+		who = "user " + strings.TrimPrefix(key.Name, "user:")
+	}
+	return limit, remaining, &APIError{Status: http.StatusTooManyRequests, Code: codeRateLimited,
+		Message:   fmt.Sprintf("%s went past its rate limit of %d scans per minute", who, limit),
+		Details:   gin.H{"limit": limit, "retry_after_seconds": seconds},
+		Retryable: true}
+}
+
+// rateLimiter is a token bucket per API key, refilling limit tokens a
+// minute and holding at most limit of them.
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+var scanLimiter = &rateLimiter{buckets: make(map[string]*bucket)}
+
+// take spends a token of name's bucket, returning the tokens left, or how
+// long until one is available when there is none.
+func (l *rateLimiter) take(name string, limit int) (int, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	perSecond := float64(limit) / 60
+	b, ok := l.buckets[name]
+	if !ok {
+		b = &bucket{tokens: float64(limit), last: now}
+		l.buckets[name] = b
+	}
+	b.tokens = math.Min(float64(limit), b.tokens+now.Sub(b.last).Seconds()*perSecond)
+	b.last = now
+	if b.tokens < 1 {
+		return 0, time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+	}
+	b.tokens--
+	return int(b.tokens), 0
+}
+
+// handleAPIKeysRequest lists the API keys, without their secrets.
+func handleAPIKeysRequest(c *gin.Context) {
+	keys, err := configuredAPIKeys()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	stored, err := analyses.storage.APIKeys(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	keys = append(keys, stored...)
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys, "required": apiKeysRequired(), "default_rate_limit": serverRateLimit()})
+}
+
+// handleCreateAPIKeyRequest creates an API key from {"name": "...",
+// "rate_limit": n, "tenant": "..."} and returns it, the only time its secret
+// is shown.
+func handleCreateAPIKeyRequest(c *gin.Context) {
+	var payload struct {
+		Name      string `json:"name"`
+		RateLimit int    `json:"rate_limit"`
+		Tenant    string `json:"tenant"`
+	}
+	if err := c.BindJSON(&payload); err != nil {
+		respondError(c, errBadRequest("%v", err))
+		return
+	}
+	if !apiKeyNamePattern.MatchString(payload.Name) {
+		respondError(c, errBadRequest("name must be letters, digits, '.', '-' and '_', at most 64 characters"))
+		return
+	}
+	if payload.RateLimit < 0 {
+		respondError(c, errBadRequest("rate_limit can't be negative"))
+		return
+	}
+	if payload.Tenant != "" {
+		if t, err := findTenant(c.Request.Context(), payload.Tenant); err != nil {
+			respondError(c, err)
+			return
+		} else if t == nil {
+			respondError(c, errBadRequest("tenant %s not found", payload.Tenant))
+			return
+		}
+	}
+	if exists, err := apiKeyExists(c.Request.Context(), payload.Name); err != nil {
+		respondError(c, err)
+		return
+	} else if exists {
+		respondError(c, &APIError{Status: http.StatusConflict, Code: codeInvalidRequest,
+			Message: fmt.Sprintf("API key %s already exists", payload.Name)})
+		return
+	}
+
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		respondError(c, err)
+		return
+	}
+	secret := "rgc_" + hex.EncodeToString(b)
+	now := time.Now().UTC()
+	key := &APIKey{
+		Name:      payload.Name,
+		Prefix:    secret[:8],
+		Hash:      hashAPIKey(secret),
+		RateLimit: payload.RateLimit,
+		Tenant:    payload.Tenant,
+		CreatedAt: &now,
+		Source:    "database",
+	}
+	if err := analyses.storage.SaveAPIKey(c.Request.Context(), key); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"api_key": key, "key": secret})
+}
+
+func apiKeyExists(ctx context.Context, name string) (bool, error) {
+	configured, err := configuredAPIKeys()
+	if err != nil {
+		return false, err
+	}
+	stored, err := analyses.storage.APIKeys(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, k := range append(configured, stored...) {
+		if k.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// handleDeleteAPIKeyRequest revokes an API key kept in the database.
+func handleDeleteAPIKeyRequest(c *gin.Context) {
+	name := c.Param("name")
+	configured, err := configuredAPIKeys()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	for _, k := range configured {
+		if k.Name == name {
+			respondError(c, &APIError{Status: http.StatusConflict, Code: codeInvalidRequest,
+				Message: fmt.Sprintf("API key %s is set in RGC_API_KEYS, remove it there", name)})
+			return
+		}
+	}
+	if err := analyses.storage.DeleteAPIKey(c.Request.Context(), name); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,233 @@
+package rgc
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/igorfelipeduca/rgc/internal/jsparse"
+	"golang.org/x/sync/errgroup"
+)
+
+var (
+	imageExtensions = map[string]bool{
+		".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true,
+		".avif": true, ".svg": true, ".ico": true, ".bmp": true,
+	}
+	styleExtensions = map[string]bool{".css": true, ".scss": true, ".sass": true, ".less": true}
+
+	// assetRefRegex finds quoted or url() references to asset files in any
+	// source, stylesheet or HTML file.
+	assetRefRegex = regexp.MustCompile(`["'` + "`" + `(]\s*([^"'` + "`" + `()\s]+\.(?:png|jpe?g|gif|webp|avif|svg|ico|bmp|css|scss|sass|less))(?:[?#][^"'` + "`" + `()\s]*)?\s*["'` + "`" + `)]`)
+	// styleImportRegex finds Sass and Less imports, which may leave out the
+	// extension and the leading underscore of partials.
+	styleImportRegex = regexp.MustCompile(`@(?:import|use|forward)\s+(?:url\()?["']([^"']+)["']`)
+	// publicDirs serve their files from the site root.
+	publicDirs = []string{"public", "static"}
+)
+
+// AssetEntry is an image or stylesheet of the repository.
+type AssetEntry struct {
+	Path string `json:"path"`
+	// Kind is "image" or "style".
+	Kind string `json:"kind"`
+	// ReferencedBy lists the files referencing the asset.
+	ReferencedBy []string `json:"referenced_by,omitempty"`
+}
+
+// AssetsReport tells which images and stylesheets are still referenced.
+type AssetsReport struct {
+	UsedCount   int          `json:"used_count"`
+	UnusedCount int          `json:"unused_count"`
+	Used        []AssetEntry `json:"used"`
+	Unused      []AssetEntry `json:"unused"`
+	// OnlyUsedByUnused holds the assets only unused components reference:
+	// they go away with those components.
+	OnlyUsedByUnused []AssetEntry `json:"only_used_by_unused"`
+}
+
+func assetKind(p string) string {
+	ext := strings.ToLower(path.Ext(p))
+	switch {
+	case imageExtensions[ext]:
+		return "image"
+	case styleExtensions[ext]:
+		return "style"
+	}
+	return ""
+}
+
+// isAssetReferrer reports whether the file at p can reference assets and is
+// worth reading for them.
+func isAssetReferrer(p string) bool {
+	ext := strings.ToLower(path.Ext(p))
+	switch {
+	case jsparse.Supported(p), styleExtensions[ext]:
+		return true
+	case ext == ".svelte", ext == ".vue", ext == ".html", ext == ".mdx", ext == ".webmanifest":
+		return true
+	}
+	return path.Base(p) == "manifest.json"
+}
+
+// servedPath returns the URL path an asset of a public directory is served
+// from, e.g. "/img/logo.png" for "web/public/img/logo.png", or "".
+func servedPath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments[:len(segments)-1] {
+		if contains(publicDirs, s) {
+			return "/" + strings.Join(segments[i+1:], "/")
+		}
+	}
+	return ""
+}
+
+// resolveAssetRef resolves a reference found in referrer to the asset files
+// it may designate.
+func (sc *scan) resolveAssetRef(referrer, ref string, served map[string][]string) []string {
+	var candidates []string
+	switch {
+	case strings.HasPrefix(ref, "http:"), strings.HasPrefix(ref, "https:"), strings.HasPrefix(ref, "data:"):
+		return nil
+	case strings.HasPrefix(ref, "/"):
+		return served[ref]
+	case strings.HasPrefix(ref, "."):
+		candidates = append(candidates, path.Join(path.Dir(referrer), ref))
+	default:
+		for _, cfg := range sc.shadcn {
+			if p := cfg.resolveAlias(ref); p != "" && cfg.contains(referrer) {
+				candidates = append(candidates, p)
+			}
+		}
+		// Stylesheets resolve bare paths relative to themselves.
+		candidates = append(candidates, path.Join(path.Dir(referrer), ref))
+	}
+
+	if assetKind(referrer) == "style" {
+		// Sass partials: @use 'vars' may be _vars.scss.
+		var partials []string
+		for _, c := range candidates {
+			dir, name := path.Split(c)
+			for _, ext := range []string{"", ".scss", ".sass", ".css", ".less"} {
+				partials = append(partials, c+ext, dir+"_"+name+ext)
+			}
+		}
+		candidates = partials
+	}
+
+	var found []string
+	for _, c := range candidates {
+		if p, ok := sc.fileIndex[sc.nameKey(c)]; ok && assetKind(p) != "" {
+			found = append(found, p)
+		}
+	}
+	return found
+}
+
+// assetsReport reads every file that can reference an asset and reports the
+// images and stylesheets nothing references, and those only unused
+// components reference.
+func (sc *scan) assetsReport(ctx context.Context, unused []*ComponentNode) (*AssetsReport, error) {
+	served := make(map[string][]string)
+	var assets []string
+	for _, p := range sc.files {
+		if assetKind(p) == "" {
+			continue
+		}
+		assets = append(assets, p)
+		if s := servedPath(p); s != "" {
+			served[s] = append(served[s], p)
+		}
+	}
+
+	referrers := make(map[string][]string)
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(sc.concurrency)
+	for _, p := range sc.files {
+		if !isAssetReferrer(p) {
+			continue
+		}
+		p := p
+		eg.Go(func() error {
+			content, err := sc.src.ReadFile(ctx, p)
+			if err != nil {
+				if err == errFileNotFound {
+					return nil
+				}
+				return err
+			}
+			var refs []string
+			for _, m := range assetRefRegex.FindAllStringSubmatch(content, -1) {
+				refs = append(refs, m[1])
+			}
+			if assetKind(p) == "style" {
+				for _, m := range styleImportRegex.FindAllStringSubmatch(content, -1) {
+					refs = append(refs, m[1])
+				}
+			}
+
+			sc.mu.Lock()
+			defer sc.mu.Unlock()
+			for _, ref := range refs {
+				for _, asset := range sc.resolveAssetRef(p, ref, served) {
+					if asset != p && !contains(referrers[asset], p) {
+						referrers[asset] = append(referrers[asset], p)
+					}
+				}
+			}
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+	sc.assetReferrers = referrers
+
+	dead := make(map[string]bool)
+	for _, node := range unused {
+		dead[node.Component.Path] = true
+	}
+	// A stylesheet only dead files reference is dead, and so are the images
+	// it references in turn.
+	deadReferrer := func(p string) bool { return dead[p] || isSupportFile(p) }
+	for changed := true; changed; {
+		changed = false
+		for _, asset := range assets {
+			if dead[asset] || len(referrers[asset]) == 0 {
+				continue
+			}
+			allDead := true
+			for _, r := range referrers[asset] {
+				if !deadReferrer(r) {
+					allDead = false
+					break
+				}
+			}
+			if allDead {
+				dead[asset] = true
+				changed = true
+			}
+		}
+	}
+
+	report := &AssetsReport{Used: []AssetEntry{}, Unused: []AssetEntry{}, OnlyUsedByUnused: []AssetEntry{}}
+	sort.Strings(assets)
+	for _, asset := range assets {
+		by := referrers[asset]
+		sort.Strings(by)
+		entry := AssetEntry{Path: asset, Kind: assetKind(asset), ReferencedBy: by}
+		switch {
+		case len(by) == 0:
+			report.Unused = append(report.Unused, entry)
+		case dead[asset]:
+			report.OnlyUsedByUnused = append(report.OnlyUsedByUnused, entry)
+		default:
+			report.Used = append(report.Used, entry)
+		}
+	}
+	report.UsedCount = len(report.Used)
+	report.UnusedCount = len(report.Unused)
+	return report, nil
+}
@@ -0,0 +1,164 @@
+package rgc
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Audit actions.
+const (
+	auditScan    = "scan"
+	auditCleanup = "cleanup"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+	// maxMemoryAuditEvents bounds the audit log kept in memory.
+	maxMemoryAuditEvents = 10000
+)
+
+// AuditEvent records a scan stored in the history or a cleanup pull request
+// opened: who asked for it and which GitHub token it used.
+type AuditEvent struct {
+	ID     string    `json:"id"`
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	// Actor is the API key's name, "user:<login>" for a logged in user,
+	// "schedule" or "anonymous".
+	Actor  string `json:"actor"`
+	Tenant string `json:"tenant,omitempty"`
+	Owner  string `json:"owner"`
+	Repo   string `json:"repo"`
+	// Ref is the commit scanned or branched from.
+	Ref        string `json:"ref,omitempty"`
+	AnalysisID string `json:"analysis_id,omitempty"`
+	// TokenSource is where the GitHub token came from: "request",
+	// "session", "tenant" or "server".
+	TokenSource string `json:"token_source"`
+	// TokenScopes are the scopes of a classic or OAuth token, nil for
+	// fine-grained and GitHub App tokens, which don't report them.
+	TokenScopes []string `json:"token_scopes"`
+	PullRequest string   `json:"pull_request,omitempty"`
+}
+
+// AuditQuery filters the audit log. Zero fields match everything.
+type AuditQuery struct {
+	Owner, Repo string
+	Action      string
+	Actor       string
+	Since       time.Time
+	Limit       int
+}
+
+func (q AuditQuery) matches(e *AuditEvent) bool {
+	return (q.Owner == "" || repoKey(q.Owner, q.Repo) == repoKey(e.Owner, e.Repo)) &&
+		(q.Action == "" || q.Action == e.Action) &&
+		(q.Actor == "" || q.Actor == e.Actor) &&
+		!e.Time.Before(q.Since)
+}
+
+// scanOrigin is who started a scan and where its token came from.
+type scanOrigin struct {
+	actor, tenant, tokenSource string
+}
+
+// requestActor returns who the request of ctx acts for: its API key, the
+// logged in user, or anonymous.
+func requestActor(ctx context.Context) (actor, tenant string) {
+	if key, ok := ctx.Value(apiKeyContextKey{}).(*APIKey); ok {
+		return key.Name, key.Tenant
+	}
+	if session, ok := ctx.Value(sessionContextKey{}).(*Session); ok {
+		return "user:" + session.Login, ""
+	}
+	return "anonymous", ""
+}
+
+// recordAudit completes e with the scopes of token and stores it. The
+// action already happened, so failing to record it is only logged.
+func recordAudit(ctx context.Context, e *AuditEvent, token string) {
+	if e.TokenSource == "" {
+		e.TokenSource = "request"
+		if token == "" {
+			e.TokenSource = "server"
+		}
+	}
+	if token == "" {
+		token = os.Getenv("GITHUB_TOKEN")
+	}
+	if info, err := inspectToken(ctx, newGitHubClient(token)); err == nil && info.Classic {
+		e.TokenScopes = info.Scopes
+		if e.TokenScopes == nil {
+			e.TokenScopes = []string{}
+		}
+	}
+	if e.Actor == "" {
+		e.Actor = "anonymous"
+	}
+	e.Time = time.Now().UTC()
+	if err := analyses.storage.SaveAuditEvent(ctx, e); err != nil {
+		loggerFrom(ctx).Warn("audit event not recorded", "action", e.Action, "error", err)
+	}
+}
+
+// auditAnalysis records who started the scan a was stored from.
+func auditAnalysis(ctx context.Context, a *Analysis, opts ScanOptions) {
+	recordAudit(ctx, &AuditEvent{
+		Action:      auditScan,
+		Actor:       opts.origin.actor,
+		Tenant:      opts.origin.tenant,
+		Owner:       a.Owner,
+		Repo:        a.Repo,
+		Ref:         a.Ref,
+		AnalysisID:  a.ID,
+		TokenSource: opts.origin.tokenSource,
+	}, opts.Token)
+}
+
+// handleAuditRequest lists the audit log, newest first, filtered by
+// ?owner= and ?repo=, ?action=, ?actor= and ?since= (RFC 3339), at most
+// ?limit= events.
+func handleAuditRequest(c *gin.Context) {
+	q := AuditQuery{
+		Owner:  c.Query("owner"),
+		Repo:   c.Query("repo"),
+		Action: c.Query("action"),
+		Actor:  c.Query("actor"),
+		Limit:  defaultAuditLimit,
+	}
+	if (q.Owner == "") != (q.Repo == "") {
+		respondError(c, errBadRequest("owner and repo go together"))
+		return
+	}
+	if q.Action != "" && q.Action != auditScan && q.Action != auditCleanup {
+		respondError(c, errBadRequest("action must be %s or %s", auditScan, auditCleanup))
+		return
+	}
+	if v := c.Query("since"); v != "" {
+		var err error
+		if q.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			respondError(c, errBadRequest("since must be an RFC 3339 timestamp, e.g. 2024-01-31T00:00:00Z"))
+			return
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			respondError(c, errBadRequest("limit must be a positive number"))
+			return
+		}
+		q.Limit = min(n, maxAuditLimit)
+	}
+	events, err := analyses.storage.AuditEvents(c.Request.Context(), q)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
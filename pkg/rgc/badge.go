@@ -0,0 +1,107 @@
+package rgc
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const badgeLabel = "unused components"
+
+// badgeColor picks the badge color for an unused component count, from
+// green for none to red for a lot.
+func badgeColor(unused int) string {
+	switch {
+	case unused == 0:
+		return "#4c1"
+	case unused <= 5:
+		return "#dfb317"
+	case unused <= 20:
+		return "#fe7d37"
+	}
+	return "#e05d44"
+}
+
+// textWidth estimates the width in pixels of s in 11px Verdana, the font of
+// shields.io badges. It doesn't need to be exact, only to leave room.
+func textWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		switch {
+		case strings.ContainsRune("ijlI.:,'| ", r):
+			width += 4
+		case r == 'm' || r == 'w' || r == 'M' || r == 'W':
+			width += 10
+		default:
+			width += 7
+		}
+	}
+	return width
+}
+
+// renderBadge draws a flat shields.io style badge.
+func renderBadge(label, message, color string) string {
+	labelWidth := textWidth(label) + 10
+	messageWidth := textWidth(message) + 10
+	width := labelWidth + messageWidth
+	label, message = html.EscapeString(label), html.EscapeString(message)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">`, width, label, message)
+	fmt.Fprintf(&b, `<title>%s: %s</title>`, label, message)
+	b.WriteString(`<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`)
+	fmt.Fprintf(&b, `<clipPath id="r"><rect width="%d" height="20" rx="3" fill="#fff"/></clipPath>`, width)
+	fmt.Fprintf(&b, `<g clip-path="url(#r)"><rect width="%d" height="20" fill="#555"/><rect x="%d" width="%d" height="20" fill="%s"/><rect width="%d" height="20" fill="url(#s)"/></g>`,
+		labelWidth, labelWidth, messageWidth, color, width)
+	b.WriteString(`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`)
+	fmt.Fprintf(&b, `<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>`,
+		labelWidth/2, label, labelWidth/2, label)
+	fmt.Fprintf(&b, `<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>`,
+		labelWidth+messageWidth/2, message, labelWidth+messageWidth/2, message)
+	b.WriteString(`</g></svg>`)
+	return b.String()
+}
+
+// handleBadgeRequest serves /badge/:owner/:repo.svg, a badge with the unused
+// component count of the repository's latest analysis. It never scans: a
+// repository without analyses gets an "unknown" badge. Badges are embedded
+// in READMEs, fetched without credentials, so with access control on only
+// public repositories get one.
+func handleBadgeRequest(c *gin.Context) {
+	repo, ok := strings.CutSuffix(c.Param("repo"), ".svg")
+	if !ok {
+		respondError(c, errNotFound("badges are served as .svg"))
+		return
+	}
+	if repoAccessEnforced() {
+		role, err := repoRoleOf(c.Request.Context(), "", c.Param("owner"), repo)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		if role < roleRead {
+			// Not found rather than forbidden, so it doesn't tell private
+			// repositories exist.
+			respondError(c, errNotFound("no badge for %s/%s", c.Param("owner"), repo))
+			return
+		}
+	}
+
+	scans, err := analyses.list(c.Request.Context(), c.Param("owner"), repo, 1)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	message, color := "unknown", "#9f9f9f"
+	if len(scans) > 0 {
+		message, color = strconv.Itoa(scans[0].UnusedCount), badgeColor(scans[0].UnusedCount)
+	}
+
+	// README images go through GitHub's camo proxy, which honors these.
+	c.Header("Cache-Control", "max-age=300")
+	c.Data(http.StatusOK, "image/svg+xml; charset=utf-8", []byte(renderBadge(badgeLabel, message, color)))
+}
@@ -0,0 +1,196 @@
+package rgc
+
+import (
+	"context"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/igorfelipeduca/rgc/internal/jsparse"
+)
+
+const maxReExportDepth = 10
+
+// moduleExtensions are tried, in order, when resolving an extensionless
+// import specifier, the same way bundlers do.
+var moduleExtensions = []string{".tsx", ".ts", ".jsx", ".js", ".mjs", ".cjs"}
+
+// resolveModule resolves an import specifier from importer to a file of the
+// repository: the exact path, the path with a module extension, or an index
+// file inside the directory. Besides relative specifiers it understands the
+// import alias of shadcn/ui projects (e.g. "@/components/ui/button"). It
+// returns "" when nothing matches.
+func (sc *scan) resolveModule(importer, specifier string) string {
+	var base string
+	if strings.HasPrefix(specifier, ".") {
+		base = path.Join(path.Dir(importer), specifier)
+	} else {
+		for _, cfg := range sc.shadcn {
+			if !cfg.contains(importer) {
+				continue // The alias of another project of a monorepo
+			}
+			if base = cfg.resolveAlias(specifier); base != "" {
+				break
+			}
+		}
+		if base == "" {
+			return ""
+		}
+	}
+
+	candidates := []string{base}
+	for _, ext := range moduleExtensions {
+		candidates = append(candidates, base+ext)
+	}
+	for _, ext := range moduleExtensions {
+		candidates = append(candidates, base+"/index"+ext)
+	}
+	for _, candidate := range candidates {
+		if p, ok := sc.fileIndex[sc.nameKey(candidate)]; ok {
+			return p
+		}
+	}
+	return ""
+}
+
+// parseModule reads and parses a non-component module, such as a barrel
+// file. Results are cached since popular barrels are imported everywhere.
+func (sc *scan) parseModule(ctx context.Context, p string) (*jsparse.File, error) {
+	sc.mu.Lock()
+	file, ok := sc.modules[p]
+	sc.mu.Unlock()
+	if ok {
+		return file, nil
+	}
+
+	content, err := sc.src.ReadFile(ctx, p)
+	if err != nil {
+		if err == errFileNotFound {
+			return &jsparse.File{}, nil
+		}
+		return nil, err
+	}
+	sc.checkGenerated(p, content)
+	file, err = parseFile(ctx, p, content)
+	if err != nil {
+		return nil, &ParseError{Path: p, Err: err}
+	}
+
+	sc.mu.Lock()
+	sc.modules[p] = file
+	sc.mu.Unlock()
+	return file, nil
+}
+
+// importedNames returns the export names an import pulls from its module,
+// with "*" standing for all of them.
+func importedNames(imp jsparse.Import) []string {
+	bindings := imp.ValueBindings()
+	if len(bindings) == 0 {
+		// Dynamic imports, requires and namespace-less re-exports can reach anything.
+		return []string{"*"}
+	}
+	var names []string
+	for _, b := range bindings {
+		names = append(names, b.Imported)
+	}
+	return names
+}
+
+// followReExports follows the `export ... from` statements of a barrel file
+// and returns the paths of the components that provide the wanted exports.
+// Barrels re-exporting other barrels are followed transitively, and so are
+// the exports wrapping an imported component, as in
+// export const Button = memo(forwardRef(ButtonBase)).
+func (sc *scan) followReExports(ctx context.Context, barrel string, wanted []string, visited map[string]bool) ([]string, error) {
+	if visited[barrel] || len(visited) >= maxReExportDepth {
+		return nil, nil
+	}
+	visited[barrel] = true
+	defer delete(visited, barrel)
+
+	file, err := sc.parseModule(ctx, barrel)
+	if err != nil {
+		return nil, err
+	}
+
+	wantAll := contains(wanted, "*")
+	var components []string
+	reach := func(target string, next []string, star bool) error {
+		if sc.isComponent(target) {
+			name := normalizeName(extractComponentName(target))
+			// Through `export *` only the names asked for can come from the
+			// component, and a component file is assumed to export its own name.
+			if !star || wantAll || contains(wanted, name) {
+				components = append(components, target)
+			}
+			return nil
+		}
+		if jsparse.Supported(target) {
+			nested, err := sc.followReExports(ctx, target, next, visited)
+			if err != nil {
+				return err
+			}
+			components = append(components, nested...)
+		}
+		return nil
+	}
+	for _, imp := range file.Imports {
+		if imp.Kind != jsparse.ReExport || !imp.IsRuntime() {
+			continue
+		}
+		target := sc.resolveModule(barrel, imp.Specifier)
+		if target == "" {
+			continue
+		}
+
+		// Translate the wanted names into the names the target exports them as.
+		var next []string
+		star := false
+		for _, b := range imp.ValueBindings() {
+			switch {
+			case b.Imported == "*" && b.Local == "*":
+				// export * from './x' passes every name through unchanged.
+				star = true
+				next = append(next, wanted...)
+			case wantAll || contains(wanted, b.Local):
+				next = append(next, b.Imported)
+			}
+		}
+		if len(next) == 0 {
+			continue
+		}
+		if err := reach(target, next, star); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, e := range file.Exports {
+		if e.Wraps == "" || !wantAll && !contains(wanted, e.Name) {
+			continue
+		}
+		for _, imp := range file.Imports {
+			if imp.Kind != jsparse.Static {
+				continue
+			}
+			i := slices.IndexFunc(imp.ValueBindings(), func(b jsparse.Binding) bool { return b.Local == e.Wraps })
+			target := sc.resolveModule(barrel, imp.Specifier)
+			if i < 0 || target == "" {
+				continue
+			}
+			if err := reach(target, []string{imp.ValueBindings()[i].Imported}, false); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return components, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
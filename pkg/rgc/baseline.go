@@ -0,0 +1,86 @@
+package rgc
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// Baseline lists the unused components a team accepted for now, so CI only
+// fails on new dead code while the backlog is burned down.
+type Baseline struct {
+	Repository  string    `json:"repository"`
+	GeneratedAt time.Time `json:"generated_at"`
+	// Components are the paths of the accepted unused components, sorted.
+	Components []string `json:"components"`
+}
+
+func readBaseline(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading baseline: %v", err)
+	}
+	var b Baseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("error reading baseline %s: %v", path, err)
+	}
+	return &b, nil
+}
+
+// writeBaseline writes the unused components of the report to path,
+// sorted so regenerating it makes small diffs.
+func writeBaseline(path string, r *cliReport) (*Baseline, error) {
+	b := &Baseline{
+		Repository:  r.Repository,
+		GeneratedAt: time.Now().UTC().Truncate(time.Second),
+		Components:  append([]string{}, r.UnusedComponents...),
+	}
+	b.Components = append(b.Components, r.Baselined...)
+	sort.Strings(b.Components)
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return nil, fmt.Errorf("error writing baseline: %v", err)
+	}
+	return b, nil
+}
+
+// apply moves the baselined components out of the unused ones the gates
+// see, and lists the baseline entries that are no longer unused.
+func (b *Baseline) apply(r *cliReport) {
+	accepted := make(map[string]bool, len(b.Components))
+	for _, p := range b.Components {
+		accepted[p] = true
+	}
+	unused := make(map[string]bool, len(r.UnusedComponents))
+	kept := []string{}
+	for _, p := range r.UnusedComponents {
+		unused[p] = true
+		if accepted[p] {
+			r.Baselined = append(r.Baselined, p)
+		} else {
+			kept = append(kept, p)
+		}
+	}
+	r.UnusedComponents = kept
+	r.Unused = len(kept)
+
+	newUnused := []string{}
+	for _, p := range r.NewUnused {
+		if !accepted[p] {
+			newUnused = append(newUnused, p)
+		}
+	}
+	if r.NewUnused != nil {
+		r.NewUnused = newUnused
+	}
+	for _, p := range b.Components {
+		if !unused[p] {
+			r.Fixed = append(r.Fixed, p)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package rgc
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	maxBatchRepos = 20
+	// batchScanConcurrency is how many repositories of a batch are scanned
+	// at once. They share the batch's request budget.
+	batchScanConcurrency = 4
+)
+
+// BatchRepo is one repository of a batch scan.
+type BatchRepo struct {
+	Username string `json:"username"`
+	Repo     string `json:"repo"`
+	Ref      string `json:"ref"`
+}
+
+// key names the repository in the batch response, with its ref when set so
+// several refs of one repository can be scanned together.
+func (r BatchRepo) key() string {
+	key := r.Username + "/" + r.Repo
+	if r.Ref != "" {
+		key += "@" + r.Ref
+	}
+	return key
+}
+
+// BatchRequestPayload asks for several repositories to be scanned at once.
+type BatchRequestPayload struct {
+	Repos []BatchRepo `json:"repos"`
+	// Concurrency bounds the parallel GitHub requests of the whole batch,
+	// split between the repositories scanned at the same time.
+	Concurrency int    `json:"concurrency"`
+	Token       string `json:"token"`
+	// Scan holds the options of each scan, as for POST /garbage.
+	Scan RequestPayload `json:"scan"`
+}
+
+// BatchResult is the outcome of scanning one repository of a batch.
+type BatchResult struct {
+	AnalysisID string            `json:"analysis_id,omitempty"`
+	Components *ComponentsResult `json:"components,omitempty"`
+	Error      *APIError         `json:"error,omitempty"`
+}
+
+// scanBatch scans the batch's repositories, each counting against the
+// caller's rate limit. A repository failing to scan, the caller may not
+// read or past the limit, is reported with its error, it doesn't fail the
+// others.
+func scanBatch(ctx context.Context, p *BatchRequestPayload) map[string]*BatchResult {
+	parallel := min(len(p.Repos), batchScanConcurrency)
+	opts := p.Scan.scanOptions()
+	opts.fromRequest(ctx)
+	opts.Concurrency = max(scanConcurrency(p.Concurrency)/parallel, 1)
+
+	results := make([]*BatchResult, len(p.Repos))
+	eg := errgroup.Group{}
+	eg.SetLimit(parallel)
+	for i, repo := range p.Repos {
+		i, repo := i, repo
+		eg.Go(func() error {
+			if err := checkScanAccess(ctx, repo.Username, repo.Repo, p.Token); err != nil {
+				results[i] = &BatchResult{Error: toAPIError(err)}
+				return nil
+			}
+			if err := takeRepoScan(ctx); err != nil {
+				results[i] = &BatchResult{Error: toAPIError(err)}
+				return nil
+			}
+			opts, err := withCallerToken(ctx, opts, repo.Username, repo.Repo, p.Token)
+			if err != nil {
+				results[i] = &BatchResult{Error: toAPIError(err)}
+				return nil
+			}
+			opts.Ref = repo.Ref
+			result, err := ProcessRepository(repo.Username, repo.Repo, opts)
+			var analysis *Analysis
+			if err == nil {
+				analysis, err = recordAnalysis(ctx, repo.Username, repo.Repo, result, opts)
+			}
+			if err != nil {
+				results[i] = &BatchResult{Error: toAPIError(err)}
+			} else {
+				results[i] = &BatchResult{AnalysisID: analysis.ID, Components: result}
+			}
+			return nil
+		})
+	}
+	eg.Wait()
+
+	byKey := make(map[string]*BatchResult, len(results))
+	for i, repo := range p.Repos {
+		byKey[repo.key()] = results[i]
+	}
+	return byKey
+}
+
+// withCallerToken returns opts scanning owner/repo with token, or else with
+// the stored token of the caller for it, as scanAndRespond does: the logged
+// in user's, or its tenant's.
+func withCallerToken(ctx context.Context, opts ScanOptions, owner, repo, token string) (ScanOptions, error) {
+	p := RequestPayload{Username: owner, Repo: repo, Token: token}
+	if err := p.withStoredToken(ctx); err != nil {
+		return opts, err
+	}
+	opts.Token, opts.origin.tokenSource = p.Token, p.tokenSource
+	return opts, nil
+}
+
+// handleBatchRequest scans several repositories in one request, e.g. the
+// apps a CI pipeline covers, and returns each result keyed by
+// "owner/repo", or "owner/repo@ref" when a ref is given.
+func handleBatchRequest(c *gin.Context) {
+	var payload BatchRequestPayload
+	if err := c.BindJSON(&payload); err != nil {
+		respondError(c, errBadRequest("%v", err))
+		return
+	}
+	if len(payload.Repos) == 0 {
+		respondError(c, errBadRequest("repos is required"))
+		return
+	}
+	if len(payload.Repos) > maxBatchRepos {
+		respondError(c, errBadRequest("a batch can't scan more than %d repositories", maxBatchRepos))
+		return
+	}
+	seen := make(map[string]bool, len(payload.Repos))
+	for _, repo := range payload.Repos {
+		if err := validateRepo("username", repo.Username, repo.Repo); err != nil {
+			respondError(c, err)
+			return
+		}
+		key := strings.ToLower(repo.key())
+		if seen[key] {
+			respondError(c, errBadRequest("repository %s is listed twice", repo.key()))
+			return
+		}
+		seen[key] = true
+	}
+	if payload.Scan.Verify {
+		respondError(c, errBadRequest("batch scans can't verify deletions"))
+		return
+	}
+	if err := payload.Scan.validateOptions(); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	results := scanBatch(accessContext(c), &payload)
+	failed := 0
+	for _, r := range results {
+		if r.Error != nil {
+			failed++
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results, "failed": failed})
+}
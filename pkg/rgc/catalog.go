@@ -0,0 +1,149 @@
+package rgc
+
+import (
+	"html/template"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/igorfelipeduca/rgc/internal/jsparse"
+)
+
+// CatalogEntry describes one component of the inventory.
+type CatalogEntry struct {
+	Name   string   `json:"name"`
+	Path   string   `json:"path"`
+	Owners []string `json:"owners,omitempty"`
+	// Status is "used", "unused", "test_only", "storybook_only", "e2e_only"
+	// or "possibly_used".
+	Status string `json:"status"`
+	// UsageCount is the number of components importing this one.
+	UsageCount int            `json:"usage_count"`
+	Props      []jsparse.Prop `json:"props,omitempty"`
+	PropUsage  *PropUsage     `json:"prop_usage,omitempty"`
+	PreviewURL string         `json:"preview_url,omitempty"`
+	// Size and LastCommit are set when the scan asked for metadata.
+	Size       int         `json:"size,omitempty"`
+	LastCommit *LastCommit `json:"last_commit,omitempty"`
+	// Confidence is set on unused components.
+	Confidence *Confidence `json:"confidence,omitempty"`
+	// Metrics are set when the scan asked for complexity.
+	Metrics *ComponentMetrics `json:"metrics,omitempty"`
+}
+
+// Catalog is a component inventory built from an analysis, to feed a
+// design-system catalog or to be served as a static page.
+type Catalog struct {
+	Repository  string         `json:"repository"`
+	Commit      string         `json:"commit,omitempty"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	Components  []CatalogEntry `json:"components"`
+}
+
+func buildCatalog(title string, result *ComponentsResult) *Catalog {
+	catalog := &Catalog{Repository: title, GeneratedAt: time.Now().UTC(), Components: []CatalogEntry{}}
+	if result.Meta != nil {
+		catalog.Commit = result.Meta.HeadSHA
+	}
+
+	usage := make(map[string]int)
+	buckets := []struct {
+		status string
+		nodes  []*ComponentNode
+	}{
+		{"used", result.Used},
+		{"unused", result.Unused},
+		{"test_only", result.TestOnly},
+		{"storybook_only", result.StorybookOnly},
+		{"e2e_only", result.E2EOnly},
+		{"possibly_used", result.PossiblyUsed},
+	}
+	for _, bucket := range buckets {
+		for _, node := range bucket.nodes {
+			for _, child := range node.Children {
+				usage[child.Component.Path]++
+			}
+		}
+	}
+
+	for _, bucket := range buckets {
+		for _, node := range bucket.nodes {
+			catalog.Components = append(catalog.Components, CatalogEntry{
+				Name:       node.Component.Name,
+				Path:       node.Component.Path,
+				Owners:     node.Owners,
+				Status:     bucket.status,
+				UsageCount: usage[node.Component.Path],
+				Props:      node.Props,
+				PropUsage:  node.PropUsage,
+				PreviewURL: previewURL(result.Meta, node.Component.Path),
+				Size:       node.Size,
+				LastCommit: node.LastCommit,
+				Confidence: node.Confidence,
+				Metrics:    node.Metrics,
+			})
+		}
+	}
+	sort.Slice(catalog.Components, func(i, j int) bool {
+		return catalog.Components[i].Path < catalog.Components[j].Path
+	})
+	return catalog
+}
+
+// previewURL links to the component's source on GitHub, pinned to the
+// analyzed commit.
+func previewURL(meta *RepoMeta, p string) string {
+	if meta == nil || meta.FullName == "" || meta.HeadSHA == "" {
+		return ""
+	}
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return "https://github.com/" + meta.FullName + "/blob/" + meta.HeadSHA + "/" + strings.Join(segments, "/")
+}
+
+var catalogTemplate = template.Must(template.New("catalog").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Repository}} components</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem; color: #1f2328; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: .5rem; border-bottom: 1px solid #d0d7de; vertical-align: top; }
+code { font-size: .85em; }
+.status { font-weight: 600; }
+.used { color: #1a7f37; }
+.unused { color: #cf222e; }
+.test_only, .storybook_only, .e2e_only { color: #9a6700; }
+.optional { color: #656d76; }
+</style>
+</head>
+<body>
+<h1>{{.Repository}}</h1>
+<p>{{len .Components}} components{{if .Commit}} at <code>{{.Commit}}</code>{{end}}, generated {{.GeneratedAt.Format "2006-01-02 15:04 MST"}}.</p>
+<table>
+<thead><tr><th>Component</th><th>Status</th><th>Used by</th><th>Owners</th><th>Props</th></tr></thead>
+<tbody>
+{{- range .Components}}
+<tr>
+<td>{{if .PreviewURL}}<a href="{{.PreviewURL}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}<br><code>{{.Path}}</code></td>
+<td class="status {{.Status}}">{{.Status}}</td>
+<td>{{.UsageCount}}</td>
+<td>{{range $i, $o := .Owners}}{{if $i}}, {{end}}{{$o}}{{end}}</td>
+<td>{{range .Props}}<code{{if not .Required}} class="optional"{{end}}>{{.Name}}{{if not .Required}}?{{end}}: {{.Type}}</code><br>{{end}}</td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+</body>
+</html>
+`))
+
+// renderCatalogHTML writes the catalog as a self-contained static page.
+func renderCatalogHTML(w io.Writer, catalog *Catalog) error {
+	return catalogTemplate.Execute(w, catalog)
+}
@@ -0,0 +1,592 @@
+package rgc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-github/v39/github"
+	"github.com/igorfelipeduca/rgc/internal/jsparse"
+	"golang.org/x/sync/errgroup"
+)
+
+// CleanupRequest asks for a pull request deleting unused components.
+type CleanupRequest struct {
+	// Components are the paths of the components to delete, each reported
+	// as unused by the analysis.
+	Components []string `json:"components"`
+	// Branch is the branch to create, rgc/cleanup-<timestamp> by default.
+	Branch string `json:"branch"`
+	// Base is the branch the pull request targets, by default the branch
+	// the analysis scanned.
+	Base string `json:"base"`
+	// Token is an optional GitHub token used instead of the server's GITHUB_TOKEN.
+	Token string `json:"token"`
+}
+
+// CleanupPlan lists the files deleting components takes.
+type CleanupPlan struct {
+	Components []string `json:"components"`
+	// Orphans are the tests, stories, snapshots and styles named after a
+	// deleted component and sitting next to it.
+	Orphans []string `json:"orphans"`
+	// Barrels are the index files re-exporting deleted files, whose
+	// re-exports of them are removed.
+	Barrels []string `json:"barrels"`
+	// Kept are the files named after a deleted component that stay because
+	// a file not being deleted imports them, or because they import another
+	// component, e.g. a shared test.
+	Kept []string `json:"kept,omitempty"`
+
+	barrelEdits map[string]barrelEdit
+}
+
+// barrelEdit is a barrel's content and the lines to remove from it,
+// counting from 1.
+type barrelEdit struct {
+	content string
+	remove  map[int]bool
+}
+
+// edited returns the barrel's content without the removed lines.
+func (e barrelEdit) edited() string {
+	var b strings.Builder
+	for i, line := range strings.SplitAfter(e.content, "\n") {
+		if !e.remove[i+1] {
+			b.WriteString(line)
+		}
+	}
+	return b.String()
+}
+
+// files returns every file the plan deletes.
+func (p *CleanupPlan) files() []string {
+	return append(append([]string{}, p.Components...), p.Orphans...)
+}
+
+// CleanupPullRequest is a pull request opened to delete unused components.
+type CleanupPullRequest struct {
+	Number   int          `json:"number"`
+	URL      string       `json:"url"`
+	Branch   string       `json:"branch"`
+	Plan     *CleanupPlan `json:"plan"`
+	Warnings []string     `json:"warnings,omitempty"`
+}
+
+// planCleanup checks every path names an unused component of result and
+// finds their orphans among files.
+func planCleanup(result *ComponentsResult, paths, files []string) (*CleanupPlan, error) {
+	unused := make(map[string]bool, len(result.Unused))
+	for _, node := range result.Unused {
+		unused[node.Component.Path] = true
+	}
+
+	plan := &CleanupPlan{Components: []string{}, Orphans: []string{}, Barrels: []string{}}
+	deleting := make(map[string]bool)
+	stems := make(map[string]bool)
+	var notUnused []string
+	for _, p := range paths {
+		if deleting[p] {
+			continue
+		}
+		if !unused[p] {
+			notUnused = append(notUnused, p)
+			continue
+		}
+		deleting[p] = true
+		stems[path.Join(path.Dir(p), fileStem(p))] = true
+		plan.Components = append(plan.Components, p)
+	}
+	if len(notUnused) > 0 {
+		return nil, &APIError{Status: http.StatusBadRequest, Code: codeInvalidRequest,
+			Message: "only components the analysis reports as unused can be deleted",
+			Details: gin.H{"components": notUnused}}
+	}
+	if len(plan.Components) == 0 {
+		return nil, errBadRequest("components must list at least one unused component")
+	}
+
+	for _, f := range files {
+		if deleting[f] || !isCompanionFile(f) {
+			continue
+		}
+		// Tests and stories may sit in __tests__ and the like next to the component.
+		dir := path.Dir(f)
+		for base := path.Base(dir); strings.HasPrefix(base, "__") && strings.HasSuffix(base, "__"); base = path.Base(dir) {
+			dir = path.Dir(dir)
+		}
+		if stems[path.Join(dir, fileStem(f))] {
+			plan.Orphans = append(plan.Orphans, f)
+		}
+	}
+	sort.Strings(plan.Components)
+	sort.Strings(plan.Orphans)
+	return plan, nil
+}
+
+// findBarrels finds the index files of the deleted files' directories and
+// their parents that re-export a deleted file, and plans removing those
+// re-exports so the barrels don't point at missing files.
+func (p *CleanupPlan) findBarrels(ctx context.Context, src Source, files []string) error {
+	// A scan resolves specifiers the same way the analysis did.
+	sc := &scan{fileIndex: make(map[string]string, len(files))}
+	for _, f := range files {
+		sc.fileIndex[sc.nameKey(f)] = f
+	}
+	deleted := make(map[string]bool)
+	for _, f := range p.files() {
+		deleted[f] = true
+	}
+
+	candidates := make(map[string]bool)
+	for f := range deleted {
+		for dir := path.Dir(f); ; dir = path.Dir(dir) {
+			for _, ext := range moduleExtensions {
+				if barrel := path.Join(dir, "index"+ext); sc.fileIndex[sc.nameKey(barrel)] != "" && !deleted[barrel] {
+					candidates[barrel] = true
+				}
+			}
+			if dir == "." || dir == "/" {
+				break
+			}
+		}
+	}
+
+	p.barrelEdits = make(map[string]barrelEdit)
+	for _, barrel := range sortedKeys(candidates) {
+		content, err := src.ReadFile(ctx, barrel)
+		if err == errFileNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		file, err := parseFile(ctx, barrel, content)
+		if err != nil {
+			return &ParseError{Path: barrel, Err: err}
+		}
+
+		lines := strings.Split(content, "\n")
+		remove := make(map[int]bool)
+		for _, imp := range file.Imports {
+			if imp.Kind != jsparse.ReExport || !deleted[sc.resolveModule(barrel, imp.Specifier)] {
+				continue
+			}
+			// The statement runs until the line naming the module.
+			for n := imp.Line; n <= len(lines); n++ {
+				remove[n] = true
+				if strings.Contains(lines[n-1], imp.Specifier) {
+					break
+				}
+			}
+		}
+		if len(remove) > 0 {
+			p.Barrels = append(p.Barrels, barrel)
+			p.barrelEdits[barrel] = barrelEdit{content: content, remove: remove}
+		}
+	}
+	return nil
+}
+
+// keepImportedOrphans reads the repository's modules and takes back the
+// orphans something that survives the cleanup still needs: the ones a
+// file not being deleted imports, the ones importing a component that
+// isn't deleted, and the snapshots of the tests kept.
+func (p *CleanupPlan) keepImportedOrphans(ctx context.Context, src Source, result *ComponentsResult, files []string) error {
+	if len(p.Orphans) == 0 {
+		return nil
+	}
+	sc := &scan{fileIndex: make(map[string]string, len(files))}
+	for _, f := range files {
+		sc.fileIndex[sc.nameKey(f)] = f
+	}
+	deleting := make(map[string]bool, len(p.Components))
+	for _, f := range p.Components {
+		deleting[f] = true
+	}
+	orphans := make(map[string]bool, len(p.Orphans))
+	for _, f := range p.Orphans {
+		orphans[f] = true
+	}
+	components := make(map[string]bool)
+	for _, nodes := range [][]*ComponentNode{result.Used, result.Unused, result.TestOnly, result.StorybookOnly, result.E2EOnly, result.PossiblyUsed} {
+		for _, node := range nodes {
+			components[node.Component.Path] = true
+		}
+	}
+
+	// imports maps every module to the orphans and components it imports.
+	// Modules that don't mention an orphan's name can't import it, so only
+	// the orphans themselves are parsed without it.
+	var stems []string
+	for f := range orphans {
+		stems = append(stems, fileStem(f))
+	}
+	imports := make(map[string][]string)
+	var mu sync.Mutex
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.SetLimit(defaultConcurrency)
+	for _, f := range files {
+		if deleting[f] || !jsparse.Supported(f) {
+			continue
+		}
+		f := f
+		eg.Go(func() error {
+			content, err := src.ReadFile(egCtx, f)
+			if err == errFileNotFound {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if !orphans[f] && !containsAny(content, stems) {
+				return nil
+			}
+			file, err := parseFile(egCtx, f, content)
+			if err != nil {
+				return &ParseError{Path: f, Err: err}
+			}
+			var targets []string
+			for _, imp := range file.Imports {
+				if target := sc.resolveModule(f, imp.Specifier); orphans[target] || components[target] {
+					targets = append(targets, target)
+				}
+			}
+			mu.Lock()
+			imports[f] = targets
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+
+	// Keeping an orphan may keep the ones it imports, so go until nothing changes.
+	kept := make(map[string]bool)
+	survives := func(f string) bool { return !deleting[f] && (!orphans[f] || kept[f]) }
+	for changed := true; changed; {
+		changed = false
+		keep := func(f string) {
+			if orphans[f] && !kept[f] {
+				kept[f] = true
+				changed = true
+			}
+		}
+		for from, targets := range imports {
+			for _, to := range targets {
+				switch {
+				case orphans[to] && survives(from):
+					keep(to)
+				case components[to] && !deleting[to] && orphans[from]:
+					keep(from)
+				}
+			}
+		}
+		for f := range orphans {
+			if test := snapshotTest(f); test != "" && kept[test] {
+				keep(f)
+			}
+		}
+	}
+
+	if len(kept) == 0 {
+		return nil
+	}
+	remaining := []string{}
+	for _, f := range p.Orphans {
+		if kept[f] {
+			p.Kept = append(p.Kept, f)
+		} else {
+			remaining = append(remaining, f)
+		}
+	}
+	p.Orphans = remaining
+	return nil
+}
+
+// snapshotTest returns the test a Jest snapshot belongs to, e.g.
+// Button.test.tsx for __snapshots__/Button.test.tsx.snap.
+func snapshotTest(p string) string {
+	if !strings.HasSuffix(p, ".snap") || path.Base(path.Dir(p)) != "__snapshots__" {
+		return ""
+	}
+	return path.Join(path.Dir(path.Dir(p)), strings.TrimSuffix(path.Base(p), ".snap"))
+}
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileStem returns the name of the file at p up to its first dot, e.g.
+// "Button" for Button.test.tsx.
+func fileStem(p string) string {
+	stem, _, _ := strings.Cut(path.Base(p), ".")
+	return stem
+}
+
+// isCompanionFile reports whether p is the kind of file that exists for
+// a single component: its tests, stories, snapshots or styles.
+func isCompanionFile(p string) bool {
+	base := path.Base(p)
+	return isSupportFile(p) || styleExtensions[path.Ext(p)] || strings.HasSuffix(base, ".snap") ||
+		strings.Contains(base, ".styles.") || strings.Contains(base, ".styled.")
+}
+
+// buildCleanupPlan lists the repository's files at the analyzed commit and
+// plans deleting the components at paths, returning the mode of every
+// file and what couldn't be looked into.
+func buildCleanupPlan(ctx context.Context, src *githubSource, a *Analysis, paths []string) (*CleanupPlan, map[string]string, []string, error) {
+	tree, _, err := src.client.Git.GetTree(ctx, src.owner, src.repo, src.ref, true)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error getting repository tree: %w", err)
+	}
+	var files []string
+	modes := make(map[string]string)
+	for _, entry := range tree.Entries {
+		if entry.GetType() == "blob" {
+			files = append(files, entry.GetPath())
+			modes[entry.GetPath()] = entry.GetMode()
+		}
+	}
+	var warnings []string
+	if tree.GetTruncated() {
+		files = nil
+		warnings = append(warnings, "the repository is too large to look for orphaned tests, stories, styles and barrels")
+	}
+
+	plan, err := planCleanup(a.Result, paths, files)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := plan.keepImportedOrphans(ctx, src, a.Result, files); err != nil {
+		return nil, nil, nil, fmt.Errorf("error looking for imports of orphans: %w", err)
+	}
+	if err := plan.findBarrels(ctx, src, files); err != nil {
+		return nil, nil, nil, fmt.Errorf("error reading barrel files: %w", err)
+	}
+	return plan, modes, warnings, nil
+}
+
+// openCleanupPR commits the deletion of the requested components and their
+// orphans on a new branch off the analyzed commit, and opens a pull
+// request. Once it's open, failing to triage it only adds a warning.
+func openCleanupPR(ctx context.Context, token string, a *Analysis, req CleanupRequest) (*CleanupPullRequest, error) {
+	if a.Ref == "" || a.Result.Meta == nil {
+		return nil, errBadRequest("the analysis has no commit to branch from")
+	}
+	meta := a.Result.Meta
+	client := newGitHubClient(token)
+	info, err := inspectToken(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	if err := info.check(meta.Private, opWritePullRequests); err != nil {
+		return nil, err
+	}
+
+	src := &githubSource{client: client, owner: a.Owner, repo: a.Repo, ref: a.Ref}
+	plan, _, warnings, err := buildCleanupPlan(ctx, src, a, req.Components)
+	if err != nil {
+		return nil, err
+	}
+
+	branch := req.Branch
+	if branch == "" {
+		branch = "rgc/cleanup-" + time.Now().UTC().Format("20060102-150405")
+	}
+	base := req.Base
+	if base == "" {
+		base = meta.Ref
+	}
+	if base == "" {
+		base = meta.DefaultBranch
+	}
+	if _, resp, err := client.Git.GetRef(ctx, a.Owner, a.Repo, "heads/"+branch); err == nil {
+		return nil, &APIError{Status: http.StatusConflict, Code: codeInvalidRequest, Message: fmt.Sprintf("branch %s already exists", branch)}
+	} else if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return nil, fmt.Errorf("error checking branch %s: %w", branch, err)
+	}
+
+	parent, _, err := client.Git.GetCommit(ctx, a.Owner, a.Repo, a.Ref)
+	if err != nil {
+		return nil, fmt.Errorf("error getting commit %s: %w", a.Ref, err)
+	}
+	var entries []*github.TreeEntry
+	for _, p := range plan.files() {
+		// An entry without a SHA or content deletes the file.
+		entries = append(entries, &github.TreeEntry{Path: github.String(p), Mode: github.String("100644"), Type: github.String("blob")})
+	}
+	for _, p := range plan.Barrels {
+		entries = append(entries, &github.TreeEntry{
+			Path:    github.String(p),
+			Mode:    github.String("100644"),
+			Type:    github.String("blob"),
+			Content: github.String(plan.barrelEdits[p].edited()),
+		})
+	}
+	newTree, _, err := client.Git.CreateTree(ctx, a.Owner, a.Repo, parent.GetTree().GetSHA(), entries)
+	if err != nil {
+		return nil, fmt.Errorf("error creating tree: %w", err)
+	}
+	title := cleanupTitle(len(plan.Components))
+	commit, _, err := client.Git.CreateCommit(ctx, a.Owner, a.Repo, &github.Commit{
+		Message: github.String(title),
+		Tree:    newTree,
+		Parents: []*github.Commit{{SHA: parent.SHA}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating commit: %w", err)
+	}
+	_, _, err = client.Git.CreateRef(ctx, a.Owner, a.Repo, &github.Reference{
+		Ref:    github.String("refs/heads/" + branch),
+		Object: &github.GitObject{SHA: commit.SHA},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating branch %s: %w", branch, err)
+	}
+
+	pr, _, err := client.PullRequests.Create(ctx, a.Owner, a.Repo, &github.NewPullRequest{
+		Title: github.String(title),
+		Head:  github.String(branch),
+		Base:  github.String(base),
+		Body:  github.String(cleanupBody(a, plan)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error opening pull request: %w", err)
+	}
+
+	owners, err := fetchCodeowners(ctx, src)
+	if err == nil {
+		err = loadPRTriage().apply(ctx, client, a.Owner, a.Repo, pr.GetNumber(), owners, plan.files())
+	}
+	if err != nil {
+		warnings = append(warnings, err.Error())
+	}
+
+	return &CleanupPullRequest{
+		Number:   pr.GetNumber(),
+		URL:      pr.GetHTMLURL(),
+		Branch:   branch,
+		Plan:     plan,
+		Warnings: warnings,
+	}, nil
+}
+
+func cleanupTitle(components int) string {
+	if components == 1 {
+		return "Remove 1 unused component"
+	}
+	return fmt.Sprintf("Remove %d unused components", components)
+}
+
+// cleanupBody describes the pull request: the components deleted, with
+// their deletion confidence, and their orphans.
+func cleanupBody(a *Analysis, plan *CleanupPlan) string {
+	confidence := make(map[string]*Confidence)
+	for _, node := range a.Result.Unused {
+		confidence[node.Component.Path] = node.Confidence
+	}
+
+	var b strings.Builder
+	b.WriteString("RGC found nothing imports these components anymore")
+	if url := reportURL(a.Owner, a.Repo, a.ID); url != "" {
+		fmt.Fprintf(&b, " ([analysis](%s))", url)
+	}
+	b.WriteString(".\n\n| Component | Confidence |\n| --- | --- |\n")
+	for _, p := range plan.Components {
+		score := "-"
+		if c := confidence[p]; c != nil {
+			score = fmt.Sprintf("%.2f", c.Score)
+			for _, s := range c.Signals {
+				score += fmt.Sprintf(", %s", s.Kind)
+			}
+		}
+		fmt.Fprintf(&b, "| `%s` | %s |\n", p, score)
+	}
+	if len(plan.Orphans) > 0 {
+		b.WriteString("\nTheir tests, stories and styles go with them:\n\n")
+		for _, p := range plan.Orphans {
+			fmt.Fprintf(&b, "- `%s`\n", p)
+		}
+	}
+	if len(plan.Kept) > 0 {
+		b.WriteString("\nThese are named after them but still use or are used by other files, so they stay; check they don't need what is deleted:\n\n")
+		for _, p := range plan.Kept {
+			fmt.Fprintf(&b, "- `%s`\n", p)
+		}
+	}
+	if len(plan.Barrels) > 0 {
+		b.WriteString("\nTheir re-exports are removed from:\n\n")
+		for _, p := range plan.Barrels {
+			fmt.Fprintf(&b, "- `%s`\n", p)
+		}
+	}
+	return b.String()
+}
+
+// fetchCodeowners reads the repository's CODEOWNERS file, if it has one.
+func fetchCodeowners(ctx context.Context, src Source) (codeowners, error) {
+	for _, p := range codeownersPaths {
+		content, err := src.ReadFile(ctx, p)
+		if err == errFileNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return parseCodeowners(content), nil
+	}
+	return nil, nil
+}
+
+// handleCleanupRequest opens a pull request deleting unused components of
+// a past analysis, with the tests, stories and styles only they needed.
+func handleCleanupRequest(c *gin.Context) {
+	var req CleanupRequest
+	if err := c.BindJSON(&req); err != nil {
+		respondError(c, errBadRequest("%v", err))
+		return
+	}
+	a, err := analyses.get(c.Request.Context(), c.Param("owner"), c.Param("repo"), c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	token, source, err := actingToken(c.Request.Context(), a.Owner, a.Repo, req.Token)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	pr, err := openCleanupPR(c.Request.Context(), token, a, req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	loggerFrom(c.Request.Context()).Info("cleanup pull request opened", "pull_request", pr.URL, "components", len(pr.Plan.Components))
+	actor, tenant := requestActor(c.Request.Context())
+	recordAudit(c.Request.Context(), &AuditEvent{
+		Action:      auditCleanup,
+		Actor:       actor,
+		Tenant:      tenant,
+		Owner:       a.Owner,
+		Repo:        a.Repo,
+		Ref:         a.Ref,
+		AnalysisID:  a.ID,
+		PullRequest: pr.URL,
+		TokenSource: source,
+	}, token)
+	c.JSON(http.StatusCreated, pr)
+}
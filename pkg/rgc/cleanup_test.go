@@ -0,0 +1,150 @@
+package rgc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+// library has two unused components, Button and Card, with their tests,
+// stories, snapshots and styles, some of which other files still need.
+var library = fstest.MapFS{
+	"src/index.ts":                                   {Data: []byte("export * from './Button'\nexport {\n  Card,\n} from './Card'\nexport * from './CardList'\n")},
+	"src/Button.tsx":                                 {Data: []byte("export function Button() { return <button /> }\n")},
+	"src/Button.test.tsx":                            {Data: []byte("import { Button } from './Button'\nimport { ButtonGroup } from './ButtonGroup'\n")},
+	"src/Button.module.css":                          {Data: []byte(".button {}\n")},
+	"src/ButtonGroup.tsx":                            {Data: []byte("export function ButtonGroup() { return <div /> }\n")},
+	"src/Card.tsx":                                   {Data: []byte("import styles from './Card.module.css'\nexport function Card() { return <div /> }\n")},
+	"src/Card.module.css":                            {Data: []byte(".card {}\n")},
+	"src/Card.styles.ts":                             {Data: []byte("export const shadow = '0 1px 2px'\n")},
+	"src/Card.stories.tsx":                           {Data: []byte("import { Card } from './Card'\nimport { renderWith } from './test-utils'\n")},
+	"src/test-utils.ts":                              {Data: []byte("export const renderWith = () => null\n")},
+	"src/CardList.tsx":                               {Data: []byte("import { shadow } from './Card.styles'\nexport function CardList() { return <ul /> }\n")},
+	"src/__tests__/Card.test.tsx":                    {Data: []byte("import { Card } from '../Card'\n")},
+	"src/__snapshots__/Button.test.tsx.snap":         {Data: []byte("exports[`Button`] = `<button />`;\n")},
+	"src/__tests__/__snapshots__/Card.test.tsx.snap": {Data: []byte("exports[`Card`] = `<div />`;\n")},
+	"src/CardHeader.tsx":                             {Data: []byte("export function CardHeader() { return <h2 /> }\n")},
+}
+
+func libraryResult() *ComponentsResult {
+	node := func(p string) *ComponentNode { return &ComponentNode{Component: Component{Path: p}} }
+	return &ComponentsResult{
+		Used:   []*ComponentNode{node("src/ButtonGroup.tsx"), node("src/CardList.tsx"), node("src/CardHeader.tsx")},
+		Unused: []*ComponentNode{node("src/Button.tsx"), node("src/Card.tsx")},
+	}
+}
+
+func TestPlanCleanup(t *testing.T) {
+	files, err := (&fsSource{fsys: library}).ListFiles(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		paths       []string
+		wantOrphans []string
+		wantErr     bool
+	}{
+		{"button", []string{"src/Button.tsx"}, []string{"src/Button.module.css", "src/Button.test.tsx", "src/__snapshots__/Button.test.tsx.snap"}, false},
+		{"card", []string{"src/Card.tsx", "src/Card.tsx"}, []string{
+			"src/Card.module.css", "src/Card.stories.tsx", "src/Card.styles.ts",
+			"src/__tests__/Card.test.tsx", "src/__tests__/__snapshots__/Card.test.tsx.snap",
+		}, false},
+		{"used component", []string{"src/Card.tsx", "src/CardList.tsx"}, nil, true},
+		{"nothing", nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planCleanup(libraryResult(), tt.paths, files)
+			var apiErr *APIError
+			if tt.wantErr {
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("planCleanup() error = %v, want an API error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("planCleanup() error = %v", err)
+			}
+			if !reflect.DeepEqual(plan.Orphans, tt.wantOrphans) {
+				t.Errorf("Orphans = %v, want %v", plan.Orphans, tt.wantOrphans)
+			}
+		})
+	}
+}
+
+func TestKeepImportedOrphans(t *testing.T) {
+	src := &fsSource{fsys: library}
+	files, err := src.ListFiles(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		paths       []string
+		wantOrphans []string
+		wantKept    []string
+	}{
+		// The test also covers ButtonGroup, so it and its snapshot stay.
+		{"shared test", []string{"src/Button.tsx"}, []string{"src/Button.module.css"},
+			[]string{"src/Button.test.tsx", "src/__snapshots__/Button.test.tsx.snap"}},
+		// CardList imports Card.styles; the story's helper isn't a component.
+		{"imported styles", []string{"src/Card.tsx"}, []string{
+			"src/Card.module.css", "src/Card.stories.tsx",
+			"src/__tests__/Card.test.tsx", "src/__tests__/__snapshots__/Card.test.tsx.snap",
+		}, []string{"src/Card.styles.ts"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planCleanup(libraryResult(), tt.paths, files)
+			if err != nil {
+				t.Fatalf("planCleanup() error = %v", err)
+			}
+			if err := plan.keepImportedOrphans(context.Background(), src, libraryResult(), files); err != nil {
+				t.Fatalf("keepImportedOrphans() error = %v", err)
+			}
+			if !reflect.DeepEqual(plan.Orphans, tt.wantOrphans) {
+				t.Errorf("Orphans = %v, want %v", plan.Orphans, tt.wantOrphans)
+			}
+			if !reflect.DeepEqual(plan.Kept, tt.wantKept) {
+				t.Errorf("Kept = %v, want %v", plan.Kept, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestFindBarrels(t *testing.T) {
+	src := &fsSource{fsys: library}
+	files, err := src.ListFiles(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		paths []string
+		want  string
+	}{
+		{"export star", []string{"src/Button.tsx"}, "export {\n  Card,\n} from './Card'\nexport * from './CardList'\n"},
+		{"multi-line export", []string{"src/Card.tsx"}, "export * from './Button'\nexport * from './CardList'\n"},
+		{"both", []string{"src/Button.tsx", "src/Card.tsx"}, "export * from './CardList'\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planCleanup(libraryResult(), tt.paths, files)
+			if err != nil {
+				t.Fatalf("planCleanup() error = %v", err)
+			}
+			if err := plan.findBarrels(context.Background(), src, files); err != nil {
+				t.Fatalf("findBarrels() error = %v", err)
+			}
+			if want := []string{"src/index.ts"}; !reflect.DeepEqual(plan.Barrels, want) {
+				t.Fatalf("Barrels = %v, want %v", plan.Barrels, want)
+			}
+			if got := plan.barrelEdits["src/index.ts"].edited(); got != tt.want {
+				t.Errorf("edited barrel = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package rgc
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Exit codes of the scan command.
+const (
+	exitOK = 0
+	// exitGateFailed means the scan succeeded but a threshold was exceeded.
+	exitGateFailed = 1
+	// exitError means the scan couldn't run, or was called wrong.
+	exitError = 2
+)
+
+// scanGates are the thresholds that fail the scan command.
+type scanGates struct {
+	// maxUnused fails when more components are unused, unless negative.
+	maxUnused int
+	// failOnNewUnused fails when a component is unused at the scanned ref
+	// but not at base.
+	failOnNewUnused bool
+	base            string
+}
+
+// check records in the report the gates it fails.
+func (g scanGates) check(r *cliReport) {
+	if g.maxUnused >= 0 && r.Unused > g.maxUnused {
+		r.Failures = append(r.Failures, fmt.Sprintf("%d unused components, more than the %d allowed", r.Unused, g.maxUnused))
+	}
+	if n := len(r.NewUnused); n > 0 {
+		r.Failures = append(r.Failures, fmt.Sprintf("%d components are unused but weren't at %s", n, r.Base))
+	}
+}
+
+// cliReport is what the scan command prints in JSON.
+type cliReport struct {
+	Repository string `json:"repository"`
+	Ref        string `json:"ref,omitempty"`
+	Used       int    `json:"used"`
+	Unused     int    `json:"unused"`
+	// UnusedComponents are the paths of the unused components.
+	UnusedComponents []string `json:"unused_components"`
+	// EstimatedSavings is the size in bytes of the unused components and
+	// of the assets only they reference.
+	EstimatedSavings int `json:"estimated_savings"`
+	// Base and NewUnused are set with --fail-on-new-unused.
+	Base      string   `json:"base,omitempty"`
+	NewUnused []string `json:"new_unused,omitempty"`
+	// Baselined are the unused components the baseline accepts, left out of
+	// the above, and Fixed the baseline entries no longer unused.
+	Baselined []string `json:"baselined,omitempty"`
+	Fixed     []string `json:"fixed,omitempty"`
+	// Ignored are the unused components opting out with an rgc-ignore
+	// comment, never counted as unused.
+	Ignored []string `json:"ignored,omitempty"`
+	// SkippedFiles are the component files too large to parse.
+	SkippedFiles []string `json:"skipped_files,omitempty"`
+	// PossiblyUsed are the components kept out of unused by a string
+	// reference, with --string-references.
+	PossiblyUsed []string `json:"possibly_used,omitempty"`
+	// Generated counts the generated files left out of the scan.
+	Generated int `json:"generated,omitempty"`
+	// Failures are the gates the scan failed, empty when it passed.
+	Failures []string `json:"failures"`
+}
+
+// RunScan runs the scan command: it scans a repository with the server's
+// GITHUB_TOKEN, prints the unused components and exits non-zero when a
+// threshold is exceeded, to gate merges in CI. In GitHub Actions it also
+// annotates the unused components and writes a job summary.
+func RunScan(args []string) int {
+	return runScan(context.Background(), args, os.Stdout, os.Stderr)
+}
+
+func runScan(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: rgc scan [flags] owner/repo")
+		fs.PrintDefaults()
+	}
+	var (
+		payload     RequestPayload
+		gates       scanGates
+		entryPoints string
+		format      string
+		baseline    string
+		write       string
+	)
+	fs.StringVar(&payload.Ref, "ref", "", "branch, tag or commit to scan, the default branch by default")
+	fs.StringVar(&payload.Mode, "mode", "", `"api" (default) or "clone"`)
+	fs.StringVar(&payload.Path, "path", "", "only report the components under this directory")
+	fs.StringVar(&entryPoints, "entry-points", "", "comma separated entry point patterns, e.g. pages/,src/main.tsx")
+	fs.Int64Var(&payload.SkipLargeFiles.MaxSize, "skip-file-size", 0, "skip component files larger than `bytes`, negative to parse every file (default RGC_SKIP_FILE_SIZE or 1 MiB)")
+	fs.Func("skip-file-size-for", "override --skip-file-size for a path `pattern=bytes`, e.g. src/icons/=4194304; repeatable", func(v string) error {
+		pattern, size, ok := strings.Cut(v, "=")
+		n, err := strconv.ParseInt(size, 10, 64)
+		if !ok || pattern == "" || err != nil {
+			return fmt.Errorf("want pattern=bytes, got %q", v)
+		}
+		if payload.SkipLargeFiles.Paths == nil {
+			payload.SkipLargeFiles.Paths = make(map[string]int64)
+		}
+		payload.SkipLargeFiles.Paths[pattern] = n
+		return nil
+	})
+	fs.BoolVar(&payload.StringReferences, "string-references", false, "report the unused components whose name is quoted as possibly used")
+	fs.StringVar(&format, "format", "text", `"text" or "json"`)
+	fs.IntVar(&gates.maxUnused, "max-unused", -1, "fail when more than `N` components are unused")
+	fs.BoolVar(&gates.failOnNewUnused, "fail-on-new-unused", false, "fail when a component is unused but wasn't at --base")
+	fs.StringVar(&gates.base, "base", "", "ref --fail-on-new-unused compares with, the default branch by default")
+	fs.StringVar(&baseline, "baseline", "", "baseline `file` listing the accepted unused components, left out of the gates")
+	fs.StringVar(&write, "write-baseline", "", "write the unused components to a baseline `file` instead of checking the gates")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	owner, repo, ok := strings.Cut(fs.Arg(0), "/")
+	if fs.NArg() != 1 || !ok {
+		fs.Usage()
+		return exitError
+	}
+	if format != "text" && format != "json" {
+		fmt.Fprintf(stderr, "rgc: --format must be text or json, got %q\n", format)
+		return exitError
+	}
+	if entryPoints != "" {
+		payload.EntryPoints = strings.Split(entryPoints, ",")
+	}
+	if err := validateRepo("owner", owner, repo); err != nil {
+		fmt.Fprintf(stderr, "rgc: %v\n", err)
+		return exitError
+	}
+	if err := payload.validateOptions(); err != nil {
+		fmt.Fprintf(stderr, "rgc: %v\n", err)
+		return exitError
+	}
+
+	var accepted *Baseline
+	if baseline != "" {
+		var err error
+		if accepted, err = readBaseline(baseline); err != nil {
+			fmt.Fprintf(stderr, "rgc: %v\n", err)
+			return exitError
+		}
+	}
+
+	report, err := scanForCLI(ctx, owner, repo, payload, gates)
+	if err != nil {
+		fmt.Fprintf(stderr, "rgc: %v\n", err)
+		return exitError
+	}
+	if write != "" {
+		b, err := writeBaseline(write, report)
+		if err != nil {
+			fmt.Fprintf(stderr, "rgc: %v\n", err)
+			return exitError
+		}
+		fmt.Fprintf(stdout, "Wrote %d unused components to %s\n", len(b.Components), write)
+		return exitOK
+	}
+	if accepted != nil {
+		accepted.apply(report)
+	}
+	gates.check(report)
+	if inGitHubActions() {
+		// Actions reads workflow commands from both streams, keep them out
+		// of the JSON.
+		annotations := stdout
+		if format == "json" {
+			annotations = stderr
+		}
+		report.writeAnnotations(annotations)
+		if err := report.writeStepSummary(); err != nil {
+			fmt.Fprintf(stderr, "rgc: %v\n", err)
+		}
+	}
+	if format == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		report.writeText(stdout)
+	}
+	if len(report.Failures) > 0 {
+		return exitGateFailed
+	}
+	return exitOK
+}
+
+func scanForCLI(ctx context.Context, owner, repo string, payload RequestPayload, gates scanGates) (*cliReport, error) {
+	opts := payload.scanOptions()
+	opts.Logger = newLogger()
+	result, err := ProcessRepository(owner, repo, opts)
+	if err != nil {
+		return nil, err
+	}
+	report := &cliReport{
+		Repository:       owner + "/" + repo,
+		Ref:              payload.Ref,
+		Used:             result.UsedCount,
+		Unused:           result.UnusedCount,
+		UnusedComponents: []string{},
+		EstimatedSavings: result.EstimatedSavings,
+		Failures:         []string{},
+	}
+	for _, node := range result.Unused {
+		report.UnusedComponents = append(report.UnusedComponents, node.Component.Path)
+	}
+	for _, c := range result.Ignored {
+		report.Ignored = append(report.Ignored, c.Path)
+	}
+	for _, s := range result.SkippedFiles {
+		report.SkippedFiles = append(report.SkippedFiles, s.Path)
+	}
+	report.Generated = result.GeneratedCount
+	for _, node := range result.PossiblyUsed {
+		report.PossiblyUsed = append(report.PossiblyUsed, node.Component.Path)
+	}
+	if gates.failOnNewUnused {
+		base := gates.base
+		if base == "" && result.Meta != nil {
+			base = result.Meta.DefaultBranch
+		}
+		baseOpts := opts
+		baseOpts.Ref = base
+		baseResult, err := ProcessRepository(owner, repo, baseOpts)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning base %s: %w", base, err)
+		}
+		report.Base = base
+		report.NewUnused = newlyUnused(baseResult, result)
+	}
+	return report, nil
+}
+
+func (r *cliReport) writeText(w io.Writer) {
+	fmt.Fprintf(w, "%s: %d used, %d unused\n", r.Repository, r.Used, r.Unused)
+	for _, p := range r.UnusedComponents {
+		fmt.Fprintf(w, "  ✗ %s\n", p)
+	}
+	if r.EstimatedSavings > 0 {
+		fmt.Fprintf(w, "\nDeleting them saves about %d bytes of source\n", r.EstimatedSavings)
+	}
+	if len(r.Baselined) > 0 {
+		fmt.Fprintf(w, "\n%d more are accepted by the baseline\n", len(r.Baselined))
+	}
+	if len(r.Ignored) > 0 {
+		fmt.Fprintf(w, "\n%d more are ignored by rgc-ignore comments\n", len(r.Ignored))
+	}
+	if len(r.PossiblyUsed) > 0 {
+		fmt.Fprintf(w, "\n%d components are possibly used, their name is quoted:\n", len(r.PossiblyUsed))
+		for _, p := range r.PossiblyUsed {
+			fmt.Fprintf(w, "  ? %s\n", p)
+		}
+	}
+	if r.Generated > 0 {
+		fmt.Fprintf(w, "\n%d generated files were left out\n", r.Generated)
+	}
+	if len(r.SkippedFiles) > 0 {
+		fmt.Fprintf(w, "\n%d component files were too large to parse and skipped:\n", len(r.SkippedFiles))
+		for _, p := range r.SkippedFiles {
+			fmt.Fprintf(w, "  ~ %s\n", p)
+		}
+	}
+	if len(r.Fixed) > 0 {
+		fmt.Fprintf(w, "\n%d baseline entries are no longer unused, regenerate it with --write-baseline:\n", len(r.Fixed))
+		for _, p := range r.Fixed {
+			fmt.Fprintf(w, "  - %s\n", p)
+		}
+	}
+	if r.Base != "" {
+		fmt.Fprintf(w, "\nNew since %s: %d\n", r.Base, len(r.NewUnused))
+		for _, p := range r.NewUnused {
+			fmt.Fprintf(w, "  + %s\n", p)
+		}
+	}
+	if len(r.Failures) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	for _, f := range r.Failures {
+		fmt.Fprintf(w, "FAIL: %s\n", f)
+	}
+}
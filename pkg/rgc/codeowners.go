@@ -0,0 +1,72 @@
+package rgc
+
+import (
+	"bufio"
+	"context"
+	"strings"
+)
+
+// codeownersPaths are the locations GitHub reads CODEOWNERS from, in order.
+var codeownersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+type codeownersRule struct {
+	pattern []string
+	owners  []string
+}
+
+// codeowners maps repository paths to their owners following GitHub's
+// CODEOWNERS rules: the last matching pattern wins.
+type codeowners []codeownersRule
+
+func parseCodeowners(content string) codeowners {
+	var rules codeowners
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		pattern := fields[0]
+		if !strings.HasPrefix(pattern, "/") && !strings.Contains(strings.TrimSuffix(pattern, "/"), "/") {
+			// A pattern without a slash matches at any depth.
+			pattern = "**/" + pattern
+		}
+		pattern = strings.TrimPrefix(pattern, "/")
+		pattern = strings.TrimSuffix(pattern, "/")
+		rules = append(rules, codeownersRule{pattern: strings.Split(pattern, "/"), owners: fields[1:]})
+	}
+	return rules
+}
+
+// owners returns the owners of the file at p, or nil if it has none.
+func (c codeowners) owners(p string) []string {
+	segments := strings.Split(p, "/")
+	for i := len(c) - 1; i >= 0; i-- {
+		rule := c[i]
+		// A pattern naming a directory owns everything below it.
+		if matchSegments(rule.pattern, segments) || matchSegments(append(rule.pattern[:len(rule.pattern):len(rule.pattern)], "**"), segments) {
+			return rule.owners
+		}
+	}
+	return nil
+}
+
+// loadCodeowners reads the repository's CODEOWNERS file, if it has one.
+func (sc *scan) loadCodeowners(ctx context.Context) error {
+	for _, p := range codeownersPaths {
+		if _, ok := sc.fileIndex[sc.nameKey(p)]; !ok {
+			continue
+		}
+		content, err := sc.src.ReadFile(ctx, p)
+		if err != nil {
+			if err == errFileNotFound {
+				continue
+			}
+			return err
+		}
+		sc.codeowners = parseCodeowners(content)
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package rgc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/igorfelipeduca/rgc/internal/graph"
+	"github.com/igorfelipeduca/rgc/internal/jsparse"
+)
+
+// ComponentMetrics sizes up a component, to weigh dead code against the
+// complexity hot spots of the codebase.
+type ComponentMetrics struct {
+	Lines int `json:"lines"`
+	// Children counts the components it imports, and FanIn the components
+	// importing it. FanOut counts every module it imports, packages
+	// included.
+	Children int `json:"children"`
+	FanIn    int `json:"fan_in"`
+	FanOut   int `json:"fan_out"`
+	// JSXDepth is how deeply the JSX it renders nests.
+	JSXDepth int `json:"jsx_depth"`
+}
+
+// measure records the metrics of the component at p its own source tells:
+// its lines, the modules it imports and its JSX depth.
+func (sc *scan) measure(ctx context.Context, p, content string) error {
+	m := &ComponentMetrics{Lines: strings.Count(content, "\n")}
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		m.Lines++
+	}
+	if jsparse.Supported(p) {
+		file, err := parseFile(ctx, p, content)
+		if err != nil {
+			return &ParseError{Path: p, Err: err}
+		}
+		specifiers := make(map[string]bool)
+		for _, imp := range file.Imports {
+			if imp.IsRuntime() {
+				specifiers[imp.Specifier] = true
+			}
+		}
+		m.FanOut = len(specifiers)
+		if m.JSXDepth, err = jsparse.JSXDepth(ctx, p, []byte(content)); err != nil {
+			return &ParseError{Path: p, Err: err}
+		}
+	}
+	sc.mu.Lock()
+	sc.metrics[p] = m
+	sc.mu.Unlock()
+	return nil
+}
+
+// setMetrics sets the metrics of the components listed in the result,
+// counting their children and importers in the component graph.
+func (sc *scan) setMetrics(g *graph.Graph, buckets ...[]*ComponentNode) {
+	for _, nodes := range buckets {
+		for _, node := range nodes {
+			p := node.Component.Path
+			measured, ok := sc.metrics[p]
+			if !ok {
+				continue
+			}
+			m := *measured
+			m.Children = g.OutDegree(p)
+			m.FanIn = g.InDegree(p)
+			node.Metrics = &m
+		}
+	}
+}
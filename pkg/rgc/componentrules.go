@@ -0,0 +1,182 @@
+package rgc
+
+import (
+	"context"
+	"path"
+	"slices"
+	"unicode"
+
+	"github.com/igorfelipeduca/rgc/internal/jsparse"
+	"golang.org/x/sync/errgroup"
+)
+
+// ComponentRules narrows down which files are components. Without rules,
+// every .tsx, .jsx, .svelte and .component.ts file other than tests,
+// stories and end-to-end specs is one.
+type ComponentRules struct {
+	// Extensions replaces the extensions of React components, .tsx and .jsx.
+	Extensions []string `json:"extensions,omitempty"`
+	// PascalCase only counts files named in PascalCase, like Button.tsx, so
+	// types.tsx or useModal.tsx aren't components.
+	PascalCase bool `json:"pascal_case,omitempty"`
+	// Directories are path patterns components must be under, e.g.
+	// "src/components/" or "packages/*/src/**".
+	Directories []string `json:"directories,omitempty"`
+	// Exports is the export a React component file must have: "default"
+	// for a default export, "named" for a PascalCase named export, or
+	// empty for any.
+	Exports string `json:"exports,omitempty"`
+	// Allow are path patterns always taken for components, whatever the
+	// other rules say; Deny are never components.
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// validate checks the rules that can be refused before scanning.
+func (r ComponentRules) validate() error {
+	switch r.Exports {
+	case "", "default", "named":
+	default:
+		return errBadRequest("component_rules.exports must be \"default\" or \"named\"")
+	}
+	for _, ext := range r.Extensions {
+		if len(ext) < 2 || ext[0] != '.' {
+			return errBadRequest("component_rules.extensions must start with a dot, got %q", ext)
+		}
+	}
+	return nil
+}
+
+// isComponent reports whether p is a component under the scan's rules.
+func (sc *scan) isComponent(p string) bool {
+	r := sc.rules
+	if sc.rejected[p] {
+		return false
+	}
+	react := !isAngularComponent(p) && path.Ext(p) != ".svelte"
+	if sc.plainComponents[p] {
+		// Defined without JSX, see findPlainComponents.
+	} else if react && len(r.Extensions) > 0 {
+		if isSupportFile(p) || !analyzerEnabled("react") || !slices.Contains(r.Extensions, path.Ext(p)) {
+			return false
+		}
+	} else if !isComponent(p) {
+		return false
+	}
+
+	for _, pattern := range r.Deny {
+		if matchPath(pattern, p) {
+			return false
+		}
+	}
+	for _, pattern := range r.Allow {
+		if matchPath(pattern, p) {
+			return true
+		}
+	}
+	if len(r.Directories) > 0 && !slices.ContainsFunc(r.Directories, func(pattern string) bool {
+		return matchDirectory(pattern, p)
+	}) {
+		return false
+	}
+	if r.PascalCase && !isAngularComponent(p) && !isPascalCase(extractComponentName(p)) {
+		return false
+	}
+	return true
+}
+
+// matchDirectory reports whether p matches pattern or is under a directory
+// matching it.
+func matchDirectory(pattern, p string) bool {
+	for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+		if matchPath(pattern, dir) {
+			return true
+		}
+	}
+	return matchPath(pattern, p)
+}
+
+func isPascalCase(name string) bool {
+	for i, r := range name {
+		if i == 0 && !unicode.IsUpper(r) || !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// checkComponentExports rejects the React components without the export
+// the rules require, unless allowed explicitly. Their sources are kept for
+// building the component tree, so they're only read once.
+func (sc *scan) checkComponentExports(ctx context.Context) error {
+	if sc.rules.Exports == "" {
+		return nil
+	}
+	if sc.prefetched == nil {
+		sc.prefetched = make(map[string]string)
+	}
+	sc.rejected = make(map[string]bool)
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(sc.concurrency)
+	for _, component := range sc.createdComponents {
+		p := component.Path
+		if isAngularComponent(p) || path.Ext(p) == ".svelte" || !jsparse.Supported(p) ||
+			slices.ContainsFunc(sc.rules.Allow, func(pattern string) bool { return matchPath(pattern, p) }) {
+			continue
+		}
+		sc.mu.Lock()
+		content, read := sc.prefetched[p]
+		sc.mu.Unlock()
+		eg.Go(func() error {
+			if !read {
+				var err error
+				content, err = sc.src.ReadFile(ctx, p)
+				if err == errFileNotFound {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+			}
+			file, err := parseFile(ctx, p, content)
+			if err != nil {
+				return &ParseError{Path: p, Err: err}
+			}
+			ok := slices.ContainsFunc(file.Exports, func(e jsparse.Export) bool {
+				if sc.rules.Exports == "default" {
+					return e.Name == "default"
+				}
+				return e.Name != "default" && isPascalCase(e.Name)
+			})
+
+			sc.mu.Lock()
+			defer sc.mu.Unlock()
+			if ok {
+				sc.prefetched[p] = content
+			} else {
+				sc.rejected[p] = true
+			}
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+	for p := range sc.rejected {
+		sc.removeComponent(p)
+	}
+	return nil
+}
+
+// readComponent reads a component's source, taking it from the sources
+// already read when it is there.
+func (sc *scan) readComponent(ctx context.Context, p string) (string, error) {
+	sc.mu.Lock()
+	content, ok := sc.prefetched[p]
+	delete(sc.prefetched, p)
+	sc.mu.Unlock()
+	if ok {
+		return content, nil
+	}
+	return sc.src.ReadFile(ctx, p)
+}
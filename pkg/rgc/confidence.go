@@ -0,0 +1,223 @@
+package rgc
+
+import (
+	"context"
+	"math"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/igorfelipeduca/rgc/internal/jsparse"
+	"golang.org/x/sync/errgroup"
+)
+
+// Confidence estimates how safe deleting an unused component likely is.
+type Confidence struct {
+	// Score goes from 0 to 1, the higher the safer. Every signal found
+	// lowers it by its weight.
+	Score   float64            `json:"score"`
+	Signals []ConfidenceSignal `json:"signals,omitempty"`
+}
+
+// ConfidenceSignal is a hint that an unused component may be in use after
+// all, in a way static analysis can't follow.
+type ConfidenceSignal struct {
+	// Kind is "dynamic_import", "string_reference", "tested" or
+	// "recently_changed".
+	Kind   string  `json:"kind"`
+	Detail string  `json:"detail"`
+	Weight float64 `json:"weight"`
+	// Files are the files the signal was found in.
+	Files []string `json:"files,omitempty"`
+}
+
+const (
+	// weightDynamicImport applies when an import computed at runtime may
+	// load the component's directory, weightAnyDynamicImport when one could
+	// load anything.
+	weightDynamicImport    = 0.5
+	weightAnyDynamicImport = 0.15
+	weightStringReference  = 0.3
+	weightTested           = 0.1
+	weightRecentlyChanged  = 0.2
+
+	// recentChange is how long after its last commit a component counts as
+	// being worked on, perhaps not wired up yet.
+	recentChange = 30 * 24 * time.Hour
+	// maxSignalFiles bounds the files listed in a signal.
+	maxSignalFiles = 3
+)
+
+// quotedNameRegex finds string literals that could name a component.
+var quotedNameRegex = regexp.MustCompile(`["'` + "`" + `]([A-Z][A-Za-z0-9_]*)["'` + "`" + `]`)
+
+func (c *Confidence) add(kind, detail string, weight float64, files []string) {
+	if len(files) > maxSignalFiles {
+		files = files[:maxSignalFiles]
+	}
+	c.Signals = append(c.Signals, ConfidenceSignal{Kind: kind, Detail: detail, Weight: weight, Files: files})
+	c.Score = math.Max(0, math.Round((c.Score-weight)*100)/100)
+}
+
+// recordReferences notes the imports computed at runtime and the quoted
+// component-like names of a parsed file, for scoring deletions. Tests,
+// stories and specs don't ship, so their computed imports don't count.
+func (sc *scan) recordReferences(p, content string, file *jsparse.File) {
+	matches := quotedNameRegex.FindAllStringSubmatch(content, -1)
+	var computed []string
+	if !isSupportFile(p) {
+		computed = file.ComputedImports
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for _, prefix := range computed {
+		target := ""
+		if strings.HasPrefix(prefix, ".") {
+			target = path.Join(path.Dir(p), prefix)
+			if strings.HasSuffix(prefix, "/") {
+				target += "/"
+			}
+		}
+		addReference(sc.computedImports, target, p)
+	}
+	for _, m := range matches {
+		addReference(sc.stringRefs, m[1], p)
+	}
+}
+
+// readStringReferences notes the quoted component-like names of every other
+// shipped source file, JSON included, so that registries mapping names to
+// components (componentMap['HeroBanner']) outside component files are seen.
+func (sc *scan) readStringReferences(ctx context.Context) error {
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(sc.concurrency)
+	for _, p := range sc.files {
+		switch ext := path.Ext(p); {
+		case sc.isComponent(p) || isSupportFile(p) || sc.isGenerated(p):
+			continue // Components were read with the tree
+		case jsparse.Supported(p) || ext == ".json" || ext == ".mdx" || ext == ".vue":
+		default:
+			continue
+		}
+		p := p
+		eg.Go(func() error {
+			content, err := sc.src.ReadFile(ctx, p)
+			if err != nil {
+				if err == errFileNotFound {
+					return nil
+				}
+				return err
+			}
+			matches := quotedNameRegex.FindAllStringSubmatch(content, -1)
+			sc.mu.Lock()
+			defer sc.mu.Unlock()
+			for _, m := range matches {
+				addReference(sc.stringRefs, m[1], p)
+			}
+			return nil
+		})
+	}
+	return eg.Wait()
+}
+
+// splitPossiblyUsed moves the unused components whose name a shipped file
+// quotes out of unused, keeping the order of both.
+func splitPossiblyUsed(unused []*ComponentNode) (rest, possiblyUsed []*ComponentNode) {
+	rest = []*ComponentNode{}
+	for _, node := range unused {
+		if node.Confidence != nil && slices.ContainsFunc(node.Confidence.Signals, func(s ConfidenceSignal) bool {
+			return s.Kind == "string_reference"
+		}) {
+			possiblyUsed = append(possiblyUsed, node)
+		} else {
+			rest = append(rest, node)
+		}
+	}
+	return rest, possiblyUsed
+}
+
+// addReference notes that file p refers to key. Files are read
+// concurrently, so the same file may come back in any order.
+func addReference(refs map[string]map[string]bool, key, p string) {
+	if refs[key] == nil {
+		refs[key] = make(map[string]bool)
+	}
+	refs[key][p] = true
+}
+
+// scoreDeletions sets the confidence of every unused component from the
+// signals the scan gathered. Names quoted in the dead files, the unused
+// components and those only tests, stories or specs use, don't count.
+func (sc *scan) scoreDeletions(unused []*ComponentNode, dead map[string]bool) {
+	prefixes := make([]string, 0, len(sc.computedImports))
+	for prefix := range sc.computedImports {
+		if prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	sort.Strings(prefixes)
+
+	testNames := make(map[string]bool)
+	for _, p := range sc.files {
+		if isTestFile(p) {
+			name := strings.TrimSuffix(path.Base(p), path.Ext(p))
+			name = strings.TrimSuffix(strings.TrimSuffix(name, ".test"), ".spec")
+			testNames[name] = true
+		}
+	}
+
+	for _, node := range unused {
+		c := &Confidence{Score: 1}
+		name, p := node.Component.Name, node.Component.Path
+
+		dynamic := false
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(p, prefix) {
+				c.add("dynamic_import", "an import computed at runtime may load it", weightDynamicImport, sortedKeys(sc.computedImports[prefix]))
+				dynamic = true
+				break
+			}
+		}
+		if files := sortedKeys(sc.computedImports[""]); !dynamic && len(files) > 0 {
+			c.add("dynamic_import", "the repository imports paths computed at runtime", weightAnyDynamicImport, files)
+		}
+
+		var shipped, tests []string
+		for _, f := range sortedKeys(sc.stringRefs[name]) {
+			switch {
+			case dead[f]:
+			case isTestFile(f):
+				tests = append(tests, f)
+			case !isSupportFile(f):
+				shipped = append(shipped, f)
+			}
+		}
+		if len(shipped) > 0 {
+			c.add("string_reference", "its name is quoted, e.g. in a registry or a lazy-loading map", weightStringReference, shipped)
+		}
+		if len(tests) > 0 || testNames[name] {
+			c.add("tested", "it has tests, so someone still cares about it", weightTested, tests)
+		}
+		node.Confidence = c
+	}
+}
+
+// scoreRecency lowers the confidence of unused components changed lately,
+// which may be work in progress not wired up yet. It needs the last
+// commits, so it runs once the file history is known.
+func scoreRecency(unused []*ComponentNode, now time.Time) {
+	for _, node := range unused {
+		if node.Confidence == nil || node.LastCommit == nil || now.Sub(node.LastCommit.Date) > recentChange {
+			continue
+		}
+		detail := "changed " + node.LastCommit.Date.Format("2006-01-02")
+		if node.LastCommit.Author != "" {
+			detail += " by " + node.LastCommit.Author
+		}
+		node.Confidence.add("recently_changed", detail, weightRecentlyChanged, nil)
+	}
+}
@@ -0,0 +1,148 @@
+package rgc
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// analyzer describes a language analyzer. RulesVersion is bumped whenever
+// the analyzer's matching rules change, so results from different versions
+// can be told apart.
+type analyzer struct {
+	Name         string   `json:"name"`
+	Extensions   []string `json:"extensions"`
+	RulesVersion int      `json:"rules_version"`
+	Enabled      bool     `json:"enabled"`
+}
+
+var analyzers = []analyzer{
+	{Name: "react", Extensions: []string{".tsx", ".jsx"}, RulesVersion: 15},
+	{Name: "svelte", Extensions: []string{".svelte"}, RulesVersion: 8},
+	{Name: "angular", Extensions: []string{".component.ts"}, RulesVersion: 5},
+	{Name: "mdx", Extensions: []string{".mdx"}, RulesVersion: 1},
+}
+
+// analyzerEnabled reports whether the named analyzer is switched on.
+// RGC_ANALYZERS holds a comma separated list of analyzers to run; all of
+// them run when it's unset.
+func analyzerEnabled(name string) bool {
+	enabled := os.Getenv("RGC_ANALYZERS")
+	if enabled == "" {
+		return true
+	}
+	for _, n := range strings.Split(enabled, ",") {
+		if strings.TrimSpace(n) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// redact hides a secret while still showing whether it's set.
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "[redacted]"
+}
+
+// requireAdmin only lets through requests bearing RGC_ADMIN_TOKEN. Admin
+// endpoints are disabled altogether when it isn't set.
+func requireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminToken := os.Getenv("RGC_ADMIN_TOKEN")
+		if adminToken == "" {
+			respondError(c, errNotFound("admin endpoints are disabled"))
+			return
+		}
+		given := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(adminToken)) != 1 {
+			respondError(c, &APIError{Status: http.StatusUnauthorized, Code: codeUnauthorized, Message: "invalid admin token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// handleConfigRequest returns the configuration this instance actually runs
+// with, secrets redacted.
+func handleConfigRequest(c *gin.Context) {
+	sandbox, err := loadSandboxConfig()
+	var sandboxErr string
+	if err != nil {
+		sandboxErr = err.Error()
+	}
+
+	active := make([]analyzer, len(analyzers))
+	for i, a := range analyzers {
+		a.Enabled = analyzerEnabled(a.Name)
+		active[i] = a
+	}
+
+	limits, _ := serverLimits()
+
+	sharedETagTransport.mu.Lock()
+	etagEntries := len(sharedETagTransport.entries)
+	sharedETagTransport.mu.Unlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"github": gin.H{
+			"token": redact(os.Getenv("GITHUB_TOKEN")),
+		},
+		"admin": gin.H{
+			"token": redact(os.Getenv("RGC_ADMIN_TOKEN")),
+		},
+		"scan": gin.H{
+			"limits":              limits,
+			"default_concurrency": scanConcurrency(0),
+			"max_concurrency":     maxConcurrency,
+			"refuse_archived":     refuseArchived(),
+			"skip_file_size":      skipFileSize(),
+		},
+		"verify": gin.H{
+			"command":         verifyCommand(),
+			"install_command": verifyInstallCommand(),
+			"timeout":         verifyTimeout().String(),
+		},
+		"sandbox": gin.H{
+			"config": sandbox,
+			"error":  sandboxErr,
+		},
+		"transport": gin.H{
+			"http2":                   githubTransport.ForceAttemptHTTP2,
+			"max_idle_conns_per_host": githubTransport.MaxIdleConnsPerHost,
+			"idle_conn_timeout":       githubTransport.IdleConnTimeout.String(),
+			"response_header_timeout": githubTransport.ResponseHeaderTimeout.String(),
+		},
+		"etag_cache": gin.H{
+			"entries":     etagEntries,
+			"max_entries": maxETagEntries,
+		},
+		"history": gin.H{
+			"storage":               redactURL(storageURL()),
+			"max_analyses_per_repo": maxAnalysesPerRepo,
+			"access_control":        repoAccessEnforced(),
+		},
+		"commit_statuses": gin.H{
+			"context":    statusContext,
+			"public_url": os.Getenv("RGC_PUBLIC_URL"),
+		},
+		"scheduler": gin.H{
+			"enabled": schedulerEnabled(),
+		},
+		"digest": digestConfig(),
+		"jira":   jiraSettings(),
+		"vault":  vaultSettings(),
+		"oauth":  oauthSettingsJSON(),
+		"post_processors": gin.H{
+			"configured": configuredPostProcessors(),
+			"available":  postProcessorNames(),
+		},
+		"pull_requests": loadPRTriage(),
+		"analyzers":     active,
+	})
+}
@@ -0,0 +1,155 @@
+package rgc
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"io/fs"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// demoFiles holds small synthetic projects showing what RGC reports,
+// analyzed on first request so the output always matches the running
+// analyzers, and the stored analyses of public repositories in fixtures.
+//
+//go:embed demo
+var demoFiles embed.FS
+
+// demoProject is a project served by the demo endpoints: a synthetic one,
+// or a public repository analyzed at Commit.
+type demoProject struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	EntryPoints []string `json:"entry_points"`
+	// Synthetic is set on the projects written for the demo.
+	Synthetic  bool       `json:"synthetic"`
+	Repository string     `json:"repository,omitempty"`
+	Commit     string     `json:"commit,omitempty"`
+	AnalyzedAt *time.Time `json:"analyzed_at,omitempty"`
+
+	mu     sync.Mutex
+	result *ComponentsResult
+}
+
+var demoProjects = map[string]*demoProject{
+	"nextjs-blog": {
+		Name:        "nextjs-blog",
+		Description: "A Next.js blog: routed pages, a component dropped in a redesign and one only its Storybook story uses",
+		EntryPoints: []string{"pages/"},
+		Synthetic:   true,
+	},
+	"vite-dashboard": {
+		Name:        "vite-dashboard",
+		Description: "A Vite app with React Router, a lazy loaded chart, a ui barrel and a component only a Cypress spec still exercises",
+		EntryPoints: []string{"src/main.tsx"},
+		Synthetic:   true,
+	},
+	"svelte-shop": {
+		Name:        "svelte-shop",
+		Description: "A Svelte store front with a component imported but never rendered",
+		EntryPoints: []string{"src/App.svelte"},
+		Synthetic:   true,
+	},
+}
+
+// fsSource reads a repository from a file system.
+type fsSource struct {
+	fsys fs.FS
+}
+
+func (s *fsSource) ListFiles(ctx context.Context) ([]string, error) {
+	var files []string
+	err := fs.WalkDir(s.fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func (s *fsSource) ReadFile(ctx context.Context, p string) (string, error) {
+	content, err := fs.ReadFile(s.fsys, p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", errFileNotFound
+	}
+	return string(content), err
+}
+
+// analyze returns the project's analysis, running it the first time for a
+// synthetic project; fixtures come with theirs. A failed analysis, a
+// timeout on a loaded server say, isn't kept: the next request runs it
+// again.
+func (d *demoProject) analyze() (*ComponentsResult, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.result != nil {
+		return d.result, nil
+	}
+	fsys, err := fs.Sub(demoFiles, "demo/"+d.Name)
+	if err != nil {
+		return nil, err
+	}
+	limits, _ := serverLimits()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(limits.Timeout))
+	defer cancel()
+	result, err := analyzeSource(ctx, &fsSource{fsys: fsys}, defaultConcurrency, ScanOptions{
+		Limits:      limits,
+		EntryPoints: d.EntryPoints,
+		Props:       true,
+		Hygiene:     true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Meta = &RepoMeta{FullName: d.fullName(), Description: d.Description, DefaultBranch: "main"}
+	d.result = result
+	return result, nil
+}
+
+// fullName names the project's repository, a made-up one for synthetic
+// projects.
+func (d *demoProject) fullName() string {
+	if d.Repository != "" {
+		return d.Repository
+	}
+	return "rgc-demo/" + d.Name
+}
+
+// handleDemosRequest lists the demo projects.
+func handleDemosRequest(c *gin.Context) {
+	demos := make([]*demoProject, 0, len(demoProjects))
+	for _, d := range demoProjects {
+		demos = append(demos, d)
+	}
+	sort.Slice(demos, func(i, j int) bool { return demos[i].Name < demos[j].Name })
+	c.JSON(http.StatusOK, gin.H{"demos": demos})
+}
+
+// handleDemoRequest returns the analysis of a demo project, in the same
+// shape as POST /garbage, without a token or a scan of GitHub.
+func handleDemoRequest(c *gin.Context) {
+	demo, ok := demoProjects[c.Param("name")]
+	if !ok {
+		respondError(c, errNotFound("demo not found"))
+		return
+	}
+	result, err := demo.analyze()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if c.Query("format") == "text" {
+		c.String(http.StatusOK, renderTextTree(demo.fullName(), result))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"components": result})
+}
@@ -0,0 +1,7 @@
+interface DateProps {
+  dateString: string
+}
+
+export default function Date({ dateString }: DateProps) {
+  return <time dateTime={dateString}>{dateString}</time>
+}
@@ -0,0 +1,3 @@
+export default function Header() {
+  return <header>My blog</header>
+}
@@ -0,0 +1,6 @@
+import Subscribe from './Subscribe'
+
+// Removed from the home page after the redesign.
+export default function NewsletterBanner() {
+  return <aside><Subscribe /></aside>
+}
@@ -0,0 +1,5 @@
+import Date from './Date'
+
+export default function PostList() {
+  return <ul><li><Date dateString="2024-01-31" /></li></ul>
+}
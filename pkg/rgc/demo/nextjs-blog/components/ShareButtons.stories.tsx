@@ -0,0 +1,5 @@
+import ShareButtons from './ShareButtons'
+
+export default { component: ShareButtons }
+
+export const Default = { args: { url: 'https://example.com' } }
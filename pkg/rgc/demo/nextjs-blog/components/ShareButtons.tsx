@@ -0,0 +1,8 @@
+interface ShareButtonsProps {
+  url: string
+  title?: string
+}
+
+export default function ShareButtons({ url, title }: ShareButtonsProps) {
+  return <a href={`https://twitter.com/intent/tweet?url=${url}&text=${title}`}>Share</a>
+}
@@ -0,0 +1,3 @@
+export default function Subscribe() {
+  return <form><input type="email" /></form>
+}
@@ -0,0 +1,10 @@
+import Layout from '../components/Layout'
+import PostList from '../components/PostList'
+
+export default function Home() {
+  return (
+    <Layout>
+      <PostList />
+    </Layout>
+  )
+}
@@ -0,0 +1,11 @@
+import Layout from '../../components/Layout'
+import Date from '../../components/Date'
+
+export default function Post({ post }) {
+  return (
+    <Layout>
+      <h1>{post.title}</h1>
+      <Date dateString={post.date} />
+    </Layout>
+  )
+}
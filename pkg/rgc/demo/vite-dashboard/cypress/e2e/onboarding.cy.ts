@@ -0,0 +1,6 @@
+describe('onboarding', () => {
+  it('shows the tour', () => {
+    cy.visit('/')
+    cy.get('[data-testid="onboarding-tour"]').should('be.visible')
+  })
+})
@@ -0,0 +1,5 @@
+import { Badge } from './ui'
+
+export default function LegacyTable() {
+  return <table><tbody><tr><td><Badge /></td></tr></tbody></table>
+}
@@ -0,0 +1,3 @@
+export default function OnboardingTour() {
+  return <div data-testid="onboarding-tour">Welcome!</div>
+}
@@ -0,0 +1,3 @@
+export function Badge() {
+  return <span className="badge" />
+}
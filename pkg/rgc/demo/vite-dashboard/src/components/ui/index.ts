@@ -0,0 +1,2 @@
+export { Button } from './Button'
+export { Badge } from './Badge'
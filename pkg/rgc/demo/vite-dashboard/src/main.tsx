@@ -0,0 +1,4 @@
+import { RouterProvider } from 'react-router-dom'
+import { router } from './routes'
+
+ReactDOM.createRoot(document.getElementById('root')!).render(<RouterProvider router={router} />)
@@ -0,0 +1,13 @@
+import { lazy } from 'react'
+import { Button } from '../components/ui'
+
+const Chart = lazy(() => import('../components/Chart'))
+
+export default function Dashboard() {
+  return (
+    <section>
+      <Chart />
+      <Button>Refresh</Button>
+    </section>
+  )
+}
@@ -0,0 +1,5 @@
+import { Button } from '../components/ui'
+
+export default function Settings() {
+  return <Button>Save</Button>
+}
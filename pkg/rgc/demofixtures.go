@@ -0,0 +1,169 @@
+package rgc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// demoFixturesDir holds the stored analyses of public repositories the
+// demo serves, one JSON file per repository, written by the demo-fixture
+// command.
+const demoFixturesDir = "demo/fixtures"
+
+// demoFixture is an analysis of a public repository at a pinned commit.
+// Unlike the synthetic projects, it isn't re-run: it shows what RGC
+// reported on that commit.
+type demoFixture struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Repository  string            `json:"repository"`
+	Commit      string            `json:"commit"`
+	EntryPoints []string          `json:"entry_points,omitempty"`
+	AnalyzedAt  time.Time         `json:"analyzed_at"`
+	Result      *ComponentsResult `json:"result"`
+}
+
+var (
+	commitSHARegex = regexp.MustCompile(`^[0-9a-f]{40}$`)
+	demoNameRegex  = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+)
+
+func (f *demoFixture) validate() error {
+	switch {
+	case !demoNameRegex.MatchString(f.Name):
+		return fmt.Errorf("name %q must be lowercase letters, digits and dashes", f.Name)
+	case strings.Count(f.Repository, "/") != 1:
+		return fmt.Errorf("repository %q must be owner/repo", f.Repository)
+	case !commitSHARegex.MatchString(f.Commit):
+		return fmt.Errorf("commit %q must be a full commit SHA", f.Commit)
+	case f.Result == nil:
+		return errors.New("the analysis result is missing")
+	}
+	return nil
+}
+
+// loadDemoFixtures adds the stored analyses of fsys's fixtures directory to
+// projects. Fixtures are built into the binary, so a broken one is an error.
+func loadDemoFixtures(fsys fs.FS, projects map[string]*demoProject) error {
+	entries, err := fs.ReadDir(fsys, demoFixturesDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		p := path.Join(demoFixturesDir, entry.Name())
+		content, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		var f demoFixture
+		if err := json.Unmarshal(content, &f); err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		if err := f.validate(); err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		if _, ok := projects[f.Name]; ok {
+			return fmt.Errorf("%s: demo %s already exists", p, f.Name)
+		}
+		analyzedAt := f.AnalyzedAt
+		projects[f.Name] = &demoProject{
+			Name:        f.Name,
+			Description: f.Description,
+			EntryPoints: f.EntryPoints,
+			Repository:  f.Repository,
+			Commit:      f.Commit,
+			AnalyzedAt:  &analyzedAt,
+			result:      f.Result,
+		}
+	}
+	return nil
+}
+
+func init() {
+	if err := loadDemoFixtures(demoFiles, demoProjects); err != nil {
+		panic("rgc: invalid demo fixture: " + err.Error())
+	}
+}
+
+// RunDemoFixture runs the demo-fixture command: it scans a public
+// repository at a commit with the server's GITHUB_TOKEN and prints the
+// fixture to store in pkg/rgc/demo/fixtures.
+func RunDemoFixture(args []string) int {
+	return runDemoFixture(context.Background(), args, os.Stdout, os.Stderr)
+}
+
+func runDemoFixture(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("demo-fixture", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: rgc demo-fixture [flags] owner/repo@commit")
+		fs.PrintDefaults()
+	}
+	var (
+		f           demoFixture
+		entryPoints string
+	)
+	fs.StringVar(&f.Name, "name", "", "name of the demo, the repository's name by default")
+	fs.StringVar(&f.Description, "description", "", "what the demo shows off")
+	fs.StringVar(&entryPoints, "entry-points", "", "comma separated entry point patterns, e.g. pages/,src/main.tsx")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	repository, commit, ok := strings.Cut(fs.Arg(0), "@")
+	owner, repo, _ := strings.Cut(repository, "/")
+	if fs.NArg() != 1 || !ok {
+		fs.Usage()
+		return exitError
+	}
+	f.Repository, f.Commit = repository, commit
+	if f.Name == "" {
+		f.Name = strings.ToLower(repo)
+	}
+	if entryPoints != "" {
+		f.EntryPoints = strings.Split(entryPoints, ",")
+	}
+	if err := validateRepo("owner", owner, repo); err != nil {
+		fmt.Fprintf(stderr, "rgc: %v\n", err)
+		return exitError
+	}
+
+	opts := ScanOptions{Ref: commit, EntryPoints: f.EntryPoints, Props: true, Hygiene: true, Logger: newLogger()}
+	result, err := ProcessRepository(owner, repo, opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "rgc: %v\n", err)
+		return exitError
+	}
+	if result.Meta != nil && result.Meta.Private {
+		fmt.Fprintf(stderr, "rgc: %s is private, demos only show public repositories\n", repository)
+		return exitError
+	}
+	f.Result = result
+	f.AnalyzedAt = time.Now().UTC().Truncate(time.Second)
+	if err := f.validate(); err != nil {
+		fmt.Fprintf(stderr, "rgc: %v\n", err)
+		return exitError
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(&f); err != nil {
+		fmt.Fprintf(stderr, "rgc: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
@@ -0,0 +1,46 @@
+package rgc
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadDemoFixtures(t *testing.T) {
+	const sha = "0123456789abcdef0123456789abcdef01234567"
+	fixture := func(name, repository, commit string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(`{"name": "` + name + `", "repository": "` + repository + `", "commit": "` + commit + `",
+			"analyzed_at": "2026-01-02T03:04:05Z", "result": {"unused_count": 1, "unused": [{"Component": {"Name": "Old", "Path": "src/Old.tsx"}}]}}`)}
+	}
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		want    []string
+		wantErr bool
+	}{
+		{"none", fstest.MapFS{}, nil, false},
+		{"fixture", fstest.MapFS{"demo/fixtures/app.json": fixture("app", "acme/app", sha), "demo/fixtures/README": {}}, []string{"app"}, false},
+		{"short commit", fstest.MapFS{"demo/fixtures/app.json": fixture("app", "acme/app", "0123456")}, nil, true},
+		{"no owner", fstest.MapFS{"demo/fixtures/app.json": fixture("app", "app", sha)}, nil, true},
+		{"taken name", fstest.MapFS{"demo/fixtures/blog.json": fixture("nextjs-blog", "acme/blog", sha)}, nil, true},
+		{"not JSON", fstest.MapFS{"demo/fixtures/app.json": {Data: []byte("{")}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projects := map[string]*demoProject{"nextjs-blog": {Name: "nextjs-blog", Synthetic: true}}
+			err := loadDemoFixtures(tt.fsys, projects)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadDemoFixtures() error = %v, want error %v", err, tt.wantErr)
+			}
+			for _, name := range tt.want {
+				d := projects[name]
+				if d == nil || d.Synthetic || d.Commit != sha || d.fullName() != "acme/app" {
+					t.Fatalf("projects[%s] = %+v, want the fixture", name, d)
+				}
+				result, err := d.analyze()
+				if err != nil || result.UnusedCount != 1 || result.Unused[0].Component.Path != "src/Old.tsx" {
+					t.Errorf("analyze() = %+v, %v, want the stored result", result, err)
+				}
+			}
+		})
+	}
+}
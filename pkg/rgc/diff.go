@@ -0,0 +1,172 @@
+package rgc
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/errgroup"
+)
+
+// ResultDiff describes how the components of a repository changed between two analyses.
+type ResultDiff struct {
+	From         string   `json:"from"`
+	To           string   `json:"to"`
+	Added        []string `json:"added"`
+	Removed      []string `json:"removed"`
+	BecameUsed   []string `json:"became_used"`
+	BecameUnused []string `json:"became_unused"`
+}
+
+// diffResults compares two results by component path.
+func diffResults(from, to *ComponentsResult) *ResultDiff {
+	before := componentStatus(from)
+	after := componentStatus(to)
+
+	diff := &ResultDiff{
+		Added:        []string{},
+		Removed:      []string{},
+		BecameUsed:   []string{},
+		BecameUnused: []string{},
+	}
+	for path, used := range after {
+		wasUsed, existed := before[path]
+		switch {
+		case !existed:
+			diff.Added = append(diff.Added, path)
+		case used && !wasUsed:
+			diff.BecameUsed = append(diff.BecameUsed, path)
+		case !used && wasUsed:
+			diff.BecameUnused = append(diff.BecameUnused, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			diff.Removed = append(diff.Removed, path)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.BecameUsed)
+	sort.Strings(diff.BecameUnused)
+	return diff
+}
+
+// componentStatus maps every component path in the result to whether it's used.
+func componentStatus(result *ComponentsResult) map[string]bool {
+	status := make(map[string]bool, len(result.Used)+len(result.Unused))
+	for _, node := range result.Used {
+		status[node.Component.Path] = true
+	}
+	// Possibly used components aren't reported unused.
+	for _, node := range result.PossiblyUsed {
+		status[node.Component.Path] = true
+	}
+	for _, node := range result.Unused {
+		status[node.Component.Path] = false
+	}
+	// Test, story and e2e only components aren't shipped, so they count as unused.
+	for _, node := range result.TestOnly {
+		status[node.Component.Path] = false
+	}
+	for _, node := range result.StorybookOnly {
+		status[node.Component.Path] = false
+	}
+	for _, node := range result.E2EOnly {
+		status[node.Component.Path] = false
+	}
+	return status
+}
+
+// RefScan is one side of a diff between two refs.
+type RefScan struct {
+	Ref        string `json:"ref"`
+	SHA        string `json:"sha"`
+	AnalysisID string `json:"analysis_id"`
+	// Cached is set when an earlier analysis of the same commit was reused.
+	Cached bool `json:"cached"`
+}
+
+// analysisAtRef returns an analysis of the repository at ref, reusing the
+// latest stored analysis of the commit it points to made with the default
+// options, or scanning it. It reads the repository with the caller's token,
+// or the server's.
+func analysisAtRef(ctx context.Context, owner, repo, ref string) (*Analysis, *RefScan, error) {
+	token, source, err := actingToken(ctx, owner, repo, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	sha, err := resolveRef(ctx, newGitHubClient(token), owner, repo, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := ScanOptions{Ref: sha, Token: token, origin: scanOrigin{tokenSource: source}}
+	a, err := analyses.findByRef(ctx, owner, repo, sha, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	cached := a != nil
+	if !cached {
+		opts.fromRequest(ctx)
+		result, err := ProcessRepository(owner, repo, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		if a, err = recordAnalysis(ctx, owner, repo, result, opts); err != nil {
+			return nil, nil, err
+		}
+	}
+	return a, &RefScan{Ref: ref, SHA: sha, AnalysisID: a.ID, Cached: cached}, nil
+}
+
+// handleDiffRequest compares the repository at two refs, typically the base
+// and head of a pull request, and reports the components added, deleted,
+// or that became used or unused in between.
+func handleDiffRequest(c *gin.Context) {
+	owner, repo := c.Query("owner"), c.Query("repo")
+	base, head := c.Query("base"), c.Query("head")
+	if owner == "" || repo == "" || base == "" || head == "" {
+		respondError(c, errBadRequest("owner, repo, base and head query parameters are required"))
+		return
+	}
+	baseScan, headScan, diff, err := diffRefs(accessContext(c), owner, repo, base, head)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"base": baseScan, "head": headScan, "changes": diff})
+}
+
+// diffRefs analyzes the repository at base and head and compares them.
+func diffRefs(ctx context.Context, owner, repo, base, head string) (*RefScan, *RefScan, *ResultDiff, error) {
+	if err := validateRepo("owner", owner, repo); err != nil {
+		return nil, nil, nil, err
+	}
+	if err := checkRepoAccess(ctx, owner, repo, roleRead); err != nil {
+		return nil, nil, nil, err
+	}
+
+	var (
+		baseAnalysis, headAnalysis *Analysis
+		baseScan, headScan         *RefScan
+	)
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.Go(func() (err error) {
+		baseAnalysis, baseScan, err = analysisAtRef(ctx, owner, repo, base)
+		return err
+	})
+	eg.Go(func() (err error) {
+		headAnalysis, headScan, err = analysisAtRef(ctx, owner, repo, head)
+		return err
+	})
+	if err := eg.Wait(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	diff := diffResults(baseAnalysis.Result, headAnalysis.Result)
+	diff.From, diff.To = baseAnalysis.ID, headAnalysis.ID
+	return baseScan, headScan, diff, nil
+}
@@ -0,0 +1,327 @@
+package rgc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// defaultDigestCron sends the digests on Monday mornings, UTC.
+	defaultDigestCron = "0 8 * * 1"
+	defaultDigestTop  = 10
+	maxDigestTop      = 50
+	// digestPeriod is how far back a digest's trend goes.
+	digestPeriod = 7 * 24 * time.Hour
+)
+
+// Subscriber receives a weekly email digest of the dead code of some
+// repositories.
+type Subscriber struct {
+	Email string `json:"email"`
+	// Repos are the "owner/repo" repositories the digest covers.
+	Repos []string `json:"repos"`
+	// Top is how many unused components the digest lists per repository,
+	// the ones most likely safe to delete first.
+	Top        int        `json:"top,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSentAt *time.Time `json:"last_sent_at,omitempty"`
+}
+
+// smtpConfig is the mail server digests are sent through.
+type smtpConfig struct {
+	host, port         string
+	username, password string
+	from               string
+}
+
+// loadSMTPConfig reads RGC_SMTP_HOST, RGC_SMTP_PORT (587 by default),
+// RGC_SMTP_USERNAME, RGC_SMTP_PASSWORD and RGC_SMTP_FROM. Digests are off
+// without a host.
+func loadSMTPConfig() (*smtpConfig, bool) {
+	cfg := &smtpConfig{
+		host:     os.Getenv("RGC_SMTP_HOST"),
+		port:     os.Getenv("RGC_SMTP_PORT"),
+		username: os.Getenv("RGC_SMTP_USERNAME"),
+		password: os.Getenv("RGC_SMTP_PASSWORD"),
+		from:     os.Getenv("RGC_SMTP_FROM"),
+	}
+	if cfg.host == "" {
+		return nil, false
+	}
+	if cfg.port == "" {
+		cfg.port = "587"
+	}
+	if cfg.from == "" {
+		cfg.from = "rgc@" + cfg.host
+	}
+	return cfg, true
+}
+
+func digestCron() string {
+	if spec := os.Getenv("RGC_DIGEST_CRON"); spec != "" {
+		return spec
+	}
+	return defaultDigestCron
+}
+
+// digestConfig describes the digest settings, without the SMTP password.
+func digestConfig() gin.H {
+	cfg, ok := loadSMTPConfig()
+	if !ok {
+		return gin.H{"enabled": false}
+	}
+	return gin.H{
+		"enabled":   schedulerEnabled(),
+		"cron":      digestCron(),
+		"smtp_host": net.JoinHostPort(cfg.host, cfg.port),
+		"from":      cfg.from,
+	}
+}
+
+// scheduleDigests sends the digests on RGC_DIGEST_CRON, when SMTP is set up.
+func (s *scheduler) scheduleDigests() error {
+	if _, ok := loadSMTPConfig(); !ok {
+		return nil
+	}
+	spec, err := cron.ParseStandard(digestCron())
+	if err != nil {
+		return fmt.Errorf("invalid RGC_DIGEST_CRON %q: %v", digestCron(), err)
+	}
+	s.cron.Schedule(spec, cron.FuncJob(func() { sendDigests(context.Background()) }))
+	return nil
+}
+
+// sendDigests emails every subscriber their digest. A subscriber failing
+// is logged and doesn't stop the others.
+func sendDigests(ctx context.Context) {
+	subscribers, err := analyses.storage.Subscribers(ctx)
+	if err != nil {
+		slog.Error("digests not sent", "error", err)
+		return
+	}
+	for _, sub := range subscribers {
+		if err := sendDigest(ctx, sub); err != nil {
+			slog.Warn("digest not sent", "email", sub.Email, "error", err)
+		}
+	}
+}
+
+// sendDigest emails sub their digest and records when.
+func sendDigest(ctx context.Context, sub *Subscriber) error {
+	cfg, ok := loadSMTPConfig()
+	if !ok {
+		return &APIError{Status: http.StatusConflict, Code: codeInvalidRequest, Message: "RGC_SMTP_HOST isn't set, digests are off"}
+	}
+	subject, body, err := buildDigest(ctx, sub, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if err := cfg.send(sub.Email, subject, body); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	sub.LastSentAt = &now
+	return analyses.storage.SaveSubscriber(ctx, sub)
+}
+
+func (cfg *smtpConfig) send(to, subject, body string) error {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", cfg.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mimeHeader(subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if cfg.username != "" {
+		auth = smtp.PlainAuth("", cfg.username, cfg.password, cfg.host)
+	}
+	if err := smtp.SendMail(net.JoinHostPort(cfg.host, cfg.port), auth, cfg.from, []string{to}, []byte(msg.String())); err != nil {
+		return fmt.Errorf("error sending email: %v", err)
+	}
+	return nil
+}
+
+// mimeHeader encodes a header value that isn't plain ASCII.
+func mimeHeader(s string) string {
+	for _, r := range s {
+		if r > 127 {
+			return mime.QEncoding.Encode("utf-8", s)
+		}
+	}
+	return s
+}
+
+// buildDigest writes the digest of sub's repositories: the unused count of
+// each, its change over the last week, and the unused components most
+// likely safe to delete.
+func buildDigest(ctx context.Context, sub *Subscriber, now time.Time) (string, string, error) {
+	top := sub.Top
+	if top == 0 {
+		top = defaultDigestTop
+	}
+	var b strings.Builder
+	total := 0
+	for _, full := range sub.Repos {
+		owner, repo, _ := strings.Cut(full, "/")
+		scans, err := analyses.list(ctx, owner, repo, maxTrendPoints)
+		if err != nil {
+			return "", "", err
+		}
+		fmt.Fprintf(&b, "%s\n%s\n", full, strings.Repeat("=", len(full)))
+		if len(scans) == 0 {
+			b.WriteString("Not scanned yet.\n\n")
+			continue
+		}
+		latest, err := analyses.get(ctx, owner, repo, scans[0].ID)
+		if err != nil {
+			return "", "", err
+		}
+		total += latest.Result.UnusedCount
+
+		trend := buildTrend(scans, now.Add(-digestPeriod))
+		switch n := len(trend.Points); {
+		case n == 0:
+			fmt.Fprintf(&b, "%d unused components, no scan this week (last on %s).\n", latest.Result.UnusedCount, latest.CreatedAt.Format("2006-01-02"))
+		case n == 1:
+			fmt.Fprintf(&b, "%d unused components, scanned once this week.\n", latest.Result.UnusedCount)
+		default:
+			fmt.Fprintf(&b, "%d unused components (%+d this week, over %d scans).\n", latest.Result.UnusedCount, trend.UnusedChange, n)
+		}
+		if link := reportURL(owner, repo, latest.ID); link != "" {
+			fmt.Fprintf(&b, "Report: %s\n", link)
+		}
+
+		q := componentsQuery{status: "unused", sort: "confidence", desc: true, page: 1, perPage: top}
+		entries, matched := q.apply(buildCatalog(full, latest.Result).Components)
+		if len(entries) > 0 {
+			b.WriteString("\nMost likely safe to delete:\n")
+			for _, e := range entries {
+				fmt.Fprintf(&b, "- %s", e.Path)
+				if e.Confidence != nil {
+					fmt.Fprintf(&b, " (confidence %.2f)", e.Confidence.Score)
+				}
+				b.WriteString("\n")
+			}
+			if more := matched - len(entries); more > 0 {
+				fmt.Fprintf(&b, "...and %d more\n", more)
+			}
+		}
+		b.WriteString("\n")
+	}
+	subject := fmt.Sprintf("Weekly dead code digest: %d unused components in %d repositories", total, len(sub.Repos))
+	if len(sub.Repos) == 1 {
+		subject = fmt.Sprintf("Weekly dead code digest: %d unused components in %s", total, sub.Repos[0])
+	}
+	return subject, b.String(), nil
+}
+
+func findSubscriber(ctx context.Context, email string) (*Subscriber, error) {
+	subscribers, err := analyses.storage.Subscribers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range subscribers {
+		if strings.EqualFold(sub.Email, email) {
+			return sub, nil
+		}
+	}
+	return nil, nil
+}
+
+// handlePutSubscriberRequest subscribes an email address to the digest of
+// some repositories, from {"repos": ["owner/repo"], "top": n}, replacing
+// its previous subscription.
+func handlePutSubscriberRequest(c *gin.Context) {
+	var sub Subscriber
+	if err := c.BindJSON(&sub); err != nil {
+		respondError(c, errBadRequest("%v", err))
+		return
+	}
+	addr, err := mail.ParseAddress(c.Param("email"))
+	if err != nil || addr.Name != "" || strings.ContainsAny(addr.Address, "\r\n") {
+		respondError(c, errBadRequest("%q isn't an email address", c.Param("email")))
+		return
+	}
+	if len(sub.Repos) == 0 {
+		respondError(c, errBadRequest("repos is required"))
+		return
+	}
+	for _, full := range sub.Repos {
+		owner, repo, ok := strings.Cut(full, "/")
+		if !ok {
+			respondError(c, errBadRequest("repos must be owner/repo, got %q", full))
+			return
+		}
+		if err := validateRepo("owner", owner, repo); err != nil {
+			respondError(c, err)
+			return
+		}
+	}
+	if sub.Top < 0 || sub.Top > maxDigestTop {
+		respondError(c, errBadRequest("top must be between 1 and %d", maxDigestTop))
+		return
+	}
+
+	sub.Email = addr.Address
+	sub.CreatedAt = time.Now().UTC()
+	sub.LastSentAt = nil
+	if err := analyses.storage.SaveSubscriber(c.Request.Context(), &sub); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+func handleDeleteSubscriberRequest(c *gin.Context) {
+	if err := analyses.storage.DeleteSubscriber(c.Request.Context(), c.Param("email")); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// handleSubscribersRequest lists every subscriber.
+func handleSubscribersRequest(c *gin.Context) {
+	subscribers, err := analyses.storage.Subscribers(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	sort.Slice(subscribers, func(i, j int) bool { return subscribers[i].Email < subscribers[j].Email })
+	c.JSON(http.StatusOK, gin.H{"subscribers": subscribers, "cron": digestCron()})
+}
+
+// handleSendDigestRequest sends a subscriber their digest right away, e.g.
+// to check the SMTP settings.
+func handleSendDigestRequest(c *gin.Context) {
+	sub, err := findSubscriber(c.Request.Context(), c.Param("email"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if sub == nil {
+		respondError(c, errNotFound("subscriber not found"))
+		return
+	}
+	if err := sendDigest(c.Request.Context(), sub); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd)
+
+package rgc
+
+import "errors"
+
+func freeDiskSpace(dir string) (uint64, error) {
+	return 0, errors.New("not supported on this platform")
+}
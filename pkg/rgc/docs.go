@@ -0,0 +1,57 @@
+package rgc
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// openAPISpec is the OpenAPI 3 document describing the API. Keep it in step
+// with the handlers.
+//
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// openAPIJSON is openAPISpec converted to JSON once, at startup.
+var openAPIJSON = func() map[string]interface{} {
+	var spec map[string]interface{}
+	if err := yaml.Unmarshal(openAPISpec, &spec); err != nil {
+		panic("invalid openapi.yaml: " + err.Error())
+	}
+	return spec
+}()
+
+func handleOpenAPIYAMLRequest(c *gin.Context) {
+	c.Data(http.StatusOK, "application/yaml; charset=utf-8", openAPISpec)
+}
+
+func handleOpenAPIJSONRequest(c *gin.Context) {
+	c.JSON(http.StatusOK, openAPIJSON)
+}
+
+// swaggerUIVersion is the Swagger UI release /docs loads from a CDN.
+const swaggerUIVersion = "5.17.14"
+
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>rgc API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@` + swaggerUIVersion + `/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@` + swaggerUIVersion + `/swagger-ui-bundle.js" crossorigin></script>
+<script>
+window.ui = SwaggerUIBundle({url: "openapi.json", dom_id: "#swagger-ui"});
+</script>
+</body>
+</html>
+`
+
+// handleDocsRequest serves Swagger UI, browsing the OpenAPI document.
+func handleDocsRequest(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+}
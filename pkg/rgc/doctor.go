@@ -0,0 +1,267 @@
+package rgc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v39/github"
+)
+
+// minWorkspaceSpace is the free space below which clones for clone mode and
+// verification are likely to fail.
+const minWorkspaceSpace = 1 << 30
+
+type checkStatus string
+
+const (
+	checkOK   checkStatus = "ok"
+	checkWarn checkStatus = "warn"
+	checkFail checkStatus = "fail"
+)
+
+// diagnostic is the outcome of one startup check, with what to do about it
+// when it isn't ok.
+type diagnostic struct {
+	Name   string
+	Status checkStatus
+	Detail string
+	Fix    string
+}
+
+// runDiagnostics checks everything a scan relies on: the server token and
+// its scopes, GitHub reachability, the caches, workspace disk space and the
+// configuration.
+func runDiagnostics(ctx context.Context) []diagnostic {
+	var diags []diagnostic
+	diags = append(diags, checkGitHub(ctx)...)
+	diags = append(diags, checkCaches())
+	diags = append(diags, checkWorkspace()...)
+	diags = append(diags, checkConfig()...)
+	diags = append(diags, checkStorage(ctx))
+	return diags
+}
+
+func checkGitHub(ctx context.Context) []diagnostic {
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		// Still check GitHub can be reached, anonymously.
+		client := github.NewClient(&http.Client{Transport: sharedETagTransport})
+		reach := diagnostic{Name: "github", Status: checkOK, Detail: "api.github.com is reachable"}
+		if _, _, err := client.RateLimits(ctx); err != nil {
+			reach = diagnostic{Name: "github", Status: checkWarn, Detail: fmt.Sprintf("can't reach GitHub: %v", err),
+				Fix: "check network access and HTTPS_PROXY"}
+		}
+		return []diagnostic{
+			{Name: "token", Status: checkWarn, Detail: "GITHUB_TOKEN is not set, every request must provide a token",
+				Fix: "export GITHUB_TOKEN with a personal access token"},
+			reach,
+		}
+	}
+
+	info, err := inspectToken(ctx, newGitHubClient(token))
+	switch {
+	case errors.Is(err, errInvalidToken):
+		return []diagnostic{
+			{Name: "github", Status: checkOK, Detail: "api.github.com is reachable"},
+			{Name: "token", Status: checkFail, Detail: err.Error(),
+				Fix: "generate a new token at https://github.com/settings/tokens"},
+		}
+	case err != nil:
+		// GitHub may just be unreachable right now.
+		return []diagnostic{
+			{Name: "github", Status: checkWarn, Detail: fmt.Sprintf("can't reach GitHub: %v", err),
+				Fix: "check network access and HTTPS_PROXY"},
+			{Name: "token", Status: checkWarn, Detail: "GITHUB_TOKEN couldn't be checked"},
+		}
+	}
+
+	diags := []diagnostic{{Name: "github", Status: checkOK, Detail: "api.github.com is reachable"}}
+	kind := "fine-grained token, permissions are checked when used"
+	if info.Classic {
+		kind = fmt.Sprintf("classic token with scopes [%s]", strings.Join(info.Scopes, ", "))
+	}
+	diags = append(diags, diagnostic{Name: "token", Status: checkOK, Detail: "valid " + kind})
+	if err := info.check(true, opReadContents); err != nil {
+		diags = append(diags, diagnostic{Name: "token scopes", Status: checkWarn,
+			Detail: fmt.Sprintf("private repositories can't be scanned: %v", err),
+			Fix:    "grant the token the \"repo\" scope to scan private repositories"})
+	}
+	return diags
+}
+
+func checkCaches() diagnostic {
+	sharedETagTransport.mu.Lock()
+	entries, size := len(sharedETagTransport.entries), sharedETagTransport.size
+	sharedETagTransport.mu.Unlock()
+	return diagnostic{Name: "caches", Status: checkOK,
+		Detail: fmt.Sprintf("in memory: ETag cache (%d/%d entries, %d/%d MiB), analysis history",
+			entries, maxETagEntries, size>>20, maxETagBytes>>20)}
+}
+
+func checkWorkspace() []diagnostic {
+	var diags []diagnostic
+	dir := os.TempDir()
+	free, err := freeDiskSpace(dir)
+	switch {
+	case err != nil:
+		diags = append(diags, diagnostic{Name: "workspace", Status: checkWarn,
+			Detail: fmt.Sprintf("can't measure free space in %s: %v", dir, err)})
+	case free < minWorkspaceSpace:
+		diags = append(diags, diagnostic{Name: "workspace", Status: checkWarn,
+			Detail: fmt.Sprintf("only %d MB free in %s, clones may fail", free>>20, dir),
+			Fix:    "free up space or point TMPDIR to a larger disk"})
+	default:
+		diags = append(diags, diagnostic{Name: "workspace", Status: checkOK,
+			Detail: fmt.Sprintf("%d MB free in %s", free>>20, dir)})
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		diags = append(diags, diagnostic{Name: "git", Status: checkWarn, Detail: "git not found, clone mode won't work",
+			Fix: "install git"})
+	} else {
+		diags = append(diags, diagnostic{Name: "git", Status: checkOK, Detail: "git is installed"})
+	}
+	return diags
+}
+
+func checkConfig() []diagnostic {
+	var problems []string
+	if v := os.Getenv("RGC_CONCURRENCY"); v != "" {
+		if n, err := strconv.Atoi(v); err != nil || n <= 0 {
+			problems = append(problems, fmt.Sprintf("RGC_CONCURRENCY %q is not a positive number", v))
+		}
+	}
+	if v := os.Getenv("RGC_VERIFY_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			problems = append(problems, fmt.Sprintf("RGC_VERIFY_TIMEOUT %q is not a positive duration", v))
+		}
+	}
+	if v := os.Getenv("RGC_ANALYZERS"); v != "" {
+		for _, name := range strings.Split(v, ",") {
+			if !knownAnalyzer(strings.TrimSpace(name)) {
+				problems = append(problems, fmt.Sprintf("RGC_ANALYZERS names unknown analyzer %q", strings.TrimSpace(name)))
+			}
+		}
+	}
+
+	if _, err := configuredAPIKeys(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if v := os.Getenv("RGC_API_RATE_LIMIT"); v != "" {
+		if n, err := strconv.Atoi(v); err != nil || n <= 0 {
+			problems = append(problems, fmt.Sprintf("RGC_API_RATE_LIMIT %q is not a positive number", v))
+		}
+	}
+	if _, err := loadVault(); err != nil && err != errVaultDisabled {
+		problems = append(problems, err.Error())
+	} else if _, ok := loadOAuthConfig(); ok && err == errVaultDisabled {
+		problems = append(problems, "GitHub login needs RGC_VAULT_KEY or RGC_VAULT_KEY_FILE to seal the tokens it's granted")
+	}
+	if v := os.Getenv("RGC_SESSION_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			problems = append(problems, fmt.Sprintf("RGC_SESSION_TTL %q is not a positive duration", v))
+		}
+	}
+	_, limitProblems := serverLimits()
+	problems = append(problems, limitProblems...)
+	if _, err := postProcessorPipeline(nil); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	var diags []diagnostic
+	sandbox, err := loadSandboxConfig()
+	if err != nil {
+		problems = append(problems, err.Error())
+	} else if _, err := newSandbox(false); err != nil {
+		problems = append(problems, err.Error())
+	} else if bin := sandboxBinary(sandbox); bin != "" {
+		if _, err := exec.LookPath(bin); err != nil {
+			diags = append(diags, diagnostic{Name: "sandbox", Status: checkWarn,
+				Detail: fmt.Sprintf("%s not found, deletion verification won't work", bin),
+				Fix:    fmt.Sprintf("install %s or set RGC_SANDBOX", bin)})
+		} else {
+			diags = append(diags, diagnostic{Name: "sandbox", Status: checkOK, Detail: sandbox.Kind + " is available"})
+		}
+	}
+
+	if len(problems) > 0 {
+		return append(diags, diagnostic{Name: "config", Status: checkFail, Detail: strings.Join(problems, "; "),
+			Fix: "fix the environment variables above, see the README"})
+	}
+	return append(diags, diagnostic{Name: "config", Status: checkOK, Detail: "configuration is valid"})
+}
+
+func knownAnalyzer(name string) bool {
+	for _, a := range analyzers {
+		if a.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sandboxBinary returns the program the sandbox runs commands with.
+func sandboxBinary(cfg sandboxConfig) string {
+	switch cfg.Kind {
+	case "docker", "podman":
+		return cfg.Runtime
+	case "nsjail":
+		return "nsjail"
+	}
+	return ""
+}
+
+// printDiagnostics writes the diagnostics one per line, with the fix below
+// each one that isn't ok, and reports whether any check failed.
+func printDiagnostics(w io.Writer, diags []diagnostic) bool {
+	failed := false
+	for _, d := range diags {
+		mark := "✓"
+		switch d.Status {
+		case checkWarn:
+			mark = "!"
+		case checkFail:
+			mark = "✗"
+			failed = true
+		}
+		fmt.Fprintf(w, "%s %-12s %s\n", mark, d.Name, d.Detail)
+		if d.Status != checkOK && d.Fix != "" {
+			fmt.Fprintf(w, "  %-12s → %s\n", "", d.Fix)
+		}
+	}
+	return failed
+}
+
+// RunDoctor implements `rgc doctor`, returning the process exit code.
+func RunDoctor() int {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if printDiagnostics(os.Stdout, runDiagnostics(ctx)) {
+		return 1
+	}
+	return 0
+}
+
+func checkStorage(ctx context.Context) diagnostic {
+	url := storageURL()
+	if url == "memory" {
+		return diagnostic{Name: "storage", Status: checkWarn,
+			Detail: fmt.Sprintf("analyses are kept in memory, the last %d per repository, and lost on restart", maxAnalysesPerRepo),
+			Fix:    "set RGC_DATABASE_URL to a sqlite: or postgres:// URL to keep the scan history"}
+	}
+	storage, err := openStorage(ctx, url)
+	if err != nil {
+		return diagnostic{Name: "storage", Status: checkFail, Detail: err.Error(),
+			Fix: "check RGC_DATABASE_URL and that the database is reachable"}
+	}
+	storage.Close()
+	return diagnostic{Name: "storage", Status: checkOK, Detail: "connected to " + redactURL(url)}
+}
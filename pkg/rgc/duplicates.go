@@ -0,0 +1,180 @@
+package rgc
+
+import (
+	"hash/fnv"
+	"math"
+	"regexp"
+	"sort"
+)
+
+// DuplicateCluster is a group of components whose sources are nearly the
+// same, usually copies that drifted apart and could be consolidated.
+type DuplicateCluster struct {
+	Paths []string `json:"paths"`
+	// Similarity is the lowest estimated similarity, from 0 to 1, of the
+	// pairs of components linking the cluster.
+	Similarity float64 `json:"similarity"`
+	// SameName is set when the components also share their name.
+	SameName bool `json:"same_name,omitempty"`
+}
+
+const (
+	// shingleSize is the number of tokens in each shingle compared.
+	shingleSize = 5
+	// minDuplicateTokens keeps the smallest components, which look alike
+	// whatever they do, out of the clusters.
+	minDuplicateTokens = 40
+	// duplicateThreshold is the similarity from which components are
+	// reported duplicates.
+	duplicateThreshold = 0.8
+	// signatureBands split the signatures into bands of rows; components
+	// sharing a band are compared.
+	signatureBands = 16
+	signatureRows  = 4
+	signatureSize  = signatureBands * signatureRows
+)
+
+var (
+	sourceCommentRegex = regexp.MustCompile(`(?s)/\*.*?\*/|//[^\n]*`)
+	sourceTokenRegex   = regexp.MustCompile(`[A-Za-z_$][\w$]*|\d+|\S`)
+)
+
+// signature is the MinHash signature of a component's source: the
+// proportion of equal values of two signatures estimates the Jaccard
+// similarity of the components' shingles.
+type signature [signatureSize]uint64
+
+// sourceSignature returns the signature of a component's source, leaving
+// out comments and whitespace, and false when it's too short to compare.
+func sourceSignature(content string) (signature, bool) {
+	var sig signature
+	tokens := sourceTokenRegex.FindAllString(sourceCommentRegex.ReplaceAllString(content, " "), -1)
+	if len(tokens) < minDuplicateTokens {
+		return sig, false
+	}
+	for i := range sig {
+		sig[i] = math.MaxUint64
+	}
+	for i := 0; i+shingleSize <= len(tokens); i++ {
+		h := fnv.New64a()
+		for _, t := range tokens[i : i+shingleSize] {
+			h.Write([]byte(t))
+			h.Write([]byte{0})
+		}
+		x := h.Sum64()
+		for j := range sig {
+			// Each row hashes the shingle differently, with its own seed.
+			v := mix(x ^ uint64(j+1)*0x9e3779b97f4a7c15)
+			if v < sig[j] {
+				sig[j] = v
+			}
+		}
+	}
+	return sig, true
+}
+
+// mix is the finalizer of SplitMix64, spreading the bits of x.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+func (s *signature) similarity(other *signature) float64 {
+	same := 0
+	for i := range s {
+		if s[i] == other[i] {
+			same++
+		}
+	}
+	return float64(same) / signatureSize
+}
+
+// duplicateClusters groups the components whose sources are nearly the
+// same. Only the pairs sharing a band of their signatures are compared, so
+// large repositories don't compare every pair of components.
+func (sc *scan) duplicateClusters() []DuplicateCluster {
+	paths := make([]string, 0, len(sc.signatures))
+	for p := range sc.signatures {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	candidates := make(map[[2]int]bool)
+	for band := 0; band < signatureBands; band++ {
+		buckets := make(map[uint64][]int)
+		for i, p := range paths {
+			sig := sc.signatures[p]
+			h := fnv.New64a()
+			for _, v := range sig[band*signatureRows : (band+1)*signatureRows] {
+				var b [8]byte
+				for k := range b {
+					b[k] = byte(v >> (8 * k))
+				}
+				h.Write(b[:])
+			}
+			key := h.Sum64()
+			for _, j := range buckets[key] {
+				candidates[[2]int{j, i}] = true
+			}
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+
+	parent := make([]int, len(paths))
+	lowest := make([]float64, len(paths))
+	for i := range parent {
+		parent[i], lowest[i] = i, 1
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for pair := range candidates {
+		a, b := sc.signatures[paths[pair[0]]], sc.signatures[paths[pair[1]]]
+		s := a.similarity(&b)
+		if s < duplicateThreshold {
+			continue
+		}
+		ra, rb := find(pair[0]), find(pair[1])
+		if ra != rb {
+			parent[ra] = rb
+			lowest[rb] = min(lowest[rb], lowest[ra])
+		}
+		lowest[rb] = min(lowest[rb], s)
+	}
+
+	members := make(map[int][]string)
+	for i, p := range paths {
+		members[find(i)] = append(members[find(i)], p)
+	}
+	var clusters []DuplicateCluster
+	for root, group := range members {
+		if len(group) < 2 {
+			continue
+		}
+		sameName := true
+		for _, p := range group[1:] {
+			if sc.nameKey(sc.createdComponents[p].Name) != sc.nameKey(sc.createdComponents[group[0]].Name) {
+				sameName = false
+			}
+		}
+		clusters = append(clusters, DuplicateCluster{
+			Paths:      group,
+			Similarity: math.Round(lowest[root]*100) / 100,
+			SameName:   sameName,
+		})
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].Paths) != len(clusters[j].Paths) {
+			return len(clusters[i].Paths) > len(clusters[j].Paths)
+		}
+		return clusters[i].Paths[0] < clusters[j].Paths[0]
+	})
+	return clusters
+}
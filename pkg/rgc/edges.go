@@ -0,0 +1,54 @@
+package rgc
+
+import "github.com/igorfelipeduca/rgc/internal/jsparse"
+
+// Import kinds of an edge of the component graph.
+const (
+	edgeDefault   = "default"
+	edgeNamed     = "named"
+	edgeNamespace = "namespace"
+	edgeDynamic   = "dynamic"
+	edgeReExport  = "re-export"
+	edgeRequire   = "require"
+)
+
+// ImportEdge describes how a component pulls in one of its children.
+type ImportEdge struct {
+	Kind string `json:"kind"`
+	// Specifier is the import specifier as written, e.g. "../ui/Button".
+	Specifier string `json:"specifier"`
+	Line      int    `json:"line,omitempty"`
+	// Via is the barrel file the import reached the component through.
+	Via string `json:"via,omitempty"`
+}
+
+// childImport is a component a module imports, with how it does. Path is
+// the file the import resolves to, when it does.
+type childImport struct {
+	Name string
+	Path string
+	Edge ImportEdge
+}
+
+// edgeKind classifies an import. A static import bringing in both a default
+// and named bindings counts as default, the usual way to import a component.
+func edgeKind(imp jsparse.Import) string {
+	switch imp.Kind {
+	case jsparse.Dynamic:
+		return edgeDynamic
+	case jsparse.ReExport:
+		return edgeReExport
+	case jsparse.Require:
+		return edgeRequire
+	}
+	kind := edgeNamed
+	for _, b := range imp.ValueBindings() {
+		switch b.Imported {
+		case "default":
+			return edgeDefault
+		case "*":
+			kind = edgeNamespace
+		}
+	}
+	return kind
+}
@@ -0,0 +1,104 @@
+package rgc
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/igorfelipeduca/rgc/internal/jsparse"
+	"golang.org/x/sync/errgroup"
+)
+
+// matchPath reports whether the repository path p matches pattern. A pattern
+// ending in "/" matches everything under that directory, "**" matches any
+// number of directories and the other segments follow path.Match, so
+// "pages/", "src/index.tsx" and "app/**/page.tsx" all work.
+func matchPath(pattern, p string) bool {
+	pattern = strings.TrimPrefix(pattern, "./")
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(p, pattern)
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(p, "/"))
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], segments[0]); err != nil || !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// entryRoots returns the components the entry points make reachable: the
+// components matched by a pattern themselves, and the components imported by
+// matched non-component modules such as src/main.ts. Patterns that match no
+// file are returned separately so they can be reported.
+func (sc *scan) entryRoots(ctx context.Context, patterns []string) ([]string, []string, error) {
+	matched := make(map[string]bool)
+	var roots, modules []string
+	for _, p := range sc.files {
+		for _, pattern := range patterns {
+			if !matchPath(pattern, p) {
+				continue
+			}
+			matched[pattern] = true
+			if sc.isComponent(p) {
+				roots = append(roots, p)
+			} else if jsparse.Supported(p) {
+				modules = append(modules, p)
+			}
+			break
+		}
+	}
+
+	var unmatched []string
+	for _, pattern := range patterns {
+		if !matched[pattern] {
+			unmatched = append(unmatched, pattern)
+		}
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(sc.concurrency)
+	for _, p := range modules {
+		p := p
+		eg.Go(func() error {
+			content, err := sc.src.ReadFile(ctx, p)
+			if err != nil {
+				if err == errFileNotFound {
+					return nil
+				}
+				return err
+			}
+			children, err := sc.findChildComponents(ctx, p, content)
+			if err != nil {
+				return err
+			}
+			sc.mu.Lock()
+			for _, child := range children {
+				if root := sc.childPath(p, child); root != "" {
+					roots = append(roots, root)
+				}
+			}
+			sc.mu.Unlock()
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, nil, err
+	}
+	return roots, unmatched, nil
+}
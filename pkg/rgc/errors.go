@@ -0,0 +1,139 @@
+package rgc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-github/v39/github"
+)
+
+var errTokenNotSet = errors.New("GITHUB_TOKEN environment variable not set")
+
+// Error codes of API error responses. Clients switch on them, so they never
+// change once released.
+const (
+	codeInvalidRequest        = "invalid_request"
+	codeUnauthorized          = "unauthorized"
+	codeForbidden             = "forbidden"
+	codeNotFound              = "not_found"
+	codeRepoNotFound          = "repo_not_found"
+	codeRefNotFound           = "ref_not_found"
+	codeDefaultBranchNotFound = "default_branch_not_found"
+	codeTokenMissing          = "token_missing"
+	codeTokenInvalid          = "token_invalid"
+	codeTokenMissingScopes    = "token_missing_scopes"
+	codeRateLimited           = "rate_limited"
+	codeTimeout               = "timeout"
+	codeLimitExceeded         = "limit_exceeded"
+	codeParseFailure          = "parse_failure"
+	codeGitHubError           = "github_error"
+	codeJiraError             = "jira_error"
+	codeShuttingDown          = "shutting_down"
+	codeInternal              = "internal"
+)
+
+// APIError is the body of an error response: a stable code to switch on,
+// a message for humans, optional details and whether trying again later
+// may succeed.
+type APIError struct {
+	Status    int    `json:"-"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   gin.H  `json:"details,omitempty"`
+	Retryable bool   `json:"retryable"`
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+func errBadRequest(format string, args ...interface{}) *APIError {
+	return &APIError{Status: http.StatusBadRequest, Code: codeInvalidRequest, Message: fmt.Sprintf(format, args...)}
+}
+
+func errNotFound(format string, args ...interface{}) *APIError {
+	return &APIError{Status: http.StatusNotFound, Code: codeNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+// ParseError means a source file couldn't be parsed.
+type ParseError struct {
+	Path string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("error parsing %s: %v", e.Path, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// toAPIError maps err to the error response describing it. Errors it
+// doesn't know are internal errors.
+func toAPIError(err error) *APIError {
+	var (
+		apiErr    *APIError
+		scopesErr *MissingScopesError
+		parseErr  *ParseError
+		limitErr  *LimitError
+		rateErr   *github.RateLimitError
+		abuseErr  *github.AbuseRateLimitError
+		githubErr *github.ErrorResponse
+		jiraErr   *jiraError
+	)
+	e := &APIError{Status: http.StatusInternalServerError, Code: codeInternal, Message: err.Error()}
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.As(err, &limitErr):
+		return limitErr.apiError()
+	case errors.Is(err, context.DeadlineExceeded):
+		e.Status, e.Code, e.Retryable = http.StatusGatewayTimeout, codeTimeout, true
+	case errors.As(err, &rateErr):
+		e.Status, e.Code, e.Retryable = http.StatusTooManyRequests, codeRateLimited, true
+		e.Details = gin.H{"reset_at": rateErr.Rate.Reset.Time}
+	case errors.As(err, &abuseErr):
+		e.Status, e.Code, e.Retryable = http.StatusTooManyRequests, codeRateLimited, true
+		if abuseErr.RetryAfter != nil {
+			e.Details = gin.H{"retry_after_seconds": int(abuseErr.RetryAfter.Seconds())}
+		}
+	case errors.Is(err, errInvalidPostProcessor):
+		e.Status, e.Code = http.StatusBadRequest, codeInvalidRequest
+	case errors.Is(err, errTokenNotSet):
+		e.Status, e.Code = http.StatusUnauthorized, codeTokenMissing
+	case errors.Is(err, errInvalidToken):
+		e.Status, e.Code = http.StatusUnauthorized, codeTokenInvalid
+	case errors.As(err, &scopesErr):
+		e.Status, e.Code = http.StatusForbidden, codeTokenMissingScopes
+		e.Details = gin.H{"operation": scopesErr.Operation, "missing": scopesErr.Missing}
+	case errors.Is(err, errRepositoryNotFound):
+		e.Status, e.Code = http.StatusNotFound, codeRepoNotFound
+	case errors.Is(err, errRefNotFound):
+		e.Status, e.Code = http.StatusNotFound, codeRefNotFound
+	case errors.Is(err, errAnalysisNotFound):
+		e.Status, e.Code = http.StatusNotFound, codeNotFound
+	case errors.Is(err, errDefaultBranchNotFound):
+		e.Status, e.Code = http.StatusConflict, codeDefaultBranchNotFound
+	case errors.As(err, &parseErr):
+		e.Status, e.Code = http.StatusUnprocessableEntity, codeParseFailure
+		e.Details = gin.H{"path": parseErr.Path}
+	case errors.As(err, &githubErr) && githubErr.Response != nil:
+		status := githubErr.Response.StatusCode
+		e.Status, e.Code, e.Retryable = http.StatusBadGateway, codeGitHubError, status >= 500
+		e.Details = gin.H{"github_status": status}
+	case errors.As(err, &jiraErr):
+		e.Status, e.Code, e.Retryable = http.StatusBadGateway, codeJiraError, jiraErr.status >= 500
+		e.Details = gin.H{"jira_status": jiraErr.status}
+	}
+	return e
+}
+
+// respondError answers with the structured form of err.
+func respondError(c *gin.Context, err error) {
+	apiErr := toAPIError(err)
+	c.AbortWithStatusJSON(apiErr.Status, gin.H{"error": apiErr})
+}
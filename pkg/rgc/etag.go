@@ -0,0 +1,129 @@
+package rgc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// maxETagEntries and maxETagBytes bound the cache, in entries and in the
+// combined size of the bodies kept.
+const (
+	maxETagEntries = 20000
+	maxETagBytes   = 128 << 20
+)
+
+// etagTransport remembers the ETag of every successful GET and replays the
+// cached body when GitHub answers a conditional request with 304 Not Modified.
+// GitHub doesn't count 304s against the rate limit, so rescans of mostly
+// unchanged repositories become almost free.
+type etagTransport struct {
+	base http.RoundTripper
+
+	mu      sync.Mutex
+	entries map[string]*etagEntry
+	size    int
+}
+
+type etagEntry struct {
+	etag   string
+	header http.Header
+	body   []byte
+}
+
+// sharedETagTransport is reused by every scan so the cache survives between requests.
+var sharedETagTransport = &etagTransport{
+	base:    &tracingTransport{base: githubTransport},
+	entries: make(map[string]*etagEntry),
+}
+
+func (t *etagTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.base.RoundTrip(req)
+	}
+
+	key := etagKey(req)
+	t.mu.Lock()
+	entry := t.entries[key]
+	t.mu.Unlock()
+
+	if entry != nil {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", entry.etag)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		resp.Body.Close()
+		header := entry.header.Clone()
+		// Keep the rate limit headers fresh, they come from the 304.
+		for name, values := range resp.Header {
+			if strings.HasPrefix(name, "X-Ratelimit-") {
+				header[name] = values
+			}
+		}
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         resp.Proto,
+			ProtoMajor:    resp.ProtoMajor,
+			ProtoMinor:    resp.ProtoMinor,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(entry.body)),
+			ContentLength: int64(len(entry.body)),
+			Request:       req,
+		}, nil
+	}
+
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	if len(body) <= maxETagBytes {
+		t.store(key, &etagEntry{etag: etag, header: resp.Header.Clone(), body: body})
+	}
+	return resp, nil
+}
+
+// store caches an entry, evicting others until the cache fits its limits.
+func (t *etagTransport) store(key string, entry *etagEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if old, ok := t.entries[key]; ok {
+		t.size -= len(old.body)
+		delete(t.entries, key)
+	}
+	for len(t.entries) >= maxETagEntries || t.size+len(entry.body) > maxETagBytes {
+		// Evict an arbitrary entry, map iteration order is random enough.
+		for k, e := range t.entries {
+			t.size -= len(e.body)
+			delete(t.entries, k)
+			break
+		}
+	}
+	t.entries[key] = entry
+	t.size += len(entry.body)
+}
+
+// etagKey scopes cache entries to the credentials used, so content fetched
+// with one token is never served to another.
+func etagKey(req *http.Request) string {
+	auth := sha256.Sum256([]byte(req.Header.Get("Authorization")))
+	return hex.EncodeToString(auth[:8]) + " " + req.Header.Get("Accept") + " " + req.URL.String()
+}
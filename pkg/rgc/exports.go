@@ -0,0 +1,167 @@
+package rgc
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/igorfelipeduca/rgc/internal/jsparse"
+	"golang.org/x/sync/errgroup"
+)
+
+// UnusedExport is an exported symbol nothing imports, inside a module that
+// is otherwise used.
+type UnusedExport struct {
+	Path string `json:"path"`
+	Name string `json:"name"`
+	// Kind is "component", "hook" or "value".
+	Kind string `json:"kind"`
+	Line int    `json:"line"`
+}
+
+// exportUsage records which exports of each module are imported somewhere,
+// and the import graph of every file.
+type exportUsage struct {
+	exports map[string][]jsparse.Export
+	// used maps a module to the names imported from it, "*" meaning all.
+	used map[string]map[string]bool
+	// imports maps a file to the modules it imports, importers a module to
+	// the files importing it.
+	imports   map[string][]string
+	importers map[string][]string
+}
+
+// collectExports parses every module of the repository, including Svelte
+// script blocks, recording what each exports and what each imports.
+// Unlike the component scan it reads all source files, not just components.
+func (sc *scan) collectExports(ctx context.Context) (*exportUsage, error) {
+	usage := &exportUsage{
+		exports:   make(map[string][]jsparse.Export),
+		used:      make(map[string]map[string]bool),
+		imports:   make(map[string][]string),
+		importers: make(map[string][]string),
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(sc.concurrency)
+	for _, p := range sc.files {
+		p := p
+		switch {
+		case jsparse.Supported(p):
+			eg.Go(func() error {
+				file, err := sc.parseModule(ctx, p)
+				if err != nil || sc.isGenerated(p) {
+					return err
+				}
+				sc.mu.Lock()
+				defer sc.mu.Unlock()
+				usage.exports[p] = file.Exports
+				sc.recordImports(usage, p, file)
+				return nil
+			})
+		case path.Ext(p) == ".svelte":
+			eg.Go(func() error {
+				content, err := sc.src.ReadFile(ctx, p)
+				if err != nil {
+					if err == errFileNotFound {
+						return nil
+					}
+					return err
+				}
+				for _, script := range svelteScriptRe.FindAllStringSubmatch(content, -1) {
+					file, err := parseFile(ctx, "script.ts", script[1])
+					if err != nil {
+						return err
+					}
+					sc.mu.Lock()
+					sc.recordImports(usage, p, file)
+					sc.mu.Unlock()
+				}
+				return nil
+			})
+		}
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// recordImports marks the names file imports from each module as used.
+// Re-exports count as usage too: following what consumers of a barrel pick
+// from it would flag exports that are only reachable through `export *`.
+func (sc *scan) recordImports(usage *exportUsage, importer string, file *jsparse.File) {
+	for _, imp := range file.Imports {
+		if !imp.IsRuntime() {
+			continue
+		}
+		target := sc.resolveModule(importer, imp.Specifier)
+		if target == "" {
+			continue
+		}
+		usage.imports[importer] = append(usage.imports[importer], target)
+		usage.importers[target] = append(usage.importers[target], importer)
+		names := usage.used[target]
+		if names == nil {
+			names = make(map[string]bool)
+			usage.used[target] = names
+		}
+		if imp.Kind == jsparse.SideEffect {
+			continue // Makes the module used without using any export
+		}
+		for _, name := range importedNames(imp) {
+			names[name] = true
+		}
+	}
+}
+
+// unusedExports lists the exports no module imports, in modules something
+// imports. Modules nothing imports are dead as a whole and reported as such,
+// and modules the framework loads (routes, pages) use their exports in ways
+// imports don't show.
+func (sc *scan) unusedExports(usage *exportUsage) []UnusedExport {
+	unused := []UnusedExport{}
+	for p, exports := range usage.exports {
+		used, imported := usage.used[p]
+		if !imported || used["*"] || isSupportFile(p) {
+			continue
+		}
+		if sc.isComponent(p) && sc.frameworkRoots[p] {
+			continue
+		}
+		for _, export := range exports {
+			if export.TypeOnly || used[export.Name] {
+				continue
+			}
+			unused = append(unused, UnusedExport{
+				Path: p,
+				Name: export.Name,
+				Kind: exportKind(p, export.Name),
+				Line: export.Line,
+			})
+		}
+	}
+	sort.Slice(unused, func(i, j int) bool {
+		if unused[i].Path != unused[j].Path {
+			return unused[i].Path < unused[j].Path
+		}
+		return unused[i].Line < unused[j].Line
+	})
+	return unused
+}
+
+func exportKind(p, name string) string {
+	switch {
+	case name == "default":
+		if isComponent(p) {
+			return "component"
+		}
+	case strings.HasPrefix(name, "use") && len(name) > 3 && unicode.IsUpper([]rune(name)[3]):
+		return "hook"
+	case unicode.IsUpper([]rune(name)[0]):
+		return "component"
+	}
+	return "value"
+}
@@ -0,0 +1,153 @@
+package rgc
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v39/github"
+	"golang.org/x/sync/errgroup"
+)
+
+// LastCommit is the last commit touching a file.
+type LastCommit struct {
+	SHA    string    `json:"sha"`
+	Date   time.Time `json:"date"`
+	Author string    `json:"author"`
+	Email  string    `json:"email,omitempty"`
+	// Login is the author's GitHub login, when GitHub knows it.
+	Login string `json:"login,omitempty"`
+}
+
+// historySource is a Source that can tell the last commit touching a file.
+type historySource interface {
+	LastCommits(ctx context.Context, paths []string) (map[string]*LastCommit, error)
+}
+
+// LastCommits lists the commits of each file, one request per file, and
+// keeps the most recent one.
+func (s *githubSource) LastCommits(ctx context.Context, paths []string) (map[string]*LastCommit, error) {
+	var mu sync.Mutex
+	commits := make(map[string]*LastCommit, len(paths))
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(s.concurrency)
+	for _, p := range paths {
+		p := p
+		eg.Go(func() error {
+			list, _, err := s.client.Repositories.ListCommits(ctx, s.owner, s.repo, &github.CommitsListOptions{
+				SHA:         s.ref,
+				Path:        p,
+				ListOptions: github.ListOptions{PerPage: 1},
+			})
+			if err != nil {
+				return fmt.Errorf("error listing commits of %s: %w", p, err)
+			}
+			if len(list) == 0 {
+				return nil
+			}
+			c := list[0]
+			commit := &LastCommit{
+				SHA:    c.GetSHA(),
+				Date:   c.GetCommit().GetAuthor().GetDate(),
+				Author: c.GetCommit().GetAuthor().GetName(),
+				Email:  c.GetCommit().GetAuthor().GetEmail(),
+				Login:  c.GetAuthor().GetLogin(),
+			}
+			mu.Lock()
+			commits[p] = commit
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+	return commits, nil
+}
+
+// LastCommits walks the clone's history once, newest first, until every
+// path has been seen. The clone must have been made with its history.
+func (s *cloneSource) LastCommits(ctx context.Context, paths []string) (map[string]*LastCommit, error) {
+	wanted := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		wanted[p] = true
+	}
+	commits := make(map[string]*LastCommit, len(paths))
+	if len(wanted) == 0 {
+		return commits, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", "-C", s.dir, "-c", "core.quotePath=false", "log", "--no-renames", "--name-only",
+		"--format=%x1e%H%x1f%aI%x1f%an%x1f%ae", "HEAD")
+	// Partial clones fetch what the log needs on demand.
+	cmd.Env = s.env
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error reading history: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error reading history: %v", err)
+	}
+
+	var current *LastCommit
+	lines := bufio.NewScanner(out)
+	for lines.Scan() {
+		line := lines.Text()
+		if header, ok := strings.CutPrefix(line, "\x1e"); ok {
+			fields := strings.Split(header, "\x1f")
+			if len(fields) != 4 {
+				continue
+			}
+			date, _ := time.Parse(time.RFC3339, fields[1])
+			current = &LastCommit{SHA: fields[0], Date: date, Author: fields[2], Email: fields[3]}
+			continue
+		}
+		if current != nil && wanted[line] && commits[line] == nil {
+			commits[line] = current
+			if len(commits) == len(wanted) {
+				break
+			}
+		}
+	}
+	// Stop git rather than reading the rest of the history.
+	complete := len(commits) == len(wanted)
+	if complete || lines.Err() != nil {
+		cancel()
+	}
+	if err := cmd.Wait(); err != nil && !complete {
+		return nil, fmt.Errorf("error reading history: %v", err)
+	}
+	return commits, nil
+}
+
+// addFileHistory sets the last commit of every component in the result,
+// when src can tell it.
+func addFileHistory(ctx context.Context, src Source, result *ComponentsResult) error {
+	hs, ok := src.(historySource)
+	if !ok {
+		return nil
+	}
+	var nodes []*ComponentNode
+	for _, bucket := range [][]*ComponentNode{result.Used, result.Unused, result.TestOnly, result.StorybookOnly, result.E2EOnly, result.PossiblyUsed} {
+		nodes = append(nodes, bucket...)
+	}
+	paths := make([]string, len(nodes))
+	for i, node := range nodes {
+		paths[i] = node.Component.Path
+	}
+
+	commits, err := hs.LastCommits(ctx, paths)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		node.LastCommit = commits[node.Component.Path]
+	}
+	return nil
+}
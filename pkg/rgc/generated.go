@@ -0,0 +1,112 @@
+package rgc
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// GeneratedFile is a file a code generator wrote. Generated files are left
+// out of the components and of the import graph: nobody deletes them by
+// hand, and what they import is whatever the generator needed.
+type GeneratedFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+var (
+	generatedMarkerRegex  = regexp.MustCompile(`@generated\b`)
+	doNotEditRegex        = regexp.MustCompile(`(?i)\bdo not edit\b`)
+	graphqlCodegenRegex   = regexp.MustCompile(`export type Exact<T extends \{ \[key: string\]: unknown \}>|Map of all GraphQL operations in the project`)
+	generatedHeadSize     = 1024
+	graphqlCodegenHeadMax = 8192
+)
+
+// generatedReason tells why the file at p looks generated, or returns ""
+// when it doesn't. Markers only count at the top of the file, where
+// generators put them, so a comment mentioning them further down doesn't.
+func generatedReason(p, content string) string {
+	if strings.Contains("/"+p, "/__generated__/") {
+		return "inside a __generated__ directory"
+	}
+	if strings.Contains(path.Base(p), ".generated.") {
+		return "named *.generated.*"
+	}
+	head := content
+	if len(head) > generatedHeadSize {
+		head = head[:generatedHeadSize]
+	}
+	switch {
+	case generatedMarkerRegex.MatchString(head):
+		return "@generated marker"
+	case doNotEditRegex.MatchString(head):
+		return "\"DO NOT EDIT\" header"
+	}
+	// graphql-codegen writes no header, but the helper types every output
+	// of its typescript plugin starts with are distinctive enough.
+	if len(content) > graphqlCodegenHeadMax {
+		content = content[:graphqlCodegenHeadMax]
+	}
+	if graphqlCodegenRegex.MatchString(content) {
+		return "graphql-codegen output"
+	}
+	return ""
+}
+
+// checkGenerated records the file at p as generated when it looks so and
+// the scan leaves generated files out, reporting whether it does.
+func (sc *scan) checkGenerated(p, content string) bool {
+	if sc.includeGenerated {
+		return false
+	}
+	reason := generatedReason(p, content)
+	if reason == "" {
+		return false
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.generated == nil {
+		sc.generated = make(map[string]string)
+	}
+	sc.generated[p] = reason
+	return true
+}
+
+// isGenerated reports whether the file at p was found generated.
+func (sc *scan) isGenerated(p string) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	_, ok := sc.generated[p]
+	return ok
+}
+
+// dropGenerated forgets the generated components once the tree is built,
+// along with the imports of them, so neither shows up in the results.
+func (sc *scan) dropGenerated() {
+	if len(sc.generated) == 0 {
+		return
+	}
+	for p := range sc.generated {
+		sc.removeComponent(p)
+	}
+	for _, node := range sc.rootComponents {
+		var children []*ComponentNode
+		for _, child := range node.Children {
+			if _, ok := sc.generated[child.Component.Path]; !ok {
+				children = append(children, child)
+			}
+		}
+		node.Children = children
+	}
+}
+
+// generatedFiles lists the generated files found, sorted.
+func (sc *scan) generatedFiles() []GeneratedFile {
+	var files []GeneratedFile
+	for p, reason := range sc.generated {
+		files = append(files, GeneratedFile{Path: p, Reason: reason})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
@@ -0,0 +1,334 @@
+package rgc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// graphQLMaxDepth bounds how deep a query may nest, children of children
+// going around import cycles otherwise.
+const graphQLMaxDepth = 12
+
+const graphQLSchema = `
+schema {
+	query: Query
+}
+
+type Query {
+	"An analysis of the scan history, the latest one when id is omitted."
+	analysis(owner: String!, repo: String!, id: ID): Analysis
+	"The past analyses of a repository, newest first."
+	scans(owner: String!, repo: String!, limit: Int = 20): [Scan!]!
+}
+
+type Scan {
+	id: ID!
+	ref: String
+	createdAt: String!
+	counts: Counts!
+}
+
+type Analysis {
+	id: ID!
+	owner: String!
+	repo: String!
+	"The analyzed commit."
+	ref: String
+	createdAt: String!
+	counts: Counts!
+	"The components, by path, optionally of a status or under a directory."
+	components(status: Status, pathPrefix: String): [Component!]!
+	component(path: String!): Component
+}
+
+type Counts {
+	used: Int!
+	unused: Int!
+	testOnly: Int!
+	storybookOnly: Int!
+	e2eOnly: Int!
+}
+
+enum Status {
+	USED
+	UNUSED
+	TEST_ONLY
+	STORYBOOK_ONLY
+	E2E_ONLY
+	POSSIBLY_USED
+}
+
+type Component {
+	name: String!
+	path: String!
+	status: Status!
+	"How many components import this one."
+	usageCount: Int!
+	"The components this one imports."
+	children: [Component!]!
+	"The components importing this one."
+	referencedBy: [Component!]!
+	owners: [String!]!
+	"The size of the file in bytes, when the scan asked for metadata."
+	size: Int
+	"How safe deleting the component likely is, from 0 to 1, on unused components."
+	confidence: Float
+	previewUrl: String
+}
+`
+
+var graphQL = graphql.MustParseSchema(graphQLSchema, &gqlQuery{}, graphql.MaxDepth(graphQLMaxDepth))
+
+// graphQLRequest is the body of a GraphQL request.
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// handleGraphQLRequest answers GraphQL queries over the scan history, from
+// a JSON body or, for GET, the query string.
+func handleGraphQLRequest(c *gin.Context) {
+	var req graphQLRequest
+	if c.Request.Method == http.MethodGet {
+		req.Query, req.OperationName = c.Query("query"), c.Query("operationName")
+		if v := c.Query("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				respondError(c, errBadRequest("variables must be a JSON object"))
+				return
+			}
+		}
+	} else if err := c.BindJSON(&req); err != nil {
+		respondError(c, errBadRequest("%v", err))
+		return
+	}
+	if req.Query == "" {
+		respondError(c, errBadRequest("query is required"))
+		return
+	}
+	c.JSON(http.StatusOK, graphQL.Exec(accessContext(c), req.Query, req.OperationName, req.Variables))
+}
+
+// gqlError carries the code of an API error into the extensions of a
+// GraphQL error.
+type gqlError struct {
+	*APIError
+}
+
+func (e gqlError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.Code}
+}
+
+func toGQLError(err error) error {
+	return gqlError{toAPIError(err)}
+}
+
+type gqlQuery struct{}
+
+func (*gqlQuery) Analysis(ctx context.Context, args struct {
+	Owner, Repo string
+	ID          *graphql.ID
+}) (*gqlAnalysis, error) {
+	if err := checkRepoAccess(ctx, args.Owner, args.Repo, roleRead); err != nil {
+		return nil, toGQLError(err)
+	}
+	id := ""
+	if args.ID != nil {
+		id = string(*args.ID)
+	} else {
+		latest, err := analyses.list(ctx, args.Owner, args.Repo, 1)
+		if err != nil {
+			return nil, toGQLError(err)
+		}
+		if len(latest) == 0 {
+			return nil, nil
+		}
+		id = latest[0].ID
+	}
+	analysis, err := analyses.get(ctx, args.Owner, args.Repo, id)
+	if errors.Is(err, errAnalysisNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, toGQLError(err)
+	}
+	return &gqlAnalysis{analysis: analysis}, nil
+}
+
+func (*gqlQuery) Scans(ctx context.Context, args struct {
+	Owner, Repo string
+	Limit       int32
+}) ([]*gqlScan, error) {
+	if args.Limit <= 0 {
+		return nil, toGQLError(errBadRequest("limit must be a positive number"))
+	}
+	if err := checkRepoAccess(ctx, args.Owner, args.Repo, roleRead); err != nil {
+		return nil, toGQLError(err)
+	}
+	scans, err := analyses.list(ctx, args.Owner, args.Repo, min(int(args.Limit), maxScansLimit))
+	if err != nil {
+		return nil, toGQLError(err)
+	}
+	resolvers := make([]*gqlScan, len(scans))
+	for i := range scans {
+		resolvers[i] = &gqlScan{scans[i]}
+	}
+	return resolvers, nil
+}
+
+type gqlScan struct {
+	s ScanSummary
+}
+
+func (r *gqlScan) ID() graphql.ID    { return graphql.ID(r.s.ID) }
+func (r *gqlScan) Ref() *string      { return optionalString(r.s.Ref) }
+func (r *gqlScan) CreatedAt() string { return r.s.CreatedAt.Format(time.RFC3339) }
+func (r *gqlScan) Counts() *gqlCounts {
+	return &gqlCounts{r.s.UsedCount, r.s.UnusedCount, r.s.TestOnlyCount, r.s.StorybookOnlyCount, r.s.E2EOnlyCount}
+}
+
+type gqlAnalysis struct {
+	analysis *Analysis
+
+	once  sync.Once
+	graph *gqlGraph
+}
+
+func (r *gqlAnalysis) ID() graphql.ID    { return graphql.ID(r.analysis.ID) }
+func (r *gqlAnalysis) Owner() string     { return r.analysis.Owner }
+func (r *gqlAnalysis) Repo() string      { return r.analysis.Repo }
+func (r *gqlAnalysis) Ref() *string      { return optionalString(r.analysis.Ref) }
+func (r *gqlAnalysis) CreatedAt() string { return r.analysis.CreatedAt.Format(time.RFC3339) }
+func (r *gqlAnalysis) Counts() *gqlCounts {
+	res := r.analysis.Result
+	return &gqlCounts{res.UsedCount, res.UnusedCount, res.TestOnlyCount, res.StorybookOnlyCount, res.E2EOnlyCount}
+}
+
+func (r *gqlAnalysis) Components(args struct {
+	Status     *string
+	PathPrefix *string
+}) []*gqlComponent {
+	list := []*gqlComponent{}
+	for _, c := range r.components().list {
+		if args.Status != nil && c.Status() != *args.Status {
+			continue
+		}
+		if args.PathPrefix != nil && !strings.HasPrefix(c.entry.Path, *args.PathPrefix) {
+			continue
+		}
+		list = append(list, c)
+	}
+	return list
+}
+
+func (r *gqlAnalysis) Component(args struct{ Path string }) *gqlComponent {
+	return r.components().byPath[args.Path]
+}
+
+// components indexes the analysis' components, once per query.
+func (r *gqlAnalysis) components() *gqlGraph {
+	r.once.Do(func() {
+		r.graph = newGQLGraph(r.analysis)
+	})
+	return r.graph
+}
+
+type gqlCounts struct {
+	used, unused, testOnly, storybookOnly, e2eOnly int
+}
+
+func (r *gqlCounts) Used() int32          { return int32(r.used) }
+func (r *gqlCounts) Unused() int32        { return int32(r.unused) }
+func (r *gqlCounts) TestOnly() int32      { return int32(r.testOnly) }
+func (r *gqlCounts) StorybookOnly() int32 { return int32(r.storybookOnly) }
+func (r *gqlCounts) E2EOnly() int32       { return int32(r.e2eOnly) }
+
+// gqlGraph links the components of an analysis both ways: to the ones they
+// import and to the ones importing them.
+type gqlGraph struct {
+	list   []*gqlComponent
+	byPath map[string]*gqlComponent
+}
+
+func newGQLGraph(a *Analysis) *gqlGraph {
+	g := &gqlGraph{byPath: make(map[string]*gqlComponent)}
+	for _, entry := range buildCatalog(a.Owner+"/"+a.Repo, a.Result).Components {
+		c := &gqlComponent{entry: entry, children: []*gqlComponent{}, referencedBy: []*gqlComponent{}}
+		g.list = append(g.list, c)
+		g.byPath[entry.Path] = c
+	}
+	for _, nodes := range [][]*ComponentNode{a.Result.Used, a.Result.Unused, a.Result.TestOnly, a.Result.StorybookOnly, a.Result.E2EOnly, a.Result.PossiblyUsed} {
+		for _, node := range nodes {
+			parent := g.byPath[node.Component.Path]
+			for _, child := range node.Children {
+				c, ok := g.byPath[child.Component.Path]
+				if !ok || parent == nil {
+					continue
+				}
+				parent.children = append(parent.children, c)
+				c.referencedBy = append(c.referencedBy, parent)
+			}
+		}
+	}
+	for _, c := range g.list {
+		sortGQLComponents(c.children)
+		sortGQLComponents(c.referencedBy)
+	}
+	return g
+}
+
+func sortGQLComponents(list []*gqlComponent) {
+	sort.Slice(list, func(i, j int) bool { return list[i].entry.Path < list[j].entry.Path })
+}
+
+type gqlComponent struct {
+	entry        CatalogEntry
+	children     []*gqlComponent
+	referencedBy []*gqlComponent
+}
+
+func (r *gqlComponent) Name() string                  { return r.entry.Name }
+func (r *gqlComponent) Path() string                  { return r.entry.Path }
+func (r *gqlComponent) Status() string                { return strings.ToUpper(r.entry.Status) }
+func (r *gqlComponent) UsageCount() int32             { return int32(r.entry.UsageCount) }
+func (r *gqlComponent) Children() []*gqlComponent     { return r.children }
+func (r *gqlComponent) ReferencedBy() []*gqlComponent { return r.referencedBy }
+func (r *gqlComponent) PreviewURL() *string           { return optionalString(r.entry.PreviewURL) }
+
+func (r *gqlComponent) Owners() []string {
+	if r.entry.Owners == nil {
+		return []string{}
+	}
+	return r.entry.Owners
+}
+
+func (r *gqlComponent) Size() *int32 {
+	if r.entry.Size == 0 {
+		return nil
+	}
+	size := int32(r.entry.Size)
+	return &size
+}
+
+func (r *gqlComponent) Confidence() *float64 {
+	if r.entry.Confidence == nil {
+		return nil
+	}
+	return &r.entry.Confidence.Score
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,400 @@
+package rgc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/igorfelipeduca/rgc/pkg/rgcpb"
+)
+
+// defaultGRPCAddr is where the gRPC API listens, next to the REST API.
+const defaultGRPCAddr = ":9090"
+
+// grpcAddr returns the address of the gRPC API, set in RGC_GRPC_ADDR, or ""
+// when it's "off".
+func grpcAddr() string {
+	switch addr := os.Getenv("RGC_GRPC_ADDR"); addr {
+	case "":
+		return defaultGRPCAddr
+	case "off":
+		return ""
+	default:
+		return addr
+	}
+}
+
+// newGRPCServer returns the server of the gRPC API, proto/rgc/v1/scan.proto.
+// Calls are logged and take API keys like REST requests do.
+func newGRPCServer() *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(grpcUnaryInterceptor),
+		grpc.ChainStreamInterceptor(grpcStreamInterceptor),
+	)
+	rgcpb.RegisterScanServiceServer(srv, scanService{})
+	return srv
+}
+
+func grpcUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, done := startGRPCCall(ctx, info.FullMethod)
+	ctx, err := grpcAuthenticate(ctx)
+	var resp interface{}
+	if err == nil {
+		resp, err = handler(ctx, req)
+	}
+	return resp, done(err)
+}
+
+func grpcStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, done := startGRPCCall(ss.Context(), info.FullMethod)
+	ctx, err := grpcAuthenticate(ctx)
+	if err == nil {
+		err = handler(srv, &grpcStream{ServerStream: ss, ctx: ctx})
+	}
+	return done(err)
+}
+
+// grpcStream is a server stream with the context set up by its interceptor.
+type grpcStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *grpcStream) Context() context.Context {
+	return s.ctx
+}
+
+// startGRPCCall gives a call its logger, as requestLogger does. The returned
+// function logs the call once it's over and turns its error into a status.
+func startGRPCCall(ctx context.Context, method string) (context.Context, func(error) error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var id string
+	if ids := md.Get(requestIDHeader); len(ids) > 0 && len(ids[0]) <= 128 {
+		id = ids[0]
+	}
+	if id == "" {
+		id = newRequestID()
+	}
+	logger := slog.Default().With("request_id", id)
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		logger = logger.With("trace_id", sc.TraceID().String())
+	}
+	ctx = context.WithValue(ctx, loggerKey{}, logger)
+
+	start := time.Now()
+	return ctx, func(err error) error {
+		err = grpcError(err)
+		code := status.Code(err)
+		level := slog.LevelInfo
+		if code == codes.Internal || code == codes.Unknown {
+			level = slog.LevelError
+		}
+		logger.LogAttrs(ctx, level, "grpc request",
+			slog.String("method", method),
+			slog.String("code", code.String()),
+			slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+		)
+		return err
+	}
+}
+
+// grpcAuthenticate only lets through calls bearing a known API key in the
+// x-api-key metadata, when keys are required, as requireAPIKey does. It
+// also picks up the caller's GitHub token from x-github-token.
+func grpcAuthenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if token := md.Get(githubTokenHeader); len(token) > 0 {
+		ctx = withGitHubToken(ctx, token[0])
+	}
+	if !apiKeysRequired() {
+		return ctx, nil
+	}
+	given := md.Get(apiKeyHeader)
+	if len(given) == 0 || given[0] == "" {
+		return ctx, &APIError{Status: http.StatusUnauthorized, Code: codeUnauthorized,
+			Message: "an API key is required, pass it in the " + strings.ToLower(apiKeyHeader) + " metadata"}
+	}
+	key, err := findAPIKey(ctx, given[0])
+	if err != nil {
+		return ctx, err
+	}
+	if key == nil {
+		return ctx, &APIError{Status: http.StatusUnauthorized, Code: codeUnauthorized, Message: "invalid API key"}
+	}
+	return withAPIKey(ctx, key), nil
+}
+
+// admitScan refuses new scans while the server drains and those of an API
+// key past its rate limit, as rejectWhileDraining and limitScans do.
+func admitScan(ctx context.Context) error {
+	if draining.Load() {
+		return errShuttingDown()
+	}
+	if key, ok := ctx.Value(apiKeyContextKey{}).(*APIKey); ok {
+		if _, _, apiErr := takeScan(key); apiErr != nil {
+			return apiErr
+		}
+	}
+	return nil
+}
+
+// grpcError turns err into a status with the code of the API error it maps
+// to, carrying the API error code as the reason of an ErrorInfo.
+func grpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, err.Error())
+	}
+	apiErr := toAPIError(err)
+	info := &errdetails.ErrorInfo{Reason: apiErr.Code, Domain: "rgc", Metadata: map[string]string{}}
+	for k, v := range apiErr.Details {
+		info.Metadata[k] = fmt.Sprint(v)
+	}
+	if apiErr.Retryable {
+		info.Metadata["retryable"] = "true"
+	}
+	st := status.New(grpcCode(apiErr), apiErr.Message)
+	if detailed, err := st.WithDetails(info); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+func grpcCode(apiErr *APIError) codes.Code {
+	switch apiErr.Status {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.FailedPrecondition
+	case http.StatusUnprocessableEntity:
+		if apiErr.Code == codeLimitExceeded {
+			return codes.ResourceExhausted
+		}
+		return codes.InvalidArgument
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	return codes.Internal
+}
+
+// scanService implements the gRPC API over the same scans, jobs and scan
+// history as the REST API.
+type scanService struct {
+	rgcpb.UnimplementedScanServiceServer
+}
+
+func (scanService) Scan(ctx context.Context, req *rgcpb.ScanRequest) (*rgcpb.Analysis, error) {
+	payload, err := payloadFromProto(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateRepo("owner", payload.Username, payload.Repo); err != nil {
+		return nil, err
+	}
+	if err := checkScanAccess(ctx, payload.Username, payload.Repo, payload.Token); err != nil {
+		return nil, err
+	}
+	if err := admitScan(ctx); err != nil {
+		return nil, err
+	}
+	opts := payload.scanOptions()
+	opts.fromRequest(ctx)
+	result, err := ProcessRepository(payload.Username, payload.Repo, opts)
+	if err != nil {
+		return nil, err
+	}
+	analysis, err := recordAnalysis(ctx, payload.Username, payload.Repo, result, opts)
+	if err != nil {
+		return nil, err
+	}
+	return analysisToProto(analysis)
+}
+
+func (scanService) GetScan(ctx context.Context, req *rgcpb.GetScanRequest) (*rgcpb.Analysis, error) {
+	if err := checkRepoAccess(ctx, req.GetOwner(), req.GetRepo(), roleRead); err != nil {
+		return nil, err
+	}
+	analysis, err := analyses.get(ctx, req.GetOwner(), req.GetRepo(), req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	return analysisToProto(analysis)
+}
+
+func (scanService) Diff(ctx context.Context, req *rgcpb.DiffRequest) (*rgcpb.DiffResponse, error) {
+	if req.GetOwner() == "" || req.GetRepo() == "" || req.GetBase() == "" || req.GetHead() == "" {
+		return nil, errBadRequest("owner, repo, base and head are required")
+	}
+	if err := admitScan(ctx); err != nil {
+		return nil, err
+	}
+	base, head, diff, err := diffRefs(ctx, req.GetOwner(), req.GetRepo(), req.GetBase(), req.GetHead())
+	if err != nil {
+		return nil, err
+	}
+	return &rgcpb.DiffResponse{
+		Base: refScanToProto(base),
+		Head: refScanToProto(head),
+		Changes: &rgcpb.Changes{
+			From:         diff.From,
+			To:           diff.To,
+			Added:        diff.Added,
+			Removed:      diff.Removed,
+			BecameUsed:   diff.BecameUsed,
+			BecameUnused: diff.BecameUnused,
+		},
+	}, nil
+}
+
+func (scanService) StreamProgress(req *rgcpb.StreamProgressRequest, stream grpc.ServerStreamingServer[rgcpb.ScanEvent]) error {
+	ctx := stream.Context()
+	id := req.GetJobId()
+	if scan := req.GetScan(); scan != nil {
+		payload, err := payloadFromProto(scan)
+		if err != nil {
+			return err
+		}
+		if err := admitScan(ctx); err != nil {
+			return err
+		}
+		job, err := startJob(ctx, payload)
+		if err != nil {
+			return err
+		}
+		id = job.ID
+	}
+	if id == "" {
+		return errBadRequest("scan or job_id is required")
+	}
+
+	job, _ := jobs.get(id)
+	if job == nil {
+		return errNotFound("job not found")
+	}
+	if err := stream.Send(&rgcpb.ScanEvent{Event: &rgcpb.ScanEvent_Job{Job: jobToProto(job)}}); err != nil {
+		return err
+	}
+	job, err := jobs.watch(ctx, id, func(progress *ProgressSnapshot) error {
+		return stream.Send(&rgcpb.ScanEvent{Event: &rgcpb.ScanEvent_Progress{Progress: &rgcpb.Progress{
+			Phase:       progress.Phase,
+			FilesParsed: int32(progress.FilesParsed),
+			TotalFiles:  int32(progress.TotalFiles),
+			Percent:     int32(progress.Percent),
+		}}})
+	})
+	if err != nil {
+		return err
+	}
+	if job.Status == jobFailed {
+		return job.Error
+	}
+	analysis, err := analyses.get(ctx, job.Owner, job.Repo, job.AnalysisID)
+	if err != nil {
+		return err
+	}
+	result, err := analysisToProto(analysis)
+	if err != nil {
+		return err
+	}
+	return stream.Send(&rgcpb.ScanEvent{Event: &rgcpb.ScanEvent_Result{Result: result}})
+}
+
+// payloadFromProto reads a scan request: its options are the fields of the
+// POST /garbage payload, which the request's own fields override.
+func payloadFromProto(req *rgcpb.ScanRequest) (RequestPayload, error) {
+	var payload RequestPayload
+	if options := req.GetOptions(); options != nil {
+		encoded, err := options.MarshalJSON()
+		if err != nil {
+			return payload, errBadRequest("invalid options: %v", err)
+		}
+		if err := json.Unmarshal(encoded, &payload); err != nil {
+			return payload, errBadRequest("invalid options: %v", err)
+		}
+	}
+	payload.Username, payload.Repo = req.GetOwner(), req.GetRepo()
+	for field, value := range map[*string]string{
+		&payload.Ref:   req.GetRef(),
+		&payload.Mode:  req.GetMode(),
+		&payload.Token: req.GetToken(),
+	} {
+		if value != "" {
+			*field = value
+		}
+	}
+	return payload, nil
+}
+
+func analysisToProto(a *Analysis) (*rgcpb.Analysis, error) {
+	encoded, err := json.Marshal(a.Result)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+	components, err := structpb.NewStruct(fields)
+	if err != nil {
+		return nil, err
+	}
+	return &rgcpb.Analysis{
+		Id:        a.ID,
+		Owner:     a.Owner,
+		Repo:      a.Repo,
+		Ref:       a.Ref,
+		CreatedAt: timestamppb.New(a.CreatedAt),
+		Counts: &rgcpb.Counts{
+			Used:          int32(a.Result.UsedCount),
+			Unused:        int32(a.Result.UnusedCount),
+			TestOnly:      int32(a.Result.TestOnlyCount),
+			StorybookOnly: int32(a.Result.StorybookOnlyCount),
+			E2EOnly:       int32(a.Result.E2EOnlyCount),
+		},
+		Components: components,
+	}, nil
+}
+
+func refScanToProto(s *RefScan) *rgcpb.RefScan {
+	return &rgcpb.RefScan{Ref: s.Ref, Sha: s.SHA, AnalysisId: s.AnalysisID, Cached: s.Cached}
+}
+
+func jobToProto(j *Job) *rgcpb.Job {
+	return &rgcpb.Job{
+		Id:        j.ID,
+		Owner:     j.Owner,
+		Repo:      j.Repo,
+		Status:    j.Status,
+		CreatedAt: timestamppb.New(j.CreatedAt),
+	}
+}
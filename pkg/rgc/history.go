@@ -0,0 +1,156 @@
+package rgc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxAnalysesPerRepo = 20
+
+// Analysis is a completed scan of a repository.
+type Analysis struct {
+	ID        string    `json:"id"`
+	Owner     string    `json:"owner"`
+	Repo      string    `json:"repo"`
+	Ref       string    `json:"ref,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// OptionsHash identifies the options that shaped the result, see
+	// ScanOptions.hash.
+	OptionsHash string            `json:"options_hash,omitempty"`
+	Result      *ComponentsResult `json:"result"`
+}
+
+// ScanSummary is an analysis without its result, as listed in a
+// repository's scan history.
+type ScanSummary struct {
+	ID                 string    `json:"id"`
+	Owner              string    `json:"owner"`
+	Repo               string    `json:"repo"`
+	Ref                string    `json:"ref,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+	UsedCount          int       `json:"used_count"`
+	UnusedCount        int       `json:"unused_count"`
+	TestOnlyCount      int       `json:"test_only_count"`
+	StorybookOnlyCount int       `json:"storybook_only_count"`
+	E2EOnlyCount       int       `json:"e2e_only_count"`
+}
+
+func (a *Analysis) summary() ScanSummary {
+	s := ScanSummary{ID: a.ID, Owner: a.Owner, Repo: a.Repo, Ref: a.Ref, CreatedAt: a.CreatedAt}
+	if r := a.Result; r != nil {
+		s.UsedCount, s.UnusedCount = r.UsedCount, r.UnusedCount
+		s.TestOnlyCount, s.StorybookOnlyCount, s.E2EOnlyCount = r.TestOnlyCount, r.StorybookOnlyCount, r.E2EOnlyCount
+	}
+	return s
+}
+
+// analysisStore records finished analyses in the configured Storage and
+// lets callers wait for the next analysis of a repository.
+type analysisStore struct {
+	storage Storage
+
+	mu      sync.Mutex
+	waiters map[string]chan struct{}
+}
+
+var analyses = &analysisStore{
+	storage: newMemoryStorage(maxAnalysesPerRepo),
+	waiters: make(map[string]chan struct{}),
+}
+
+func repoKey(owner, repo string) string {
+	return strings.ToLower(owner + "/" + repo)
+}
+
+// add records a finished analysis and wakes up everyone waiting on the repository.
+func (s *analysisStore) add(ctx context.Context, owner, repo string, result *ComponentsResult, opts ScanOptions) (*Analysis, error) {
+	a := &Analysis{
+		Owner:       owner,
+		Repo:        repo,
+		CreatedAt:   time.Now().UTC(),
+		OptionsHash: opts.hash(),
+		Result:      result,
+	}
+	if result.Meta != nil {
+		a.Ref = result.Meta.HeadSHA
+	}
+	if err := s.storage.Save(ctx, a); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := repoKey(owner, repo)
+	if ch, ok := s.waiters[key]; ok {
+		close(ch)
+		delete(s.waiters, key)
+	}
+	return a, nil
+}
+
+// recordAnalysis adds a finished scan to the history and the audit log and,
+// when the scan asked for it, posts its commit status. Failing to post the status doesn't
+// lose the analysis, it's reported in the result's warnings instead.
+func recordAnalysis(ctx context.Context, owner, repo string, result *ComponentsResult, opts ScanOptions) (*Analysis, error) {
+	a, err := analyses.add(ctx, owner, repo, result, opts)
+	if err != nil {
+		return nil, err
+	}
+	auditAnalysis(ctx, a, opts)
+	if !opts.CommitStatus {
+		return a, nil
+	}
+
+	var previous *ScanSummary
+	if scans, err := analyses.list(ctx, owner, repo, 2); err == nil && len(scans) == 2 {
+		previous = &scans[1]
+	}
+	token := opts.Token
+	if token == "" {
+		token = os.Getenv("GITHUB_TOKEN")
+	}
+	if err := postCommitStatus(ctx, token, a, previous); err != nil {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("commit status not posted: %v", err))
+	}
+	return a, nil
+}
+
+// get returns the analysis with the given ID, or errAnalysisNotFound.
+func (s *analysisStore) get(ctx context.Context, owner, repo, id string) (*Analysis, error) {
+	return s.storage.Get(ctx, owner, repo, id)
+}
+
+// findByRef returns the latest analysis of the repository at commit ref
+// made with options, or nil.
+func (s *analysisStore) findByRef(ctx context.Context, owner, repo, ref string, opts ScanOptions) (*Analysis, error) {
+	return s.storage.FindByRef(ctx, owner, repo, ref, opts.hash())
+}
+
+// list returns the most recent analyses of a repository, newest first.
+func (s *analysisStore) list(ctx context.Context, owner, repo string, limit int) ([]ScanSummary, error) {
+	return s.storage.List(ctx, owner, repo, limit)
+}
+
+// next returns the analysis that followed since. When there is none yet it
+// returns a channel that is closed once a new analysis of the repository is added.
+func (s *analysisStore) next(ctx context.Context, since *Analysis) (*Analysis, <-chan struct{}, error) {
+	// Register before looking, so an analysis added in between isn't missed.
+	s.mu.Lock()
+	key := repoKey(since.Owner, since.Repo)
+	ch, ok := s.waiters[key]
+	if !ok {
+		ch = make(chan struct{})
+		s.waiters[key] = ch
+	}
+	s.mu.Unlock()
+
+	a, err := s.storage.After(ctx, since)
+	if err != nil || a != nil {
+		return a, nil, err
+	}
+	return nil, ch, nil
+}
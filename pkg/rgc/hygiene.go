@@ -0,0 +1,158 @@
+package rgc
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/igorfelipeduca/rgc/internal/jsparse"
+)
+
+// maxRelativeDepth is how many "../" an import may climb before it's
+// reported as a deep relative import.
+const maxRelativeDepth = 2
+
+// Kinds of import hygiene issues.
+const (
+	hygieneDeepRelative     = "deep-relative"
+	hygieneBarrelBypass     = "barrel-bypass"
+	hygieneFeatureInternals = "feature-internals"
+)
+
+// featureDirs are the directory names whose subdirectories are features,
+// each with its own internals, e.g. features/cart/.
+var featureDirs = map[string]bool{"features": true, "modules": true}
+
+// HygieneIssue is an import that works but hurts maintainability.
+type HygieneIssue struct {
+	Kind      string `json:"kind"`
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Specifier string `json:"specifier"`
+	Target    string `json:"target,omitempty"`
+	// Suggestion is a better specifier to import from, when there is one.
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// HygieneReport aggregates the import hygiene issues of a repository.
+type HygieneReport struct {
+	DeepRelative     int            `json:"deep_relative"`
+	BarrelBypass     int            `json:"barrel_bypass"`
+	FeatureInternals int            `json:"feature_internals"`
+	Issues           []HygieneIssue `json:"issues"`
+}
+
+// checkImportHygiene looks for deep relative imports, imports reaching past
+// a barrel file to the module behind it, and imports into the internals of
+// another feature.
+func (sc *scan) checkImportHygiene(ctx context.Context, importer string, file *jsparse.File) {
+	for _, imp := range file.Imports {
+		relative := strings.HasPrefix(imp.Specifier, ".")
+		target := sc.resolveModule(importer, imp.Specifier)
+		if !relative && target == "" {
+			continue // Packages
+		}
+		issue := HygieneIssue{File: importer, Line: imp.Line, Specifier: imp.Specifier, Target: target}
+
+		if relative && strings.Count(imp.Specifier, "../") > maxRelativeDepth {
+			issue.Kind = hygieneDeepRelative
+			sc.addHygieneIssue(issue)
+		}
+		if target == "" {
+			continue
+		}
+
+		if barrel := sc.barrelFor(ctx, importer, target); barrel != "" {
+			issue.Kind = hygieneBarrelBypass
+			issue.Suggestion = path.Dir(imp.Specifier)
+			sc.addHygieneIssue(issue)
+		}
+
+		if feature := featureOf(target); feature != "" && feature != featureOf(importer) && !isFeatureEntry(feature, target) {
+			issue.Kind = hygieneFeatureInternals
+			issue.Suggestion = ""
+			sc.addHygieneIssue(issue)
+		}
+	}
+}
+
+// barrelFor returns the index file re-exporting target from its directory,
+// when importer sits outside that directory and should use it instead.
+func (sc *scan) barrelFor(ctx context.Context, importer, target string) string {
+	dir := path.Dir(target)
+	if strings.HasPrefix(importer, dir+"/") {
+		return "" // Modules next to each other import each other directly
+	}
+	barrel := sc.resolveModule(target, "./index")
+	if barrel == "" || barrel == target {
+		return ""
+	}
+	file, err := sc.parseModule(ctx, barrel)
+	if err != nil {
+		return ""
+	}
+	for _, imp := range file.Imports {
+		if imp.Kind == jsparse.ReExport && imp.IsRuntime() && sc.resolveModule(barrel, imp.Specifier) == target {
+			return barrel
+		}
+	}
+	return ""
+}
+
+// featureOf returns the feature directory p belongs to, e.g.
+// "src/features/cart" for "src/features/cart/components/Item.tsx".
+func featureOf(p string) string {
+	segments := strings.Split(p, "/")
+	for i := 0; i < len(segments)-2; i++ {
+		if featureDirs[segments[i]] {
+			return strings.Join(segments[:i+2], "/")
+		}
+	}
+	return ""
+}
+
+// isFeatureEntry reports whether p is the public entry point of feature.
+func isFeatureEntry(feature, p string) bool {
+	return path.Dir(p) == feature && strings.TrimSuffix(path.Base(p), path.Ext(p)) == "index"
+}
+
+func (sc *scan) addHygieneIssue(issue HygieneIssue) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.hygieneIssues = append(sc.hygieneIssues, issue)
+}
+
+func (sc *scan) hygieneReport() *HygieneReport {
+	report := &HygieneReport{Issues: sc.hygieneIssues}
+	if report.Issues == nil {
+		report.Issues = []HygieneIssue{}
+	}
+	sort.Slice(report.Issues, func(i, j int) bool {
+		a, b := report.Issues[i], report.Issues[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Kind < b.Kind
+	})
+	report.count()
+	return report
+}
+
+// count sets the number of issues of each kind.
+func (r *HygieneReport) count() {
+	r.DeepRelative, r.BarrelBypass, r.FeatureInternals = 0, 0, 0
+	for _, issue := range r.Issues {
+		switch issue.Kind {
+		case hygieneDeepRelative:
+			r.DeepRelative++
+		case hygieneBarrelBypass:
+			r.BarrelBypass++
+		case hygieneFeatureInternals:
+			r.FeatureInternals++
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package rgc
+
+import (
+	"regexp"
+	"strings"
+)
+
+// IgnoredComponent is an unused component whose file opts out of the unused
+// list with an rgc-ignore comment, e.g.
+//
+//	// rgc-ignore: kept for marketing experiments
+type IgnoredComponent struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Reason string `json:"reason,omitempty"`
+}
+
+var (
+	ignoreCommentRegex = regexp.MustCompile(`^rgc-ignore(?:$|[\s:]\s*(.*))`)
+	// headerRegex matches what may come before the comment: directives such
+	// as "use client" and the opening tag of a Svelte script.
+	headerRegex = regexp.MustCompile(`^(?:["']use [a-z]+["'];?|<script[^>]*>)`)
+)
+
+// ignoreReason looks for an rgc-ignore comment among the comments at the top
+// of a component file, and returns the reason it gives.
+func ignoreReason(content string) (string, bool) {
+	s := content
+	for {
+		s = strings.TrimLeft(s, " \t\r\n\ufeff")
+		var comment string
+		var ok bool
+		switch {
+		case strings.HasPrefix(s, "//"):
+			comment, s, _ = strings.Cut(s[2:], "\n")
+		case strings.HasPrefix(s, "/*"):
+			if comment, s, ok = strings.Cut(s[2:], "*/"); !ok {
+				return "", false
+			}
+		case strings.HasPrefix(s, "<!--"):
+			if comment, s, ok = strings.Cut(s[4:], "-->"); !ok {
+				return "", false
+			}
+		default:
+			if m := headerRegex.FindString(s); m != "" {
+				s = s[len(m):]
+				continue
+			}
+			return "", false
+		}
+		for _, line := range strings.Split(comment, "\n") {
+			line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "*"))
+			if m := ignoreCommentRegex.FindStringSubmatch(line); m != nil {
+				return strings.TrimSpace(m[1]), true
+			}
+		}
+	}
+}
+
+func (sc *scan) markIgnored(p, reason string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.ignored == nil {
+		sc.ignored = make(map[string]string)
+	}
+	sc.ignored[p] = reason
+}
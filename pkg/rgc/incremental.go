@@ -0,0 +1,203 @@
+package rgc
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/google/go-github/v39/github"
+)
+
+// maxSnapshots is how many repository snapshots are kept in memory for
+// incremental rescans, and maxSnapshotBytes how large their files may be
+// altogether, the oldest being dropped first.
+const (
+	maxSnapshots     = 16
+	maxSnapshotBytes = 256 << 20
+)
+
+// maxCompareFiles is the most files the Compare API lists. A comparison
+// listing that many may have been cut, so it can't drive a rescan.
+const maxCompareFiles = 300
+
+// IncrementalScan describes a rescan that only fetched the files changed
+// since a previous analysis.
+type IncrementalScan struct {
+	// Since is the previous analysis and Base the commit it analyzed.
+	Since string `json:"since"`
+	Base  string `json:"base"`
+	// Changed counts the files changed in between, Fetched the files read
+	// from GitHub and Reused the files taken from the previous scan.
+	Changed int `json:"changed"`
+	Fetched int `json:"fetched"`
+	Reused  int `json:"reused"`
+}
+
+// snapshot holds the content of every file a scan read.
+type snapshot struct {
+	contents map[string]string
+	size     int
+}
+
+// snapshotStore keeps the snapshots of the latest api mode scans, keyed by
+// repository and commit.
+type snapshotStore struct {
+	mu      sync.Mutex
+	entries map[string]*snapshot
+	order   []string
+	size    int
+}
+
+var snapshots = &snapshotStore{entries: make(map[string]*snapshot)}
+
+func snapshotKey(owner, repo, sha string) string {
+	return repoKey(owner, repo) + "@" + sha
+}
+
+func (s *snapshotStore) get(owner, repo, sha string) *snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[snapshotKey(owner, repo, sha)]
+}
+
+// put keeps a snapshot, unless it alone is larger than maxSnapshotBytes,
+// dropping the oldest ones until the store fits its limits.
+func (s *snapshotStore) put(owner, repo, sha string, snap *snapshot) {
+	if snap.size > maxSnapshotBytes {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := snapshotKey(owner, repo, sha)
+	if old, ok := s.entries[key]; ok {
+		s.size -= old.size
+		s.order = slices.DeleteFunc(s.order, func(k string) bool { return k == key })
+	}
+	s.order = append(s.order, key)
+	s.entries[key] = snap
+	s.size += snap.size
+	for len(s.order) > maxSnapshots || s.size > maxSnapshotBytes {
+		s.size -= s.entries[s.order[0]].size
+		delete(s.entries, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// recordingSource remembers the content of every file read through it.
+type recordingSource struct {
+	Source
+
+	mu       sync.Mutex
+	contents map[string]string
+}
+
+func newRecordingSource(src Source) *recordingSource {
+	return &recordingSource{Source: src, contents: make(map[string]string)}
+}
+
+func (s *recordingSource) ReadFile(ctx context.Context, p string) (string, error) {
+	content, err := s.Source.ReadFile(ctx, p)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.contents[p] = content
+	s.mu.Unlock()
+	return content, nil
+}
+
+// Warnings passes on the wrapped Source's warnings, if it has any.
+func (s *recordingSource) Warnings() []string {
+	if src, ok := s.Source.(interface{ Warnings() []string }); ok {
+		return src.Warnings()
+	}
+	return nil
+}
+
+func (s *recordingSource) snapshot() *snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := &snapshot{contents: s.contents}
+	for _, content := range s.contents {
+		snap.size += len(content)
+	}
+	return snap
+}
+
+// incrementalSource reads the files that didn't change since a snapshot
+// from it, and the others from the wrapped Source.
+type incrementalSource struct {
+	Source
+	base    *snapshot
+	changed map[string]bool
+
+	mu              sync.Mutex
+	fetched, reused int
+}
+
+func (s *incrementalSource) ReadFile(ctx context.Context, p string) (string, error) {
+	if content, ok := s.base.contents[p]; ok && !s.changed[p] {
+		s.mu.Lock()
+		s.reused++
+		s.mu.Unlock()
+		return content, nil
+	}
+	s.mu.Lock()
+	s.fetched++
+	s.mu.Unlock()
+	return s.Source.ReadFile(ctx, p)
+}
+
+// Warnings passes on the wrapped Source's warnings, if it has any.
+func (s *incrementalSource) Warnings() []string {
+	if src, ok := s.Source.(interface{ Warnings() []string }); ok {
+		return src.Warnings()
+	}
+	return nil
+}
+
+// incrementalBase finds what a rescan can start from: the snapshot of the
+// analysis since and the files changed between its commit and head, given
+// or compared. It explains in a warning why the rescan can't be
+// incremental when it returns no snapshot.
+func incrementalBase(ctx context.Context, client *github.Client, owner, repo, since, head string, changedFiles []string) (*Analysis, *snapshot, map[string]bool, string, error) {
+	prev, err := analyses.get(ctx, owner, repo, since)
+	if err != nil {
+		return nil, nil, nil, "", err
+	}
+	snap := snapshots.get(owner, repo, prev.Ref)
+	if prev.Ref == "" || snap == nil {
+		return prev, nil, nil, fmt.Sprintf("the files of analysis %s aren't kept anymore, the repository was scanned in full", since), nil
+	}
+
+	changed := make(map[string]bool)
+	if changedFiles != nil {
+		for _, p := range changedFiles {
+			changed[p] = true
+		}
+		return prev, snap, changed, "", nil
+	}
+	if prev.Ref == head {
+		return prev, snap, changed, "", nil
+	}
+
+	// Only the first page of a comparison lists the files.
+	comparison, _, err := client.Repositories.CompareCommits(ctx, owner, repo, prev.Ref, head, nil)
+	if err != nil {
+		return nil, nil, nil, "", fmt.Errorf("error comparing %s with %s: %w", prev.Ref, head, err)
+	}
+	if status := comparison.GetStatus(); status != "ahead" && status != "identical" {
+		return prev, nil, nil, fmt.Sprintf("%s isn't ahead of the commit of analysis %s, the repository was scanned in full", head, since), nil
+	}
+	if len(comparison.Files) >= maxCompareFiles {
+		return prev, nil, nil, fmt.Sprintf("too many files changed since analysis %s, the repository was scanned in full", since), nil
+	}
+	for _, f := range comparison.Files {
+		changed[f.GetFilename()] = true
+		if f.GetPreviousFilename() != "" {
+			changed[f.GetPreviousFilename()] = true
+		}
+	}
+	return prev, snap, changed, "", nil
+}
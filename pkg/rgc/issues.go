@@ -0,0 +1,316 @@
+package rgc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v39/github"
+)
+
+const (
+	// maxIssuesPerScan bounds how many issues a scan files, so a repository
+	// whose entry points changed doesn't get hundreds at once.
+	maxIssuesPerScan = 20
+	// maxIssuePages bounds how many pages of issues are searched for the ones
+	// already filed.
+	maxIssuePages = 10
+	// maxChecklistItems keeps the checklist well under GitHub's body size
+	// limit.
+	maxChecklistItems = 500
+
+	checklistMarker = "<!-- rgc:dead-code-checklist -->"
+)
+
+// componentMarkerPattern finds the component an issue was filed for.
+var componentMarkerPattern = regexp.MustCompile(`<!-- rgc:unused-component (.+?) -->`)
+
+// checklistItemPattern finds a component and whether it's ticked in the
+// checklist issue.
+var checklistItemPattern = regexp.MustCompile("(?m)^- \\[([ xX])\\] `([^`]+)`")
+
+// IssueFiling files GitHub issues about the unused components found by the
+// scheduled scans of a repository, so their cleanup lands in the backlog.
+type IssueFiling struct {
+	// Mode is "per_component", one issue per component that became unused,
+	// or "checklist", one rolling issue listing every unused component.
+	Mode string `json:"mode"`
+	// Labels are added to the issues, RGC_PR_LABELS by default.
+	Labels []string `json:"labels,omitempty"`
+}
+
+func validateIssueFiling(f *IssueFiling) error {
+	if f == nil {
+		return nil
+	}
+	if f.Mode != "per_component" && f.Mode != "checklist" {
+		return errBadRequest("issues.mode must be per_component or checklist")
+	}
+	return nil
+}
+
+func (f *IssueFiling) labels() []string {
+	if f.Labels != nil {
+		return f.Labels
+	}
+	return loadPRTriage().Labels
+}
+
+func componentMarker(path string) string {
+	return fmt.Sprintf("<!-- rgc:unused-component %s -->", path)
+}
+
+// fileIssues files the issues a scheduled scan calls for. Failures are
+// logged and don't fail the scan.
+func fileIssues(ctx context.Context, sched *Schedule, a *Analysis) {
+	if sched.Issues == nil {
+		return
+	}
+	logger := loggerFrom(ctx)
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		logger.Warn("issues not filed", "error", errTokenNotSet)
+		return
+	}
+	client := newGitHubClient(token)
+	info, err := inspectToken(ctx, client)
+	if err == nil {
+		err = info.check(a.Result.Meta != nil && a.Result.Meta.Private, opWriteIssues)
+	}
+	if err != nil {
+		logger.Warn("issues not filed", "error", err)
+		return
+	}
+	filer := &issueFiler{client: client, filing: sched.Issues, analysis: a}
+	if sched.Issues.Mode == "checklist" {
+		err = filer.updateChecklist(ctx)
+	} else {
+		err = filer.fileComponentIssues(ctx)
+	}
+	if err != nil {
+		logger.Warn("issues not filed", "mode", sched.Issues.Mode, "error", err)
+	}
+}
+
+type issueFiler struct {
+	client   *github.Client
+	filing   *IssueFiling
+	analysis *Analysis
+}
+
+// filedIssues returns the issues RGC filed on the repository, open and
+// closed, narrowed to the first label when there is one.
+func (f *issueFiler) filedIssues(ctx context.Context) ([]*github.Issue, error) {
+	opts := &github.IssueListByRepoOptions{State: "all", ListOptions: github.ListOptions{PerPage: 100}}
+	if labels := f.filing.labels(); len(labels) > 0 {
+		opts.Labels = labels[:1]
+	}
+	var filed []*github.Issue
+	for page := 0; page < maxIssuePages; page++ {
+		issues, resp, err := f.client.Issues.ListByRepo(ctx, f.analysis.Owner, f.analysis.Repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("error listing issues: %w", err)
+		}
+		for _, issue := range issues {
+			body := issue.GetBody()
+			if !issue.IsPullRequest() && (strings.Contains(body, checklistMarker) || componentMarkerPattern.MatchString(body)) {
+				filed = append(filed, issue)
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return filed, nil
+}
+
+// fileComponentIssues opens an issue per component that became unused since
+// the previous scan, unless one was ever filed for it, and closes the open
+// ones of components that are used again or gone.
+func (f *issueFiler) fileComponentIssues(ctx context.Context) error {
+	a := f.analysis
+	summary, err := summarizeScan(ctx, a)
+	if err != nil {
+		return err
+	}
+	filed, err := f.filedIssues(ctx)
+	if err != nil {
+		return err
+	}
+	byPath := make(map[string]*github.Issue)
+	for _, issue := range filed {
+		if m := componentMarkerPattern.FindStringSubmatch(issue.GetBody()); m != nil {
+			byPath[m[1]] = issue
+		}
+	}
+
+	entries := make(map[string]CatalogEntry)
+	for _, e := range buildCatalog(a.Owner+"/"+a.Repo, a.Result).Components {
+		entries[e.Path] = e
+	}
+	opened := 0
+	for _, path := range summary.newUnused {
+		if _, ok := byPath[path]; ok {
+			continue
+		}
+		if opened == maxIssuesPerScan {
+			break
+		}
+		req := &github.IssueRequest{
+			Title: github.String(fmt.Sprintf("Unused component: %s", path)),
+			Body:  github.String(componentIssueBody(a, entries[path])),
+		}
+		if labels := f.filing.labels(); len(labels) > 0 {
+			req.Labels = &labels
+		}
+		if _, _, err := f.client.Issues.Create(ctx, a.Owner, a.Repo, req); err != nil {
+			return fmt.Errorf("error opening issue for %s: %w", path, err)
+		}
+		opened++
+	}
+
+	for path, issue := range byPath {
+		if issue.GetState() != "open" || entries[path].Status == "unused" {
+			continue
+		}
+		reason := "is used again"
+		if _, ok := entries[path]; !ok {
+			reason = "was deleted"
+		}
+		comment := fmt.Sprintf("`%s` %s as of %s, closing.", path, reason, analysisName(a))
+		if _, _, err := f.client.Issues.CreateComment(ctx, a.Owner, a.Repo, issue.GetNumber(), &github.IssueComment{Body: &comment}); err != nil {
+			return fmt.Errorf("error closing issue #%d: %w", issue.GetNumber(), err)
+		}
+		if _, _, err := f.client.Issues.Edit(ctx, a.Owner, a.Repo, issue.GetNumber(), &github.IssueRequest{State: github.String("closed")}); err != nil {
+			return fmt.Errorf("error closing issue #%d: %w", issue.GetNumber(), err)
+		}
+	}
+	return nil
+}
+
+func componentIssueBody(a *Analysis, e CatalogEntry) string {
+	var details []string
+	if e.Confidence != nil {
+		details = append(details, fmt.Sprintf("Confidence it's safe to delete: %.2f", e.Confidence.Score))
+	}
+	if len(e.Owners) > 0 {
+		details = append(details, "Owners: "+strings.Join(e.Owners, ", "))
+	}
+	if e.LastCommit != nil {
+		details = append(details, fmt.Sprintf("Last changed by %s on %s", e.LastCommit.Author, e.LastCommit.Date.Format("2006-01-02")))
+	}
+	if link := reportURL(a.Owner, a.Repo, a.ID); link != "" {
+		details = append(details, fmt.Sprintf("[Report](%s)", link))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "`%s` isn't imported by any entry point as of %s, so it can likely be deleted.\n\n", e.Path, analysisName(a))
+	for _, d := range details {
+		fmt.Fprintf(&b, "- %s\n", d)
+	}
+	if len(details) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("Closing this issue without deleting the component keeps it from being filed again.\n\n")
+	b.WriteString(componentMarker(e.Path))
+	return b.String()
+}
+
+// updateChecklist maintains the rolling checklist issue: every unused
+// component unticked, the ones listed before that no longer are ticked.
+// It's opened when there are unused components and closed once none are
+// left.
+func (f *issueFiler) updateChecklist(ctx context.Context) error {
+	a := f.analysis
+	filed, err := f.filedIssues(ctx)
+	if err != nil {
+		return err
+	}
+	var issue *github.Issue
+	for _, i := range filed {
+		if i.GetState() == "open" && strings.Contains(i.GetBody(), checklistMarker) {
+			issue = i
+			break
+		}
+	}
+	if issue == nil && a.Result.UnusedCount == 0 {
+		return nil
+	}
+
+	unused := make([]string, 0, len(a.Result.Unused))
+	for _, node := range a.Result.Unused {
+		unused = append(unused, node.Component.Path)
+	}
+	sort.Strings(unused)
+	var done []string
+	if issue != nil {
+		isUnused := make(map[string]bool, len(unused))
+		for _, p := range unused {
+			isUnused[p] = true
+		}
+		for _, m := range checklistItemPattern.FindAllStringSubmatch(issue.GetBody(), -1) {
+			if !isUnused[m[2]] {
+				done = append(done, m[2])
+			}
+		}
+	}
+
+	req := &github.IssueRequest{
+		Title: github.String(fmt.Sprintf("Dead code: %s", statusDescription(a.Result.UnusedCount, nil))),
+		Body:  github.String(checklistBody(a, unused, done)),
+	}
+	if issue == nil {
+		if labels := f.filing.labels(); len(labels) > 0 {
+			req.Labels = &labels
+		}
+		if _, _, err := f.client.Issues.Create(ctx, a.Owner, a.Repo, req); err != nil {
+			return fmt.Errorf("error opening checklist issue: %w", err)
+		}
+		return nil
+	}
+	if a.Result.UnusedCount == 0 {
+		req.State = github.String("closed")
+	}
+	if _, _, err := f.client.Issues.Edit(ctx, a.Owner, a.Repo, issue.GetNumber(), req); err != nil {
+		return fmt.Errorf("error updating checklist issue #%d: %w", issue.GetNumber(), err)
+	}
+	return nil
+}
+
+func checklistBody(a *Analysis, unused, done []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Components no entry point imports as of %s. Each can likely be deleted; RGC ticks them off once they're gone or used again.\n\n", analysisName(a))
+	if link := reportURL(a.Owner, a.Repo, a.ID); link != "" {
+		fmt.Fprintf(&b, "[Full report](%s)\n\n", link)
+	}
+	items := 0
+	for _, p := range unused {
+		if items == maxChecklistItems {
+			fmt.Fprintf(&b, "\n…and %d more\n", len(unused)-items)
+			break
+		}
+		fmt.Fprintf(&b, "- [ ] `%s`\n", p)
+		items++
+	}
+	for _, p := range done {
+		if items >= maxChecklistItems {
+			break
+		}
+		fmt.Fprintf(&b, "- [x] `%s`\n", p)
+		items++
+	}
+	b.WriteString("\n" + checklistMarker)
+	return b.String()
+}
+
+// analysisName names an analysis in issue text: its commit, or its ID.
+func analysisName(a *Analysis) string {
+	if a.Ref != "" {
+		return fmt.Sprintf("commit %.7s", a.Ref)
+	}
+	return "scan " + a.ID
+}
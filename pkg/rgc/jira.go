@@ -0,0 +1,410 @@
+package rgc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// jiraTimeout bounds each request to Jira.
+	jiraTimeout = 30 * time.Second
+	// maxJiraSearchResults bounds how many open tickets are looked through
+	// for the ones already created.
+	maxJiraSearchResults = 1000
+	defaultJiraIssueType = "Task"
+	defaultJiraLabel     = "rgc"
+)
+
+// jiraProjectPattern matches Jira project keys, which go into JQL queries.
+var jiraProjectPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,254}$`)
+
+// JiraFiling creates Jira tickets for the components that became unused
+// between two scans.
+type JiraFiling struct {
+	// Project is the key of the project tickets go to, RGC_JIRA_PROJECT by
+	// default.
+	Project string `json:"project,omitempty"`
+	// IssueType is the name of the tickets' issue type, RGC_JIRA_ISSUE_TYPE
+	// or "Task" by default.
+	IssueType string `json:"issue_type,omitempty"`
+	// Labels are added to the tickets besides "rgc", which finds them back.
+	Labels []string `json:"labels,omitempty"`
+}
+
+// JiraTicket is a ticket created for an unused component.
+type JiraTicket struct {
+	Key  string `json:"key"`
+	URL  string `json:"url"`
+	Path string `json:"path"`
+}
+
+// JiraResult reports what filing tickets for a diff did.
+type JiraResult struct {
+	From    string       `json:"from"`
+	To      string       `json:"to"`
+	Created []JiraTicket `json:"created"`
+	// Existing are the components that already have an open ticket.
+	Existing []string `json:"existing"`
+}
+
+// jiraConfig is the Jira instance tickets are created on.
+type jiraConfig struct {
+	url   string
+	email string
+	token string
+}
+
+// loadJiraConfig reads RGC_JIRA_URL and RGC_JIRA_TOKEN, with RGC_JIRA_EMAIL
+// for Jira Cloud API tokens. Without an email the token is sent as a Data
+// Center personal access token.
+func loadJiraConfig() (*jiraConfig, bool) {
+	cfg := &jiraConfig{
+		url:   strings.TrimSuffix(os.Getenv("RGC_JIRA_URL"), "/"),
+		email: os.Getenv("RGC_JIRA_EMAIL"),
+		token: os.Getenv("RGC_JIRA_TOKEN"),
+	}
+	return cfg, cfg.url != "" && cfg.token != ""
+}
+
+// jiraSettings describes the Jira settings, without the token.
+func jiraSettings() gin.H {
+	cfg, ok := loadJiraConfig()
+	if !ok {
+		return gin.H{"enabled": false}
+	}
+	return gin.H{
+		"enabled":    true,
+		"url":        cfg.url,
+		"email":      cfg.email,
+		"project":    os.Getenv("RGC_JIRA_PROJECT"),
+		"issue_type": envOr("RGC_JIRA_ISSUE_TYPE", defaultJiraIssueType),
+	}
+}
+
+// withDefaults fills the project and issue type from the environment.
+func (f JiraFiling) withDefaults() JiraFiling {
+	if f.Project == "" {
+		f.Project = os.Getenv("RGC_JIRA_PROJECT")
+	}
+	if f.IssueType == "" {
+		f.IssueType = envOr("RGC_JIRA_ISSUE_TYPE", defaultJiraIssueType)
+	}
+	return f
+}
+
+func validateJiraFiling(f *JiraFiling) error {
+	if f == nil {
+		return nil
+	}
+	if _, ok := loadJiraConfig(); !ok {
+		return errBadRequest("jira needs RGC_JIRA_URL and RGC_JIRA_TOKEN to be set")
+	}
+	project := f.withDefaults().Project
+	if project == "" {
+		return errBadRequest("jira.project is required when RGC_JIRA_PROJECT isn't set")
+	}
+	if !jiraProjectPattern.MatchString(project) {
+		return errBadRequest("jira.project must be a project key such as WEB, got %q", project)
+	}
+	for _, label := range f.Labels {
+		if label == "" || strings.ContainsAny(label, " \t\r\n") {
+			return errBadRequest("Jira labels can't be empty or contain spaces, got %q", label)
+		}
+	}
+	return nil
+}
+
+// jiraError is an error response from Jira.
+type jiraError struct {
+	status   int
+	messages []string
+}
+
+func (e *jiraError) Error() string {
+	if len(e.messages) == 0 {
+		return fmt.Sprintf("Jira answered %d", e.status)
+	}
+	return fmt.Sprintf("Jira answered %d: %s", e.status, strings.Join(e.messages, "; "))
+}
+
+// do sends a request to the Jira REST API and decodes its answer into out.
+func (cfg *jiraConfig) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	ctx, cancel := context.WithTimeout(ctx, jiraTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, cfg.url+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if cfg.email != "" {
+		req.SetBasicAuth(cfg.email, cfg.token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+cfg.token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling Jira: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var problem struct {
+			ErrorMessages []string          `json:"errorMessages"`
+			Errors        map[string]string `json:"errors"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&problem)
+		e := &jiraError{status: resp.StatusCode, messages: problem.ErrorMessages}
+		for field, msg := range problem.Errors {
+			e.messages = append(e.messages, field+": "+msg)
+		}
+		return e
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding Jira response: %v", err)
+	}
+	return nil
+}
+
+type jiraSearchPage struct {
+	Issues []struct {
+		Key    string `json:"key"`
+		Fields struct {
+			Summary string `json:"summary"`
+		} `json:"fields"`
+	} `json:"issues"`
+	// Total is set by the classic search, NextPageToken by Jira Cloud's.
+	Total         int    `json:"total"`
+	NextPageToken string `json:"nextPageToken"`
+	IsLast        bool   `json:"isLast"`
+}
+
+// openTicketSummaries returns the summaries of the open tickets RGC created
+// in project. Jira Cloud only has the enhanced search, Data Center only the
+// classic one.
+func (cfg *jiraConfig) openTicketSummaries(ctx context.Context, project string) (map[string]bool, error) {
+	jql := fmt.Sprintf(`project = "%s" AND labels = "%s" AND statusCategory != Done`, project, defaultJiraLabel)
+	summaries := make(map[string]bool)
+	q := url.Values{"jql": {jql}, "fields": {"summary"}, "maxResults": {"100"}}
+	for fetched := 0; fetched < maxJiraSearchResults; {
+		var page jiraSearchPage
+		err := cfg.do(ctx, http.MethodGet, "/rest/api/2/search/jql?"+q.Encode(), nil, &page)
+		var jiraErr *jiraError
+		if errors.As(err, &jiraErr) && jiraErr.status == http.StatusNotFound {
+			return cfg.classicSearch(ctx, jql, summaries)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range page.Issues {
+			summaries[issue.Fields.Summary] = true
+		}
+		fetched += len(page.Issues)
+		if page.IsLast || page.NextPageToken == "" || len(page.Issues) == 0 {
+			break
+		}
+		q.Set("nextPageToken", page.NextPageToken)
+	}
+	return summaries, nil
+}
+
+func (cfg *jiraConfig) classicSearch(ctx context.Context, jql string, summaries map[string]bool) (map[string]bool, error) {
+	q := url.Values{"jql": {jql}, "fields": {"summary"}, "maxResults": {"100"}}
+	for startAt := 0; startAt < maxJiraSearchResults; {
+		q.Set("startAt", fmt.Sprint(startAt))
+		var page jiraSearchPage
+		if err := cfg.do(ctx, http.MethodGet, "/rest/api/2/search?"+q.Encode(), nil, &page); err != nil {
+			return nil, err
+		}
+		for _, issue := range page.Issues {
+			summaries[issue.Fields.Summary] = true
+		}
+		startAt += len(page.Issues)
+		if len(page.Issues) == 0 || startAt >= page.Total {
+			break
+		}
+	}
+	return summaries, nil
+}
+
+func jiraSummary(a *Analysis, path string) string {
+	return fmt.Sprintf("Remove unused component %s in %s/%s", path, a.Owner, a.Repo)
+}
+
+// jiraDescription describes the component in Jira's wiki markup.
+func jiraDescription(a *Analysis, e CatalogEntry) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "{{%s}} in %s/%s isn't imported by any entry point as of %s, so it can likely be deleted.\n\n", e.Path, a.Owner, a.Repo, analysisName(a))
+	if e.Size > 0 {
+		fmt.Fprintf(&b, "* Size: %d bytes\n", e.Size)
+	}
+	if e.LastCommit != nil {
+		fmt.Fprintf(&b, "* Last author: %s, %s\n", e.LastCommit.Author, e.LastCommit.Date.Format("2006-01-02"))
+	}
+	if len(e.Owners) > 0 {
+		fmt.Fprintf(&b, "* Owners: %s\n", strings.Join(e.Owners, ", "))
+	}
+	if e.Confidence != nil {
+		fmt.Fprintf(&b, "* Confidence it's safe to delete: %.2f\n", e.Confidence.Score)
+	}
+	if link := reportURL(a.Owner, a.Repo, a.ID); link != "" {
+		fmt.Fprintf(&b, "* [Report|%s]\n", link)
+	}
+	return b.String()
+}
+
+// fileJiraTickets creates a ticket per component unused in a but not in
+// from, skipping those with an open ticket already, at most
+// maxIssuesPerScan of them.
+func fileJiraTickets(ctx context.Context, filing JiraFiling, from, a *Analysis) (*JiraResult, error) {
+	cfg, ok := loadJiraConfig()
+	if !ok {
+		return nil, errBadRequest("RGC_JIRA_URL and RGC_JIRA_TOKEN aren't set")
+	}
+	filing = filing.withDefaults()
+	open, err := cfg.openTicketSummaries(ctx, filing.Project)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]CatalogEntry)
+	for _, e := range buildCatalog(a.Owner+"/"+a.Repo, a.Result).Components {
+		entries[e.Path] = e
+	}
+	labels := append([]string{defaultJiraLabel}, filing.Labels...)
+	result := &JiraResult{From: from.ID, To: a.ID, Created: []JiraTicket{}, Existing: []string{}}
+	for _, path := range newlyUnused(from.Result, a.Result) {
+		summary := jiraSummary(a, path)
+		if open[summary] {
+			result.Existing = append(result.Existing, path)
+			continue
+		}
+		if len(result.Created) == maxIssuesPerScan {
+			break
+		}
+		fields := map[string]interface{}{
+			"project":     map[string]string{"key": filing.Project},
+			"issuetype":   map[string]string{"name": filing.IssueType},
+			"summary":     summary,
+			"description": jiraDescription(a, entries[path]),
+			"labels":      labels,
+		}
+		var created struct {
+			Key string `json:"key"`
+		}
+		if err := cfg.do(ctx, http.MethodPost, "/rest/api/2/issue", map[string]interface{}{"fields": fields}, &created); err != nil {
+			return result, fmt.Errorf("error creating Jira ticket for %s: %w", path, err)
+		}
+		result.Created = append(result.Created, JiraTicket{Key: created.Key, URL: cfg.url + "/browse/" + created.Key, Path: path})
+	}
+	return result, nil
+}
+
+// fileScheduledJiraTickets files the tickets of a scheduled scan against the
+// one before it. Failures are logged and don't fail the scan.
+func fileScheduledJiraTickets(ctx context.Context, sched *Schedule, a *Analysis) {
+	if sched.Jira == nil {
+		return
+	}
+	logger := loggerFrom(ctx)
+	previous, err := previousAnalysis(ctx, a)
+	if err != nil {
+		logger.Warn("Jira tickets not filed", "error", err)
+		return
+	}
+	if previous == nil {
+		return // Nothing to diff the first scan against
+	}
+	result, err := fileJiraTickets(ctx, *sched.Jira, previous, a)
+	if err != nil {
+		logger.Warn("Jira tickets not filed", "error", err)
+		return
+	}
+	if len(result.Created) > 0 {
+		logger.Info("Jira tickets filed", "created", len(result.Created))
+	}
+}
+
+// previousAnalysis returns the analysis saved before a in its repository's
+// history, or nil.
+func previousAnalysis(ctx context.Context, a *Analysis) (*Analysis, error) {
+	scans, err := analyses.list(ctx, a.Owner, a.Repo, maxTrendPoints)
+	if err != nil {
+		return nil, err
+	}
+	for i, s := range scans {
+		if s.ID == a.ID && i+1 < len(scans) {
+			return analyses.get(ctx, a.Owner, a.Repo, scans[i+1].ID)
+		}
+	}
+	return nil, nil
+}
+
+// handleJiraRequest creates Jira tickets for the components that became
+// unused in a scan since ?base= (the scan before it by default), from an
+// optional {"project", "issue_type", "labels"} body.
+func handleJiraRequest(c *gin.Context) {
+	var filing JiraFiling
+	if c.Request.ContentLength != 0 {
+		if err := c.BindJSON(&filing); err != nil {
+			respondError(c, errBadRequest("%v", err))
+			return
+		}
+	}
+	if err := validateJiraFiling(&filing); err != nil {
+		respondError(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	owner, repo := c.Param("owner"), c.Param("repo")
+	a, err := analyses.get(ctx, owner, repo, c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	var base *Analysis
+	if id := c.Query("base"); id != "" {
+		base, err = analyses.get(ctx, owner, repo, id)
+	} else {
+		base, err = previousAnalysis(ctx, a)
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if base == nil {
+		respondError(c, errBadRequest("scan %s is the first of %s/%s, pass base to compare it with", a.ID, owner, repo))
+		return
+	}
+
+	result, err := fileJiraTickets(ctx, filing, base, a)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,213 @@
+package rgc
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jobRetention is how long a finished job can still be looked up.
+const jobRetention = time.Hour
+
+// jobWatchInterval is how often a job being watched checks its progress.
+const jobWatchInterval = 250 * time.Millisecond
+
+const (
+	jobRunning   = "running"
+	jobSucceeded = "succeeded"
+	jobFailed    = "failed"
+)
+
+// Job is a scan running in the background.
+type Job struct {
+	ID         string     `json:"id"`
+	Owner      string     `json:"owner"`
+	Repo       string     `json:"repo"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// AnalysisID identifies the finished analysis in the scan history.
+	AnalysisID string    `json:"analysis_id,omitempty"`
+	Error      *APIError `json:"error,omitempty"`
+
+	progress *Progress
+}
+
+// jobStore keeps the running and recently finished jobs in memory.
+type jobStore struct {
+	mu      sync.Mutex
+	seq     int64
+	jobs    map[string]*Job
+	running sync.WaitGroup
+}
+
+var jobs = &jobStore{jobs: make(map[string]*Job)}
+
+// start runs a scan in the background and returns its job.
+func (s *jobStore) start(owner, repo string, opts ScanOptions) *Job {
+	s.mu.Lock()
+	s.seq++
+	job := &Job{
+		ID:        strconv.FormatInt(s.seq, 10),
+		Owner:     owner,
+		Repo:      repo,
+		Status:    jobRunning,
+		CreatedAt: time.Now().UTC(),
+		progress:  newProgress(),
+	}
+	s.jobs[job.ID] = job
+	s.mu.Unlock()
+
+	opts.Progress = job.progress
+	if opts.Logger != nil {
+		opts.Logger = opts.Logger.With("job_id", job.ID)
+	}
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		result, err := ProcessRepository(owner, repo, opts)
+		var analysis *Analysis
+		if err == nil {
+			analysis, err = recordAnalysis(context.Background(), owner, repo, result, opts)
+		}
+
+		s.mu.Lock()
+		now := time.Now().UTC()
+		job.FinishedAt = &now
+		if err != nil {
+			job.Status, job.Error = jobFailed, toAPIError(err)
+		} else {
+			job.Status, job.AnalysisID = jobSucceeded, analysis.ID
+		}
+		s.mu.Unlock()
+
+		time.AfterFunc(jobRetention, func() {
+			s.mu.Lock()
+			delete(s.jobs, job.ID)
+			s.mu.Unlock()
+		})
+	}()
+	return job
+}
+
+// drain waits for the running jobs to finish, until ctx is done. It returns
+// how many were still running.
+func (s *jobStore) drain(ctx context.Context) int {
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return 0
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, job := range s.jobs {
+		if job.Status == jobRunning {
+			n++
+		}
+	}
+	return n
+}
+
+// get returns a copy of the job with its progress, or nil.
+func (s *jobStore) get(id string) (*Job, *ProgressSnapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, nil
+	}
+	progress := job.progress.snapshot()
+	copied := *job
+	return &copied, &progress
+}
+
+// watch calls progress with the progress of a job every time it changes,
+// until the job finishes, and returns the finished job. It stops early, with
+// the error, when ctx is done or progress fails.
+func (s *jobStore) watch(ctx context.Context, id string, progress func(*ProgressSnapshot) error) (*Job, error) {
+	var last ProgressSnapshot
+	ticker := time.NewTicker(jobWatchInterval)
+	defer ticker.Stop()
+	for {
+		job, snapshot := s.get(id)
+		if job == nil {
+			return nil, errNotFound("job not found")
+		}
+		if job.Status != jobRunning {
+			return job, nil
+		}
+		if *snapshot != last {
+			last = *snapshot
+			if err := progress(snapshot); err != nil {
+				return nil, err
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// handleCreateJobRequest starts scanning a repository in the background. It
+// takes the same payload as POST /garbage and answers 202 with the job.
+func handleCreateJobRequest(c *gin.Context) {
+	var payload RequestPayload
+	if err := c.BindJSON(&payload); err != nil {
+		respondError(c, errBadRequest("%v", err))
+		return
+	}
+	job, err := startJob(accessContext(c), payload)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Header("Location", "/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, gin.H{"job": job})
+}
+
+// startJob checks a scan request and starts it in the background.
+func startJob(ctx context.Context, payload RequestPayload) (*Job, error) {
+	if err := validateRepo("username", payload.Username, payload.Repo); err != nil {
+		return nil, err
+	}
+	if err := payload.validateOptions(); err != nil {
+		return nil, err
+	}
+	if err := checkScanAccess(ctx, payload.Username, payload.Repo, payload.Token); err != nil {
+		return nil, err
+	}
+	if err := payload.withStoredToken(ctx); err != nil {
+		return nil, err
+	}
+	// Otherwise a typo only shows up later as a failed job.
+	if err := checkRepoExists(ctx, payload.Token, payload.Username, payload.Repo); err != nil {
+		return nil, err
+	}
+	opts := payload.scanOptions()
+	opts.fromRequest(ctx)
+	return jobs.start(payload.Username, payload.Repo, opts), nil
+}
+
+// handleJobRequest returns a job's status and progress: the current phase,
+// the component files parsed out of the total, and an overall percentage.
+func handleJobRequest(c *gin.Context) {
+	job, progress := jobs.get(c.Param("id"))
+	if job == nil {
+		respondError(c, errNotFound("job not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"job": job, "progress": progress})
+}
@@ -0,0 +1,76 @@
+package rgc
+
+import (
+	"os"
+	"strconv"
+)
+
+// defaultSkipFileSize is the size past which component files aren't parsed,
+// unless RGC_SKIP_FILE_SIZE says otherwise.
+const defaultSkipFileSize = 1 << 20
+
+// FileSizeRules skip component files past a size, typically generated ones
+// such as icon packs: they're slow to parse and rarely matter.
+type FileSizeRules struct {
+	// MaxSize is the largest component file parsed, in bytes. Zero uses
+	// RGC_SKIP_FILE_SIZE, 1 MiB by default, and a negative size parses
+	// every file.
+	MaxSize int64 `json:"max_size,omitempty"`
+	// Paths overrides MaxSize for the files matching path patterns, e.g.
+	// {"src/icons/": 4194304, "src/legacy/**": -1}. The longest matching
+	// pattern wins.
+	Paths map[string]int64 `json:"paths,omitempty"`
+}
+
+// SkippedFile is a component file left out of the scan for its size.
+type SkippedFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// Limit is the size it went past.
+	Limit int64 `json:"limit"`
+}
+
+// skipFileSize returns RGC_SKIP_FILE_SIZE, or defaultSkipFileSize when it
+// isn't a number. Zero or less parses every file.
+func skipFileSize() int64 {
+	if n, err := strconv.ParseInt(os.Getenv("RGC_SKIP_FILE_SIZE"), 10, 64); err == nil {
+		return n
+	}
+	return defaultSkipFileSize
+}
+
+// validate checks the rules that can be refused before scanning.
+func (r FileSizeRules) validate() error {
+	for pattern, size := range r.Paths {
+		if size == 0 {
+			return errBadRequest("skip_large_files.paths[%q] must be a size in bytes, or negative to parse every file", pattern)
+		}
+	}
+	return nil
+}
+
+// limit returns the size past which the file at p is skipped, or -1 when it
+// never is.
+func (r FileSizeRules) limit(p string) int64 {
+	limit := r.MaxSize
+	if limit == 0 {
+		limit = skipFileSize()
+	}
+	best := ""
+	for pattern, size := range r.Paths {
+		longer := len(pattern) > len(best) || (len(pattern) == len(best) && pattern < best)
+		if (best == "" || longer) && matchPath(pattern, p) {
+			limit, best = size, pattern
+		}
+	}
+	if limit <= 0 {
+		return -1
+	}
+	return limit
+}
+
+func (sc *scan) markSkipped(p string, size, limit int64) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.skipped = append(sc.skipped, SkippedFile{Path: p, Size: size, Limit: limit})
+}
@@ -0,0 +1,229 @@
+package rgc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Default server limits, overridden by RGC_SCAN_TIMEOUT, RGC_MAX_FILES,
+// RGC_MAX_FILE_SIZE, RGC_MAX_TOTAL_BYTES and RGC_MAX_REPO_SIZE.
+const (
+	defaultScanTimeout   = 75 * time.Second
+	defaultMaxFiles      = 200_000
+	defaultMaxFileSize   = 10 << 20
+	defaultMaxTotalBytes = 1 << 30
+	defaultMaxRepoSize   = 2 << 30
+)
+
+var errLimitExceeded = errors.New("scan limit exceeded")
+
+// Duration is a time.Duration written as a string such as "90s" in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"90s\"")
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// ScanLimits bound the work of a scan. In a request, a zero field uses the
+// server's limit, and no field may go past it.
+type ScanLimits struct {
+	Timeout Duration `json:"timeout,omitempty"`
+	// MaxFiles is the most files the repository may list.
+	MaxFiles int `json:"max_files,omitempty"`
+	// MaxFileSize is the largest file, in bytes, the scan may read.
+	MaxFileSize int64 `json:"max_file_size,omitempty"`
+	// MaxTotalBytes bounds the bytes read over the whole scan.
+	MaxTotalBytes int64 `json:"max_total_bytes,omitempty"`
+	// MaxRepoSize is the largest repository, in bytes as GitHub reports
+	// its size, the scan may start on.
+	MaxRepoSize int64 `json:"max_repo_size,omitempty"`
+}
+
+// serverLimits returns the limits the server enforces, and what's wrong
+// with the environment variables setting them. Invalid values are ignored.
+func serverLimits() (ScanLimits, []string) {
+	limits := ScanLimits{
+		Timeout:       Duration(defaultScanTimeout),
+		MaxFiles:      defaultMaxFiles,
+		MaxFileSize:   defaultMaxFileSize,
+		MaxTotalBytes: defaultMaxTotalBytes,
+		MaxRepoSize:   defaultMaxRepoSize,
+	}
+	var problems []string
+	if v := os.Getenv("RGC_SCAN_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			problems = append(problems, fmt.Sprintf("RGC_SCAN_TIMEOUT %q is not a positive duration", v))
+		} else {
+			limits.Timeout = Duration(d)
+		}
+	}
+	if v := os.Getenv("RGC_MAX_FILES"); v != "" {
+		if n, err := strconv.Atoi(v); err != nil || n <= 0 {
+			problems = append(problems, fmt.Sprintf("RGC_MAX_FILES %q is not a positive number", v))
+		} else {
+			limits.MaxFiles = n
+		}
+	}
+	for _, env := range []struct {
+		name  string
+		limit *int64
+	}{
+		{"RGC_MAX_FILE_SIZE", &limits.MaxFileSize},
+		{"RGC_MAX_TOTAL_BYTES", &limits.MaxTotalBytes},
+		{"RGC_MAX_REPO_SIZE", &limits.MaxRepoSize},
+	} {
+		if v := os.Getenv(env.name); v != "" {
+			if n, err := strconv.ParseInt(v, 10, 64); err != nil || n <= 0 {
+				problems = append(problems, fmt.Sprintf("%s %q is not a positive number of bytes", env.name, v))
+			} else {
+				*env.limit = n
+			}
+		}
+	}
+	return limits, problems
+}
+
+// resolveLimits fills the limits a request left out with the server's, and
+// refuses the ones going past them.
+func resolveLimits(requested ScanLimits) (ScanLimits, error) {
+	limits, _ := serverLimits()
+	if requested.Timeout < 0 || requested.MaxFiles < 0 || requested.MaxFileSize < 0 || requested.MaxTotalBytes < 0 || requested.MaxRepoSize < 0 {
+		return limits, errBadRequest("limits can't be negative")
+	}
+	if requested.Timeout > limits.Timeout {
+		return limits, errBadRequest("limits.timeout can't exceed the server's %s", time.Duration(limits.Timeout))
+	}
+	if requested.MaxFiles > limits.MaxFiles {
+		return limits, errBadRequest("limits.max_files can't exceed the server's %d", limits.MaxFiles)
+	}
+	if requested.MaxFileSize > limits.MaxFileSize {
+		return limits, errBadRequest("limits.max_file_size can't exceed the server's %d", limits.MaxFileSize)
+	}
+	if requested.MaxTotalBytes > limits.MaxTotalBytes {
+		return limits, errBadRequest("limits.max_total_bytes can't exceed the server's %d", limits.MaxTotalBytes)
+	}
+	if requested.MaxRepoSize > limits.MaxRepoSize {
+		return limits, errBadRequest("limits.max_repo_size can't exceed the server's %d", limits.MaxRepoSize)
+	}
+
+	if requested.Timeout > 0 {
+		limits.Timeout = requested.Timeout
+	}
+	if requested.MaxFiles > 0 {
+		limits.MaxFiles = requested.MaxFiles
+	}
+	if requested.MaxFileSize > 0 {
+		limits.MaxFileSize = requested.MaxFileSize
+	}
+	if requested.MaxTotalBytes > 0 {
+		limits.MaxTotalBytes = requested.MaxTotalBytes
+	}
+	if requested.MaxRepoSize > 0 {
+		limits.MaxRepoSize = requested.MaxRepoSize
+	}
+	return limits, nil
+}
+
+// LimitError means a scan stopped because it went past one of its limits.
+type LimitError struct {
+	// Limit is the name of the limit, as in ScanLimits' JSON form.
+	Limit string
+	Max   int64
+	// Detail says what went past the limit.
+	Detail string
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%v: %s (%s is %d)", errLimitExceeded, e.Detail, e.Limit, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return errLimitExceeded
+}
+
+func (e *LimitError) apiError() *APIError {
+	return &APIError{
+		Status:  http.StatusUnprocessableEntity,
+		Code:    codeLimitExceeded,
+		Message: e.Error(),
+		Details: gin.H{"limit": e.Limit, "max": e.Max},
+	}
+}
+
+// checkRepoSize refuses to start scanning a repository larger than the
+// scan's limit, unless the scan allows large repositories: past the limit,
+// a scan would likely run out of time halfway through instead.
+func checkRepoSize(meta *RepoMeta, opts ScanOptions) error {
+	if opts.AllowLargeRepo || opts.Limits.MaxRepoSize <= 0 || meta.Size <= opts.Limits.MaxRepoSize {
+		return nil
+	}
+	return &LimitError{Limit: "max_repo_size", Max: opts.Limits.MaxRepoSize,
+		Detail: fmt.Sprintf("%s is %d bytes, set allow_large_repo to scan it anyway", meta.FullName, meta.Size)}
+}
+
+// limitedSource enforces a scan's file count and size limits on the
+// Source it wraps, failing the scan as soon as one is exceeded.
+type limitedSource struct {
+	Source
+	limits ScanLimits
+	read   atomic.Int64
+}
+
+func (s *limitedSource) ListFiles(ctx context.Context) ([]string, error) {
+	files, err := s.Source.ListFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s.limits.MaxFiles > 0 && len(files) > s.limits.MaxFiles {
+		return nil, &LimitError{Limit: "max_files", Max: int64(s.limits.MaxFiles),
+			Detail: fmt.Sprintf("the repository has %d files", len(files))}
+	}
+	return files, nil
+}
+
+func (s *limitedSource) ReadFile(ctx context.Context, p string) (string, error) {
+	content, err := s.Source.ReadFile(ctx, p)
+	if err != nil {
+		return "", err
+	}
+	size := int64(len(content))
+	if s.limits.MaxFileSize > 0 && size > s.limits.MaxFileSize {
+		return "", &LimitError{Limit: "max_file_size", Max: s.limits.MaxFileSize,
+			Detail: fmt.Sprintf("%s is %d bytes", p, size)}
+	}
+	if total := s.read.Add(size); s.limits.MaxTotalBytes > 0 && total > s.limits.MaxTotalBytes {
+		return "", &LimitError{Limit: "max_total_bytes", Max: s.limits.MaxTotalBytes,
+			Detail: fmt.Sprintf("the scan read %d bytes", total)}
+	}
+	return content, nil
+}
+
+// Warnings passes on the wrapped Source's warnings, if it has any.
+func (s *limitedSource) Warnings() []string {
+	if src, ok := s.Source.(interface{ Warnings() []string }); ok {
+		return src.Warnings()
+	}
+	return nil
+}
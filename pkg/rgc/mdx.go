@@ -0,0 +1,143 @@
+package rgc
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+var mdxCommentRe = regexp.MustCompile(`(?s)<!--.*?-->|\{/\*.*?\*/\}`)
+
+// markMDXComponents marks the components MDX documents render as framework
+// roots: the MDX pipeline (@next/mdx, Docusaurus, Storybook docs, a CMS)
+// compiles them into pages, so the components they import are used even
+// though no component imports them.
+func (sc *scan) markMDXComponents(ctx context.Context) error {
+	if !analyzerEnabled("mdx") {
+		return nil
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(sc.concurrency)
+	for _, p := range sc.files {
+		if path.Ext(p) != ".mdx" {
+			continue
+		}
+		p := p
+		eg.Go(func() error {
+			content, err := sc.src.ReadFile(ctx, p)
+			if err != nil {
+				if err == errFileNotFound {
+					return nil
+				}
+				return err
+			}
+			children, err := findMDXChildComponents(ctx, content)
+			if err != nil {
+				return &ParseError{Path: p, Err: err}
+			}
+			var rendered []string
+			sc.mu.Lock()
+			for _, child := range children {
+				// Imports from packages can't be told apart from local
+				// components by name, so only resolved ones count.
+				target := sc.resolveModule(p, child.Edge.Specifier)
+				if _, ok := sc.createdComponents[target]; ok {
+					rendered = append(rendered, target)
+				}
+			}
+			sc.mu.Unlock()
+			for _, target := range rendered {
+				sc.markFrameworkRoot(target)
+			}
+			return nil
+		})
+	}
+	return eg.Wait()
+}
+
+// findMDXChildComponents returns the components an MDX document imports and
+// renders in its content or its exports, as a <Tag> or in a {expression}.
+// Imports and tags inside fenced code blocks are examples, not usage.
+func findMDXChildComponents(ctx context.Context, content string) ([]childImport, error) {
+	esm, markup := splitMDX(content)
+	if esm == "" {
+		return nil, nil
+	}
+	// MDX exports may hold JSX, which the TSX grammar accepts.
+	file, err := parseFile(ctx, "document.tsx", esm)
+	if err != nil {
+		return nil, err
+	}
+	markup = mdxCommentRe.ReplaceAllString(markup, "")
+
+	var childComponents []childImport
+	for _, imp := range file.Imports {
+		if !imp.IsRuntime() {
+			continue
+		}
+		for _, b := range imp.ValueBindings() {
+			if mdxRendersComponent(markup, b.Local) {
+				childComponents = append(childComponents, childImport{
+					Name: componentNameFromSpecifier(imp.Specifier),
+					Edge: ImportEdge{Kind: edgeKind(imp), Specifier: imp.Specifier, Line: imp.Line},
+				})
+				break
+			}
+		}
+	}
+	return childComponents, nil
+}
+
+// splitMDX separates the import and export statements of an MDX document,
+// which are paragraphs starting with import or export, from its content,
+// leaving out fenced code blocks. The statements keep their line numbers,
+// and the exports, which may render components too, stay in the markup.
+func splitMDX(content string) (esm, markup string) {
+	var esmLines, markupLines []string
+	var fence string
+	inESM, exports := false, false
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			line = ""
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+			inESM = false
+			line = ""
+		case trimmed == "":
+			inESM = false
+		case !inESM && (strings.HasPrefix(line, "import ") || strings.HasPrefix(line, "export ")):
+			inESM, exports = true, strings.HasPrefix(line, "export ")
+		}
+		switch {
+		case inESM && exports:
+			esmLines = append(esmLines, line)
+			markupLines = append(markupLines, line)
+		case inESM:
+			esmLines = append(esmLines, line)
+			markupLines = append(markupLines, "")
+		default:
+			esmLines = append(esmLines, "")
+			markupLines = append(markupLines, line)
+		}
+	}
+	esm = strings.Join(esmLines, "\n")
+	if strings.TrimSpace(esm) == "" {
+		esm = ""
+	}
+	return esm, strings.Join(markupLines, "\n")
+}
+
+func mdxRendersComponent(markup, local string) bool {
+	name := regexp.QuoteMeta(local)
+	re := regexp.MustCompile(`<` + name + `[\s/>.]|\{[^}]*\b` + name + `\b[^}]*\}`)
+	return re.MatchString(markup)
+}
@@ -0,0 +1,99 @@
+package rgc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-github/v39/github"
+)
+
+// RepoMeta describes the analyzed repository.
+type RepoMeta struct {
+	FullName      string   `json:"full_name"`
+	Description   string   `json:"description,omitempty"`
+	Topics        []string `json:"topics,omitempty"`
+	Language      string   `json:"language,omitempty"`
+	DefaultBranch string   `json:"default_branch"`
+	Archived      bool     `json:"archived"`
+	Private       bool     `json:"private"`
+	// Size is the size of the repository, history included, in bytes.
+	Size int64 `json:"size,omitempty"`
+	// Ref is the branch, tag or commit the scan asked for, when it isn't
+	// the default branch. HeadSHA is the commit it points to.
+	Ref     string `json:"ref,omitempty"`
+	HeadSHA string `json:"head_sha,omitempty"`
+	Empty   bool   `json:"empty,omitempty"`
+}
+
+// errRepositoryNotFound means GitHub doesn't know the repository, or the
+// token can't see it.
+var errRepositoryNotFound = errors.New("repository not found")
+
+// errDefaultBranchNotFound means the repository has commits but its
+// configured default branch doesn't exist.
+var errDefaultBranchNotFound = errors.New("default branch not found")
+
+// errRefNotFound means the branch, tag or commit to analyze doesn't exist.
+var errRefNotFound = errors.New("ref not found")
+
+func fetchRepoMeta(ctx context.Context, client *github.Client, owner, repo string) (*RepoMeta, error) {
+	r, resp, err := client.Repositories.Get(ctx, owner, repo)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %s/%s", errRepositoryNotFound, owner, repo)
+		}
+		return nil, fmt.Errorf("error getting repository: %w", err)
+	}
+
+	return &RepoMeta{
+		FullName:      r.GetFullName(),
+		Description:   r.GetDescription(),
+		Topics:        r.Topics,
+		Language:      r.GetLanguage(),
+		DefaultBranch: r.GetDefaultBranch(),
+		Archived:      r.GetArchived(),
+		Private:       r.GetPrivate(),
+		Size:          int64(r.GetSize()) << 10, // GitHub counts kilobytes
+	}, nil
+}
+
+// resolveDefaultBranch returns the commit SHA the default branch points to.
+// An empty repository has no commits at all, in which case it returns an
+// empty SHA and no error.
+func resolveDefaultBranch(ctx context.Context, client *github.Client, owner, repo, branch string) (string, error) {
+	b, resp, err := client.Repositories.GetBranch(ctx, owner, repo, branch, true)
+	if err == nil {
+		return b.GetCommit().GetSHA(), nil
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return "", fmt.Errorf("error getting default branch: %w", err)
+	}
+
+	// GitHub answers 409 Conflict when listing the commits of an empty repository.
+	opts := &github.CommitsListOptions{ListOptions: github.ListOptions{PerPage: 1}}
+	_, resp, err = client.Repositories.ListCommits(ctx, owner, repo, opts)
+	if resp != nil && resp.StatusCode == http.StatusConflict {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error listing commits: %w", err)
+	}
+	return "", fmt.Errorf("%w: %q", errDefaultBranchNotFound, branch)
+}
+
+// resolveRef returns the commit SHA a branch, tag or (possibly abbreviated)
+// commit SHA points to.
+func resolveRef(ctx context.Context, client *github.Client, owner, repo, ref string) (string, error) {
+	sha, resp, err := client.Repositories.GetCommitSHA1(ctx, owner, repo, ref, "")
+	if err != nil {
+		// GitHub answers 422 for a ref that isn't a commit, 409 in an empty repository.
+		if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnprocessableEntity ||
+			resp.StatusCode == http.StatusConflict) {
+			return "", fmt.Errorf("%w: %q", errRefNotFound, ref)
+		}
+		return "", fmt.Errorf("error resolving ref %q: %w", ref, err)
+	}
+	return sha, nil
+}
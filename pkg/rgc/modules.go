@@ -0,0 +1,148 @@
+package rgc
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/igorfelipeduca/rgc/internal/graph"
+)
+
+// ModuleEntry is a non-component module: a custom hook or a utility.
+type ModuleEntry struct {
+	Path string `json:"path"`
+	// Kind is "hook" or "util".
+	Kind string `json:"kind"`
+}
+
+// ModulesReport splits the repository's hooks and utility modules into used
+// and unused ones.
+type ModulesReport struct {
+	UsedCount   int           `json:"used_count"`
+	UnusedCount int           `json:"unused_count"`
+	Used        []ModuleEntry `json:"used"`
+	Unused      []ModuleEntry `json:"unused"`
+}
+
+// isUtilityModule reports whether p is a hook or utility module: a
+// JavaScript or TypeScript module that isn't a component, a test, a story,
+// a declaration file or a tool's config file.
+func isUtilityModule(p string) bool {
+	if isComponent(p) || isSupportFile(p) || path.Ext(p) == ".svelte" {
+		return false
+	}
+	switch path.Ext(p) {
+	case ".ts", ".js", ".mjs", ".cjs", ".mts", ".cts":
+	default:
+		return false
+	}
+	base := path.Base(p)
+	if strings.HasSuffix(base, ".d.ts") || strings.Contains(base, ".config.") || strings.HasPrefix(base, ".") {
+		return false
+	}
+	return true
+}
+
+// isLoadedByTooling reports whether the module at p is loaded by a bundler
+// or framework rather than imported: the app's main/index entry file, and
+// Next.js route handlers, API routes and middleware.
+func isLoadedByTooling(p string, nextRoots []string) bool {
+	name := strings.TrimSuffix(path.Base(p), path.Ext(p))
+	if (name == "main" || name == "index") && strings.Count(p, "/") <= 1 {
+		return true
+	}
+	for _, root := range nextRoots {
+		rel := p
+		if root != "." {
+			if !strings.HasPrefix(p, root+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(p, root+"/")
+		}
+		rel = strings.TrimPrefix(rel, "src/")
+		switch {
+		case name == "middleware" && !strings.Contains(rel, "/"),
+			name == "instrumentation" && !strings.Contains(rel, "/"),
+			strings.HasPrefix(rel, "pages/api/"),
+			strings.HasPrefix(rel, "app/") && name == "route":
+			return true
+		}
+	}
+	return false
+}
+
+func moduleKind(p string) string {
+	name := path.Base(p)
+	if strings.HasPrefix(name, "use") && len(name) > 3 && unicode.IsUpper([]rune(name)[3]) {
+		return "hook"
+	}
+	if strings.Contains("/"+p, "/hooks/") {
+		return "hook"
+	}
+	return "util"
+}
+
+// modulesReport applies the component rules to hooks and utilities: a module
+// is used when a shipped file imports it or, when entry points are given,
+// when it is reachable from one through the import graph of every file.
+func (sc *scan) modulesReport(usage *exportUsage, entryPoints []string) *ModulesReport {
+	nextRoots := sc.nextProjectRoots()
+
+	var reachable map[string]bool
+	if len(entryPoints) > 0 {
+		g := graph.New()
+		for importer, targets := range usage.imports {
+			g.AddNode(importer)
+			for _, target := range targets {
+				g.AddEdge(importer, target)
+			}
+		}
+		var roots []string
+		for _, p := range sc.files {
+			switch {
+			case isLoadedByTooling(p, nextRoots):
+				roots = append(roots, p)
+			case isComponent(p) && sc.frameworkRoots[p]:
+				roots = append(roots, p)
+			default:
+				for _, pattern := range entryPoints {
+					if matchPath(pattern, p) {
+						roots = append(roots, p)
+						break
+					}
+				}
+			}
+		}
+		reachable = g.Reachable(roots...)
+	}
+
+	report := &ModulesReport{Used: []ModuleEntry{}, Unused: []ModuleEntry{}}
+	for _, p := range sc.files {
+		if !isUtilityModule(p) || isLoadedByTooling(p, nextRoots) || sc.generated[p] != "" {
+			continue
+		}
+		var used bool
+		if reachable != nil {
+			used = reachable[p]
+		} else {
+			for _, importer := range usage.importers[p] {
+				if !isSupportFile(importer) {
+					used = true
+					break
+				}
+			}
+		}
+		entry := ModuleEntry{Path: p, Kind: moduleKind(p)}
+		if used {
+			report.Used = append(report.Used, entry)
+		} else {
+			report.Unused = append(report.Unused, entry)
+		}
+	}
+	sort.Slice(report.Used, func(i, j int) bool { return report.Used[i].Path < report.Used[j].Path })
+	sort.Slice(report.Unused, func(i, j int) bool { return report.Unused[i].Path < report.Unused[j].Path })
+	report.UsedCount = len(report.Used)
+	report.UnusedCount = len(report.Unused)
+	return report
+}
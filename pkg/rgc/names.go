@@ -0,0 +1,55 @@
+package rgc
+
+import (
+	"sort"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// folder does locale independent full Unicode case folding, so the same
+// name always folds to the same key whatever the server's locale.
+var folder = cases.Fold()
+
+// normalizeName puts a component name in Unicode NFC. macOS writes file names
+// decomposed (NFD) while editors usually type imports composed, so "Café" in
+// a path and in an import would otherwise be different strings.
+func normalizeName(name string) string {
+	return norm.NFC.String(name)
+}
+
+// nameKey returns the key file paths and component names are indexed by. Imports
+// resolve case-sensitively like on Linux and in most bundlers, unless the
+// scan targets a case-insensitive platform (macOS, Windows), where
+// "./button" finds Button.tsx.
+func (sc *scan) nameKey(name string) string {
+	name = normalizeName(name)
+	if sc.caseInsensitive {
+		return folder.String(name)
+	}
+	return name
+}
+
+// NameCollision is a component name several components share. Components
+// are told apart by path, but the name alone is ambiguous to readers, and to
+// imports that can't be resolved to a file.
+type NameCollision struct {
+	Name  string   `json:"name"`
+	Paths []string `json:"paths"`
+}
+
+// nameCollisions lists the names shared by several components, compared
+// case-insensitively when the scan is.
+func (sc *scan) nameCollisions() []NameCollision {
+	var collisions []NameCollision
+	for _, paths := range sc.componentsByName {
+		if len(paths) < 2 {
+			continue
+		}
+		sorted := append([]string(nil), paths...)
+		sort.Strings(sorted)
+		collisions = append(collisions, NameCollision{Name: sc.createdComponents[sorted[0]].Name, Paths: sorted})
+	}
+	sort.Slice(collisions, func(i, j int) bool { return collisions[i].Name < collisions[j].Name })
+	return collisions
+}
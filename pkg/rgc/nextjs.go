@@ -0,0 +1,67 @@
+package rgc
+
+import (
+	"path"
+	"strings"
+)
+
+// nextSpecialFiles are the app router files Next.js renders itself.
+var nextSpecialFiles = map[string]bool{
+	"page":         true,
+	"layout":       true,
+	"template":     true,
+	"loading":      true,
+	"error":        true,
+	"global-error": true,
+	"not-found":    true,
+	"default":      true,
+}
+
+// nextProjectRoots returns the directories holding a next.config file.
+func (sc *scan) nextProjectRoots() []string {
+	var roots []string
+	for _, p := range sc.files {
+		base := path.Base(p)
+		if strings.TrimSuffix(base, path.Ext(base)) == "next.config" {
+			roots = append(roots, path.Dir(p))
+		}
+	}
+	return roots
+}
+
+// isNextRoute reports whether p, relative to a Next.js project root, is a
+// file-system route: any component under pages/ (including _app and
+// _document) or a special file of the app router. Both may live in src/.
+func isNextRoute(rel string) bool {
+	rel = strings.TrimPrefix(rel, "src/")
+	base := path.Base(rel)
+	switch {
+	case strings.HasPrefix(rel, "pages/"):
+		return !strings.HasPrefix(rel, "pages/api/")
+	case strings.HasPrefix(rel, "app/"):
+		return nextSpecialFiles[strings.TrimSuffix(base, path.Ext(base))]
+	}
+	return false
+}
+
+// markNextRoutes marks the routes of Next.js projects as framework roots:
+// Next.js mounts them from their location, so nothing imports them.
+func (sc *scan) markNextRoutes() {
+	for _, root := range sc.nextProjectRoots() {
+		for _, p := range sc.files {
+			if !sc.isComponent(p) {
+				continue
+			}
+			rel := p
+			if root != "." {
+				if !strings.HasPrefix(p, root+"/") {
+					continue
+				}
+				rel = strings.TrimPrefix(p, root+"/")
+			}
+			if isNextRoute(rel) {
+				sc.markFrameworkRoot(p)
+			}
+		}
+	}
+}
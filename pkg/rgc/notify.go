@@ -0,0 +1,182 @@
+package rgc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// notifyTimeout bounds how long posting a notification may take.
+	notifyTimeout = 10 * time.Second
+	// notifyTopComponents is how many new unused components a notification
+	// lists by name.
+	notifyTopComponents = 5
+)
+
+// Notification posts a summary of every scheduled scan of a repository to
+// a chat webhook.
+type Notification struct {
+	// Type is "slack" or "discord".
+	Type string `json:"type"`
+	// URL is the incoming webhook URL the channel gave.
+	URL string `json:"url"`
+	// OnlyRegressions skips scans that didn't add unused components.
+	OnlyRegressions bool `json:"only_regressions,omitempty"`
+}
+
+// notificationHosts are the hosts webhook URLs of each type may point to,
+// so schedules can't make the server post anywhere else.
+var notificationHosts = map[string][]string{
+	"slack":   {"hooks.slack.com"},
+	"discord": {"discord.com", "discordapp.com"},
+}
+
+func validateNotifications(notify []Notification) error {
+	for _, n := range notify {
+		hosts, ok := notificationHosts[n.Type]
+		if !ok {
+			return errBadRequest("notification type must be slack or discord")
+		}
+		u, err := url.Parse(n.URL)
+		if err != nil || u.Scheme != "https" || !slices.Contains(hosts, u.Hostname()) {
+			return errBadRequest("%s notification url must be an https URL on %s", n.Type, strings.Join(hosts, " or "))
+		}
+	}
+	return nil
+}
+
+// scanSummary is what a notification tells about a scan.
+type scanSummary struct {
+	analysis *Analysis
+	previous *Analysis
+	// newUnused are the components unused now but not in the previous
+	// analysis, either new or no longer used.
+	newUnused []string
+}
+
+func summarizeScan(ctx context.Context, a *Analysis) (*scanSummary, error) {
+	s := &scanSummary{analysis: a}
+	scans, err := analyses.list(ctx, a.Owner, a.Repo, 2)
+	if err != nil {
+		return nil, err
+	}
+	if len(scans) < 2 || scans[0].ID != a.ID {
+		return s, nil
+	}
+	if s.previous, err = analyses.get(ctx, a.Owner, a.Repo, scans[1].ID); err != nil {
+		return nil, err
+	}
+	s.newUnused = newlyUnused(s.previous.Result, a.Result)
+	return s, nil
+}
+
+// newlyUnused returns the components unused in to but not in from, either
+// new or no longer used.
+func newlyUnused(from, to *ComponentsResult) []string {
+	diff := diffResults(from, to)
+	status := componentStatus(to)
+	unused := append([]string{}, diff.BecameUnused...)
+	for _, p := range diff.Added {
+		if !status[p] {
+			unused = append(unused, p)
+		}
+	}
+	return unused
+}
+
+// text renders the summary as a chat message, in Slack's or Discord's
+// markdown.
+func (s *scanSummary) text(kind string) string {
+	a := s.analysis
+	var previous *ScanSummary
+	if s.previous != nil {
+		summary := s.previous.summary()
+		previous = &summary
+	}
+	repo := a.Owner + "/" + a.Repo
+	var b strings.Builder
+	headline := fmt.Sprintf("%s: %s", repo, statusDescription(a.Result.UnusedCount, previous))
+	link := reportURL(a.Owner, a.Repo, a.ID)
+	switch {
+	case link != "" && kind == "slack":
+		fmt.Fprintf(&b, "*<%s|%s>*", link, headline)
+	case link != "":
+		fmt.Fprintf(&b, "**[%s](%s)**", headline, link)
+	case kind == "slack":
+		fmt.Fprintf(&b, "*%s*", headline)
+	default:
+		fmt.Fprintf(&b, "**%s**", headline)
+	}
+	if len(s.newUnused) > 0 {
+		b.WriteString("\nNew unused components:")
+		for _, p := range s.newUnused[:min(len(s.newUnused), notifyTopComponents)] {
+			fmt.Fprintf(&b, "\n• `%s`", p)
+		}
+		if more := len(s.newUnused) - notifyTopComponents; more > 0 {
+			fmt.Fprintf(&b, "\n…and %d more", more)
+		}
+	}
+	return b.String()
+}
+
+// notify posts the summary of a scheduled scan to the schedule's
+// notifications. A failing webhook is logged and doesn't stop the others.
+func notify(ctx context.Context, sched *Schedule, a *Analysis) {
+	if len(sched.Notify) == 0 {
+		return
+	}
+	logger := loggerFrom(ctx)
+	summary, err := summarizeScan(ctx, a)
+	if err != nil {
+		logger.Warn("notifications not sent", "error", err)
+		return
+	}
+	for _, n := range sched.Notify {
+		if n.OnlyRegressions && len(summary.newUnused) == 0 {
+			continue
+		}
+		if err := postNotification(ctx, n, summary.text(n.Type)); err != nil {
+			logger.Warn("notification not sent", "type", n.Type, "error", err)
+		}
+	}
+}
+
+func postNotification(ctx context.Context, n Notification, text string) error {
+	payload := map[string]string{"text": text}
+	if n.Type == "discord" {
+		payload = map[string]string{"content": text}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// The URL is a secret, keep it out of the logs.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("error posting notification: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("error posting notification: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package rgc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+	githuboauth "golang.org/x/oauth2/github"
+)
+
+const (
+	sessionCookie    = "rgc_session"
+	oauthStateCookie = "rgc_oauth_state"
+	// defaultSessionTTL is how long a login lasts unless RGC_SESSION_TTL
+	// says otherwise.
+	defaultSessionTTL = 7 * 24 * time.Hour
+	// oauthStateTTL is how long a user has to authorize the app on GitHub.
+	oauthStateTTL = 10 * time.Minute
+)
+
+// Session is a user logged in with GitHub. The token GitHub granted is kept
+// sealed in the vault and scans the user's repositories.
+type Session struct {
+	Login     string    `json:"login"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Hash is the SHA-256 of the session ID the cookie carries.
+	Hash  string       `json:"-"`
+	Token *SealedToken `json:"-"`
+}
+
+// token decrypts the token GitHub granted the session.
+func (s *Session) token() (string, error) {
+	v, err := loadVault()
+	if err != nil {
+		return "", err
+	}
+	token, err := v.open(s.Token, "session/"+s.Hash)
+	if err != nil {
+		return "", fmt.Errorf("error opening the token of %s's session: %v", s.Login, err)
+	}
+	return token, nil
+}
+
+// oauthSettings configure logging in with GitHub.
+type oauthSettings struct {
+	config oauth2.Config
+	// returnURL is where users land after logging in, unless they asked
+	// for a path on the same site.
+	returnURL string
+	secure    bool
+}
+
+// loadOAuthConfig reads the GitHub OAuth app: RGC_GITHUB_CLIENT_ID and
+// RGC_GITHUB_CLIENT_SECRET, with the callback at RGC_OAUTH_REDIRECT_URL,
+// by default /auth/github/callback under RGC_PUBLIC_URL. ok is false when
+// the app isn't configured.
+func loadOAuthConfig() (cfg oauthSettings, ok bool) {
+	clientID, secret := os.Getenv("RGC_GITHUB_CLIENT_ID"), os.Getenv("RGC_GITHUB_CLIENT_SECRET")
+	if clientID == "" || secret == "" {
+		return cfg, false
+	}
+	publicURL := strings.TrimSuffix(os.Getenv("RGC_PUBLIC_URL"), "/")
+	redirect := envOr("RGC_OAUTH_REDIRECT_URL", publicURL+"/auth/github/callback")
+	cfg.config = oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: secret,
+		Endpoint:     githuboauth.Endpoint,
+		RedirectURL:  redirect,
+		Scopes:       strings.Split(envOr("RGC_OAUTH_SCOPES", "repo,read:org"), ","),
+	}
+	cfg.returnURL = envOr("RGC_OAUTH_RETURN_URL", publicURL+"/")
+	cfg.secure = strings.HasPrefix(redirect, "https://")
+	return cfg, true
+}
+
+// sessionTTL returns RGC_SESSION_TTL, or defaultSessionTTL when it isn't a
+// positive duration.
+func sessionTTL() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("RGC_SESSION_TTL")); err == nil && d > 0 {
+		return d
+	}
+	return defaultSessionTTL
+}
+
+func oauthSettingsJSON() gin.H {
+	cfg, ok := loadOAuthConfig()
+	if !ok {
+		return gin.H{"enabled": false}
+	}
+	return gin.H{
+		"enabled":       true,
+		"client_id":     cfg.config.ClientID,
+		"client_secret": redact(cfg.config.ClientSecret),
+		"redirect_url":  cfg.config.RedirectURL,
+		"return_url":    cfg.returnURL,
+		"scopes":        cfg.config.Scopes,
+		"session_ttl":   sessionTTL().String(),
+	}
+}
+
+// requireOAuth answers 404 when logging in with GitHub isn't configured,
+// including the vault the granted tokens are sealed in.
+func requireOAuth(c *gin.Context) (oauthSettings, bool) {
+	cfg, ok := loadOAuthConfig()
+	if !ok {
+		respondError(c, errNotFound("GitHub login is disabled, set RGC_GITHUB_CLIENT_ID and RGC_GITHUB_CLIENT_SECRET"))
+		return cfg, false
+	}
+	if _, err := loadVault(); err != nil {
+		respondError(c, errNotFound("GitHub login needs the token vault: %v", err))
+		return cfg, false
+	}
+	return cfg, true
+}
+
+// safeReturnURL returns where to send the user after logging in: to
+// returnTo when it's a path on this site or on the site of the configured
+// return URL, and to the return URL otherwise, so the login can't be used
+// as an open redirect.
+func (cfg oauthSettings) safeReturnURL(returnTo string) string {
+	if returnTo == "" {
+		return cfg.returnURL
+	}
+	if strings.HasPrefix(returnTo, "/") && !strings.HasPrefix(returnTo, "//") && !strings.HasPrefix(returnTo, "/\\") {
+		return returnTo
+	}
+	target, err := url.Parse(returnTo)
+	if err != nil {
+		return cfg.returnURL
+	}
+	base, err := url.Parse(cfg.returnURL)
+	if err != nil || base.Host == "" || target.Scheme != base.Scheme || target.Host != base.Host {
+		return cfg.returnURL
+	}
+	return returnTo
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// handleGitHubLoginRequest sends the user to GitHub to authorize the app,
+// remembering in a cookie the state to check on the way back and where to
+// return to, ?return_to=.
+func handleGitHubLoginRequest(c *gin.Context) {
+	cfg, ok := requireOAuth(c)
+	if !ok {
+		return
+	}
+	state, err := randomHex(16)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	value := state + "|" + url.QueryEscape(cfg.safeReturnURL(c.Query("return_to")))
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, value, int(oauthStateTTL.Seconds()), "/auth/github", "", cfg.secure, true)
+	c.Redirect(http.StatusFound, cfg.config.AuthCodeURL(state))
+}
+
+// handleGitHubCallbackRequest finishes the login: it trades the code for a
+// token, looks up who it belongs to, and starts a session.
+func handleGitHubCallbackRequest(c *gin.Context) {
+	cfg, ok := requireOAuth(c)
+	if !ok {
+		return
+	}
+	cookie, _ := c.Cookie(oauthStateCookie)
+	c.SetCookie(oauthStateCookie, "", -1, "/auth/github", "", cfg.secure, true)
+	state, returnTo, _ := strings.Cut(cookie, "|")
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(c.Query("state"))) != 1 {
+		respondError(c, errBadRequest("the login expired or didn't start here, log in again"))
+		return
+	}
+	if reason := c.Query("error"); reason != "" {
+		respondError(c, errBadRequest("GitHub login failed: %s", c.DefaultQuery("error_description", reason)))
+		return
+	}
+	returnTo, _ = url.QueryUnescape(returnTo)
+
+	ctx := c.Request.Context()
+	granted, err := cfg.config.Exchange(ctx, c.Query("code"))
+	if err != nil {
+		respondError(c, errBadRequest("error exchanging the GitHub login code: %v", err))
+		return
+	}
+	id, session, err := startSession(ctx, granted.AccessToken)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(sessionCookie, id, int(sessionTTL().Seconds()), "/", "", cfg.secure, true)
+	loggerFrom(ctx).Info("logged in with GitHub", "login", session.Login)
+	c.Redirect(http.StatusFound, cfg.safeReturnURL(returnTo))
+}
+
+// startSession stores a session for the user token belongs to and returns
+// its ID.
+func startSession(ctx context.Context, token string) (string, *Session, error) {
+	client := newGitHubClient(token)
+	user, _, err := client.Users.Get(ctx, "")
+	if err != nil {
+		return "", nil, fmt.Errorf("error looking up the GitHub user: %w", err)
+	}
+	info, err := inspectToken(ctx, client)
+	if err != nil {
+		return "", nil, err
+	}
+	id, err := randomHex(32)
+	if err != nil {
+		return "", nil, err
+	}
+	v, err := loadVault()
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now().UTC()
+	session := &Session{
+		Login:     user.GetLogin(),
+		Scopes:    info.Scopes,
+		CreatedAt: now,
+		ExpiresAt: now.Add(sessionTTL()),
+		Hash:      hashAPIKey(id),
+	}
+	if session.Scopes == nil {
+		session.Scopes = []string{}
+	}
+	if session.Token, err = v.seal(token, "session/"+session.Hash); err != nil {
+		return "", nil, err
+	}
+	if err := analyses.storage.SaveSession(ctx, session); err != nil {
+		return "", nil, err
+	}
+	return id, session, nil
+}
+
+type sessionContextKey struct{}
+
+// requestSession returns the unexpired session of the request's cookie,
+// or nil.
+func requestSession(c *gin.Context) (*Session, error) {
+	id, err := c.Cookie(sessionCookie)
+	if err != nil || id == "" {
+		return nil, nil
+	}
+	session, err := analyses.storage.FindSession(c.Request.Context(), hashAPIKey(id))
+	if err != nil || session == nil {
+		return nil, err
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil, analyses.storage.DeleteSession(c.Request.Context(), session.Hash)
+	}
+	return session, nil
+}
+
+// withSession returns ctx carrying the session of its request.
+func withSession(ctx context.Context, session *Session) context.Context {
+	ctx = context.WithValue(ctx, sessionContextKey{}, session)
+	return context.WithValue(ctx, loggerKey{}, loggerFrom(ctx).With("user", session.Login))
+}
+
+// handleSessionRequest returns who is logged in.
+func handleSessionRequest(c *gin.Context) {
+	session, err := requestSession(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if session == nil {
+		respondError(c, &APIError{Status: http.StatusUnauthorized, Code: codeUnauthorized, Message: "not logged in"})
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// handleLogoutRequest ends the session of the request, if any.
+func handleLogoutRequest(c *gin.Context) {
+	if id, err := c.Cookie(sessionCookie); err == nil && id != "" {
+		if err := analyses.storage.DeleteSession(c.Request.Context(), hashAPIKey(id)); err != nil {
+			respondError(c, err)
+			return
+		}
+	}
+	cfg, _ := loadOAuthConfig()
+	c.SetCookie(sessionCookie, "", -1, "/", "", cfg.secure, true)
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,254 @@
+package rgc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-github/v39/github"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	defaultOrgRepos = 50
+	maxOrgRepos     = 200
+	// orgScanConcurrency is how many repositories of an organization are
+	// scanned at once, each with its own parallel requests.
+	orgScanConcurrency = 4
+)
+
+// OrgRequestPayload asks for every repository of an organization to be
+// scanned.
+type OrgRequestPayload struct {
+	Org string `json:"org"`
+	// Topic and Language keep only the repositories with that topic or
+	// primary language.
+	Topic    string `json:"topic"`
+	Language string `json:"language"`
+	// IncludeArchived and IncludeForks also scan archived repositories and
+	// forks, skipped by default.
+	IncludeArchived bool   `json:"include_archived"`
+	IncludeForks    bool   `json:"include_forks"`
+	MaxRepos        int    `json:"max_repos"`
+	Token           string `json:"token"`
+	// Merge adds the repositories' results merged into one, their paths
+	// prefixed with the repository name.
+	Merge bool `json:"merge"`
+	// Scan holds the options of each scan, as for POST /garbage.
+	Scan RequestPayload `json:"scan"`
+}
+
+// OrgRepoReport is the outcome of scanning one repository of an organization.
+type OrgRepoReport struct {
+	Repo               string    `json:"repo"`
+	AnalysisID         string    `json:"analysis_id,omitempty"`
+	UsedCount          int       `json:"used_count"`
+	UnusedCount        int       `json:"unused_count"`
+	TestOnlyCount      int       `json:"test_only_count"`
+	StorybookOnlyCount int       `json:"storybook_only_count"`
+	E2EOnlyCount       int       `json:"e2e_only_count"`
+	Error              *APIError `json:"error,omitempty"`
+}
+
+// OrgReport aggregates the scans of an organization's repositories, the
+// repositories with the most unused components first.
+type OrgReport struct {
+	Org                string          `json:"org"`
+	Repositories       int             `json:"repositories"`
+	Scanned            int             `json:"scanned"`
+	Failed             int             `json:"failed"`
+	UsedCount          int             `json:"used_count"`
+	UnusedCount        int             `json:"unused_count"`
+	TestOnlyCount      int             `json:"test_only_count"`
+	StorybookOnlyCount int             `json:"storybook_only_count"`
+	E2EOnlyCount       int             `json:"e2e_only_count"`
+	Truncated          bool            `json:"truncated,omitempty"`
+	Repos              []OrgRepoReport `json:"repos"`
+	// Merged holds every result merged into one, when asked for.
+	Merged *ComponentsResult `json:"merged,omitempty"`
+}
+
+// matches reports whether the payload's filters keep repo.
+func (p *OrgRequestPayload) matches(repo *github.Repository) bool {
+	if repo.GetArchived() && !p.IncludeArchived {
+		return false
+	}
+	if repo.GetFork() && !p.IncludeForks {
+		return false
+	}
+	if p.Language != "" && !strings.EqualFold(repo.GetLanguage(), p.Language) {
+		return false
+	}
+	if p.Topic != "" && !contains(repo.Topics, strings.ToLower(p.Topic)) {
+		return false
+	}
+	return true
+}
+
+// listOrgRepos returns the names of the organization's repositories the
+// filters keep, at most limit of them, and whether there were more.
+func listOrgRepos(ctx context.Context, client *github.Client, p *OrgRequestPayload, limit int) ([]string, bool, error) {
+	var names []string
+	opts := &github.RepositoryListByOrgOptions{Type: "all", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		repos, resp, err := client.Repositories.ListByOrg(ctx, p.Org, opts)
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				return nil, false, fmt.Errorf("%w: organization %s", errRepositoryNotFound, p.Org)
+			}
+			return nil, false, fmt.Errorf("error listing repositories of %s: %w", p.Org, err)
+		}
+		for _, repo := range repos {
+			if !p.matches(repo) {
+				continue
+			}
+			if len(names) == limit {
+				return names, true, nil
+			}
+			names = append(names, repo.GetName())
+		}
+		if resp.NextPage == 0 {
+			return names, false, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// scanOrg scans the organization's repositories and aggregates the results.
+// A repository failing to scan is reported with its error, it doesn't fail
+// the others, as one past the caller's rate limit is: each repository
+// counts against it. Those the caller may not read are left out.
+func scanOrg(ctx context.Context, p *OrgRequestPayload) (*OrgReport, error) {
+	// The logged in user's token lists the private repositories it sees.
+	token, _, err := actingToken(ctx, p.Org, "", p.Token)
+	if err != nil {
+		return nil, err
+	}
+	limit := defaultOrgRepos
+	if p.MaxRepos > 0 {
+		limit = min(p.MaxRepos, maxOrgRepos)
+	}
+
+	names, truncated, err := listOrgRepos(ctx, newGitHubClient(token), p, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := p.Scan.scanOptions()
+	opts.fromRequest(ctx)
+	reports := make([]OrgRepoReport, len(names))
+	results := make([]*ComponentsResult, len(names))
+	hidden := make([]bool, len(names))
+	eg := errgroup.Group{}
+	eg.SetLimit(orgScanConcurrency)
+	for i, name := range names {
+		i, name := i, name
+		eg.Go(func() error {
+			report := OrgRepoReport{Repo: name}
+			if err := checkScanAccess(ctx, p.Org, name, p.Token); err != nil {
+				// The repositories the caller may not read aren't named.
+				hidden[i] = toAPIError(err).Status == http.StatusForbidden
+				report.Error = toAPIError(err)
+				reports[i] = report
+				return nil
+			}
+			if err := takeRepoScan(ctx); err != nil {
+				report.Error = toAPIError(err)
+				reports[i] = report
+				return nil
+			}
+			opts, err := withCallerToken(ctx, opts, p.Org, name, p.Token)
+			if err != nil {
+				report.Error = toAPIError(err)
+				reports[i] = report
+				return nil
+			}
+			result, err := ProcessRepository(p.Org, name, opts)
+			var analysis *Analysis
+			if err == nil {
+				analysis, err = recordAnalysis(ctx, p.Org, name, result, opts)
+			}
+			if err != nil {
+				report.Error = toAPIError(err)
+			} else {
+				report.AnalysisID = analysis.ID
+				results[i] = result
+				report.UsedCount, report.UnusedCount = result.UsedCount, result.UnusedCount
+				report.TestOnlyCount, report.StorybookOnlyCount, report.E2EOnlyCount =
+					result.TestOnlyCount, result.StorybookOnlyCount, result.E2EOnlyCount
+			}
+			reports[i] = report
+			return nil
+		})
+	}
+	eg.Wait()
+	for i := len(names) - 1; i >= 0; i-- {
+		if hidden[i] {
+			names = append(names[:i], names[i+1:]...)
+			reports = append(reports[:i], reports[i+1:]...)
+			results = append(results[:i], results[i+1:]...)
+		}
+	}
+
+	report := &OrgReport{Org: p.Org, Repositories: len(names), Truncated: truncated, Repos: reports}
+	for _, r := range reports {
+		if r.Error != nil {
+			report.Failed++
+			continue
+		}
+		report.Scanned++
+		report.UsedCount += r.UsedCount
+		report.UnusedCount += r.UnusedCount
+		report.TestOnlyCount += r.TestOnlyCount
+		report.StorybookOnlyCount += r.StorybookOnlyCount
+		report.E2EOnlyCount += r.E2EOnlyCount
+	}
+	if p.Merge {
+		parts := make(map[string]*ComponentsResult)
+		for i, result := range results {
+			if result != nil {
+				parts[names[i]] = result
+			}
+		}
+		report.Merged = mergeResults(parts)
+	}
+	sort.SliceStable(report.Repos, func(i, j int) bool {
+		if report.Repos[i].UnusedCount != report.Repos[j].UnusedCount {
+			return report.Repos[i].UnusedCount > report.Repos[j].UnusedCount
+		}
+		return report.Repos[i].Repo < report.Repos[j].Repo
+	})
+	return report, nil
+}
+
+// handleOrgRequest scans the repositories of an organization, e.g. to audit
+// dead components across all frontends of a company at once.
+func handleOrgRequest(c *gin.Context) {
+	var payload OrgRequestPayload
+	if err := c.BindJSON(&payload); err != nil {
+		respondError(c, errBadRequest("%v", err))
+		return
+	}
+	if err := validateOwner("org", payload.Org); err != nil {
+		respondError(c, err)
+		return
+	}
+	if payload.Scan.Verify {
+		respondError(c, errBadRequest("organization scans can't verify deletions"))
+		return
+	}
+	if err := payload.Scan.validateOptions(); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	report, err := scanOrg(accessContext(c), &payload)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,112 @@
+package rgc
+
+import (
+	"sort"
+
+	"github.com/igorfelipeduca/rgc/internal/graph"
+)
+
+// OrphanedSubtree is a group of unused components importing each other,
+// deletable as a unit since nothing else imports any of them.
+type OrphanedSubtree struct {
+	// Roots are the members no other member imports, or the members of the
+	// cycle the group hangs from when every one of them is imported.
+	Roots []string `json:"roots"`
+	// Components lists the paths of every member, in deletion order.
+	Components []string `json:"components"`
+	// Size is the members' combined file size in bytes.
+	Size int `json:"size"`
+}
+
+// liveComponents returns the components reachable from the components
+// nothing imports but that import others, taken for the application's roots
+// when no entry points are given, and from the framework's roots. Components
+// only importing each other are left out.
+func (sc *scan) liveComponents(g *graph.Graph) map[string]bool {
+	var roots []string
+	for _, p := range g.Nodes() {
+		if g.InDegree(p) == 0 && g.OutDegree(p) > 0 {
+			roots = append(roots, p)
+		}
+	}
+	for p := range sc.frameworkRoots {
+		roots = append(roots, p)
+	}
+	return g.Reachable(roots...)
+}
+
+// orphanedSubtrees groups the unused components linked by imports, keeping
+// the groups of more than one component. unused is in deletion order.
+func (sc *scan) orphanedSubtrees(g *graph.Graph, unused []*ComponentNode) []OrphanedSubtree {
+	unusedPaths := make(map[string]bool, len(unused))
+	for _, node := range unused {
+		unusedPaths[node.Component.Path] = true
+	}
+	sub := g.Subgraph(func(id string) bool { return unusedPaths[id] })
+
+	// Union the components linked by an import in either direction.
+	group := make(map[string]string)
+	var find func(id string) string
+	find = func(id string) string {
+		if p, ok := group[id]; ok && p != id {
+			group[id] = find(p)
+			return group[id]
+		}
+		group[id] = id
+		return id
+	}
+	for _, p := range sub.Nodes() {
+		for _, next := range sub.Successors(p) {
+			if a, b := find(p), find(next); a != b {
+				group[a] = b
+			}
+		}
+	}
+
+	// A root is a member of a cycle, or a lone component, nothing outside
+	// of it imports.
+	scc := make(map[string]int)
+	for i, members := range sub.StronglyConnectedComponents() {
+		for _, p := range members {
+			scc[p] = i
+		}
+	}
+	imported := make(map[int]bool)
+	for _, p := range sub.Nodes() {
+		for _, from := range sub.Predecessors(p) {
+			if scc[from] != scc[p] {
+				imported[scc[p]] = true
+			}
+		}
+	}
+
+	subtrees := make(map[string]*OrphanedSubtree)
+	var order []string
+	for _, node := range unused {
+		p := node.Component.Path
+		if len(sub.Predecessors(p)) == 0 && len(sub.Successors(p)) == 0 {
+			continue
+		}
+		key := find(p)
+		s := subtrees[key]
+		if s == nil {
+			s = &OrphanedSubtree{Roots: []string{}}
+			subtrees[key] = s
+			order = append(order, key)
+		}
+		s.Components = append(s.Components, p)
+		s.Size += sc.sizes[p]
+		if !imported[scc[p]] {
+			s.Roots = append(s.Roots, p)
+		}
+	}
+
+	result := make([]OrphanedSubtree, 0, len(order))
+	for _, key := range order {
+		s := subtrees[key]
+		sort.Strings(s.Roots)
+		result = append(result, *s)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Size > result[j].Size })
+	return result
+}
@@ -0,0 +1,101 @@
+package rgc
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"path"
+	"strconv"
+	"sync"
+
+	"github.com/igorfelipeduca/rgc/internal/jsparse"
+)
+
+// parseCacheVersion is part of every parse cache key. Bump it when the
+// output of jsparse.Parse changes, so results of the old parser are ignored.
+const parseCacheVersion = 2
+
+// maxCachedParses is how many parsed files are kept in memory, in front of
+// the ones persisted in the Storage.
+const maxCachedParses = 20000
+
+// parseCache keeps parsed files by content, so a file that didn't change
+// isn't parsed again, whatever the repository or scan it shows up in. The
+// most recent ones are kept in memory and all of them in analyses' Storage,
+// which keeps them across restarts when it's a database.
+type parseCache struct {
+	mu      sync.Mutex
+	entries map[string]*jsparse.File
+	order   []string
+}
+
+var parses = &parseCache{entries: make(map[string]*jsparse.File)}
+
+// blobSHA returns the Git blob SHA of content, the one GitHub lists in trees.
+func blobSHA(content string) string {
+	h := sha1.New()
+	h.Write([]byte("blob " + strconv.Itoa(len(content)) + "\x00"))
+	h.Write([]byte(content))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// parseCacheKey identifies the parse of content as the file at p: the
+// grammar depends on its extension.
+func parseCacheKey(p, content string) string {
+	return strconv.Itoa(parseCacheVersion) + ":" + path.Ext(p) + ":" + blobSHA(content)
+}
+
+func (c *parseCache) get(key string) *jsparse.File {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[key]
+}
+
+func (c *parseCache) put(key string, file *jsparse.File) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = file
+	for len(c.order) > maxCachedParses {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// parseFile parses content as the file at p, or returns the cached result
+// of a previous parse of the same content. Parsed files are shared between
+// scans and must not be modified. The Storage failing only costs a parse.
+func parseFile(ctx context.Context, p, content string) (*jsparse.File, error) {
+	key := parseCacheKey(p, content)
+	if file := parses.get(key); file != nil {
+		return file, nil
+	}
+
+	storage := analyses.storage
+	stored, err := storage.ParsedFile(ctx, key)
+	if err != nil {
+		loggerFrom(ctx).Warn("parse cache unavailable", "error", err)
+	}
+	if stored != nil {
+		var file jsparse.File
+		if err := json.Unmarshal(stored, &file); err == nil {
+			parses.put(key, &file)
+			return &file, nil
+		}
+	}
+
+	file, err := jsparse.Parse(ctx, p, []byte(content))
+	if err != nil {
+		return nil, err
+	}
+	parses.put(key, file)
+	if encoded, err := json.Marshal(file); err == nil {
+		if err := storage.SaveParsedFile(ctx, key, encoded); err != nil {
+			loggerFrom(ctx).Warn("parsed file not cached", "path", p, "error", err)
+		}
+	}
+	return file, nil
+}
@@ -0,0 +1,194 @@
+package rgc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/errgroup"
+)
+
+// patchContext is the number of unchanged lines around each hunk.
+const patchContext = 3
+
+// writeCleanupPatch renders the plan as a unified diff git apply accepts:
+// the deleted files, read from src, followed by the edited barrels.
+func writeCleanupPatch(ctx context.Context, src Source, plan *CleanupPlan, modes map[string]string) (string, error) {
+	files := plan.files()
+	contents := make([]string, len(files))
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.SetLimit(defaultConcurrency)
+	for i, p := range files {
+		i, p := i, p
+		eg.Go(func() error {
+			content, err := src.ReadFile(egCtx, p)
+			if err != nil && err != errFileNotFound {
+				return fmt.Errorf("error reading %s: %w", p, err)
+			}
+			contents[i] = content
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for i, p := range files {
+		mode := modes[p]
+		if mode == "" {
+			mode = "100644"
+		}
+		fmt.Fprintf(&b, "diff --git a/%s b/%s\ndeleted file mode %s\n--- a/%s\n+++ /dev/null\n", p, p, mode, p)
+		lines, noEOL := splitPatchLines(contents[i])
+		if len(lines) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "@@ -%s +0,0 @@\n", hunkRange(1, len(lines)))
+		for j, line := range lines {
+			writePatchLine(&b, '-', line, noEOL && j == len(lines)-1)
+		}
+	}
+	for _, p := range plan.Barrels {
+		edit := plan.barrelEdits[p]
+		fmt.Fprintf(&b, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n", p, p, p, p)
+		writeRemovalHunks(&b, edit)
+	}
+	return b.String(), nil
+}
+
+// writeRemovalHunks writes the hunks removing edit's lines, merging the
+// ones whose context overlaps.
+func writeRemovalHunks(b *strings.Builder, edit barrelEdit) {
+	lines, noEOL := splitPatchLines(edit.content)
+	type span struct{ start, end int }
+	var spans []span
+	for i := range lines {
+		if !edit.remove[i+1] {
+			continue
+		}
+		s := span{max(0, i-patchContext), min(len(lines), i+patchContext+1)}
+		if n := len(spans); n > 0 && s.start <= spans[n-1].end {
+			spans[n-1].end = s.end
+			continue
+		}
+		spans = append(spans, s)
+	}
+
+	removed := 0
+	for _, s := range spans {
+		count := 0
+		for i := s.start; i < s.end; i++ {
+			if edit.remove[i+1] {
+				count++
+			}
+		}
+		oldLines := s.end - s.start
+		newStart := s.start + 1 - removed
+		if oldLines == count {
+			// An empty range starts at the line before it.
+			newStart--
+		}
+		fmt.Fprintf(b, "@@ -%s +%s @@\n", hunkRange(s.start+1, oldLines), hunkRange(newStart, oldLines-count))
+		for i := s.start; i < s.end; i++ {
+			op := byte(' ')
+			if edit.remove[i+1] {
+				op = '-'
+			}
+			writePatchLine(b, op, lines[i], noEOL && i == len(lines)-1)
+		}
+		removed += count
+	}
+}
+
+// splitPatchLines splits content into lines, reporting whether the last
+// one lacks a newline.
+func splitPatchLines(content string) ([]string, bool) {
+	if content == "" {
+		return nil, false
+	}
+	noEOL := !strings.HasSuffix(content, "\n")
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n"), noEOL
+}
+
+func writePatchLine(b *strings.Builder, op byte, line string, noEOL bool) {
+	b.WriteByte(op)
+	b.WriteString(line)
+	b.WriteByte('\n')
+	if noEOL {
+		b.WriteString("\\ No newline at end of file\n")
+	}
+}
+
+func hunkRange(start, lines int) string {
+	if lines == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+// handleCleanupPatchRequest serves the cleanup of a past analysis as a
+// patch, for applying locally with git apply instead of opening a pull
+// request. Without components it deletes every unused component at least
+// min_confidence sure.
+func handleCleanupPatchRequest(c *gin.Context) {
+	ctx := c.Request.Context()
+	a, err := analyses.get(ctx, c.Param("owner"), c.Param("repo"), c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	token, _, err := actingToken(ctx, a.Owner, a.Repo, "")
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	paths := c.QueryArray("components")
+	if len(paths) == 0 {
+		minConfidence := 0.0
+		if v := c.Query("min_confidence"); v != "" {
+			minConfidence, err = strconv.ParseFloat(v, 64)
+			if err != nil || minConfidence < 0 || minConfidence > 1 {
+				respondError(c, errBadRequest("min_confidence must be a number between 0 and 1"))
+				return
+			}
+		}
+		for _, node := range a.Result.Unused {
+			if node.Confidence == nil || node.Confidence.Score >= minConfidence {
+				paths = append(paths, node.Component.Path)
+			}
+		}
+		if len(paths) == 0 {
+			respondError(c, errNotFound("no unused components to remove"))
+			return
+		}
+	}
+
+	src := &githubSource{client: newGitHubClient(token), owner: a.Owner, repo: a.Repo, ref: a.Ref}
+	plan, modes, warnings, err := buildCleanupPlan(ctx, src, a, paths)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	patch, err := writeCleanupPatch(ctx, src, plan, modes)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// git apply skips everything before the first diff.
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%s/%s@%s)\n\n", cleanupTitle(len(plan.Components)), a.Owner, a.Repo, a.Ref)
+	for _, w := range warnings {
+		fmt.Fprintf(&b, "warning: %s\n", w)
+	}
+	if len(warnings) > 0 {
+		b.WriteByte('\n')
+	}
+	b.WriteString(patch)
+	c.Data(http.StatusOK, "text/x-diff; charset=utf-8", []byte(b.String()))
+}
@@ -0,0 +1,85 @@
+package rgc
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/igorfelipeduca/rgc/internal/jsparse"
+	"golang.org/x/sync/errgroup"
+)
+
+// plainExtensions are the extensions of modules that can define components
+// without JSX.
+var plainExtensions = []string{".ts", ".js", ".mjs", ".cjs"}
+
+// elementFactoryRegex matches the ways of creating elements without JSX:
+// React.createElement, the compiled jsx() and jsxs() calls, and
+// styled-components' styled.button`...` or styled(Button)`...`.
+var elementFactoryRegex = regexp.MustCompile(`\bcreateElement\s*\(|\b_?jsxs?\s*\(|\bstyled(\.[A-Za-z][A-Za-z0-9]*|\s*\()`)
+
+// isPlainCandidate reports whether p may be a component written without
+// JSX. Like components, such modules are named in PascalCase, which keeps
+// hooks, utilities and configuration files out.
+func isPlainCandidate(p string) bool {
+	if !slices.Contains(plainExtensions, path.Ext(p)) || strings.HasSuffix(p, ".d.ts") ||
+		isSupportFile(p) || isAngularComponent(p) {
+		return false
+	}
+	return isPascalCase(extractComponentName(p))
+}
+
+// findPlainComponents adds the PascalCase .ts and .js modules creating
+// elements and exporting a default or PascalCase binding to the components,
+// so the components they render keep their importer. Their sources are
+// kept for building the component tree.
+func (sc *scan) findPlainComponents(ctx context.Context) error {
+	if !analyzerEnabled("react") {
+		return nil
+	}
+	if sc.prefetched == nil {
+		sc.prefetched = make(map[string]string)
+	}
+	sc.plainComponents = make(map[string]bool)
+
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(sc.concurrency)
+	for _, p := range sc.files {
+		if !isPlainCandidate(p) {
+			continue
+		}
+		eg.Go(func() error {
+			content, err := sc.src.ReadFile(ctx, p)
+			if err == errFileNotFound {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if !elementFactoryRegex.MatchString(content) {
+				return nil
+			}
+			file, err := parseFile(ctx, p, content)
+			if err != nil {
+				return &ParseError{Path: p, Err: err}
+			}
+			if !slices.ContainsFunc(file.Exports, func(e jsparse.Export) bool {
+				return e.Name == "default" || isPascalCase(e.Name)
+			}) {
+				return nil
+			}
+
+			sc.mu.Lock()
+			defer sc.mu.Unlock()
+			sc.plainComponents[p] = true
+			if sc.isComponent(p) {
+				sc.addComponent(p)
+				sc.prefetched[p] = content
+			}
+			return nil
+		})
+	}
+	return eg.Wait()
+}
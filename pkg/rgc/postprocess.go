@@ -0,0 +1,578 @@
+package rgc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errInvalidPostProcessor = errors.New("invalid post-processor")
+
+// execPostProcessorTimeout bounds how long an exec post-processor may run.
+const execPostProcessorTimeout = 30 * time.Second
+
+// PostProcessor rewrites a finished result before it's recorded and
+// returned. Transform must not modify its argument, results may be shared
+// with the scan history.
+type PostProcessor interface {
+	Transform(result *ComponentsResult) *ComponentsResult
+}
+
+// PostProcessorFunc adapts a function to a PostProcessor.
+type PostProcessorFunc func(result *ComponentsResult) *ComponentsResult
+
+func (f PostProcessorFunc) Transform(result *ComponentsResult) *ComponentsResult {
+	return f(result)
+}
+
+// PostProcessorFactory builds a post-processor from the argument following
+// its name in a spec, e.g. "packages/web/=" in "rewrite-prefix:packages/web/=".
+type PostProcessorFactory func(arg string) (PostProcessor, error)
+
+type postProcessorEntry struct {
+	factory PostProcessorFactory
+	// operatorOnly post-processors may only be set in RGC_POST_PROCESSORS,
+	// not by scan requests.
+	operatorOnly bool
+}
+
+var (
+	postProcessorsMu sync.RWMutex
+	postProcessors   = map[string]postProcessorEntry{
+		"redact-paths":   {factory: newRedactPaths},
+		"rewrite-prefix": {factory: newRewritePrefix},
+		"exec":           {factory: newExecPostProcessor, operatorOnly: true},
+	}
+)
+
+// RegisterPostProcessor makes a custom post-processor available under name,
+// to RGC_POST_PROCESSORS and to scan requests alike. Call it from an init
+// function of a file built into the server.
+func RegisterPostProcessor(name string, factory PostProcessorFactory) {
+	postProcessorsMu.Lock()
+	defer postProcessorsMu.Unlock()
+	if _, ok := postProcessors[name]; ok {
+		panic(fmt.Sprintf("post-processor %q registered twice", name))
+	}
+	postProcessors[name] = postProcessorEntry{factory: factory}
+}
+
+// postProcessorNames returns the names of the available post-processors.
+func postProcessorNames() []string {
+	postProcessorsMu.RLock()
+	defer postProcessorsMu.RUnlock()
+	names := make([]string, 0, len(postProcessors))
+	for name := range postProcessors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// configuredPostProcessors returns the specs of RGC_POST_PROCESSORS, the
+// post-processors every scan goes through.
+func configuredPostProcessors() []string {
+	var specs []string
+	for _, spec := range strings.Split(os.Getenv("RGC_POST_PROCESSORS"), ",") {
+		if spec = strings.TrimSpace(spec); spec != "" {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// buildPostProcessor builds the post-processor a "name" or "name:arg" spec
+// describes.
+func buildPostProcessor(spec string, operator bool) (PostProcessor, error) {
+	name, arg, _ := strings.Cut(spec, ":")
+	postProcessorsMu.RLock()
+	entry, ok := postProcessors[name]
+	postProcessorsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown post-processor %q", errInvalidPostProcessor, name)
+	}
+	if entry.operatorOnly && !operator {
+		return nil, fmt.Errorf("%w: %s can only be set in RGC_POST_PROCESSORS", errInvalidPostProcessor, name)
+	}
+	p, err := entry.factory(arg)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", errInvalidPostProcessor, name, err)
+	}
+	return p, nil
+}
+
+// postProcessorPipeline returns the post-processors of RGC_POST_PROCESSORS
+// followed by the ones a scan asked for, in order.
+func postProcessorPipeline(requested []string) ([]PostProcessor, error) {
+	var pipeline []PostProcessor
+	for _, spec := range configuredPostProcessors() {
+		p, err := buildPostProcessor(spec, true)
+		if err != nil {
+			return nil, fmt.Errorf("RGC_POST_PROCESSORS: %v", err)
+		}
+		pipeline = append(pipeline, p)
+	}
+	for _, spec := range requested {
+		p, err := buildPostProcessor(spec, false)
+		if err != nil {
+			return nil, err
+		}
+		pipeline = append(pipeline, p)
+	}
+	return pipeline, nil
+}
+
+func runPostProcessors(result *ComponentsResult, pipeline []PostProcessor) *ComponentsResult {
+	for _, p := range pipeline {
+		result = p.Transform(result)
+	}
+	return result
+}
+
+// rewritePaths returns a copy of result with every file path passed through
+// f. result itself is left untouched.
+func rewritePaths(result *ComponentsResult, f func(string) string) *ComponentsResult {
+	out := *result
+	out.Used = rewriteNodes(result.Used, nil, f)
+	out.Unused = rewriteNodes(result.Unused, nil, f)
+	out.TestOnly = rewriteNodes(result.TestOnly, nil, f)
+	out.StorybookOnly = rewriteNodes(result.StorybookOnly, nil, f)
+	out.E2EOnly = rewriteNodes(result.E2EOnly, nil, f)
+	out.PossiblyUsed = rewriteNodes(result.PossiblyUsed, nil, f)
+
+	if result.Cycles != nil {
+		out.Cycles = make([][]string, len(result.Cycles))
+		for i, cycle := range result.Cycles {
+			out.Cycles[i] = rewriteStrings(cycle, f)
+		}
+	}
+	if result.NameCollisions != nil {
+		out.NameCollisions = make([]NameCollision, len(result.NameCollisions))
+		for i, c := range result.NameCollisions {
+			c.Paths = rewriteStrings(c.Paths, f)
+			out.NameCollisions[i] = c
+		}
+	}
+	if result.Duplicates != nil {
+		out.Duplicates = make([]DuplicateCluster, len(result.Duplicates))
+		for i, c := range result.Duplicates {
+			c.Paths = rewriteStrings(c.Paths, f)
+			out.Duplicates[i] = c
+		}
+	}
+	if result.OrphanedSubtrees != nil {
+		out.OrphanedSubtrees = make([]OrphanedSubtree, len(result.OrphanedSubtrees))
+		for i, s := range result.OrphanedSubtrees {
+			s.Roots, s.Components = rewriteStrings(s.Roots, f), rewriteStrings(s.Components, f)
+			out.OrphanedSubtrees[i] = s
+		}
+	}
+	if result.Submodules != nil {
+		out.Submodules = make([]Submodule, len(result.Submodules))
+		for i, s := range result.Submodules {
+			s.Path = f(s.Path)
+			out.Submodules[i] = s
+		}
+	}
+	if result.Vendored != nil {
+		out.Vendored = make([]VendoredComponent, len(result.Vendored))
+		for i, v := range result.Vendored {
+			v.Path = f(v.Path)
+			out.Vendored[i] = v
+		}
+	}
+	if result.Ignored != nil {
+		out.Ignored = make([]IgnoredComponent, len(result.Ignored))
+		for i, c := range result.Ignored {
+			c.Path = f(c.Path)
+			out.Ignored[i] = c
+		}
+	}
+	if result.Generated != nil {
+		out.Generated = make([]GeneratedFile, len(result.Generated))
+		for i, g := range result.Generated {
+			g.Path = f(g.Path)
+			out.Generated[i] = g
+		}
+	}
+	if result.SkippedFiles != nil {
+		out.SkippedFiles = make([]SkippedFile, len(result.SkippedFiles))
+		for i, s := range result.SkippedFiles {
+			s.Path = f(s.Path)
+			out.SkippedFiles[i] = s
+		}
+	}
+	if result.Shadcn != nil {
+		out.Shadcn = make([]ShadcnReport, len(result.Shadcn))
+		for i, s := range result.Shadcn {
+			s.Config, s.UIDir = f(s.Config), f(s.UIDir)
+			out.Shadcn[i] = s
+		}
+	}
+	if result.Hygiene != nil {
+		hygiene := *result.Hygiene
+		hygiene.Issues = make([]HygieneIssue, len(result.Hygiene.Issues))
+		for i, issue := range result.Hygiene.Issues {
+			issue.File = f(issue.File)
+			if issue.Target != "" {
+				issue.Target = f(issue.Target)
+			}
+			hygiene.Issues[i] = issue
+		}
+		out.Hygiene = &hygiene
+	}
+	if result.UnusedExports != nil {
+		out.UnusedExports = make([]UnusedExport, len(result.UnusedExports))
+		for i, e := range result.UnusedExports {
+			e.Path = f(e.Path)
+			out.UnusedExports[i] = e
+		}
+	}
+	if result.Modules != nil {
+		modules := *result.Modules
+		modules.Used = rewriteModules(result.Modules.Used, f)
+		modules.Unused = rewriteModules(result.Modules.Unused, f)
+		out.Modules = &modules
+	}
+	if result.Assets != nil {
+		assets := *result.Assets
+		assets.Used = rewriteAssets(result.Assets.Used, f)
+		assets.Unused = rewriteAssets(result.Assets.Unused, f)
+		assets.OnlyUsedByUnused = rewriteAssets(result.Assets.OnlyUsedByUnused, f)
+		out.Assets = &assets
+	}
+	if result.StyledComponents != nil {
+		styled := *result.StyledComponents
+		styled.Used = rewriteStyled(result.StyledComponents.Used, f)
+		styled.Unused = rewriteStyled(result.StyledComponents.Unused, f)
+		out.StyledComponents = &styled
+	}
+	if result.Verification != nil {
+		verification := *result.Verification
+		verification.DeletedFiles = make([]string, len(result.Verification.DeletedFiles))
+		for i, p := range result.Verification.DeletedFiles {
+			verification.DeletedFiles[i] = f(p)
+		}
+		out.Verification = &verification
+	}
+	return &out
+}
+
+func rewriteStrings(paths []string, f func(string) string) []string {
+	out := make([]string, len(paths))
+	for i, p := range paths {
+		out[i] = f(p)
+	}
+	return out
+}
+
+func rewriteNodes(nodes []*ComponentNode, parent *ComponentNode, f func(string) string) []*ComponentNode {
+	if nodes == nil {
+		return nil
+	}
+	out := make([]*ComponentNode, len(nodes))
+	for i, n := range nodes {
+		copied := *n
+		copied.Parent = parent
+		copied.Component.Path = f(n.Component.Path)
+		if n.Import != nil {
+			edge := *n.Import
+			if edge.Via != "" {
+				edge.Via = f(edge.Via)
+			}
+			copied.Import = &edge
+		}
+		if n.Confidence != nil {
+			confidence := *n.Confidence
+			confidence.Signals = make([]ConfidenceSignal, len(n.Confidence.Signals))
+			for j, s := range n.Confidence.Signals {
+				files := make([]string, len(s.Files))
+				for k, p := range s.Files {
+					files[k] = f(p)
+				}
+				s.Files = files
+				confidence.Signals[j] = s
+			}
+			copied.Confidence = &confidence
+		}
+		if n.Savings != nil {
+			savings := *n.Savings
+			savings.Components = rewriteStrings(n.Savings.Components, f)
+			savings.Assets = rewriteStrings(n.Savings.Assets, f)
+			copied.Savings = &savings
+		}
+		copied.Children = rewriteNodes(n.Children, &copied, f)
+		out[i] = &copied
+	}
+	return out
+}
+
+func rewriteModules(modules []ModuleEntry, f func(string) string) []ModuleEntry {
+	if modules == nil {
+		return nil
+	}
+	out := make([]ModuleEntry, len(modules))
+	for i, m := range modules {
+		m.Path = f(m.Path)
+		out[i] = m
+	}
+	return out
+}
+
+func rewriteStyled(entries []StyledEntry, f func(string) string) []StyledEntry {
+	if entries == nil {
+		return nil
+	}
+	out := make([]StyledEntry, len(entries))
+	for i, e := range entries {
+		e.Path = f(e.Path)
+		if e.UsedBy != nil {
+			usedBy := make([]string, len(e.UsedBy))
+			for j, p := range e.UsedBy {
+				usedBy[j] = f(p)
+			}
+			e.UsedBy = usedBy
+		}
+		out[i] = e
+	}
+	return out
+}
+
+func rewriteAssets(assets []AssetEntry, f func(string) string) []AssetEntry {
+	if assets == nil {
+		return nil
+	}
+	out := make([]AssetEntry, len(assets))
+	for i, a := range assets {
+		a.Path = f(a.Path)
+		if a.ReferencedBy != nil {
+			refs := make([]string, len(a.ReferencedBy))
+			for j, ref := range a.ReferencedBy {
+				refs[j] = f(ref)
+			}
+			a.ReferencedBy = refs
+		}
+		out[i] = a
+	}
+	return out
+}
+
+// forEachNode calls f on every node of the result's component trees.
+func forEachNode(result *ComponentsResult, f func(*ComponentNode)) {
+	var walk func([]*ComponentNode)
+	walk = func(nodes []*ComponentNode) {
+		for _, n := range nodes {
+			f(n)
+			walk(n.Children)
+		}
+	}
+	for _, nodes := range [][]*ComponentNode{result.Used, result.Unused, result.TestOnly, result.StorybookOnly, result.E2EOnly, result.PossiblyUsed} {
+		walk(nodes)
+	}
+}
+
+// redactPath replaces the directories of p with a short hash, so files of
+// the same directory still group together but the layout isn't revealed.
+func redactPath(p string) string {
+	if p == "" {
+		return p
+	}
+	dir, file := path.Split(p)
+	if dir == "" {
+		return file
+	}
+	sum := sha256.Sum256([]byte(dir))
+	return "redacted/" + hex.EncodeToString(sum[:4]) + "/" + file
+}
+
+// newRedactPaths builds redact-paths, which hides the repository's layout:
+// directories are hashed, and import specifiers, hygiene suggestions and
+// the verify command's output are dropped.
+func newRedactPaths(arg string) (PostProcessor, error) {
+	if arg != "" {
+		return nil, fmt.Errorf("takes no argument")
+	}
+	return PostProcessorFunc(func(result *ComponentsResult) *ComponentsResult {
+		out := rewritePaths(result, redactPath)
+		forEachNode(out, func(n *ComponentNode) {
+			if n.Import != nil {
+				n.Import.Specifier = ""
+			}
+		})
+		if out.Hygiene != nil {
+			for i := range out.Hygiene.Issues {
+				out.Hygiene.Issues[i].Specifier = ""
+				out.Hygiene.Issues[i].Suggestion = ""
+			}
+		}
+		if out.Verification != nil {
+			out.Verification.Output = ""
+		}
+		return out
+	}), nil
+}
+
+// newRewritePrefix builds rewrite-prefix:<from>=<to>, which replaces the
+// leading from of every path with to, e.g. "packages/web/=" reports the paths
+// of a monorepo package relative to the package.
+func newRewritePrefix(arg string) (PostProcessor, error) {
+	from, to, ok := strings.Cut(arg, "=")
+	if !ok || from == "" {
+		return nil, fmt.Errorf("argument must be <from>=<to>, got %q", arg)
+	}
+	return PostProcessorFunc(func(result *ComponentsResult) *ComponentsResult {
+		return rewritePaths(result, func(p string) string {
+			if rest, ok := strings.CutPrefix(p, from); ok {
+				return to + rest
+			}
+			return p
+		})
+	}), nil
+}
+
+// newExecPostProcessor builds exec:<command>, which pipes the result as JSON
+// to the command's standard input and reads the transformed result from its
+// standard output. The command is split on spaces, no shell is involved. If
+// it fails, the result is kept as is with a warning.
+func newExecPostProcessor(arg string) (PostProcessor, error) {
+	argv := strings.Fields(arg)
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("a command is required")
+	}
+	return PostProcessorFunc(func(result *ComponentsResult) *ComponentsResult {
+		transformed, err := runExecPostProcessor(argv, result)
+		if err != nil {
+			out := *result
+			out.Warnings = append(append([]string(nil), result.Warnings...), fmt.Sprintf("post-processor %s failed: %v", argv[0], err))
+			return &out
+		}
+		return transformed
+	}), nil
+}
+
+func runExecPostProcessor(argv []string, result *ComponentsResult) (*ComponentsResult, error) {
+	input, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), execPostProcessorTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%v: %s", err, msg)
+		}
+		return nil, err
+	}
+
+	var transformed ComponentsResult
+	if err := json.Unmarshal(stdout.Bytes(), &transformed); err != nil {
+		return nil, fmt.Errorf("invalid output: %v", err)
+	}
+	return &transformed, nil
+}
+
+// mergeResults combines the results of several packages or repositories
+// into one, prefixing the paths of each with its name and a slash.
+func mergeResults(parts map[string]*ComponentsResult) *ComponentsResult {
+	names := make([]string, 0, len(parts))
+	for name := range parts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	merged := &ComponentsResult{
+		Used:          []*ComponentNode{},
+		Unused:        []*ComponentNode{},
+		TestOnly:      []*ComponentNode{},
+		StorybookOnly: []*ComponentNode{},
+		E2EOnly:       []*ComponentNode{},
+	}
+	for _, name := range names {
+		prefix := name + "/"
+		part := rewritePaths(parts[name], func(p string) string { return prefix + p })
+
+		merged.UsedCount += part.UsedCount
+		merged.UnusedCount += part.UnusedCount
+		merged.TestOnlyCount += part.TestOnlyCount
+		merged.StorybookOnlyCount += part.StorybookOnlyCount
+		merged.E2EOnlyCount += part.E2EOnlyCount
+		merged.PossiblyUsedCount += part.PossiblyUsedCount
+		merged.Used = append(merged.Used, part.Used...)
+		merged.Unused = append(merged.Unused, part.Unused...)
+		merged.TestOnly = append(merged.TestOnly, part.TestOnly...)
+		merged.StorybookOnly = append(merged.StorybookOnly, part.StorybookOnly...)
+		merged.E2EOnly = append(merged.E2EOnly, part.E2EOnly...)
+		merged.PossiblyUsed = append(merged.PossiblyUsed, part.PossiblyUsed...)
+		merged.Cycles = append(merged.Cycles, part.Cycles...)
+		merged.OrphanedSubtrees = append(merged.OrphanedSubtrees, part.OrphanedSubtrees...)
+		merged.NameCollisions = append(merged.NameCollisions, part.NameCollisions...)
+		merged.Duplicates = append(merged.Duplicates, part.Duplicates...)
+		merged.Submodules = append(merged.Submodules, part.Submodules...)
+		merged.Vendored = append(merged.Vendored, part.Vendored...)
+		merged.Ignored = append(merged.Ignored, part.Ignored...)
+		merged.SkippedFiles = append(merged.SkippedFiles, part.SkippedFiles...)
+		merged.Generated = append(merged.Generated, part.Generated...)
+		merged.GeneratedCount += part.GeneratedCount
+		merged.EstimatedSavings += part.EstimatedSavings
+		merged.Shadcn = append(merged.Shadcn, part.Shadcn...)
+		merged.UnusedExports = append(merged.UnusedExports, part.UnusedExports...)
+		for _, w := range part.Warnings {
+			merged.Warnings = append(merged.Warnings, name+": "+w)
+		}
+
+		if h := part.Hygiene; h != nil {
+			if merged.Hygiene == nil {
+				merged.Hygiene = &HygieneReport{Issues: []HygieneIssue{}}
+			}
+			merged.Hygiene.DeepRelative += h.DeepRelative
+			merged.Hygiene.BarrelBypass += h.BarrelBypass
+			merged.Hygiene.FeatureInternals += h.FeatureInternals
+			merged.Hygiene.Issues = append(merged.Hygiene.Issues, h.Issues...)
+		}
+		if m := part.Modules; m != nil {
+			if merged.Modules == nil {
+				merged.Modules = &ModulesReport{Used: []ModuleEntry{}, Unused: []ModuleEntry{}}
+			}
+			merged.Modules.UsedCount += m.UsedCount
+			merged.Modules.UnusedCount += m.UnusedCount
+			merged.Modules.Used = append(merged.Modules.Used, m.Used...)
+			merged.Modules.Unused = append(merged.Modules.Unused, m.Unused...)
+		}
+		if a := part.Assets; a != nil {
+			if merged.Assets == nil {
+				merged.Assets = &AssetsReport{Used: []AssetEntry{}, Unused: []AssetEntry{}}
+			}
+			merged.Assets.UsedCount += a.UsedCount
+			merged.Assets.UnusedCount += a.UnusedCount
+			merged.Assets.Used = append(merged.Assets.Used, a.Used...)
+			merged.Assets.Unused = append(merged.Assets.Unused, a.Unused...)
+			merged.Assets.OnlyUsedByUnused = append(merged.Assets.OnlyUsedByUnused, a.OnlyUsedByUnused...)
+		}
+		if s := part.StyledComponents; s != nil {
+			if merged.StyledComponents == nil {
+				merged.StyledComponents = &StyledReport{Used: []StyledEntry{}, Unused: []StyledEntry{}}
+			}
+			merged.StyledComponents.UsedCount += s.UsedCount
+			merged.StyledComponents.UnusedCount += s.UnusedCount
+			merged.StyledComponents.Used = append(merged.StyledComponents.Used, s.Used...)
+			merged.StyledComponents.Unused = append(merged.StyledComponents.Unused, s.Unused...)
+		}
+	}
+	return merged
+}
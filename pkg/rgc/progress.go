@@ -0,0 +1,126 @@
+package rgc
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Scan phases, in order.
+const (
+	phaseQueued    = "queued"
+	phaseResolving = "resolving"
+	phaseListing   = "listing"
+	phaseParsing   = "parsing"
+	phaseAnalyzing = "analyzing"
+	phaseVerifying = "verifying"
+	phaseDone      = "done"
+)
+
+// phaseStart is the overall percentage reached when a phase starts. Parsing
+// every component takes most of a scan, so it spans most of the range.
+var phaseStart = map[string]int{
+	phaseQueued:    0,
+	phaseResolving: 0,
+	phaseListing:   5,
+	phaseParsing:   10,
+	phaseAnalyzing: 90,
+	phaseVerifying: 95,
+	phaseDone:      100,
+}
+
+// Progress tracks how far a running scan got. A nil *Progress ignores
+// updates, so scans without a job report nothing. With a logger, it logs
+// how long each phase took.
+type Progress struct {
+	mu          sync.Mutex
+	phase       string
+	filesParsed int
+	totalFiles  int
+
+	logger         *slog.Logger
+	phaseStartedAt time.Time
+}
+
+// ProgressSnapshot is the state of a Progress at one point in time.
+type ProgressSnapshot struct {
+	Phase       string `json:"phase"`
+	FilesParsed int    `json:"files_parsed"`
+	TotalFiles  int    `json:"total_files"`
+	Percent     int    `json:"percent"`
+}
+
+func newProgress() *Progress {
+	return &Progress{phase: phaseQueued}
+}
+
+// setLogger makes p log the duration of every phase from now on.
+func (p *Progress) setLogger(logger *slog.Logger) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.logger = logger
+	p.phaseStartedAt = time.Now()
+}
+
+func (p *Progress) setPhase(phase string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.enter(phase)
+}
+
+// startParsing enters the parsing phase with the number of files to parse.
+func (p *Progress) startParsing(total int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.enter(phaseParsing)
+	p.totalFiles = total
+}
+
+// enter switches to phase, logging how long the previous one took. The
+// caller holds p.mu.
+func (p *Progress) enter(phase string) {
+	if phase == p.phase {
+		return
+	}
+	if p.logger != nil && p.phase != phaseQueued {
+		attrs := []any{"phase", p.phase, "duration_ms", time.Since(p.phaseStartedAt).Milliseconds()}
+		if p.phase == phaseParsing {
+			attrs = append(attrs, "files", p.filesParsed)
+		}
+		p.logger.Info("scan phase finished", attrs...)
+	}
+	p.phase = phase
+	p.phaseStartedAt = time.Now()
+}
+
+func (p *Progress) fileParsed() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.filesParsed++
+}
+
+func (p *Progress) snapshot() ProgressSnapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	percent := phaseStart[p.phase]
+	if p.phase == phaseParsing && p.totalFiles > 0 {
+		span := phaseStart[phaseAnalyzing] - phaseStart[phaseParsing]
+		percent += span * min(p.filesParsed, p.totalFiles) / p.totalFiles
+	}
+	return ProgressSnapshot{
+		Phase:       p.phase,
+		FilesParsed: p.filesParsed,
+		TotalFiles:  p.totalFiles,
+		Percent:     percent,
+	}
+}
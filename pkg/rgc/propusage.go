@@ -0,0 +1,168 @@
+package rgc
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/igorfelipeduca/rgc/internal/jsparse"
+	"golang.org/x/sync/errgroup"
+)
+
+// PropUsage tells which props the JSX call sites of a component pass.
+type PropUsage struct {
+	// CallSites counts the elements rendering the component, and Spread
+	// those spreading props into it ({...rest}).
+	CallSites int `json:"call_sites"`
+	Spread    int `json:"spread,omitempty"`
+	// Passed counts the call sites passing each prop, most passed first.
+	Passed []PropCount `json:"passed"`
+	// NeverPassed lists the declared props no call site passes, when props
+	// are extracted too. It's left out when a call site spreads props,
+	// which may pass any of them.
+	NeverPassed []string `json:"never_passed,omitempty"`
+}
+
+// PropCount is how many call sites pass a prop.
+type PropCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// propUsage collects the props passed to each component at its JSX call
+// sites in shipped files: the elements rendering a binding imported from
+// the component's file, directly or through a barrel. Tests and stories
+// don't count, as they pass props to exercise them, not to use them.
+func (sc *scan) propUsage(ctx context.Context) (map[string]*PropUsage, error) {
+	usage := make(map[string]*PropUsage)
+	passed := make(map[string]map[string]int)
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(sc.concurrency)
+	for _, p := range sc.files {
+		if !jsparse.Supported(p) || isSupportFile(p) || sc.isGenerated(p) {
+			continue
+		}
+		eg.Go(func() error {
+			content, err := sc.src.ReadFile(ctx, p)
+			if err != nil {
+				if err == errFileNotFound {
+					return nil
+				}
+				return err
+			}
+			if !strings.Contains(content, "<") {
+				return nil
+			}
+			file, err := parseFile(ctx, p, content)
+			if err != nil {
+				return &ParseError{Path: p, Err: err}
+			}
+			locals, err := sc.componentBindings(ctx, p, file)
+			if err != nil || len(locals) == 0 {
+				return err
+			}
+			elements, err := jsparse.JSXElements(ctx, p, []byte(content))
+			if err != nil {
+				return &ParseError{Path: p, Err: err}
+			}
+
+			sc.mu.Lock()
+			defer sc.mu.Unlock()
+			for _, el := range elements {
+				target, ok := locals[el.Name]
+				if !ok {
+					continue
+				}
+				u := usage[target]
+				if u == nil {
+					u = &PropUsage{Passed: []PropCount{}}
+					usage[target] = u
+					passed[target] = make(map[string]int)
+				}
+				u.CallSites++
+				if el.Spread {
+					u.Spread++
+				}
+				for _, prop := range el.Props {
+					passed[target][prop]++
+				}
+			}
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	for target, u := range usage {
+		for name, count := range passed[target] {
+			u.Passed = append(u.Passed, PropCount{Name: name, Count: count})
+		}
+		sort.Slice(u.Passed, func(i, j int) bool {
+			if u.Passed[i].Count != u.Passed[j].Count {
+				return u.Passed[i].Count > u.Passed[j].Count
+			}
+			return u.Passed[i].Name < u.Passed[j].Name
+		})
+	}
+	return usage, nil
+}
+
+// componentBindings maps the local names a file imports components under
+// to the components' paths. From a component's own file, only its default
+// export and the export named after it count, since the other exports of
+// the file are other components.
+func (sc *scan) componentBindings(ctx context.Context, p string, file *jsparse.File) (map[string]string, error) {
+	locals := make(map[string]string)
+	for _, imp := range file.Imports {
+		if imp.Kind != jsparse.Static || !imp.IsRuntime() {
+			continue
+		}
+		target := sc.resolveModule(p, imp.Specifier)
+		if target == "" {
+			continue
+		}
+		if component, ok := sc.createdComponents[target]; ok {
+			for _, b := range imp.ValueBindings() {
+				if b.Imported == "default" || normalizeName(b.Imported) == component.Name {
+					locals[b.Local] = target
+				}
+			}
+			continue
+		}
+		if sc.isComponent(target) || !jsparse.Supported(target) {
+			continue
+		}
+		for _, b := range imp.ValueBindings() {
+			if b.Imported == "*" {
+				continue
+			}
+			components, err := sc.followReExports(ctx, target, []string{b.Imported}, make(map[string]bool))
+			if err != nil {
+				return nil, err
+			}
+			if len(components) == 1 {
+				locals[b.Local] = components[0]
+			}
+		}
+	}
+	return locals, nil
+}
+
+// usageFor returns the component's prop usage, with the declared props no
+// call site passes.
+func (u *PropUsage) usageFor(props []jsparse.Prop) *PropUsage {
+	if u == nil {
+		return &PropUsage{Passed: []PropCount{}}
+	}
+	out := *u
+	if u.Spread == 0 {
+		for _, prop := range props {
+			if !slices.ContainsFunc(u.Passed, func(c PropCount) bool { return c.Name == prop.Name }) {
+				out.NeverPassed = append(out.NeverPassed, prop.Name)
+			}
+		}
+	}
+	return &out
+}
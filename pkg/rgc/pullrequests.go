@@ -0,0 +1,150 @@
+package rgc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v39/github"
+)
+
+const defaultPRLabels = "tech-debt,rgc"
+
+// prTriage is how cleanup pull requests are slotted into a repository's
+// triage workflow once opened.
+type prTriage struct {
+	// Labels are added to the pull request, and created by GitHub when the
+	// repository doesn't have them yet.
+	Labels []string `json:"labels"`
+	// Milestone is the title of an open milestone to attach, if set.
+	Milestone string `json:"milestone,omitempty"`
+	// RequestCodeowners requests reviews from the CODEOWNERS of the
+	// deleted files.
+	RequestCodeowners bool `json:"request_codeowners"`
+}
+
+// loadPRTriage reads the triage settings from RGC_PR_LABELS (comma
+// separated, "none" for no labels), RGC_PR_MILESTONE and
+// RGC_PR_REQUEST_CODEOWNERS.
+func loadPRTriage() prTriage {
+	triage := prTriage{
+		Milestone:         os.Getenv("RGC_PR_MILESTONE"),
+		RequestCodeowners: os.Getenv("RGC_PR_REQUEST_CODEOWNERS") != "false",
+	}
+	labels := envOr("RGC_PR_LABELS", defaultPRLabels)
+	if labels != "none" {
+		for _, label := range strings.Split(labels, ",") {
+			if label = strings.TrimSpace(label); label != "" {
+				triage.Labels = append(triage.Labels, label)
+			}
+		}
+	}
+	return triage
+}
+
+// apply labels the pull request, attaches the milestone and requests
+// reviews from the owners of the deleted files. The pull request is already
+// open, so every step is attempted and the failures are reported together.
+func (t prTriage) apply(ctx context.Context, client *github.Client, owner, repo string, number int, owners codeowners, deleted []string) error {
+	var problems []string
+
+	if len(t.Labels) > 0 {
+		if _, _, err := client.Issues.AddLabelsToIssue(ctx, owner, repo, number, t.Labels); err != nil {
+			problems = append(problems, fmt.Sprintf("adding labels: %v", err))
+		}
+	}
+
+	if t.Milestone != "" {
+		milestone, err := findMilestone(ctx, client, owner, repo, t.Milestone)
+		if err == nil {
+			_, _, err = client.Issues.Edit(ctx, owner, repo, number, &github.IssueRequest{Milestone: milestone.Number})
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("attaching milestone %q: %v", t.Milestone, err))
+		}
+	}
+
+	if t.RequestCodeowners {
+		users, teams := codeownersReviewers(owners, deleted)
+		// GitHub refuses review requests for the pull request's author.
+		if me, _, err := client.Users.Get(ctx, ""); err == nil {
+			users = without(users, me.GetLogin())
+		}
+		if len(users) > 0 || len(teams) > 0 {
+			_, _, err := client.PullRequests.RequestReviewers(ctx, owner, repo, number, github.ReviewersRequest{
+				Reviewers:     users,
+				TeamReviewers: teams,
+			})
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("requesting reviews: %v", err))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("error triaging pull request #%d: %s", number, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func findMilestone(ctx context.Context, client *github.Client, owner, repo, title string) (*github.Milestone, error) {
+	opts := &github.MilestoneListOptions{State: "open", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		milestones, resp, err := client.Issues.ListMilestones(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range milestones {
+			if m.GetTitle() == title {
+				return m, nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return nil, fmt.Errorf("no open milestone named %q", title)
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// codeownersReviewers returns the users and the teams (without their
+// organization) owning the given files. Email owners can't be requested
+// for review and are skipped.
+func codeownersReviewers(owners codeowners, files []string) ([]string, []string) {
+	users := make(map[string]bool)
+	teams := make(map[string]bool)
+	for _, f := range files {
+		for _, o := range owners.owners(f) {
+			if !strings.HasPrefix(o, "@") {
+				continue
+			}
+			o = strings.TrimPrefix(o, "@")
+			if _, team, ok := strings.Cut(o, "/"); ok {
+				teams[team] = true
+			} else {
+				users[o] = true
+			}
+		}
+	}
+	return sortedKeys(users), sortedKeys(teams)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func without(list []string, s string) []string {
+	var kept []string
+	for _, v := range list {
+		if !strings.EqualFold(v, s) {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
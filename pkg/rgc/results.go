@@ -0,0 +1,167 @@
+package rgc
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPerPage = 50
+	maxPerPage     = 500
+)
+
+// ComponentsPage is one page of an analysis' components, filtered and
+// sorted.
+type ComponentsPage struct {
+	AnalysisID string `json:"analysis_id"`
+	Page       int    `json:"page"`
+	PerPage    int    `json:"per_page"`
+	// Total is the number of components matching the filters, across pages.
+	Total      int            `json:"total"`
+	Components []CatalogEntry `json:"components"`
+}
+
+// componentsQuery selects the components of an analysis to return.
+type componentsQuery struct {
+	status     string
+	pathPrefix string
+	sort       string
+	desc       bool
+	page       int
+	perPage    int
+}
+
+func parseComponentsQuery(c *gin.Context) (componentsQuery, error) {
+	q := componentsQuery{
+		status:     c.Query("status"),
+		pathPrefix: c.Query("path_prefix"),
+		sort:       c.DefaultQuery("sort", "path"),
+		page:       1,
+		perPage:    defaultPerPage,
+	}
+	switch q.status {
+	case "", "used", "unused", "test_only", "storybook_only", "e2e_only", "possibly_used":
+	default:
+		return q, errBadRequest("status must be used, unused, test_only, storybook_only, e2e_only or possibly_used")
+	}
+	q.sort, q.desc = strings.TrimPrefix(q.sort, "-"), strings.HasPrefix(q.sort, "-")
+	switch q.sort {
+	case "name", "path", "usage", "size", "last_modified", "confidence":
+	default:
+		return q, errBadRequest("sort must be name, path, usage, size, last_modified or confidence, prefixed with - to reverse the order")
+	}
+	if v := c.Query("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return q, errBadRequest("page must be a positive number")
+		}
+		q.page = n
+	}
+	if v := c.Query("per_page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return q, errBadRequest("per_page must be a positive number")
+		}
+		q.perPage = min(n, maxPerPage)
+	}
+	return q, nil
+}
+
+// apply filters and sorts the entries and cuts the requested page.
+func (q componentsQuery) apply(entries []CatalogEntry) ([]CatalogEntry, int) {
+	matched := make([]CatalogEntry, 0, len(entries))
+	for _, e := range entries {
+		if q.status != "" && e.Status != q.status {
+			continue
+		}
+		if !strings.HasPrefix(e.Path, q.pathPrefix) {
+			continue
+		}
+		matched = append(matched, e)
+	}
+
+	less := func(a, b CatalogEntry) bool {
+		switch q.sort {
+		case "name":
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+		case "usage":
+			if a.UsageCount != b.UsageCount {
+				return a.UsageCount < b.UsageCount
+			}
+		case "size":
+			if a.Size != b.Size {
+				return a.Size < b.Size
+			}
+		case "last_modified":
+			if at, bt := lastModified(a), lastModified(b); !at.Equal(bt) {
+				return at.Before(bt)
+			}
+		case "confidence":
+			if as, bs := confidenceScore(a), confidenceScore(b); as != bs {
+				return as < bs
+			}
+		}
+		return a.Path < b.Path
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if q.desc {
+			return less(matched[j], matched[i])
+		}
+		return less(matched[i], matched[j])
+	})
+
+	start := min((q.page-1)*q.perPage, len(matched))
+	end := min(start+q.perPage, len(matched))
+	return matched[start:end], len(matched)
+}
+
+// lastModified returns when the entry's file last changed, or the zero time
+// when unknown, sorting it first.
+func lastModified(e CatalogEntry) time.Time {
+	if e.LastCommit == nil {
+		return time.Time{}
+	}
+	return e.LastCommit.Date
+}
+
+// confidenceScore returns the entry's deletion confidence, or -1 for
+// components that aren't unused, sorting them first.
+func confidenceScore(e CatalogEntry) float64 {
+	if e.Confidence == nil {
+		return -1
+	}
+	return e.Confidence.Score
+}
+
+// handleComponentsRequest lists the components of a past analysis one page
+// at a time, optionally filtered by status and path prefix, so large
+// repositories don't have to be downloaded as a single tree.
+func handleComponentsRequest(c *gin.Context) {
+	q, err := parseComponentsQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	owner, repo := c.Param("owner"), c.Param("repo")
+	analysis, err := analyses.get(c.Request.Context(), owner, repo, c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	components, total := q.apply(buildCatalog(owner+"/"+repo, analysis.Result).Components)
+	c.JSON(http.StatusOK, ComponentsPage{
+		AnalysisID: analysis.ID,
+		Page:       q.page,
+		PerPage:    q.perPage,
+		Total:      total,
+		Components: components,
+	})
+}
@@ -0,0 +1,1193 @@
+// Package rgc finds the components of a GitHub repository nothing uses
+// anymore. ProcessRepository scans a repository; Setup, NewRouter and Serve
+// run the HTTP API around it.
+package rgc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/igorfelipeduca/rgc/internal/graph"
+	"github.com/igorfelipeduca/rgc/internal/jsparse"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+)
+
+type Component struct {
+	Name string
+	Path string
+}
+
+type ComponentNode struct {
+	Component Component
+	Children  []*ComponentNode
+	Parent    *ComponentNode `json:"-"` // This will exclude Parent from JSON serialization
+	// Props is the component's API surface, when props extraction is on.
+	Props []jsparse.Prop `json:"props,omitempty"`
+	// PropUsage tells which props the component's call sites pass, when
+	// asked for.
+	PropUsage *PropUsage `json:"prop_usage,omitempty"`
+	// Owners are the component's owners according to CODEOWNERS.
+	Owners []string `json:"owners,omitempty"`
+	// Import tells how the parent imports the component, on child nodes
+	// linked by an import (Angular links components through templates).
+	Import *ImportEdge `json:"import,omitempty"`
+	// Parents are the paths of the components importing this one, in
+	// flattened results.
+	Parents []string `json:"parents,omitempty"`
+	// Size is the size of the component's file in bytes, and LastCommit the
+	// last commit touching it, when metadata is asked for.
+	Size       int         `json:"size,omitempty"`
+	LastCommit *LastCommit `json:"last_commit,omitempty"`
+	// Confidence tells how safe deleting an unused component likely is.
+	Confidence *Confidence `json:"confidence,omitempty"`
+	// Savings estimates what deleting an unused component saves.
+	Savings *Savings `json:"savings,omitempty"`
+	// Metrics sizes up the component, when complexity is asked for.
+	Metrics *ComponentMetrics `json:"metrics,omitempty"`
+}
+
+// Custom MarshalJSON method to handle circular references
+func (cn *ComponentNode) MarshalJSON() ([]byte, error) {
+	type Alias ComponentNode
+	return json.Marshal(&struct {
+		*Alias
+		Children []*ComponentNode `json:"children,omitempty"`
+	}{
+		Alias:    (*Alias)(cn),
+		Children: cn.Children,
+	})
+}
+
+type ComponentsResult struct {
+	UsedCount          int              `json:"used_count"`
+	UnusedCount        int              `json:"unused_count"`
+	TestOnlyCount      int              `json:"test_only_count"`
+	StorybookOnlyCount int              `json:"storybook_only_count"`
+	E2EOnlyCount       int              `json:"e2e_only_count"`
+	Used               []*ComponentNode `json:"used"`
+	Unused             []*ComponentNode `json:"unused"`
+	// TestOnly, StorybookOnly and E2EOnly hold the components only test
+	// files, Storybook stories or end-to-end specs use: not dead, but not
+	// shipped either. E2E-only components often are UI removed from the app
+	// but not from the test suite.
+	TestOnly      []*ComponentNode `json:"test_only"`
+	StorybookOnly []*ComponentNode `json:"storybook_only"`
+	E2EOnly       []*ComponentNode `json:"e2e_only"`
+	// PossiblyUsed holds the components nothing imports but whose name is
+	// quoted in a shipped file, when string references are looked for.
+	PossiblyUsed      []*ComponentNode `json:"possibly_used,omitempty"`
+	PossiblyUsedCount int              `json:"possibly_used_count,omitempty"`
+	// EstimatedSavings is the combined size in bytes of the unused
+	// components, and with assets, of the assets only they reference.
+	EstimatedSavings int `json:"estimated_savings"`
+	// Cycles lists the paths of the components importing each other.
+	Cycles [][]string `json:"cycles,omitempty"`
+	// NameCollisions lists the names several components share.
+	NameCollisions []NameCollision `json:"name_collisions,omitempty"`
+	// Duplicates groups the components whose sources are nearly the same,
+	// when asked for.
+	Duplicates []DuplicateCluster `json:"duplicates,omitempty"`
+	// OrphanedSubtrees groups the unused components importing each other.
+	OrphanedSubtrees []OrphanedSubtree `json:"orphaned_subtrees,omitempty"`
+	// Submodules lists the Git submodules of the repository.
+	Submodules []Submodule `json:"submodules,omitempty"`
+	// Vendored lists components copied from third-party libraries. They're
+	// also counted in used/unused unless the scan excludes them.
+	Vendored []VendoredComponent `json:"vendored,omitempty"`
+	// Ignored lists the unused components an rgc-ignore comment keeps out
+	// of the unused list and its count.
+	Ignored []IgnoredComponent `json:"ignored,omitempty"`
+	// SkippedFiles lists the component files too large to parse, left out
+	// of the components and the graph.
+	SkippedFiles []SkippedFile `json:"skipped_files,omitempty"`
+	// Generated lists the files code generators wrote, left out of the
+	// components and the graph, and GeneratedCount counts them.
+	Generated      []GeneratedFile `json:"generated,omitempty"`
+	GeneratedCount int             `json:"generated_count,omitempty"`
+	Shadcn         []ShadcnReport  `json:"shadcn,omitempty"`
+	Hygiene        *HygieneReport  `json:"hygiene,omitempty"`
+	// UnusedExports lists exported components, hooks and values nothing
+	// imports, inside files that are otherwise used.
+	UnusedExports []UnusedExport `json:"unused_exports,omitempty"`
+	// Modules reports custom hooks and utility modules, when asked for.
+	Modules *ModulesReport `json:"modules,omitempty"`
+	// Assets reports images and stylesheets, when asked for.
+	Assets *AssetsReport `json:"assets,omitempty"`
+	// StyledComponents reports the styled-components and Emotion
+	// components, when asked for.
+	StyledComponents *StyledReport `json:"styled_components,omitempty"`
+	Meta             *RepoMeta     `json:"meta,omitempty"`
+	// Incremental describes the rescan, when it started from a previous
+	// analysis.
+	Incremental *IncrementalScan `json:"incremental,omitempty"`
+	Warnings    []string         `json:"warnings,omitempty"`
+
+	Verification *VerificationResult `json:"verification,omitempty"`
+}
+
+const (
+	defaultConcurrency = 8
+	maxConcurrency     = 32
+)
+
+// scan holds the state of a single repository analysis.
+type scan struct {
+	src         Source
+	concurrency int
+
+	files           []string
+	fileIndex       map[string]string // nameKey(path) -> path
+	caseInsensitive bool
+
+	mu sync.Mutex
+	// createdComponents maps the path of each component to it, and
+	// componentsByName the nameKey of a component name to the paths of the
+	// components named so.
+	createdComponents map[string]Component
+	componentsByName  map[string][]string
+	rootComponents    []*ComponentNode
+	modules           map[string]*jsparse.File
+	// frameworkRoots are components the framework mounts itself, e.g.
+	// bootstrapped or routed Angular components, Next.js pages or React
+	// Router routes, so nothing imports them.
+	frameworkRoots map[string]bool
+	// vendored maps the paths of components that look copied from a
+	// third-party library to the reason they do.
+	vendored map[string]string
+	// ignored maps the paths of components opting out of the unused list
+	// with an rgc-ignore comment to the reason they give.
+	ignored map[string]string
+	// includeVendorDirs keeps the files of vendor directories in the scan.
+	includeVendorDirs bool
+	// generated maps the paths of the files a code generator wrote to the
+	// reason they look so, unless includeGenerated keeps them in.
+	generated        map[string]string
+	includeGenerated bool
+	shadcnRoots      []string
+	shadcn           []*shadcnConfig
+
+	// props enables extracting each component's props.
+	props bool
+	// metadata enables recording each component's file size.
+	metadata   bool
+	codeowners codeowners
+	// testImports, storyImports and e2eImports hold the components imported
+	// by test files, Storybook stories and end-to-end specs.
+	testImports  map[string]bool
+	storyImports map[string]bool
+	e2eImports   map[string]bool
+	// e2eTestIDs holds the test ids end-to-end specs query, testIDs the
+	// ones each component renders.
+	e2eTestIDs map[string]bool
+	testIDs    map[string][]string
+	// hygiene enables the import hygiene checks.
+	hygiene       bool
+	hygieneIssues []HygieneIssue
+	// computedImports maps the path prefixes imports computed at runtime may
+	// load, "" for any path, to the files importing them; stringRefs maps
+	// the component-like names quoted in parsed files to those files.
+	computedImports map[string]map[string]bool
+	stringRefs      map[string]map[string]bool
+	// sizes maps the path of each component, and of the assets the savings
+	// may count, to its file size.
+	sizes map[string]int
+	// duplicates enables the source signatures, kept for each component
+	// long enough to compare.
+	duplicates bool
+	signatures map[string]signature
+	// complexity enables the metrics measured in each component's source.
+	complexity bool
+	metrics    map[string]*ComponentMetrics
+	// assetReferrers maps each asset to the files referencing it, when
+	// assets are checked.
+	assetReferrers map[string][]string
+	// sizeRules pick the component files too large to parse, skipped lists
+	// them.
+	sizeRules FileSizeRules
+	skipped   []SkippedFile
+	// rules narrows down which files are components, rejected holds the
+	// files without the export they require, and prefetched the sources
+	// checking exports read, until the component tree is built.
+	rules      ComponentRules
+	rejected   map[string]bool
+	prefetched map[string]string
+	// plainComponents holds the .ts and .js files defining components
+	// without JSX.
+	plainComponents map[string]bool
+
+	progress *Progress
+	warnings []string
+}
+
+// usedByE2E reports whether end-to-end specs import the component at p or
+// query one of the test ids it renders.
+func (sc *scan) usedByE2E(p string) bool {
+	if sc.e2eImports[p] {
+		return true
+	}
+	for _, id := range sc.testIDs[p] {
+		if sc.e2eTestIDs[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// warnf records a problem that doesn't stop the scan, reported in the
+// result's warnings.
+func (sc *scan) warnf(format string, args ...interface{}) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.warnings = append(sc.warnings, fmt.Sprintf(format, args...))
+}
+
+func (sc *scan) markFrameworkRoot(p string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.frameworkRoots == nil {
+		sc.frameworkRoots = make(map[string]bool)
+	}
+	sc.frameworkRoots[p] = true
+}
+
+// ScanOptions controls how a repository is fetched and what is done with the result.
+type ScanOptions struct {
+	// Mode is "api" (default) to read through the GitHub contents API or
+	// "clone" to analyze a shallow local clone.
+	Mode string
+	// Ref is the branch, tag or commit to analyze instead of the default
+	// branch. Clone mode only accepts branches and tags.
+	Ref string
+	// Verify runs the configured verify command against the clone after
+	// deleting the unused components. Only supported in clone mode.
+	Verify bool
+	// Concurrency bounds the number of parallel GitHub requests. Zero uses
+	// RGC_CONCURRENCY, or 8 when that isn't set.
+	Concurrency int
+	// Token overrides GITHUB_TOKEN for this scan.
+	Token string
+	// CaseInsensitive resolves imports ignoring case, like on macOS and
+	// Windows file systems.
+	CaseInsensitive bool
+	// ComponentRules narrows down which files are components.
+	ComponentRules ComponentRules
+	// SkipLargeFiles leaves component files past a size out of the scan.
+	SkipLargeFiles FileSizeRules
+	// Path scopes the results to a directory of the repository, such as
+	// "packages/design-system". The whole repository is still scanned, so
+	// imports from outside the directory count.
+	Path string
+	// SparsePaths are the directories a clone checks out, e.g.
+	// "apps/web/src", along with the files at the root of the repository.
+	// The other files are never downloaded. Only supported in clone mode.
+	SparsePaths []string
+	// Submodules is "skip" (default) to only list the repository's Git
+	// submodules, or "recurse" to analyze their files along with its own.
+	Submodules string
+	// IncludeVendorDirs scans the files of directories holding third-party
+	// code, such as node_modules or vendor, which are skipped by default.
+	IncludeVendorDirs bool
+	// IncludeGenerated keeps the files code generators wrote, which are
+	// left out of the components and the import graph by default.
+	IncludeGenerated bool
+	// EntryPoints switches to reachability analysis: only components
+	// transitively imported from a file matching one of these patterns
+	// (e.g. "pages/", "src/index.tsx") are used.
+	EntryPoints []string
+	// Props extracts the prop names, types and required flags of each
+	// component from its TypeScript definition.
+	Props bool
+	// Complexity adds each component's metrics: lines, child components,
+	// import fan-in and fan-out, and JSX depth.
+	Complexity bool
+	// Duplicates clusters the components whose sources are nearly the
+	// same, found by comparing MinHash signatures of their tokens.
+	Duplicates bool
+	// PropUsage counts the props each component's JSX call sites pass, and
+	// with Props, lists the declared props none passes.
+	PropUsage bool
+	// Hygiene adds a report of deep relative imports, imports bypassing
+	// barrels and imports into another feature's internals.
+	Hygiene bool
+	// UnusedExports reports individual exports nothing imports. It reads
+	// every source file of the repository, not only components.
+	UnusedExports bool
+	// IncludeModules also reports custom hooks and utility modules as used
+	// or unused. Like UnusedExports, it reads every source file.
+	IncludeModules bool
+	// Assets reports the images and stylesheets nothing references.
+	Assets bool
+	// StyledComponents reports the styled-components and Emotion
+	// components declared in the repository as used or unused. It reads
+	// every source file.
+	StyledComponents bool
+	// StringReferences moves the unused components whose name a shipped
+	// file quotes, e.g. in a registry, to PossiblyUsed. Like
+	// UnusedExports, it reads every source file.
+	StringReferences bool
+	// Metadata adds each component's file size and last commit. It costs a
+	// request per component in api mode, and clones the history in clone
+	// mode.
+	Metadata bool
+	// ExcludeVendored leaves vendored components out of the used and unused
+	// lists and counts; they're still reported under Vendored.
+	ExcludeVendored bool
+	// CommitStatus posts a status summarizing the unused components on the
+	// analyzed commit once the analysis is recorded.
+	CommitStatus bool
+	// AllowLargeRepo scans the repository even when it's larger than
+	// Limits.MaxRepoSize.
+	AllowLargeRepo bool
+	// Since is the ID of a previous analysis of the repository. In api
+	// mode, the files that didn't change since are taken from it instead
+	// of fetched again, while it's still in memory.
+	Since string
+	// ChangedFiles are the files changed since that analysis. When nil
+	// they're found through the Compare API.
+	ChangedFiles []string
+	// PostProcessors are the specs of the post-processors the result goes
+	// through after those of RGC_POST_PROCESSORS, e.g. "redact-paths".
+	PostProcessors []string
+	// Limits tightens the server's limits for this scan.
+	Limits ScanLimits
+	// Progress, when set, is updated as the scan goes.
+	Progress *Progress
+	// Logger logs the scan, carrying the ID of the request or job that
+	// started it. Defaults to slog.Default().
+	Logger *slog.Logger
+	// Trace, when valid, is the span the scan's spans descend from.
+	Trace trace.SpanContext
+
+	// origin is recorded in the audit log with the stored analysis.
+	origin scanOrigin
+}
+
+// hash identifies the options shaping a scan's result, leaving out those
+// that don't, such as the ref, the token or the concurrency, so a stored
+// analysis is only reused for a scan asking for the same result.
+func (opts ScanOptions) hash() string {
+	shaping := opts
+	shaping.Ref, shaping.Token, shaping.Concurrency = "", "", 0
+	shaping.CommitStatus, shaping.Limits = false, ScanLimits{}
+	shaping.Progress, shaping.Logger, shaping.Trace = nil, nil, trace.SpanContext{}
+	if shaping.Mode == "" {
+		shaping.Mode = "api"
+	}
+	for _, list := range []*[]string{&shaping.SparsePaths, &shaping.EntryPoints, &shaping.ChangedFiles, &shaping.PostProcessors} {
+		if len(*list) == 0 {
+			*list = nil
+		}
+	}
+	encoded, _ := json.Marshal(shaping)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:8])
+}
+
+func refuseArchived() bool {
+	return os.Getenv("RGC_REFUSE_ARCHIVED") == "true"
+}
+
+func scanConcurrency(requested int) int {
+	n := requested
+	if n <= 0 {
+		n = defaultConcurrency
+		if v, err := strconv.Atoi(os.Getenv("RGC_CONCURRENCY")); err == nil && v > 0 {
+			n = v
+		}
+	}
+	if n > maxConcurrency {
+		n = maxConcurrency
+	}
+	return n
+}
+
+// ProcessRepository scans a repository and classifies its components,
+// logging the outcome and the duration of each phase, and tracing it.
+func ProcessRepository(username, repo string, opts ScanOptions) (*ComponentsResult, error) {
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	opts.Logger = opts.Logger.With("repo", username+"/"+repo)
+	if opts.Progress == nil {
+		opts.Progress = newProgress()
+	}
+	opts.Progress.setLogger(opts.Logger)
+
+	ctx := trace.ContextWithSpanContext(context.Background(), opts.Trace)
+	ctx, span := startSpan(ctx, "scan",
+		attribute.String("rgc.repo", username+"/"+repo),
+		attribute.String("rgc.ref", opts.Ref),
+		attribute.String("rgc.mode", opts.Mode),
+	)
+	start := time.Now()
+	result, err := processRepository(ctx, username, repo, opts)
+	duration := time.Since(start).Milliseconds()
+	if err != nil {
+		endSpan(span, err)
+		opts.Logger.Warn("scan failed", "error", err, "duration_ms", duration)
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("rgc.used", result.UsedCount), attribute.Int("rgc.unused", result.UnusedCount))
+	endSpan(span, nil)
+	opts.Progress.setPhase(phaseDone)
+	opts.Logger.Info("scan finished", "used", result.UsedCount, "unused", result.UnusedCount,
+		"warnings", len(result.Warnings), "duration_ms", duration)
+	return result, nil
+}
+
+func processRepository(ctx context.Context, username, repo string, opts ScanOptions) (*ComponentsResult, error) {
+	token := opts.Token
+	if token == "" {
+		token = os.Getenv("GITHUB_TOKEN")
+	}
+	if token == "" {
+		return nil, errTokenNotSet
+	}
+	if opts.Verify && opts.Mode != "clone" {
+		return nil, fmt.Errorf("verify is only supported in clone mode")
+	}
+	if opts.Since != "" && opts.Mode == "clone" {
+		return nil, fmt.Errorf("since is only supported in api mode")
+	}
+	if err := validateSubmodules(opts.Submodules); err != nil {
+		return nil, err
+	}
+	if err := validateSparsePaths(opts.Mode, opts.SparsePaths); err != nil {
+		return nil, err
+	}
+	pipeline, err := postProcessorPipeline(opts.PostProcessors)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Limits, err = resolveLimits(opts.Limits); err != nil {
+		return nil, err
+	}
+	if err := opts.ComponentRules.validate(); err != nil {
+		return nil, err
+	}
+	if err := opts.SkipLargeFiles.validate(); err != nil {
+		return nil, err
+	}
+
+	opts.Progress.setPhase(phaseResolving)
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(opts.Limits.Timeout))
+
+	defer cancel()
+	concurrency := scanConcurrency(opts.Concurrency)
+	client := newGitHubClient(token)
+
+	tokenInfo, err := inspectToken(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := fetchRepoMeta(ctx, client, username, repo)
+	if err != nil {
+		if errors.Is(err, errRepositoryNotFound) && tokenInfo.Classic && !tokenInfo.hasScope("repo") {
+			// Private repositories are invisible to tokens without the repo scope.
+			return nil, fmt.Errorf("%w (if it is private, grant the token the \"repo\" scope)", err)
+		}
+		return nil, err
+	}
+	if err := tokenInfo.check(meta.Private, opReadContents); err != nil {
+		return nil, err
+	}
+	var warnings []string
+	if meta.Archived {
+		if refuseArchived() {
+			return nil, fmt.Errorf("repository %s is archived", meta.FullName)
+		}
+		warnings = append(warnings, "repository is archived, its components are unlikely to change")
+	}
+	if err := checkRepoSize(meta, opts); err != nil {
+		return nil, err
+	}
+
+	if opts.Ref != "" {
+		meta.Ref = opts.Ref
+		meta.HeadSHA, err = resolveRef(ctx, client, username, repo, opts.Ref)
+	} else {
+		meta.HeadSHA, err = resolveDefaultBranch(ctx, client, username, repo, meta.DefaultBranch)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if meta.HeadSHA == "" {
+		meta.Empty = true
+		return runPostProcessors(&ComponentsResult{
+			Used:          []*ComponentNode{},
+			Unused:        []*ComponentNode{},
+			TestOnly:      []*ComponentNode{},
+			StorybookOnly: []*ComponentNode{},
+			E2EOnly:       []*ComponentNode{},
+			Meta:          meta,
+			Warnings:      append(warnings, "repository is empty, there are no commits to analyze"),
+		}, pipeline), nil
+	}
+
+	var src Source
+	var clone *cloneSource
+	var recording *recordingSource
+	var incremental *incrementalSource
+	var prev *Analysis
+	var subs interface{ Submodules() []Submodule }
+	switch opts.Mode {
+	case "", "api":
+		gh := &githubSource{client: client, owner: username, repo: repo, ref: meta.HeadSHA, concurrency: concurrency}
+		withSubmodules := newSubmoduleSource(gh, opts.Submodules == "recurse")
+		src, subs = gh, withSubmodules
+		recording = newRecordingSource(withSubmodules)
+		if opts.Since != "" {
+			var base *snapshot
+			var changed map[string]bool
+			var warning string
+			prev, base, changed, warning, err = incrementalBase(ctx, client, username, repo, opts.Since, meta.HeadSHA, opts.ChangedFiles)
+			if err != nil {
+				return nil, err
+			}
+			if warning != "" {
+				warnings = append(warnings, warning)
+			} else {
+				incremental = &incrementalSource{Source: withSubmodules, base: base, changed: changed}
+				recording = newRecordingSource(incremental)
+			}
+		}
+	case "clone":
+		branch := meta.DefaultBranch
+		if opts.Ref != "" {
+			branch = opts.Ref
+		}
+		clone, err = newCloneSource(ctx, token, username, repo, branch, opts.Metadata, opts.SparsePaths)
+		if err != nil {
+			return nil, err
+		}
+		defer clone.Close()
+		if err := clone.initSubmodules(ctx, token, opts.Submodules == "recurse"); err != nil {
+			return nil, err
+		}
+		subs, src = clone, clone
+	default:
+		return nil, fmt.Errorf("unknown mode %q", opts.Mode)
+	}
+
+	analyzed := src
+	if recording != nil {
+		analyzed = recording
+	}
+	result, err := analyzeSource(ctx, analyzed, concurrency, opts)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("scan took longer than its %s timeout: %w", time.Duration(opts.Limits.Timeout), err)
+		}
+		return nil, err
+	}
+	result.Meta = meta
+	result.Submodules = subs.Submodules()
+	result.Warnings = append(warnings, result.Warnings...)
+	if recording != nil {
+		snapshots.put(username, repo, meta.HeadSHA, recording.snapshot())
+	}
+	if incremental != nil {
+		result.Incremental = &IncrementalScan{
+			Since:   prev.ID,
+			Base:    prev.Ref,
+			Changed: len(incremental.changed),
+			Fetched: incremental.fetched,
+			Reused:  incremental.reused,
+		}
+	}
+
+	if opts.Metadata {
+		if err := addFileHistory(ctx, src, result); err != nil {
+			return nil, fmt.Errorf("error reading file history: %w", err)
+		}
+		scoreRecency(result.Unused, time.Now())
+	}
+
+	if opts.Verify {
+		opts.Progress.setPhase(phaseVerifying)
+		// The build may take much longer than the scan itself, so it gets its own deadline.
+		result.Verification, err = verifyDeletion(context.Background(), clone.dir, result.Unused)
+		if err != nil {
+			return nil, fmt.Errorf("error verifying deletion: %v", err)
+		}
+	}
+
+	return runPostProcessors(result, pipeline), nil
+}
+
+// analyzeSource scans the repository src reads and classifies its
+// components, within opts.Limits' file count and sizes. The caller fills in
+// the repository metadata and enforces the timeout.
+func analyzeSource(ctx context.Context, src Source, concurrency int, opts ScanOptions) (*ComponentsResult, error) {
+	scope, err := scopeDir(opts.Path)
+	if err != nil {
+		return nil, err
+	}
+	sc := &scan{
+		src:               &limitedSource{Source: src, limits: opts.Limits},
+		concurrency:       concurrency,
+		createdComponents: make(map[string]Component),
+		componentsByName:  make(map[string][]string),
+		modules:           make(map[string]*jsparse.File),
+		caseInsensitive:   opts.CaseInsensitive,
+		hygiene:           opts.Hygiene,
+		props:             opts.Props,
+		metadata:          opts.Metadata,
+		rules:             opts.ComponentRules,
+		sizeRules:         opts.SkipLargeFiles,
+		includeVendorDirs: opts.IncludeVendorDirs,
+		includeGenerated:  opts.IncludeGenerated,
+		duplicates:        opts.Duplicates,
+		signatures:        make(map[string]signature),
+		complexity:        opts.Complexity,
+		metrics:           make(map[string]*ComponentMetrics),
+		testImports:       make(map[string]bool),
+		storyImports:      make(map[string]bool),
+		e2eImports:        make(map[string]bool),
+		e2eTestIDs:        make(map[string]bool),
+		testIDs:           make(map[string][]string),
+		computedImports:   make(map[string]map[string]bool),
+		stringRefs:        make(map[string]map[string]bool),
+		sizes:             make(map[string]int),
+		progress:          opts.Progress,
+	}
+
+	opts.Progress.setPhase(phaseListing)
+	listCtx, span := startSpan(ctx, "list_files")
+	err = sc.processRepoContents(listCtx)
+	span.SetAttributes(attribute.Int("rgc.files", len(sc.files)), attribute.Int("rgc.components", len(sc.createdComponents)))
+	endSpan(span, err)
+	if err != nil {
+		return nil, fmt.Errorf("error processing repository: %w", err)
+	}
+
+	if err := sc.findPlainComponents(ctx); err != nil {
+		return nil, fmt.Errorf("error looking for components without JSX: %w", err)
+	}
+	if err := sc.checkComponentExports(ctx); err != nil {
+		return nil, fmt.Errorf("error checking component exports: %w", err)
+	}
+	sc.markNextRoutes()
+	if err := sc.markRouteComponents(ctx); err != nil {
+		return nil, fmt.Errorf("error reading route definitions: %w", err)
+	}
+	if err := sc.markMDXComponents(ctx); err != nil {
+		return nil, fmt.Errorf("error reading MDX documents: %w", err)
+	}
+
+	treeCtx, span := startSpan(ctx, "build_tree")
+	err = sc.buildComponentTree(treeCtx)
+	endSpan(span, err)
+	if err != nil {
+		return nil, fmt.Errorf("error building component tree: %w", err)
+	}
+
+	opts.Progress.setPhase(phaseAnalyzing)
+	ctx, span = startSpan(ctx, "analyze")
+	defer span.End()
+	err = sc.findSupportImports(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error reading test and story files: %w", err)
+	}
+
+	result := &ComponentsResult{
+		Used:          []*ComponentNode{},
+		Unused:        []*ComponentNode{},
+		TestOnly:      []*ComponentNode{},
+		StorybookOnly: []*ComponentNode{},
+		E2EOnly:       []*ComponentNode{},
+		Warnings:      sc.warnings,
+	}
+
+	g := componentGraph(sc.rootComponents)
+	var reachable map[string]bool
+	if len(opts.EntryPoints) > 0 {
+		roots, unmatched, err := sc.entryRoots(ctx, opts.EntryPoints)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving entry points: %w", err)
+		}
+		for _, pattern := range unmatched {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("entry point %q matches no file", pattern))
+		}
+		for name := range sc.frameworkRoots {
+			roots = append(roots, name)
+		}
+		reachable = g.Reachable(roots...)
+	} else {
+		reachable = sc.liveComponents(g)
+	}
+	if len(sc.ignored) > 0 {
+		// What ignored components import is kept alive with them.
+		var ignored []string
+		for p := range sc.ignored {
+			ignored = append(ignored, p)
+		}
+		for p := range g.Reachable(ignored...) {
+			if _, ok := sc.ignored[p]; !ok {
+				reachable[p] = true
+			}
+		}
+	}
+
+	for _, node := range sc.rootComponents {
+		p := node.Component.Path
+		// Dead components importing each other are still dead.
+		used := reachable[p]
+		appImported := used
+		if len(opts.EntryPoints) == 0 {
+			// The components taken for roots aren't imported by the app.
+			appImported = used && (g.InDegree(p) > 0 || sc.frameworkRoots[p])
+		}
+		storybookOnly := !appImported && sc.storyImports[p]
+		e2eOnly := !appImported && !storybookOnly && sc.usedByE2E(p)
+		testOnly := !appImported && !storybookOnly && !e2eOnly && sc.testImports[p]
+		if reason, ok := sc.vendored[p]; ok {
+			result.Vendored = append(result.Vendored, VendoredComponent{
+				Name:   node.Component.Name,
+				Path:   p,
+				Used:   used,
+				Reason: reason,
+			})
+			if opts.ExcludeVendored {
+				continue
+			}
+		}
+		reason, ignored := sc.ignored[p]
+		switch {
+		case storybookOnly:
+			result.StorybookOnly = append(result.StorybookOnly, node)
+		case e2eOnly:
+			result.E2EOnly = append(result.E2EOnly, node)
+		case testOnly:
+			result.TestOnly = append(result.TestOnly, node)
+		case used:
+			result.Used = append(result.Used, node)
+		case ignored:
+			result.Ignored = append(result.Ignored, IgnoredComponent{
+				Name:   node.Component.Name,
+				Path:   p,
+				Reason: reason,
+			})
+		default:
+			result.Unused = append(result.Unused, node)
+		}
+	}
+
+	if len(sc.skipped) > 0 {
+		sort.Slice(sc.skipped, func(i, j int) bool { return sc.skipped[i].Path < sc.skipped[j].Path })
+		result.SkippedFiles = sc.skipped
+		result.Warnings = append(result.Warnings, fmt.Sprintf(
+			"%d component files were too large to parse and skipped, the components only they import may be reported unused", len(sc.skipped)))
+	}
+	result.Unused = deletionOrder(g, result.Unused)
+	if opts.StringReferences {
+		if err := sc.readStringReferences(ctx); err != nil {
+			return nil, fmt.Errorf("error looking for string references: %w", err)
+		}
+	}
+	// Only shipped files count as quoting a name.
+	dead := make(map[string]bool)
+	for _, nodes := range [][]*ComponentNode{result.Unused, result.TestOnly, result.StorybookOnly, result.E2EOnly} {
+		for _, node := range nodes {
+			dead[node.Component.Path] = true
+		}
+	}
+	sc.scoreDeletions(result.Unused, dead)
+	if opts.StringReferences {
+		result.Unused, result.PossiblyUsed = splitPossiblyUsed(result.Unused)
+	}
+	result.OrphanedSubtrees = sc.orphanedSubtrees(g, result.Unused)
+	result.Cycles = g.Cycles()
+	result.NameCollisions = sc.nameCollisions()
+	if opts.Duplicates {
+		result.Duplicates = sc.duplicateClusters()
+	}
+	result.Shadcn = sc.shadcnReports(g, reachable)
+	if opts.Hygiene {
+		result.Hygiene = sc.hygieneReport()
+	}
+	if opts.UnusedExports || opts.IncludeModules {
+		usage, err := sc.collectExports(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error collecting exports: %w", err)
+		}
+		if opts.UnusedExports {
+			result.UnusedExports = sc.unusedExports(usage)
+		}
+		if opts.IncludeModules {
+			result.Modules = sc.modulesReport(usage, opts.EntryPoints)
+		}
+	}
+
+	if opts.Assets {
+		result.Assets, err = sc.assetsReport(ctx, result.Unused)
+		if err != nil {
+			return nil, fmt.Errorf("error checking assets: %w", err)
+		}
+	}
+	if opts.StyledComponents {
+		result.StyledComponents, err = sc.styledReport(ctx, reachable)
+		if err != nil {
+			return nil, fmt.Errorf("error looking for styled components: %w", err)
+		}
+	}
+	if err := sc.estimateSavings(ctx, g, result.Unused); err != nil {
+		return nil, fmt.Errorf("error estimating savings: %w", err)
+	}
+	if opts.Complexity {
+		sc.setMetrics(g, result.Used, result.Unused, result.TestOnly, result.StorybookOnly, result.E2EOnly, result.PossiblyUsed)
+	}
+	if opts.PropUsage {
+		usage, err := sc.propUsage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error counting prop usage: %w", err)
+		}
+		for _, nodes := range [][]*ComponentNode{result.Used, result.Unused, result.TestOnly, result.StorybookOnly, result.E2EOnly, result.PossiblyUsed} {
+			for _, node := range nodes {
+				node.PropUsage = usage[node.Component.Path].usageFor(node.Props)
+			}
+		}
+	}
+
+	// Known once every file that was going to be read has been.
+	result.Generated = sc.generatedFiles()
+	result.GeneratedCount = len(result.Generated)
+	if scope != "" {
+		if !slices.ContainsFunc(sc.files, func(p string) bool { return inScope(scope, p) }) {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("path %q matches no file", opts.Path))
+		}
+		scopeResult(result, scope)
+	}
+
+	result.UsedCount = len(result.Used)
+	result.UnusedCount = len(result.Unused)
+	result.TestOnlyCount = len(result.TestOnly)
+	result.StorybookOnlyCount = len(result.StorybookOnly)
+	result.E2EOnlyCount = len(result.E2EOnly)
+	result.PossiblyUsedCount = len(result.PossiblyUsed)
+	result.EstimatedSavings = sc.totalSavings(result)
+
+	return result, nil
+}
+
+// componentGraph builds the import graph of component paths, with an edge
+// from each component to every component it imports.
+func componentGraph(nodes []*ComponentNode) *graph.Graph {
+	g := graph.New()
+	for _, node := range nodes {
+		g.AddNode(node.Component.Path)
+		for _, child := range node.Children {
+			g.AddEdge(node.Component.Path, child.Component.Path)
+		}
+	}
+	return g
+}
+
+// deletionOrder sorts nodes so that importers come before the components they
+// import, letting them be deleted one by one without breaking the build in
+// between. Members of an import cycle are kept next to each other.
+func deletionOrder(g *graph.Graph, nodes []*ComponentNode) []*ComponentNode {
+	byPath := make(map[string]*ComponentNode, len(nodes))
+	for _, node := range nodes {
+		byPath[node.Component.Path] = node
+	}
+
+	sub := g.Subgraph(func(id string) bool { return byPath[id] != nil })
+	order, err := sub.TopoSort()
+	if err != nil {
+		order = sub.CondensedOrder()
+	}
+
+	sorted := make([]*ComponentNode, 0, len(nodes))
+	for _, p := range order {
+		sorted = append(sorted, byPath[p])
+	}
+	return sorted
+}
+
+func (sc *scan) processRepoContents(ctx context.Context) error {
+	files, err := sc.src.ListFiles(ctx)
+	if err != nil {
+		return err
+	}
+	if src, ok := sc.src.(interface{ Warnings() []string }); ok {
+		for _, w := range src.Warnings() {
+			sc.warnf("%s", w)
+		}
+	}
+
+	if !sc.includeVendorDirs {
+		files = slices.DeleteFunc(files, inVendorDir)
+	}
+	sc.files = files
+	sc.fileIndex = make(map[string]string, len(files))
+	for _, path := range files {
+		sc.fileIndex[sc.nameKey(path)] = path
+		sc.processFile(path)
+	}
+	if err := sc.loadCodeowners(ctx); err != nil {
+		return err
+	}
+	sc.shadcnRoots = sc.findShadcnRoots()
+	if err := sc.loadShadcnConfigs(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (sc *scan) processFile(path string) {
+	sc.mu.Lock()
+
+	defer sc.mu.Unlock()
+	if sc.isComponent(path) {
+		sc.addComponent(path)
+	}
+}
+
+// addComponent records the component at p. The caller holds sc.mu.
+func (sc *scan) addComponent(p string) {
+	name := normalizeName(extractComponentName(p))
+	sc.createdComponents[p] = Component{Name: name, Path: p}
+	key := sc.nameKey(name)
+	sc.componentsByName[key] = append(sc.componentsByName[key], p)
+}
+
+// removeComponent forgets the component at p.
+func (sc *scan) removeComponent(p string) {
+	component, ok := sc.createdComponents[p]
+	if !ok {
+		return
+	}
+	delete(sc.createdComponents, p)
+	key := sc.nameKey(component.Name)
+	sc.componentsByName[key] = slices.DeleteFunc(sc.componentsByName[key], func(q string) bool { return q == p })
+	if len(sc.componentsByName[key]) == 0 {
+		delete(sc.componentsByName, key)
+	}
+}
+
+// childPath returns the path of the component importer imports as child:
+// the file the import resolves to, or, when it doesn't resolve, the only
+// component with the child's name. It returns "" when the import isn't of
+// a component or the name is ambiguous.
+func (sc *scan) childPath(importer string, child childImport) string {
+	p := child.Path
+	if p == "" && child.Edge.Specifier != "" {
+		p = sc.resolveModule(importer, child.Edge.Specifier)
+	}
+	if p == "" {
+		if paths := sc.componentsByName[sc.nameKey(child.Name)]; len(paths) == 1 {
+			p = paths[0]
+		}
+	}
+	if _, ok := sc.createdComponents[p]; !ok {
+		return ""
+	}
+	return p
+}
+
+func isComponent(path string) bool {
+	if isSupportFile(path) {
+		return false
+	}
+	switch ext := filepath.Ext(path); {
+	case ext == ".tsx" || ext == ".jsx":
+		return analyzerEnabled("react")
+	case ext == ".svelte":
+		return analyzerEnabled("svelte")
+	case isAngularComponent(path):
+		return analyzerEnabled("angular")
+	}
+	return false
+}
+
+func extractComponentName(path string) string {
+	parts := strings.Split(path, "/")
+	fileName := parts[len(parts)-1]
+	return strings.TrimSuffix(fileName, filepath.Ext(fileName))
+}
+
+// buildComponentTree fetches every component's source in parallel, bounded by
+// the scan's concurrency, and links it to the components it imports.
+func (sc *scan) buildComponentTree(ctx context.Context) error {
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(sc.concurrency)
+
+	sc.progress.startParsing(len(sc.createdComponents))
+	for _, component := range sc.createdComponents {
+		if isAngularComponent(component.Path) {
+			continue // Linked through their templates below
+		}
+		component := component
+		eg.Go(func() (err error) {
+			defer sc.progress.fileParsed()
+			ctx, span := startSpan(ctx, "component", attribute.String("rgc.path", component.Path))
+			defer func() { endSpan(span, err) }()
+			node := &ComponentNode{Component: component, Owners: sc.codeowners.owners(component.Path)}
+			fileContent, err := sc.readComponent(ctx, component.Path)
+			if err != nil {
+				if err == errFileNotFound {
+					// Skip this file if it's not found
+					return nil
+				}
+				return err
+			}
+			if limit := sc.sizeRules.limit(component.Path); limit >= 0 && int64(len(fileContent)) > limit {
+				sc.markSkipped(component.Path, int64(len(fileContent)), limit)
+				return nil
+			}
+			if sc.checkGenerated(component.Path, fileContent) {
+				return nil
+			}
+			sc.mu.Lock()
+			sc.sizes[component.Path] = len(fileContent)
+			sc.mu.Unlock()
+			if sc.duplicates {
+				if sig, ok := sourceSignature(fileContent); ok {
+					sc.mu.Lock()
+					sc.signatures[component.Path] = sig
+					sc.mu.Unlock()
+				}
+			}
+			if sc.complexity {
+				if err := sc.measure(ctx, component.Path, fileContent); err != nil {
+					return err
+				}
+			}
+			if sc.metadata {
+				node.Size = len(fileContent)
+			}
+			if reason := vendoredReason(component.Path, fileContent, sc.shadcnRoots); reason != "" {
+				sc.markVendored(component.Path, reason)
+			}
+			if reason, ok := ignoreReason(fileContent); ok {
+				sc.markIgnored(component.Path, reason)
+			}
+
+			childComponents, err := sc.findChildren(ctx, component.Path, fileContent)
+			if err != nil {
+				return err
+			}
+			if ids := componentTestIDs(fileContent); len(ids) > 0 {
+				sc.mu.Lock()
+				sc.testIDs[component.Path] = ids
+				sc.mu.Unlock()
+			}
+			if sc.props && jsparse.Supported(component.Path) {
+				node.Props, err = jsparse.Props(ctx, component.Path, []byte(fileContent), component.Name)
+				if err != nil {
+					return fmt.Errorf("error extracting props of %s: %w", component.Path, err)
+				}
+			}
+			for _, child := range childComponents {
+				if childComponent, ok := sc.createdComponents[sc.childPath(component.Path, child)]; ok {
+					edge := child.Edge
+					childNode := &ComponentNode{Component: childComponent, Parent: node, Import: &edge}
+					node.Children = append(node.Children, childNode)
+				}
+			}
+
+			if node.Parent == nil {
+				sc.mu.Lock()
+				sc.rootComponents = append(sc.rootComponents, node)
+				sc.mu.Unlock()
+			}
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+	sc.dropGenerated()
+
+	if err := sc.linkAngularComponents(ctx, sc.files); err != nil {
+		return err
+	}
+
+	// Workers finish in any order, keep the output stable.
+	sort.Slice(sc.rootComponents, func(i, j int) bool {
+		return sc.rootComponents[i].Component.Path < sc.rootComponents[j].Component.Path
+	})
+	return nil
+}
+
+// findChildren returns the components the file at path imports, using the
+// analyzer for its language.
+func (sc *scan) findChildren(ctx context.Context, path, content string) ([]childImport, error) {
+	if filepath.Ext(path) == ".svelte" {
+		return findSvelteChildComponents(ctx, content)
+	}
+	return sc.findChildComponents(ctx, path, content)
+}
+
+// findChildComponents parses a JavaScript or TypeScript module and returns
+// the components it imports, re-exports, requires or loads lazily through a
+// dynamic import (React.lazy, next/dynamic and the like), each with the kind
+// and specifier of the import that brings it in. Imports of barrel files
+// are followed through their re-exports to the components they expose.
+// Type-only imports are erased by the compiler, so they don't count as usage.
+func (sc *scan) findChildComponents(ctx context.Context, path, content string) ([]childImport, error) {
+	file, err := parseFile(ctx, path, content)
+	if err != nil {
+		return nil, &ParseError{Path: path, Err: err}
+	}
+	if sc.hygiene {
+		sc.checkImportHygiene(ctx, path, file)
+	}
+	sc.recordReferences(path, content, file)
+
+	var childComponents []childImport
+	for _, imp := range file.Imports {
+		if imp.Kind == jsparse.SideEffect {
+			continue
+		}
+		if !imp.IsRuntime() {
+			continue // Skip type-only imports
+		}
+
+		target := sc.resolveModule(path, imp.Specifier)
+		relative := strings.HasPrefix(imp.Specifier, ".")
+		if target == "" && !relative {
+			continue // Packages and unknown aliases
+		}
+		edge := ImportEdge{Kind: edgeKind(imp), Specifier: imp.Specifier, Line: imp.Line}
+		if target != "" && !sc.isComponent(target) && jsparse.Supported(target) {
+			barrelComponents, err := sc.followReExports(ctx, target, importedNames(imp), make(map[string]bool))
+			if err != nil {
+				return nil, err
+			}
+			edge.Via = target
+			for _, p := range barrelComponents {
+				childComponents = append(childComponents, childImport{Name: normalizeName(extractComponentName(p)), Path: p, Edge: edge})
+			}
+			continue
+		}
+		childComponents = append(childComponents, childImport{Name: componentNameFromSpecifier(imp.Specifier), Path: target, Edge: edge})
+	}
+	return childComponents, nil
+}
+
+// componentNameFromSpecifier derives the component name from an import
+// specifier, e.g. "./components/Button.tsx" -> "Button".
+func componentNameFromSpecifier(specifier string) string {
+	childName := filepath.Base(specifier)
+	return strings.TrimSuffix(childName, filepath.Ext(childName))
+}
@@ -0,0 +1,109 @@
+package rgc
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/igorfelipeduca/rgc/internal/jsparse"
+	"golang.org/x/sync/errgroup"
+)
+
+// isRouterCandidate reports whether the non-component module at p may hold a
+// React Router configuration: routes.ts, router.js, app.routing.ts and the
+// like, or an entry file such as src/main.ts. Only those are fetched, since
+// reading every module of a repository would be too expensive.
+func isRouterCandidate(p string) bool {
+	if isComponent(p) || !jsparse.Supported(p) {
+		return false
+	}
+	base := strings.ToLower(strings.TrimSuffix(path.Base(p), path.Ext(p)))
+	if strings.Contains(base, "rout") {
+		return true
+	}
+	return (base == "main" || base == "index") && strings.Count(p, "/") <= 1
+}
+
+func importsReactRouter(file *jsparse.File) bool {
+	for _, imp := range file.Imports {
+		if imp.Specifier == "react-router" || imp.Specifier == "react-router-dom" || strings.HasPrefix(imp.Specifier, "@react-router/") {
+			return true
+		}
+	}
+	return false
+}
+
+// markRouteComponents marks the components mounted by React Router route
+// definitions in non-component modules as framework roots, along with the
+// components their routes load lazily. Route definitions inside components
+// already import what they mount, so they need no special handling.
+func (sc *scan) markRouteComponents(ctx context.Context) error {
+	if !analyzerEnabled("react") {
+		return nil
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(sc.concurrency)
+	for _, p := range sc.files {
+		if !isRouterCandidate(p) {
+			continue
+		}
+		p := p
+		eg.Go(func() error {
+			file, err := sc.parseModule(ctx, p)
+			if err != nil {
+				return err
+			}
+			if !importsReactRouter(file) {
+				return nil
+			}
+			content, err := sc.src.ReadFile(ctx, p)
+			if err != nil {
+				return err
+			}
+			locals, err := jsparse.RouteComponents(ctx, p, []byte(content))
+			if err != nil {
+				return fmt.Errorf("error parsing routes of %s: %w", p, err)
+			}
+
+			for _, imp := range file.Imports {
+				if !imp.IsRuntime() {
+					continue
+				}
+				target := sc.resolveModule(p, imp.Specifier)
+				if target == "" {
+					continue
+				}
+				var wanted []string
+				if imp.Kind == jsparse.Dynamic {
+					// lazy: () => import('./routes/Home')
+					wanted = []string{"*"}
+				} else {
+					for _, b := range imp.ValueBindings() {
+						if contains(locals, b.Local) {
+							wanted = append(wanted, b.Imported)
+						}
+					}
+				}
+				if len(wanted) == 0 {
+					continue
+				}
+
+				if sc.isComponent(target) {
+					sc.markFrameworkRoot(target)
+					continue
+				}
+				components, err := sc.followReExports(ctx, target, wanted, make(map[string]bool))
+				if err != nil {
+					return err
+				}
+				for _, component := range components {
+					sc.markFrameworkRoot(component)
+				}
+			}
+			return nil
+		})
+	}
+	return eg.Wait()
+}
@@ -0,0 +1,226 @@
+package rgc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Sandbox runs a shell command taken from, or operating on, an untrusted
+// repository checkout. Implementations must cut network access and bound CPU,
+// memory and wall time.
+type Sandbox interface {
+	Run(ctx context.Context, dir, command string, output io.Writer) error
+}
+
+const (
+	defaultSandboxImage    = "node:20-alpine"
+	defaultSandboxCPUs     = "1"
+	defaultSandboxMemoryMB = 1024
+	defaultSandboxPids     = 512
+)
+
+// sandboxConfig is the sandbox runner configuration read from the environment.
+type sandboxConfig struct {
+	Kind     string `json:"kind"`
+	Runtime  string `json:"runtime,omitempty"`
+	Image    string `json:"image,omitempty"`
+	CPUs     string `json:"cpus"`
+	MemoryMB int    `json:"memory_mb"`
+}
+
+func loadSandboxConfig() (sandboxConfig, error) {
+	cfg := sandboxConfig{
+		Kind:     envOr("RGC_SANDBOX", "docker"),
+		CPUs:     envOr("RGC_SANDBOX_CPUS", defaultSandboxCPUs),
+		MemoryMB: defaultSandboxMemoryMB,
+	}
+	if v := os.Getenv("RGC_SANDBOX_MEMORY_MB"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("invalid RGC_SANDBOX_MEMORY_MB %q", v)
+		}
+		cfg.MemoryMB = n
+	}
+	if cfg.Kind == "docker" || cfg.Kind == "podman" {
+		cfg.Runtime = envOr("RGC_SANDBOX_RUNTIME", cfg.Kind)
+		cfg.Image = envOr("RGC_SANDBOX_IMAGE", defaultSandboxImage)
+	}
+	return cfg, nil
+}
+
+// newSandbox picks the sandbox runner from RGC_SANDBOX. Running commands
+// directly on the host has to be requested explicitly with "none". Only
+// installing dependencies gets network access.
+func newSandbox(network bool) (Sandbox, error) {
+	cfg, err := loadSandboxConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Kind {
+	case "docker", "podman":
+		return &containerSandbox{
+			runtime:  cfg.Runtime,
+			image:    cfg.Image,
+			cpus:     cfg.CPUs,
+			memoryMB: cfg.MemoryMB,
+			network:  network,
+		}, nil
+	case "nsjail":
+		return &nsjailSandbox{cpus: cfg.CPUs, memoryMB: cfg.MemoryMB, network: network}, nil
+	case "none":
+		return hostSandbox{}, nil
+	default:
+		return nil, fmt.Errorf("unknown sandbox %q", cfg.Kind)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// containerSandbox runs the command in a throwaway docker or podman container
+// with the checkout mounted as its working directory.
+type containerSandbox struct {
+	runtime  string
+	image    string
+	cpus     string
+	memoryMB int
+	network  bool
+}
+
+func (s *containerSandbox) Run(ctx context.Context, dir, command string, output io.Writer) error {
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := "rgc-sandbox-" + hex.EncodeToString(suffix)
+
+	networkMode := "none"
+	if s.network {
+		networkMode = "bridge"
+	}
+	args := []string{
+		"run", "--rm", "--name", name,
+		"--network", networkMode,
+		"--cpus", s.cpus,
+		"--memory", fmt.Sprintf("%dm", s.memoryMB),
+		"--memory-swap", fmt.Sprintf("%dm", s.memoryMB),
+		"--pids-limit", strconv.Itoa(defaultSandboxPids),
+		"--cap-drop", "ALL",
+		"--security-opt", "no-new-privileges",
+		"--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()),
+		"-e", "HOME=/tmp",
+		"-v", dir + ":/workspace",
+		"-w", "/workspace",
+		s.image,
+		"sh", "-c", command,
+	}
+
+	cmd := exec.CommandContext(ctx, s.runtime, args...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err := cmd.Run()
+
+	if ctx.Err() != nil {
+		// Killing the CLI doesn't stop the container, so do it explicitly.
+		exec.Command(s.runtime, "kill", name).Run()
+	}
+	return err
+}
+
+// nsjailToolchainDirs are the host directories mounted read-only in the
+// nsjail sandbox, those a shell and a Node.js toolchain installed by the
+// system need. RGC_SANDBOX_NSJAIL_MOUNTS adds more, separated by colons.
+var nsjailToolchainDirs = []string{
+	"/bin", "/sbin", "/usr", "/lib", "/lib32", "/lib64",
+	"/etc/alternatives", "/etc/ssl", "/etc/ld.so.cache", "/etc/passwd", "/etc/group",
+}
+
+// nsjailSandbox runs the command under nsjail in an empty root holding the
+// toolchain directories read-only, the checkout read-write, a private /tmp
+// and a fresh, empty network namespace, unless it needs network. The rest
+// of the host, secrets included, stays out of sight.
+type nsjailSandbox struct {
+	cpus     string
+	memoryMB int
+	network  bool
+}
+
+func (s *nsjailSandbox) Run(ctx context.Context, dir, command string, output io.Writer) error {
+	args := []string{
+		"--mode", "o",
+		"--quiet",
+	}
+	mounts := nsjailToolchainDirs
+	if extra := os.Getenv("RGC_SANDBOX_NSJAIL_MOUNTS"); extra != "" {
+		mounts = append(slices.Clip(mounts), filepath.SplitList(extra)...)
+	}
+	for _, m := range mounts {
+		if _, err := os.Stat(m); err == nil {
+			args = append(args, "--bindmount_ro", m)
+		}
+	}
+	args = append(args,
+		"--bindmount", "/dev/null",
+		"--tmpfsmount", "/tmp",
+		"--bindmount", dir,
+		"--cwd", dir,
+		"--env", "HOME=/tmp",
+		"--env", "PATH="+os.Getenv("PATH"),
+		"--rlimit_as", strconv.Itoa(s.memoryMB),
+		"--rlimit_nproc", strconv.Itoa(defaultSandboxPids),
+		"--max_cpus", s.cpus,
+	)
+	if s.network {
+		// Share the host's network namespace, and its resolver to go with it.
+		args = append(args, "--disable_clone_newnet")
+		if _, err := os.Stat("/etc/resolv.conf"); err == nil {
+			args = append(args, "--bindmount_ro", "/etc/resolv.conf")
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		args = append(args, "--time_limit", strconv.Itoa(int(time.Until(deadline).Seconds())+1))
+	}
+	args = append(args, "--", "/bin/sh", "-c", command)
+
+	cmd := exec.CommandContext(ctx, "nsjail", args...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	return cmd.Run()
+}
+
+// hostSandboxEnv are the variables of the server's environment the host
+// sandbox passes on. The rest, GITHUB_TOKEN and the other secrets
+// included, stays out of the command's reach.
+var hostSandboxEnv = []string{"PATH", "HOME"}
+
+// hostSandbox runs the command directly on the host. Only meant for trusted
+// repositories and local development.
+type hostSandbox struct{}
+
+func (hostSandbox) Run(ctx context.Context, dir, command string, output io.Writer) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = dir
+	cmd.Env = []string{}
+	for _, key := range hostSandboxEnv {
+		if v, ok := os.LookupEnv(key); ok {
+			cmd.Env = append(cmd.Env, key+"="+v)
+		}
+	}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	return cmd.Run()
+}
@@ -0,0 +1,185 @@
+package rgc
+
+import (
+	"context"
+	"sort"
+
+	"github.com/igorfelipeduca/rgc/internal/graph"
+	"golang.org/x/sync/errgroup"
+)
+
+// Savings estimates what deleting an unused component saves. Sizes are
+// source bytes, not what a bundler would emit after minification and
+// tree shaking, but they rank deletions all the same.
+type Savings struct {
+	// Bytes is the combined size of the component's file, of the unused
+	// components only it leads to and of the assets only they reference.
+	Bytes int `json:"bytes"`
+	// Components and Assets list what goes away along with the component.
+	Components []string `json:"components,omitempty"`
+	Assets     []string `json:"assets,omitempty"`
+}
+
+// estimateSavings sets the savings of each unused component: its own file,
+// the unused components nothing but it and them import, and, when assets
+// were checked, the images and stylesheets only those files reference.
+func (sc *scan) estimateSavings(ctx context.Context, g *graph.Graph, unused []*ComponentNode) error {
+	dead := make(map[string]bool, len(unused))
+	for _, node := range unused {
+		dead[node.Component.Path] = true
+	}
+	referenced := make(map[string][]string)
+	for asset, by := range sc.assetReferrers {
+		for _, r := range by {
+			referenced[r] = append(referenced[r], asset)
+		}
+	}
+	if err := sc.readAssetSizes(ctx, dead); err != nil {
+		return err
+	}
+
+	for _, node := range unused {
+		p := node.Component.Path
+		gone := exclusiveDescendants(g, p, dead)
+		freed := sc.exclusiveAssets(gone, referenced)
+		savings := &Savings{Bytes: sc.sizes[p]}
+		for c := range gone {
+			if c != p {
+				savings.Components = append(savings.Components, c)
+				savings.Bytes += sc.sizes[c]
+			}
+		}
+		for _, asset := range freed {
+			savings.Assets = append(savings.Assets, asset)
+			savings.Bytes += sc.sizes[asset]
+		}
+		sort.Strings(savings.Components)
+		sort.Strings(savings.Assets)
+		node.Savings = savings
+	}
+	return nil
+}
+
+// exclusiveDescendants returns p and the unused components reachable from
+// it that nothing outside of them imports, cycles among them included.
+func exclusiveDescendants(g *graph.Graph, p string, dead map[string]bool) map[string]bool {
+	gone := map[string]bool{p: true}
+	queue := []string{p}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		for _, s := range g.Successors(next) {
+			if dead[s] && !gone[s] {
+				gone[s] = true
+				queue = append(queue, s)
+			}
+		}
+	}
+	// Drop the candidates imported from elsewhere, until none is left.
+	for changed := true; changed; {
+		changed = false
+		for c := range gone {
+			if c == p {
+				continue
+			}
+			for _, from := range g.Predecessors(c) {
+				if !gone[from] {
+					delete(gone, c)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return gone
+}
+
+// exclusiveAssets returns the assets only the files gone and the
+// stylesheets going with them reference. Tests and stories don't keep an
+// asset.
+func (sc *scan) exclusiveAssets(gone map[string]bool, referenced map[string][]string) []string {
+	candidates := make(map[string]bool)
+	queue := make([]string, 0, len(gone))
+	for p := range gone {
+		queue = append(queue, p)
+	}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		for _, asset := range referenced[next] {
+			if !candidates[asset] {
+				candidates[asset] = true
+				queue = append(queue, asset)
+			}
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for asset := range candidates {
+			for _, r := range sc.assetReferrers[asset] {
+				if !gone[r] && !candidates[r] && !isSupportFile(r) {
+					delete(candidates, asset)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	assets := make([]string, 0, len(candidates))
+	for asset := range candidates {
+		assets = append(assets, asset)
+	}
+	return assets
+}
+
+// readAssetSizes records the size of the assets the savings may count:
+// those only dead components, other assets, tests and stories reference.
+func (sc *scan) readAssetSizes(ctx context.Context, dead map[string]bool) error {
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(sc.concurrency)
+	for asset, by := range sc.assetReferrers {
+		if !onlyReferencedBy(by, dead) {
+			continue
+		}
+		eg.Go(func() error {
+			content, err := sc.src.ReadFile(ctx, asset)
+			if err != nil {
+				if err == errFileNotFound {
+					return nil
+				}
+				return err
+			}
+			sc.mu.Lock()
+			sc.sizes[asset] = len(content)
+			sc.mu.Unlock()
+			return nil
+		})
+	}
+	return eg.Wait()
+}
+
+// onlyReferencedBy reports whether every referrer is a dead file, a test or
+// story, or another asset.
+func onlyReferencedBy(by []string, dead map[string]bool) bool {
+	for _, r := range by {
+		if !dead[r] && assetKind(r) == "" && !isSupportFile(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// totalSavings estimates the bytes deleting every unused component saves,
+// with the assets only they reference.
+func (sc *scan) totalSavings(result *ComponentsResult) int {
+	total := 0
+	for _, node := range result.Unused {
+		total += sc.sizes[node.Component.Path]
+	}
+	if result.Assets != nil {
+		for _, a := range result.Assets.OnlyUsedByUnused {
+			total += sc.sizes[a.Path]
+		}
+	}
+	return total
+}
@@ -0,0 +1,304 @@
+package rgc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/robfig/cron/v3"
+)
+
+// Schedule rescans a repository on a cron schedule, so its history and
+// trends fill up without anyone triggering scans.
+type Schedule struct {
+	Owner string `json:"owner"`
+	Repo  string `json:"repo"`
+	// Cron is a standard five field cron expression, or a descriptor such
+	// as "@daily" or "@every 6h", evaluated in UTC.
+	Cron string `json:"cron"`
+	// Scan holds the scan options. Scheduled scans use the server's
+	// GITHUB_TOKEN, or the token stored by Tenant; scan.token is never
+	// stored.
+	Scan RequestPayload `json:"scan"`
+	// Tenant names the tenant whose vault token scans the repository. It
+	// must have registered it.
+	Tenant string `json:"tenant,omitempty"`
+	// Notify lists the chat webhooks told about every scan.
+	Notify []Notification `json:"notify,omitempty"`
+	// Issues files GitHub issues about the unused components found.
+	Issues *IssueFiling `json:"issues,omitempty"`
+	// Jira creates Jira tickets for the components that became unused.
+	Jira      *JiraFiling `json:"jira,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+
+	LastRunAt      *time.Time `json:"last_run_at,omitempty"`
+	LastAnalysisID string     `json:"last_analysis_id,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	NextRunAt      *time.Time `json:"next_run_at,omitempty"`
+}
+
+// scheduler runs the scheduled scans of this instance.
+type scheduler struct {
+	cron *cron.Cron
+
+	mu      sync.Mutex
+	entries map[string]cron.EntryID
+}
+
+var schedules = &scheduler{
+	cron: cron.New(
+		cron.WithLocation(time.UTC),
+		cron.WithChain(cron.SkipIfStillRunning(cron.DefaultLogger)),
+	),
+	entries: make(map[string]cron.EntryID),
+}
+
+// schedulerEnabled reports whether this instance runs the scheduled scans.
+// Set RGC_SCHEDULER=false on all instances sharing a database but one.
+func schedulerEnabled() bool {
+	return os.Getenv("RGC_SCHEDULER") != "false"
+}
+
+// start registers the stored schedules and the digests, and starts running
+// them.
+func (s *scheduler) start(ctx context.Context) error {
+	stored, err := analyses.storage.Schedules(ctx)
+	if err != nil {
+		return err
+	}
+	for _, sched := range stored {
+		if err := s.register(sched); err != nil {
+			slog.Warn("skipping schedule", "repo", sched.Owner+"/"+sched.Repo, "error", err)
+		}
+	}
+	if err := s.scheduleDigests(); err != nil {
+		return err
+	}
+	s.cron.Start()
+	return nil
+}
+
+// register (re)places the cron entry of a schedule.
+func (s *scheduler) register(sched *Schedule) error {
+	spec, err := cron.ParseStandard(sched.Cron)
+	if err != nil {
+		return fmt.Errorf("invalid cron expression %q: %v", sched.Cron, err)
+	}
+	owner, repo := sched.Owner, sched.Repo
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := repoKey(owner, repo)
+	if id, ok := s.entries[key]; ok {
+		s.cron.Remove(id)
+	}
+	s.entries[key] = s.cron.Schedule(spec, cron.FuncJob(func() { s.run(owner, repo) }))
+	return nil
+}
+
+func (s *scheduler) unregister(owner, repo string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := repoKey(owner, repo)
+	if id, ok := s.entries[key]; ok {
+		s.cron.Remove(id)
+		delete(s.entries, key)
+	}
+}
+
+// nextRun returns when the schedule of a repository runs next.
+func (s *scheduler) nextRun(owner, repo string) *time.Time {
+	s.mu.Lock()
+	id, ok := s.entries[repoKey(owner, repo)]
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	next := s.cron.Entry(id).Next
+	if next.IsZero() {
+		return nil
+	}
+	return &next
+}
+
+// run scans a scheduled repository and records the outcome on its schedule.
+func (s *scheduler) run(owner, repo string) {
+	ctx := context.Background()
+	sched, err := findSchedule(ctx, owner, repo)
+	if err != nil || sched == nil {
+		return // Deleted in the meantime
+	}
+
+	payload := sched.Scan
+	payload.Token = ""
+	if sched.Tenant != "" {
+		payload.Token, err = repoToken(ctx, sched.Tenant, owner, repo)
+	}
+	opts := payload.scanOptions()
+	opts.Logger = slog.Default().With("schedule", owner+"/"+repo)
+	opts.origin = scanOrigin{actor: "schedule", tenant: sched.Tenant}
+	if sched.Tenant != "" {
+		opts.origin.tokenSource = "tenant"
+	}
+	var result *ComponentsResult
+	if err == nil {
+		result, err = ProcessRepository(owner, repo, opts)
+	}
+	var analysis *Analysis
+	if err == nil {
+		analysis, err = recordAnalysis(ctx, owner, repo, result, opts)
+	}
+
+	now := time.Now().UTC()
+	sched.LastRunAt = &now
+	sched.LastError = ""
+	if err != nil {
+		sched.LastError = err.Error()
+		opts.Logger.Warn("scheduled scan failed", "error", err)
+	} else {
+		sched.LastAnalysisID = analysis.ID
+		logCtx := context.WithValue(ctx, loggerKey{}, opts.Logger)
+		notify(logCtx, sched, analysis)
+		fileIssues(logCtx, sched, analysis)
+		fileScheduledJiraTickets(logCtx, sched, analysis)
+	}
+	if err := analyses.storage.SaveSchedule(ctx, sched); err != nil {
+		opts.Logger.Error("saving schedule failed", "error", err)
+	}
+}
+
+func findSchedule(ctx context.Context, owner, repo string) (*Schedule, error) {
+	stored, err := analyses.storage.Schedules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, sched := range stored {
+		if repoKey(sched.Owner, sched.Repo) == repoKey(owner, repo) {
+			return sched, nil
+		}
+	}
+	return nil, nil
+}
+
+// handlePutScheduleRequest creates or replaces the schedule of a repository
+// from {"cron": "...", "scan": {scan options as for POST /garbage}, "notify":
+// [notifications], "issues": {issue filing}, "jira": {Jira filing},
+// "tenant": "..."}.
+func handlePutScheduleRequest(c *gin.Context) {
+	var sched Schedule
+	if err := c.BindJSON(&sched); err != nil {
+		respondError(c, errBadRequest("%v", err))
+		return
+	}
+	if sched.Cron == "" {
+		respondError(c, errBadRequest("cron is required"))
+		return
+	}
+	if sched.Scan.Token != "" {
+		respondError(c, errBadRequest("scan.token can't be stored, scheduled scans use the server's token or the tenant's"))
+		return
+	}
+	if sched.Scan.Verify {
+		respondError(c, errBadRequest("scheduled scans can't verify deletions"))
+		return
+	}
+	if err := validateRepo("owner", c.Param("owner"), c.Param("repo")); err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := sched.Scan.validateOptions(); err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := validateNotifications(sched.Notify); err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := validateIssueFiling(sched.Issues); err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := validateJiraFiling(sched.Jira); err != nil {
+		respondError(c, err)
+		return
+	}
+	if _, err := cron.ParseStandard(sched.Cron); err != nil {
+		respondError(c, errBadRequest("invalid cron expression %q: %v", sched.Cron, err))
+		return
+	}
+	var token string
+	if sched.Tenant != "" {
+		var err error
+		if token, err = repoToken(c.Request.Context(), sched.Tenant, c.Param("owner"), c.Param("repo")); err != nil {
+			respondError(c, err)
+			return
+		}
+	}
+	if err := checkRepoExists(c.Request.Context(), token, c.Param("owner"), c.Param("repo")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	sched.Owner, sched.Repo = c.Param("owner"), c.Param("repo")
+	sched.Scan.Username, sched.Scan.Repo = sched.Owner, sched.Repo
+	sched.CreatedAt = time.Now().UTC()
+	sched.LastRunAt, sched.LastAnalysisID, sched.LastError, sched.NextRunAt = nil, "", "", nil
+	if err := analyses.storage.SaveSchedule(c.Request.Context(), &sched); err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := schedules.register(&sched); err != nil {
+		respondError(c, err)
+		return
+	}
+	sched.NextRunAt = schedules.nextRun(sched.Owner, sched.Repo)
+	c.JSON(http.StatusOK, sched)
+}
+
+// handleGetScheduleRequest returns the schedule of a repository with its
+// last and next run.
+func handleGetScheduleRequest(c *gin.Context) {
+	sched, err := findSchedule(c.Request.Context(), c.Param("owner"), c.Param("repo"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if sched == nil {
+		respondError(c, errNotFound("schedule not found"))
+		return
+	}
+	sched.NextRunAt = schedules.nextRun(sched.Owner, sched.Repo)
+	c.JSON(http.StatusOK, sched)
+}
+
+func handleDeleteScheduleRequest(c *gin.Context) {
+	owner, repo := c.Param("owner"), c.Param("repo")
+	if err := analyses.storage.DeleteSchedule(c.Request.Context(), owner, repo); err != nil {
+		respondError(c, err)
+		return
+	}
+	schedules.unregister(owner, repo)
+	c.Status(http.StatusNoContent)
+}
+
+// handleSchedulesRequest lists every schedule.
+func handleSchedulesRequest(c *gin.Context) {
+	stored, err := analyses.storage.Schedules(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	sort.Slice(stored, func(i, j int) bool {
+		return repoKey(stored[i].Owner, stored[i].Repo) < repoKey(stored[j].Owner, stored[j].Repo)
+	})
+	for _, sched := range stored {
+		sched.NextRunAt = schedules.nextRun(sched.Owner, sched.Repo)
+	}
+	c.JSON(http.StatusOK, gin.H{"schedules": stored})
+}
@@ -0,0 +1,79 @@
+package rgc
+
+import (
+	"path"
+	"slices"
+	"strings"
+)
+
+// scopeDir cleans the directory a scan is scoped to, "" meaning the whole
+// repository.
+func scopeDir(dir string) (string, error) {
+	if dir == "" {
+		return "", nil
+	}
+	clean := path.Clean(strings.Trim(dir, "/"))
+	if strings.HasPrefix(dir, "/") || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", errBadRequest("path must be a directory of the repository, got %q", dir)
+	}
+	if clean == "." {
+		return "", nil
+	}
+	return clean, nil
+}
+
+// inScope reports whether p is inside the directory dir.
+func inScope(dir, p string) bool {
+	return dir == "" || strings.HasPrefix(p, dir+"/")
+}
+
+// scopeResult drops from the reports of result what lies outside dir. The
+// whole repository was scanned, so components inside dir imported from the
+// rest of it are still used.
+func scopeResult(result *ComponentsResult, dir string) {
+	outside := func(p string) bool { return !inScope(dir, p) }
+	outsideNode := func(n *ComponentNode) bool { return outside(n.Component.Path) }
+	noneInside := func(paths []string) bool {
+		return !slices.ContainsFunc(paths, func(p string) bool { return inScope(dir, p) })
+	}
+
+	result.Used = slices.DeleteFunc(result.Used, outsideNode)
+	result.Unused = slices.DeleteFunc(result.Unused, outsideNode)
+	result.TestOnly = slices.DeleteFunc(result.TestOnly, outsideNode)
+	result.StorybookOnly = slices.DeleteFunc(result.StorybookOnly, outsideNode)
+	result.E2EOnly = slices.DeleteFunc(result.E2EOnly, outsideNode)
+	result.PossiblyUsed = slices.DeleteFunc(result.PossiblyUsed, outsideNode)
+	result.Cycles = slices.DeleteFunc(result.Cycles, noneInside)
+	result.NameCollisions = slices.DeleteFunc(result.NameCollisions, func(c NameCollision) bool { return noneInside(c.Paths) })
+	result.Duplicates = slices.DeleteFunc(result.Duplicates, func(c DuplicateCluster) bool { return noneInside(c.Paths) })
+	result.OrphanedSubtrees = slices.DeleteFunc(result.OrphanedSubtrees, func(s OrphanedSubtree) bool { return noneInside(s.Components) })
+	result.Vendored = slices.DeleteFunc(result.Vendored, func(v VendoredComponent) bool { return outside(v.Path) })
+	result.Ignored = slices.DeleteFunc(result.Ignored, func(c IgnoredComponent) bool { return outside(c.Path) })
+	result.SkippedFiles = slices.DeleteFunc(result.SkippedFiles, func(s SkippedFile) bool { return outside(s.Path) })
+	result.Generated = slices.DeleteFunc(result.Generated, func(g GeneratedFile) bool { return outside(g.Path) })
+	result.GeneratedCount = len(result.Generated)
+	result.Shadcn = slices.DeleteFunc(result.Shadcn, func(s ShadcnReport) bool { return outside(s.UIDir + "/") })
+	result.UnusedExports = slices.DeleteFunc(result.UnusedExports, func(e UnusedExport) bool { return outside(e.Path) })
+	if h := result.Hygiene; h != nil {
+		h.Issues = slices.DeleteFunc(h.Issues, func(i HygieneIssue) bool { return outside(i.File) })
+		h.count()
+	}
+	if m := result.Modules; m != nil {
+		m.Used = slices.DeleteFunc(m.Used, func(e ModuleEntry) bool { return outside(e.Path) })
+		m.Unused = slices.DeleteFunc(m.Unused, func(e ModuleEntry) bool { return outside(e.Path) })
+		m.UsedCount, m.UnusedCount = len(m.Used), len(m.Unused)
+	}
+	if a := result.Assets; a != nil {
+		outsideAsset := func(e AssetEntry) bool { return outside(e.Path) }
+		a.Used = slices.DeleteFunc(a.Used, outsideAsset)
+		a.Unused = slices.DeleteFunc(a.Unused, outsideAsset)
+		a.OnlyUsedByUnused = slices.DeleteFunc(a.OnlyUsedByUnused, outsideAsset)
+		a.UsedCount, a.UnusedCount = len(a.Used), len(a.Unused)
+	}
+	if s := result.StyledComponents; s != nil {
+		outsideStyled := func(e StyledEntry) bool { return outside(e.Path) }
+		s.Used = slices.DeleteFunc(s.Used, outsideStyled)
+		s.Unused = slices.DeleteFunc(s.Unused, outsideStyled)
+		s.UsedCount, s.UnusedCount = len(s.Used), len(s.Unused)
+	}
+}
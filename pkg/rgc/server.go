@@ -0,0 +1,533 @@
+package rgc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+type RequestPayload struct {
+	Username string `json:"username"`
+	Repo     string `json:"repo"`
+	Mode     string `json:"mode"`
+	Ref      string `json:"ref"`
+	Verify   bool   `json:"verify"`
+
+	Concurrency int `json:"concurrency"`
+	// Token is an optional GitHub token used instead of the server's GITHUB_TOKEN.
+	Token string `json:"token"`
+	// tokenSource says where a stored Token came from, see withStoredToken.
+	tokenSource string
+	// CaseInsensitive resolves imports ignoring case, for projects developed
+	// on macOS or Windows.
+	CaseInsensitive bool `json:"case_insensitive"`
+	// ComponentRules narrows down which files are components, e.g. only
+	// PascalCase files under src/components/.
+	ComponentRules ComponentRules `json:"component_rules"`
+	// SkipLargeFiles leaves component files past a size out of the scan.
+	SkipLargeFiles FileSizeRules `json:"skip_large_files"`
+	// EntryPoints are path patterns of the files the application starts
+	// from; when set, only components reachable from them are used.
+	EntryPoints []string `json:"entry_points"`
+	// Props includes each component's props in the result.
+	Props bool `json:"props"`
+	// Complexity includes each component's metrics in the result.
+	Complexity bool `json:"complexity"`
+	// Duplicates adds the clusters of near-identical components.
+	Duplicates bool `json:"duplicates"`
+	// PropUsage includes the props each component's call sites pass.
+	PropUsage bool `json:"prop_usage"`
+	// Hygiene adds the opt-in import hygiene report.
+	Hygiene bool `json:"hygiene"`
+	// UnusedExports reports exports nothing imports inside used files.
+	UnusedExports bool `json:"unused_exports"`
+	// IncludeModules also reports unused hooks and utility modules.
+	IncludeModules bool `json:"include_modules"`
+	// StringReferences reports the unused components whose name is quoted
+	// as possibly used.
+	StringReferences bool `json:"string_references"`
+	// StyledComponents adds the styled-components and Emotion report.
+	StyledComponents bool `json:"styled_components"`
+	// Assets reports unreferenced images and stylesheets.
+	Assets bool `json:"assets"`
+	// Metadata adds each component's file size and last commit.
+	Metadata bool `json:"metadata"`
+	// ExcludeVendored leaves components copied from third-party libraries
+	// out of the used and unused counts.
+	ExcludeVendored bool `json:"exclude_vendored"`
+	// CommitStatus posts the unused component count as a commit status.
+	CommitStatus bool `json:"commit_status"`
+	// Path scopes the results to a directory of the repository.
+	Path string `json:"path"`
+	// SparsePaths limits a clone to these directories of the repository.
+	SparsePaths []string `json:"sparse_paths"`
+	// Submodules is "skip" or "recurse" into the repository's submodules.
+	Submodules string `json:"submodules"`
+	// IncludeVendorDirs scans node_modules, vendor and similar directories.
+	IncludeVendorDirs bool `json:"include_vendor_dirs"`
+	// IncludeGenerated keeps the files code generators wrote.
+	IncludeGenerated bool `json:"include_generated"`
+	// AllowLargeRepo scans repositories past the max_repo_size limit.
+	AllowLargeRepo bool `json:"allow_large_repo"`
+	// Since is the ID of a previous analysis to rescan from, fetching only
+	// the files changed since, listed in ChangedFiles or compared.
+	Since        string   `json:"since"`
+	ChangedFiles []string `json:"changed_files"`
+	// PostProcessors transform the result before it's recorded, e.g.
+	// ["redact-paths", "rewrite-prefix:packages/web/="].
+	PostProcessors []string `json:"post_processors"`
+	// Limits tightens the server's timeout and size limits for this scan.
+	Limits ScanLimits `json:"limits"`
+}
+
+// scanOptions returns the scan options the payload asks for.
+func (p *RequestPayload) scanOptions() ScanOptions {
+	return ScanOptions{
+		Mode:              p.Mode,
+		Ref:               p.Ref,
+		Verify:            p.Verify,
+		Concurrency:       p.Concurrency,
+		Token:             p.Token,
+		CaseInsensitive:   p.CaseInsensitive,
+		ComponentRules:    p.ComponentRules,
+		SkipLargeFiles:    p.SkipLargeFiles,
+		EntryPoints:       p.EntryPoints,
+		Props:             p.Props,
+		PropUsage:         p.PropUsage,
+		Duplicates:        p.Duplicates,
+		Complexity:        p.Complexity,
+		Hygiene:           p.Hygiene,
+		UnusedExports:     p.UnusedExports,
+		StringReferences:  p.StringReferences,
+		StyledComponents:  p.StyledComponents,
+		IncludeModules:    p.IncludeModules,
+		Assets:            p.Assets,
+		Metadata:          p.Metadata,
+		ExcludeVendored:   p.ExcludeVendored,
+		CommitStatus:      p.CommitStatus,
+		Path:              p.Path,
+		SparsePaths:       p.SparsePaths,
+		Submodules:        p.Submodules,
+		IncludeVendorDirs: p.IncludeVendorDirs,
+		IncludeGenerated:  p.IncludeGenerated,
+		AllowLargeRepo:    p.AllowLargeRepo,
+		Since:             p.Since,
+		ChangedFiles:      p.ChangedFiles,
+		PostProcessors:    p.PostProcessors,
+		Limits:            p.Limits,
+		origin:            scanOrigin{tokenSource: p.tokenSource},
+	}
+}
+
+// validateOptions checks the scan options that can be refused before
+// scanning, so background and multi-repository scans fail right away.
+func (p *RequestPayload) validateOptions() error {
+	if _, err := postProcessorPipeline(p.PostProcessors); err != nil {
+		return err
+	}
+	if err := p.ComponentRules.validate(); err != nil {
+		return err
+	}
+	if err := p.SkipLargeFiles.validate(); err != nil {
+		return err
+	}
+	if p.Since != "" && p.Mode == "clone" {
+		return errBadRequest("since is only supported in api mode")
+	}
+	if err := validateSubmodules(p.Submodules); err != nil {
+		return err
+	}
+	if err := validateSparsePaths(p.Mode, p.SparsePaths); err != nil {
+		return err
+	}
+	if _, err := scopeDir(p.Path); err != nil {
+		return err
+	}
+	_, err := resolveLimits(p.Limits)
+	return err
+}
+
+// Setup prepares the server from its environment: logging, tracing, the
+// startup checks, the scan history storage and the scheduler. The returned
+// function releases what Setup acquired.
+func Setup(ctx context.Context) (func(), error) {
+	slog.SetDefault(newLogger())
+	shutdownTracing, err := setupTracing(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkStartup(); err != nil {
+		shutdownTracing(ctx)
+		return nil, err
+	}
+
+	storage, err := openStorage(ctx, storageURL())
+	if err != nil {
+		shutdownTracing(ctx)
+		return nil, err
+	}
+	analyses.storage = storage
+	cleanup := func() {
+		storage.Close()
+		shutdownTracing(context.Background())
+	}
+	if schedulerEnabled() {
+		if err := schedules.start(ctx); err != nil {
+			cleanup()
+			return nil, err
+		}
+	}
+	return cleanup, nil
+}
+
+// NewRouter returns the handler serving the API. Without Setup, analyses
+// are kept in memory and nothing runs on a schedule.
+func NewRouter() *gin.Engine {
+	r := gin.New()
+
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowAllOrigins = true
+	corsConfig.AddAllowHeaders(apiKeyHeader, requestIDHeader, githubTokenHeader)
+	corsConfig.AddExposeHeaders(requestIDHeader, "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining")
+	r.Use(gin.Recovery(), traceRequests(), requestLogger(), cors.New(corsConfig))
+
+	r.GET("/healthz", handleHealthRequest)
+	r.GET("/readyz", handleReadyRequest)
+	r.GET("/openapi.yaml", handleOpenAPIYAMLRequest)
+	r.GET("/openapi.json", handleOpenAPIJSONRequest)
+	r.GET("/docs", handleDocsRequest)
+	r.POST("/garbage", rejectWhileDraining(), requireAPIKey(), limitScans(), handleGarbageRequest)
+	r.GET("/garbage/:owner/:repo", rejectWhileDraining(), requireAPIKey(), limitScans(), handleGarbageGetRequest)
+	// Batch and organization scans count each repository they scan.
+	r.POST("/garbage/org", rejectWhileDraining(), requireAPIKey(), handleOrgRequest)
+	r.POST("/garbage/batch", rejectWhileDraining(), requireAPIKey(), handleBatchRequest)
+	r.POST("/jobs", rejectWhileDraining(), requireAPIKey(), limitScans(), handleCreateJobRequest)
+	r.GET("/jobs/:id", requireAPIKey(), handleJobRequest)
+	r.GET("/ws", rejectWhileDraining(), requireAPIKey(), handleWebSocketRequest)
+	r.GET("/graphql", requireAPIKey(), handleGraphQLRequest)
+	r.POST("/graphql", requireAPIKey(), handleGraphQLRequest)
+	r.GET("/diff", rejectWhileDraining(), requireAPIKey(), limitScans(), handleDiffRequest)
+	r.GET("/badge/:owner/:repo", handleBadgeRequest)
+	r.GET("/demo", handleDemosRequest)
+	r.GET("/demo/:name", handleDemoRequest)
+	r.GET("/repos/:owner/:repo/changes", requireAPIKey(), requireRepoAccess(roleRead), handleChangesRequest)
+	r.GET("/repos/:owner/:repo/scans", requireAPIKey(), requireRepoAccess(roleRead), handleScansRequest)
+	r.GET("/repos/:owner/:repo/scans/:id", requireAPIKey(), requireRepoAccess(roleRead), handleScanRequest)
+	r.GET("/repos/:owner/:repo/scans/:id/components", requireAPIKey(), requireRepoAccess(roleRead), handleComponentsRequest)
+	r.POST("/repos/:owner/:repo/scans/:id/cleanup", rejectWhileDraining(), requireAPIKey(), requireRepoAccess(roleWrite), handleCleanupRequest)
+	r.GET("/repos/:owner/:repo/scans/:id/cleanup.patch", requireAPIKey(), requireRepoAccess(roleRead), handleCleanupPatchRequest)
+	r.POST("/repos/:owner/:repo/scans/:id/jira", requireAdmin(), handleJiraRequest)
+	r.GET("/repos/:owner/:repo/trends", requireAPIKey(), requireRepoAccess(roleRead), handleTrendsRequest)
+	r.GET("/config", requireAdmin(), handleConfigRequest)
+	r.GET("/audit", requireAdmin(), handleAuditRequest)
+	r.GET("/api-keys", requireAdmin(), handleAPIKeysRequest)
+	r.POST("/api-keys", requireAdmin(), handleCreateAPIKeyRequest)
+	r.DELETE("/api-keys/:name", requireAdmin(), handleDeleteAPIKeyRequest)
+	r.GET("/schedules", requireAdmin(), handleSchedulesRequest)
+	r.GET("/repos/:owner/:repo/schedule", requireAdmin(), handleGetScheduleRequest)
+	r.PUT("/repos/:owner/:repo/schedule", requireAdmin(), handlePutScheduleRequest)
+	r.DELETE("/repos/:owner/:repo/schedule", requireAdmin(), handleDeleteScheduleRequest)
+	r.GET("/subscribers", requireAdmin(), handleSubscribersRequest)
+	r.PUT("/subscribers/:email", requireAdmin(), handlePutSubscriberRequest)
+	r.DELETE("/subscribers/:email", requireAdmin(), handleDeleteSubscriberRequest)
+	r.POST("/subscribers/:email/digest", requireAdmin(), handleSendDigestRequest)
+	r.GET("/auth/github/login", handleGitHubLoginRequest)
+	r.GET("/auth/github/callback", handleGitHubCallbackRequest)
+	r.GET("/auth/session", handleSessionRequest)
+	r.POST("/auth/logout", handleLogoutRequest)
+	r.GET("/tenants", requireAdmin(), handleTenantsRequest)
+	r.POST("/tenants", requireAdmin(), handleCreateTenantRequest)
+	r.DELETE("/tenants/:name", requireAdmin(), handleDeleteTenantRequest)
+	r.GET("/tenants/:name", requireTenant(), handleTenantRequest)
+	r.PUT("/tenants/:name/repos/:owner/:repo", requireTenant(), handlePutTenantRepoRequest)
+	r.DELETE("/tenants/:name/repos/:owner/:repo", requireTenant(), handleDeleteTenantRepoRequest)
+	r.PUT("/tenants/:name/tokens/:provider", requireTenant(), handlePutTenantTokenRequest)
+	r.DELETE("/tenants/:name/tokens/:provider", requireTenant(), handleDeleteTenantTokenRequest)
+	return r
+}
+
+// checkStartup runs the doctor checks before serving, so a bad token or
+// configuration fails fast instead of on the first request. Warnings, such
+// as GitHub being unreachable right now, are only logged.
+func checkStartup() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	var failed []string
+	for _, d := range runDiagnostics(ctx) {
+		switch d.Status {
+		case checkWarn:
+			slog.Warn("startup check", "check", d.Name, "detail", d.Detail)
+		case checkFail:
+			failed = append(failed, d.Name+": "+d.Detail)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("startup checks failed (run `rgc doctor` for details): %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// resultFormat returns the ?format= the result is rendered in.
+func resultFormat(c *gin.Context) (string, error) {
+	format := c.DefaultQuery("format", "json")
+	switch format {
+	case "json", "text", "catalog", "catalog_json":
+		return format, nil
+	}
+	return "", errBadRequest("format must be json, text, catalog or catalog_json")
+}
+
+func handleGarbageRequest(c *gin.Context) {
+	format, err := resultFormat(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	var payload RequestPayload
+	if err := c.BindJSON(&payload); err != nil {
+		respondError(c, errBadRequest("%v", err))
+		return
+	}
+
+	if err := validateRepo("username", payload.Username, payload.Repo); err != nil {
+		respondError(c, err)
+		return
+	}
+	scanAndRespond(c, format, payload)
+}
+
+// handleGarbageGetRequest is the GET variant of handleGarbageRequest, taking
+// the repository from the path and the scan options from the query string,
+// so a scan can be linked to. It never accepts a token or posts a commit
+// status, since URLs end up in logs and browser history.
+func handleGarbageGetRequest(c *gin.Context) {
+	format, err := resultFormat(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	payload, err := payloadFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := validateRepo("owner", payload.Username, payload.Repo); err != nil {
+		respondError(c, err)
+		return
+	}
+	scanAndRespond(c, format, payload)
+}
+
+// payloadFromQuery reads a scan request from the path and query string.
+// Options are named as in the JSON body; lists are repeated parameters, as
+// in ?entry_points=src/main.tsx&entry_points=src/admin.tsx.
+func payloadFromQuery(c *gin.Context) (RequestPayload, error) {
+	payload := RequestPayload{
+		Username:       c.Param("owner"),
+		Repo:           c.Param("repo"),
+		Mode:           c.Query("mode"),
+		Ref:            c.Query("ref"),
+		Submodules:     c.Query("submodules"),
+		EntryPoints:    c.QueryArray("entry_points"),
+		SparsePaths:    c.QueryArray("sparse_paths"),
+		Path:           c.Query("path"),
+		PostProcessors: c.QueryArray("post_processors"),
+	}
+	flags := map[string]*bool{
+		"verify":              &payload.Verify,
+		"case_insensitive":    &payload.CaseInsensitive,
+		"props":               &payload.Props,
+		"prop_usage":          &payload.PropUsage,
+		"duplicates":          &payload.Duplicates,
+		"complexity":          &payload.Complexity,
+		"hygiene":             &payload.Hygiene,
+		"unused_exports":      &payload.UnusedExports,
+		"string_references":   &payload.StringReferences,
+		"styled_components":   &payload.StyledComponents,
+		"include_modules":     &payload.IncludeModules,
+		"assets":              &payload.Assets,
+		"metadata":            &payload.Metadata,
+		"exclude_vendored":    &payload.ExcludeVendored,
+		"allow_large_repo":    &payload.AllowLargeRepo,
+		"include_vendor_dirs": &payload.IncludeVendorDirs,
+		"include_generated":   &payload.IncludeGenerated,
+	}
+	for name, flag := range flags {
+		v := c.Query(name)
+		if v == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return payload, errBadRequest("%s must be true or false", name)
+		}
+		*flag = b
+	}
+	if v := c.Query("concurrency"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return payload, errBadRequest("concurrency must be a positive number")
+		}
+		payload.Concurrency = n
+	}
+	return payload, nil
+}
+
+// scanAndRespond scans the repository payload names, records the analysis
+// and writes the result in format.
+func scanAndRespond(c *gin.Context, format string, payload RequestPayload) {
+	shape, err := parseResultShape(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := checkScanAccess(accessContext(c), payload.Username, payload.Repo, payload.Token); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if err := payload.withStoredToken(c.Request.Context()); err != nil {
+		respondError(c, err)
+		return
+	}
+	opts := payload.scanOptions()
+	opts.Props = opts.Props || strings.HasPrefix(format, "catalog")
+	opts.fromRequest(c.Request.Context())
+	result, err := ProcessRepository(payload.Username, payload.Repo, opts)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	analysis, err := recordAnalysis(c.Request.Context(), payload.Username, payload.Repo, result, opts)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	title := payload.Username + "/" + payload.Repo
+	switch format {
+	case "text":
+		c.String(http.StatusOK, renderTextTree(title, shape.apply(result)))
+		return
+	case "catalog":
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		if err := renderCatalogHTML(c.Writer, buildCatalog(title, result)); err != nil {
+			c.Error(err)
+		}
+		return
+	case "catalog_json":
+		c.JSON(http.StatusOK, buildCatalog(title, result))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"analysis_id": analysis.ID, "components": shape.apply(result)})
+}
+
+const (
+	defaultPollTimeout = 30 * time.Second
+	maxPollTimeout     = 120 * time.Second
+)
+
+// handleChangesRequest long-polls for the analysis that follows ?since= and
+// returns its diff against since. If none arrives before ?timeout= seconds
+// it answers 204 and the client should simply poll again.
+func handleChangesRequest(c *gin.Context) {
+	owner, repo := c.Param("owner"), c.Param("repo")
+
+	sinceID := c.Query("since")
+	if sinceID == "" {
+		respondError(c, errBadRequest("since query parameter is required"))
+		return
+	}
+	since, err := analyses.get(c.Request.Context(), owner, repo, sinceID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	timeout := defaultPollTimeout
+	if v := c.Query("timeout"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs < 0 {
+			respondError(c, errBadRequest("timeout must be a number of seconds"))
+			return
+		}
+		timeout = min(time.Duration(secs)*time.Second, maxPollTimeout)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		next, wait, err := analyses.next(c.Request.Context(), since)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		if next != nil {
+			diff := diffResults(since.Result, next.Result)
+			diff.From, diff.To = since.ID, next.ID
+			c.JSON(http.StatusOK, gin.H{"analysis_id": next.ID, "changes": diff})
+			return
+		}
+
+		select {
+		case <-wait:
+		case <-timer.C:
+			c.Status(http.StatusNoContent)
+			return
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+const (
+	defaultScansLimit = 20
+	maxScansLimit     = 100
+)
+
+// handleScansRequest lists the past analyses of a repository, newest first,
+// with their counts but without the full results.
+func handleScansRequest(c *gin.Context) {
+	limit := defaultScansLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			respondError(c, errBadRequest("limit must be a positive number"))
+			return
+		}
+		limit = min(n, maxScansLimit)
+	}
+
+	scans, err := analyses.list(c.Request.Context(), c.Param("owner"), c.Param("repo"), limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"scans": scans})
+}
+
+// handleScanRequest returns one past analysis with its full result.
+func handleScanRequest(c *gin.Context) {
+	shape, err := parseResultShape(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	analysis, err := analyses.get(c.Request.Context(), c.Param("owner"), c.Param("repo"), c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	shaped := *analysis
+	shaped.Result = shape.apply(analysis.Result)
+	c.JSON(http.StatusOK, shaped)
+}
@@ -0,0 +1,154 @@
+package rgc
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/igorfelipeduca/rgc/internal/graph"
+)
+
+// ShadcnReport tells, for one shadcn/ui project, which of the primitives the
+// CLI generated into the ui directory the application actually uses.
+type ShadcnReport struct {
+	Config      string   `json:"config"`
+	UIDir       string   `json:"ui_dir"`
+	Installed   int      `json:"installed"`
+	UsedCount   int      `json:"used_count"`
+	UnusedCount int      `json:"unused_count"`
+	Used        []string `json:"used"`
+	Unused      []string `json:"unused"`
+}
+
+// shadcnConfig is the part of a components.json RGC needs.
+type shadcnConfig struct {
+	path string
+	// aliasPrefix is the import alias prefix, e.g. "@/", and aliasDir the
+	// repository directory it stands for.
+	aliasPrefix string
+	aliasDir    string
+	uiDir       string
+
+	Aliases struct {
+		Components string `json:"components"`
+		UI         string `json:"ui"`
+	} `json:"aliases"`
+}
+
+// loadShadcnConfigs reads the components.json of every shadcn/ui project in
+// the repository and works out where its import alias and ui directory point.
+// The alias is matched against the layouts the CLI generates (the project
+// root, src/ or src/lib/) rather than read from tsconfig paths.
+func (sc *scan) loadShadcnConfigs(ctx context.Context) error {
+	for _, root := range sc.shadcnRoots {
+		configPath := path.Join(root, "components.json")
+		content, err := sc.src.ReadFile(ctx, configPath)
+		if err != nil {
+			if err == errFileNotFound {
+				continue
+			}
+			return err
+		}
+
+		cfg := &shadcnConfig{path: configPath}
+		if err := json.Unmarshal([]byte(content), cfg); err != nil {
+			sc.warnf("ignoring %s: %v", configPath, err)
+			continue
+		}
+		if cfg.Aliases.Components == "" {
+			cfg.Aliases.Components = "@/components"
+		}
+		if cfg.Aliases.UI == "" {
+			// Configs written before the ui alias existed kept primitives here.
+			cfg.Aliases.UI = cfg.Aliases.Components + "/ui"
+		}
+
+		prefix, rest, ok := strings.Cut(cfg.Aliases.Components, "/")
+		if !ok {
+			continue
+		}
+		cfg.aliasPrefix = prefix + "/"
+		for _, dir := range []string{root, path.Join(root, "src"), path.Join(root, "src/lib")} {
+			if sc.hasDir(path.Join(dir, rest)) {
+				cfg.aliasDir = dir
+				break
+			}
+		}
+		if cfg.aliasDir == "" {
+			sc.warnf("%s: can't locate the %q alias in the repository", configPath, cfg.Aliases.Components)
+			continue
+		}
+		cfg.uiDir = cfg.resolveAlias(cfg.Aliases.UI)
+		sc.shadcn = append(sc.shadcn, cfg)
+	}
+	return nil
+}
+
+// resolveAlias turns an aliased specifier such as "@/components/ui/button"
+// into a repository path, or returns "" if it doesn't use the alias.
+func (cfg *shadcnConfig) resolveAlias(specifier string) string {
+	if !strings.HasPrefix(specifier, cfg.aliasPrefix) {
+		return ""
+	}
+	return path.Join(cfg.aliasDir, strings.TrimPrefix(specifier, cfg.aliasPrefix))
+}
+
+// contains reports whether the file at p belongs to the project.
+func (cfg *shadcnConfig) contains(p string) bool {
+	root := path.Dir(cfg.path)
+	return root == "." || strings.HasPrefix(p, root+"/")
+}
+
+func (sc *scan) hasDir(dir string) bool {
+	prefix := sc.nameKey(path.Clean(dir) + "/")
+	for key := range sc.fileIndex {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// shadcnReports reports, for every shadcn/ui project, which ui primitives are
+// reachable from live application code, directly or through other
+// primitives. Dead app components don't keep a primitive used.
+func (sc *scan) shadcnReports(g *graph.Graph, live map[string]bool) []ShadcnReport {
+	var reports []ShadcnReport
+	for _, cfg := range sc.shadcn {
+		var primitives []Component
+		var app []string
+		for _, component := range sc.createdComponents {
+			if !cfg.contains(component.Path) {
+				continue
+			}
+			if strings.HasPrefix(component.Path, cfg.uiDir+"/") {
+				primitives = append(primitives, component)
+			} else if live[component.Path] {
+				app = append(app, component.Path)
+			}
+		}
+		sort.Slice(primitives, func(i, j int) bool { return primitives[i].Name < primitives[j].Name })
+
+		reachable := g.Reachable(app...)
+		report := ShadcnReport{
+			Config:    cfg.path,
+			UIDir:     cfg.uiDir,
+			Installed: len(primitives),
+			Used:      []string{},
+			Unused:    []string{},
+		}
+		for _, primitive := range primitives {
+			if reachable[primitive.Path] {
+				report.Used = append(report.Used, primitive.Name)
+			} else {
+				report.Unused = append(report.Unused, primitive.Name)
+			}
+		}
+		report.UsedCount = len(report.Used)
+		report.UnusedCount = len(report.Unused)
+		reports = append(reports, report)
+	}
+	return reports
+}
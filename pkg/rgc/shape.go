@@ -0,0 +1,109 @@
+package rgc
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// resultShape trims a result's component trees for the response, keeping
+// payloads small for dashboards. The recorded analysis keeps the full
+// trees.
+type resultShape struct {
+	// depth is how many levels of children to keep under each component,
+	// or -1 for all of them.
+	depth int
+	// flat drops the children altogether, listing each component's
+	// importers as parents instead.
+	flat bool
+}
+
+// parseResultShape reads ?depth= and ?flat=.
+func parseResultShape(c *gin.Context) (resultShape, error) {
+	shape := resultShape{depth: -1}
+	if v := c.Query("depth"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return shape, errBadRequest("depth must be a number of levels, 0 or more")
+		}
+		shape.depth = n
+	}
+	if v := c.Query("flat"); v != "" {
+		flat, err := strconv.ParseBool(v)
+		if err != nil {
+			return shape, errBadRequest("flat must be true or false")
+		}
+		shape.flat = flat
+	}
+	if shape.flat && shape.depth >= 0 {
+		return shape, errBadRequest("depth and flat can't be combined")
+	}
+	return shape, nil
+}
+
+// apply returns result with its trees shaped. Nodes are copied, since they
+// are shared between the trees and with the recorded analysis.
+func (s resultShape) apply(result *ComponentsResult) *ComponentsResult {
+	if s.depth < 0 && !s.flat {
+		return result
+	}
+	shaped := *result
+	buckets := []*[]*ComponentNode{&shaped.Used, &shaped.Unused, &shaped.TestOnly, &shaped.StorybookOnly, &shaped.E2EOnly, &shaped.PossiblyUsed}
+
+	if !s.flat {
+		for _, bucket := range buckets {
+			*bucket = trimNodes(*bucket, s.depth)
+		}
+		return &shaped
+	}
+
+	parents := make(map[string][]string)
+	for _, bucket := range buckets {
+		for _, node := range *bucket {
+			for _, child := range node.Children {
+				parents[child.Component.Path] = append(parents[child.Component.Path], node.Component.Path)
+			}
+		}
+	}
+	for _, bucket := range buckets {
+		flat := make([]*ComponentNode, len(*bucket))
+		for i, node := range *bucket {
+			n := *node
+			n.Children = nil
+			n.Parents = dedupSorted(parents[node.Component.Path])
+			flat[i] = &n
+		}
+		*bucket = flat
+	}
+	return &shaped
+}
+
+// trimNodes copies nodes, keeping depth levels of children under each.
+func trimNodes(nodes []*ComponentNode, depth int) []*ComponentNode {
+	if nodes == nil {
+		return nil
+	}
+	trimmed := make([]*ComponentNode, len(nodes))
+	for i, node := range nodes {
+		n := *node
+		if depth == 0 {
+			n.Children = nil
+		} else {
+			n.Children = trimNodes(node.Children, depth-1)
+		}
+		trimmed[i] = &n
+	}
+	return trimmed
+}
+
+func dedupSorted(paths []string) []string {
+	sort.Strings(paths)
+	out := paths[:0]
+	for i, p := range paths {
+		if i == 0 || p != paths[i-1] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
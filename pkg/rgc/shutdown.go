@@ -0,0 +1,145 @@
+package rgc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+)
+
+const (
+	// defaultShutdownTimeout leaves an in-flight scan at the default
+	// timeout the time to finish.
+	defaultShutdownTimeout = 90 * time.Second
+	defaultShutdownDelay   = 5 * time.Second
+)
+
+// draining is set once the server starts shutting down.
+var draining atomic.Bool
+
+// durationEnv returns the duration set in the environment variable name,
+// or def when it's unset or invalid.
+func durationEnv(name string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(name)); err == nil && d >= 0 {
+		return d
+	}
+	return def
+}
+
+// rejectWhileDraining refuses new scans once the server is shutting down.
+// Reading results and polling jobs still work until the server stops.
+func rejectWhileDraining() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if draining.Load() {
+			c.Header("Retry-After", "5")
+			respondError(c, errShuttingDown())
+			return
+		}
+		c.Next()
+	}
+}
+
+func errShuttingDown() *APIError {
+	return &APIError{Status: http.StatusServiceUnavailable, Code: codeShuttingDown,
+		Message: "the server is shutting down, retry on another instance", Retryable: true}
+}
+
+// handleHealthRequest reports that the process is alive.
+func handleHealthRequest(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// handleReadyRequest reports whether the server takes new scans, so a load
+// balancer stops routing to it while it drains.
+func handleReadyRequest(c *gin.Context) {
+	if draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
+// Serve runs the server until SIGINT or SIGTERM, then shuts it down
+// gracefully: it stops taking new scans, waits RGC_SHUTDOWN_DELAY for load
+// balancers to notice, and lets in-flight requests, background jobs and
+// scheduled scans finish within RGC_SHUTDOWN_TIMEOUT.
+func Serve(handler http.Handler, addr string) error {
+	srv := &http.Server{Addr: addr, Handler: handler}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+
+	// The gRPC API listens on its own port, unless RGC_GRPC_ADDR is off.
+	var grpcSrv *grpc.Server
+	grpcErrc := make(chan error, 1)
+	if addr := grpcAddr(); addr != "" {
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("error listening for gRPC: %w", err)
+		}
+		grpcSrv = newGRPCServer()
+		go func() { grpcErrc <- grpcSrv.Serve(lis) }()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	select {
+	case err := <-errc:
+		return err
+	case err := <-grpcErrc:
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+
+	timeout := durationEnv("RGC_SHUTDOWN_TIMEOUT", defaultShutdownTimeout)
+	slog.Info("shutting down, draining in-flight scans", "timeout", timeout.String())
+	draining.Store(true)
+	deadline, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Kubernetes keeps routing requests for a moment after sending SIGTERM.
+	select {
+	case <-time.After(durationEnv("RGC_SHUTDOWN_DELAY", defaultShutdownDelay)):
+	case <-deadline.Done():
+	}
+
+	if err := srv.Shutdown(deadline); err != nil {
+		slog.Warn("in-flight requests didn't finish in time", "error", err)
+	}
+	if grpcSrv != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcSrv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-deadline.Done():
+			slog.Warn("in-flight gRPC calls didn't finish in time")
+			grpcSrv.Stop()
+		}
+	}
+	select {
+	case <-schedules.cron.Stop().Done():
+	case <-deadline.Done():
+		slog.Warn("scheduled scans didn't finish in time")
+	}
+	if n := jobs.drain(deadline); n > 0 {
+		slog.Warn("background jobs didn't finish in time and are lost", "jobs", n)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	slog.Info("shut down")
+	return nil
+}
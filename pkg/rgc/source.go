@@ -0,0 +1,403 @@
+package rgc
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v39/github"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/errgroup"
+)
+
+// errFileNotFound is returned by a Source when a listed file can no longer be read.
+var errFileNotFound = errors.New("file not found")
+
+// Source lists and reads the files of the repository being analyzed.
+type Source interface {
+	ListFiles(ctx context.Context) ([]string, error)
+	ReadFile(ctx context.Context, path string) (string, error)
+}
+
+// githubSource reads the repository through the GitHub contents API.
+type githubSource struct {
+	client      *github.Client
+	owner       string
+	repo        string
+	ref         string
+	concurrency int
+
+	mu       sync.Mutex
+	warnings []string
+	// submodules maps the path of every submodule listed to its commit.
+	submodules map[string]string
+}
+
+// contentsDirLimit is the most entries the contents API lists for a
+// directory. Larger directories are cut without any error.
+const contentsDirLimit = 1000
+
+// ListFiles enumerates every file with a single recursive Git Trees API call.
+// GitHub truncates very large trees, in which case it falls back to walking
+// the contents API directory by directory, and to the Git Trees API again for
+// the directories the contents API truncates.
+func (s *githubSource) ListFiles(ctx context.Context) ([]string, error) {
+	tree, _, err := s.client.Git.GetTree(ctx, s.owner, s.repo, s.ref, true)
+	if err != nil {
+		return nil, fmt.Errorf("error getting repository tree: %w", err)
+	}
+	if tree.GetTruncated() {
+		return s.walkContents(ctx)
+	}
+
+	var files []string
+	for _, entry := range tree.Entries {
+		switch entry.GetType() {
+		case "blob":
+			files = append(files, entry.GetPath())
+		case "commit":
+			s.addSubmodule(entry.GetPath(), entry.GetSHA())
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// walkContents walks the repository tree, fetching directories in parallel.
+// Each directory gets its own goroutine, but at most concurrency of them talk
+// to GitHub at once.
+func (s *githubSource) walkContents(ctx context.Context) ([]string, error) {
+	eg, ctx := errgroup.WithContext(ctx)
+	w := &treeWalk{
+		source: s,
+		eg:     eg,
+		sem:    make(chan struct{}, max(s.concurrency, 1)),
+	}
+	w.walk(ctx, "", s.ref)
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+	sort.Strings(w.files)
+	return w.files, nil
+}
+
+type treeWalk struct {
+	source *githubSource
+	eg     *errgroup.Group
+	sem    chan struct{}
+
+	mu    sync.Mutex
+	files []string
+}
+
+func (w *treeWalk) acquire(ctx context.Context) error {
+	select {
+	case w.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *treeWalk) add(p string) {
+	w.mu.Lock()
+	w.files = append(w.files, p)
+	w.mu.Unlock()
+}
+
+// walk lists the directory at path, whose tree is sha.
+func (w *treeWalk) walk(ctx context.Context, path, sha string) {
+	w.eg.Go(func() (err error) {
+		if err := w.acquire(ctx); err != nil {
+			return err
+		}
+		ctx, span := startSpan(ctx, "list_dir", attribute.String("rgc.dir", path))
+		defer func() { endSpan(span, err) }()
+		_, dirContent, _, err := w.source.client.Repositories.GetContents(ctx, w.source.owner, w.source.repo, path, w.source.refOptions())
+		<-w.sem
+		if err != nil {
+			if path == "" {
+				return fmt.Errorf("error getting repository contents: %w", err)
+			}
+			return fmt.Errorf("error getting directory contents: %w", err)
+		}
+		if len(dirContent) >= contentsDirLimit {
+			// The root was already listed recursively and came back truncated.
+			return w.walkTree(ctx, path, sha, path != "")
+		}
+
+		for _, content := range dirContent {
+			switch {
+			case *content.Type == "dir":
+				w.walk(ctx, *content.Path, content.GetSHA())
+			case *content.Type == "submodule", *content.Type == "file" && content.DownloadURL == nil:
+				// Directory listings call submodules files, without a download URL.
+				w.source.addSubmodule(*content.Path, content.GetSHA())
+			case *content.Type == "file":
+				w.add(*content.Path)
+			}
+		}
+		return nil
+	})
+}
+
+// walkTree lists a directory the contents API truncated through the Git
+// Trees API: the whole subtree at once when it fits in a response, otherwise
+// its direct entries, walking its subdirectories as usual.
+func (w *treeWalk) walkTree(ctx context.Context, dir, sha string, recursive bool) (err error) {
+	if err := w.acquire(ctx); err != nil {
+		return err
+	}
+	ctx, span := startSpan(ctx, "list_tree", attribute.String("rgc.dir", dir), attribute.Bool("rgc.recursive", recursive))
+	defer func() { endSpan(span, err) }()
+	tree, _, err := w.source.client.Git.GetTree(ctx, w.source.owner, w.source.repo, sha, recursive)
+	<-w.sem
+	if err != nil {
+		return fmt.Errorf("error getting tree of %s: %w", displayDir(dir), err)
+	}
+	if tree.GetTruncated() {
+		if recursive {
+			return w.walkTree(ctx, dir, sha, false)
+		}
+		w.source.warnf("%s has too many entries for GitHub to list, some of its files may be missing from the analysis", displayDir(dir))
+	}
+
+	for _, entry := range tree.Entries {
+		p := path.Join(dir, entry.GetPath())
+		switch entry.GetType() {
+		case "blob":
+			w.add(p)
+		case "tree":
+			if !recursive {
+				w.walk(ctx, p, entry.GetSHA())
+			}
+		case "commit":
+			w.source.addSubmodule(p, entry.GetSHA())
+		}
+	}
+	return nil
+}
+
+func displayDir(dir string) string {
+	if dir == "" {
+		return "the repository root"
+	}
+	return "directory " + dir
+}
+
+// Warnings explains why the files listed may be incomplete.
+func (s *githubSource) Warnings() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.warnings
+}
+
+func (s *githubSource) addSubmodule(p, commit string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.submodules == nil {
+		s.submodules = make(map[string]string)
+	}
+	s.submodules[p] = commit
+}
+
+// submoduleCommits returns the submodules found while listing files.
+func (s *githubSource) submoduleCommits() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.submodules
+}
+
+func (s *githubSource) warnf(format string, args ...interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.warnings = append(s.warnings, fmt.Sprintf(format, args...))
+}
+
+func (s *githubSource) refOptions() *github.RepositoryContentGetOptions {
+	if s.ref == "" {
+		return nil
+	}
+	return &github.RepositoryContentGetOptions{Ref: s.ref}
+}
+
+// rawMediaType asks the contents API for the file itself instead of a JSON
+// document carrying it base64 encoded, which also lifts the 1MB size limit
+// of the JSON form.
+const rawMediaType = "application/vnd.github.v3.raw"
+
+func (s *githubSource) ReadFile(ctx context.Context, path string) (string, error) {
+	u := fmt.Sprintf("repos/%s/%s/contents/%s", s.owner, s.repo, (&url.URL{Path: path}).String())
+	if s.ref != "" {
+		u += "?ref=" + url.QueryEscape(s.ref)
+	}
+	req, err := s.client.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", rawMediaType)
+
+	var content strings.Builder
+	resp, err := s.client.Do(ctx, req, &content)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return "", errFileNotFound
+		}
+		return "", fmt.Errorf("error getting file contents: %w", err)
+	}
+	return content.String(), nil
+}
+
+// cloneSource reads the repository from a local clone.
+type cloneSource struct {
+	dir string
+	// env authenticates the git commands run in the clone.
+	env        []string
+	submodules []Submodule
+	warnings   []string
+}
+
+// newCloneSource clones the repository at ref. With history, the clone
+// has every commit but only the files of ref, instead of being shallow.
+// With sparse paths, only those directories (and the files at the root)
+// are checked out, the other blobs never being downloaded.
+func newCloneSource(ctx context.Context, token, owner, repo, ref string, history bool, sparse []string) (*cloneSource, error) {
+	dir, err := os.MkdirTemp("", "rgc-clone-")
+	if err != nil {
+		return nil, fmt.Errorf("error creating clone directory: %v", err)
+	}
+	env := gitAuthEnv(token)
+	git := func(what string, args ...string) error {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Env = env
+		if output, err := cmd.CombinedOutput(); err != nil {
+			os.RemoveAll(dir)
+			msg := strings.ReplaceAll(string(output), token, "***")
+			return fmt.Errorf("error %s: %v: %s", what, err, strings.TrimSpace(msg))
+		}
+		return nil
+	}
+
+	// The URL carries no token: it's kept in .git/config, which the
+	// verify command can read.
+	url := fmt.Sprintf("https://github.com/%s/%s.git", owner, repo)
+	args := []string{"clone", "--depth", "1", "--quiet"}
+	if history {
+		args = []string{"clone", "--filter=blob:none", "--quiet"}
+	}
+	if len(sparse) > 0 {
+		if !history {
+			args = append(args, "--filter=blob:none")
+		}
+		args = append(args, "--no-checkout")
+	}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	if err := git("cloning repository", append(args, url, dir)...); err != nil {
+		return nil, err
+	}
+	if len(sparse) > 0 {
+		if err := git("setting up sparse checkout", append([]string{"-C", dir, "sparse-checkout", "set", "--cone", "--"}, sparse...)...); err != nil {
+			return nil, err
+		}
+		if err := git("checking out repository", "-C", dir, "checkout", "--quiet"); err != nil {
+			return nil, err
+		}
+	}
+
+	return &cloneSource{dir: dir, env: env}, nil
+}
+
+// gitAuthEnv is the environment authenticating git's requests to GitHub
+// with token, through a header set in the environment rather than in the
+// clone's configuration or on the command line. SSH URLs of submodules are
+// fetched over HTTPS to use it.
+func gitAuthEnv(token string) []string {
+	basic := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + token))
+	return append(os.Environ(),
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_CONFIG_COUNT=2",
+		"GIT_CONFIG_KEY_0=http.https://github.com/.extraHeader",
+		"GIT_CONFIG_VALUE_0=Authorization: Basic "+basic,
+		"GIT_CONFIG_KEY_1=url.https://github.com/.insteadOf",
+		"GIT_CONFIG_VALUE_1=git@github.com:",
+	)
+}
+
+// validateSparsePaths checks the directories a sparse checkout asks for:
+// only clone mode supports them, and they must stay inside the repository.
+func validateSparsePaths(mode string, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	if mode != "clone" {
+		return errBadRequest("sparse_paths is only supported in clone mode")
+	}
+	for _, p := range paths {
+		clean := path.Clean(strings.Trim(p, "/"))
+		if p == "" || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") || strings.HasPrefix(p, "/") {
+			return errBadRequest("sparse_paths must be directories of the repository, got %q", p)
+		}
+	}
+	return nil
+}
+
+func (s *cloneSource) ListFiles(ctx context.Context) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Name() == ".git" {
+			// A directory at the root, a file in checked out submodules.
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error walking clone: %v", err)
+	}
+	return files, nil
+}
+
+func (s *cloneSource) ReadFile(ctx context.Context, path string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, filepath.FromSlash(path)))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", errFileNotFound
+		}
+		return "", fmt.Errorf("error reading file: %v", err)
+	}
+	return string(data), nil
+}
+
+// Close removes the clone from disk.
+func (s *cloneSource) Close() error {
+	return os.RemoveAll(s.dir)
+}
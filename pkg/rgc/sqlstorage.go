@@ -0,0 +1,897 @@
+package rgc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqlDialect holds what differs between the supported databases.
+type sqlDialect struct {
+	driver string
+	schema []string
+	// numbered placeholders ($1, $2...) instead of ?.
+	numbered bool
+}
+
+var sqliteDialect = sqlDialect{
+	driver: "sqlite3",
+	schema: []string{
+		`CREATE TABLE IF NOT EXISTS scans (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			repo_key TEXT NOT NULL,
+			owner TEXT NOT NULL,
+			repo TEXT NOT NULL,
+			ref TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			used_count INTEGER NOT NULL,
+			unused_count INTEGER NOT NULL,
+			test_only_count INTEGER NOT NULL,
+			storybook_only_count INTEGER NOT NULL,
+			e2e_only_count INTEGER NOT NULL,
+			result TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS scans_repo_key ON scans (repo_key, id)`,
+		`CREATE TABLE IF NOT EXISTS audit_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			` + auditEventsColumns + `
+		)`,
+		`CREATE INDEX IF NOT EXISTS audit_events_repo_key ON audit_events (repo_key, id)`,
+		schedulesTable,
+		scheduleNotificationsTable,
+		scheduleIssuesTable,
+		scheduleJiraTable,
+		scheduleTenantsTable,
+		apiKeysTable,
+		apiKeyTenantsTable,
+		subscribersTable,
+		tenantsTable,
+		tenantTokensTable,
+		sessionsTable,
+		parsedFilesTable,
+		scanOptionsTable,
+	},
+}
+
+var postgresDialect = sqlDialect{
+	driver:   "pgx",
+	numbered: true,
+	schema: []string{
+		`CREATE TABLE IF NOT EXISTS scans (
+			id BIGSERIAL PRIMARY KEY,
+			repo_key TEXT NOT NULL,
+			owner TEXT NOT NULL,
+			repo TEXT NOT NULL,
+			ref TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL,
+			used_count INTEGER NOT NULL,
+			unused_count INTEGER NOT NULL,
+			test_only_count INTEGER NOT NULL,
+			storybook_only_count INTEGER NOT NULL,
+			e2e_only_count INTEGER NOT NULL,
+			result JSONB NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS scans_repo_key ON scans (repo_key, id)`,
+		`CREATE TABLE IF NOT EXISTS audit_events (
+			id BIGSERIAL PRIMARY KEY,
+			` + auditEventsColumns + `
+		)`,
+		`CREATE INDEX IF NOT EXISTS audit_events_repo_key ON audit_events (repo_key, id)`,
+		schedulesTable,
+		scheduleNotificationsTable,
+		scheduleIssuesTable,
+		scheduleJiraTable,
+		scheduleTenantsTable,
+		apiKeysTable,
+		apiKeyTenantsTable,
+		subscribersTable,
+		tenantsTable,
+		tenantTokensTable,
+		sessionsTable,
+		parsedFilesTable,
+		scanOptionsTable,
+	},
+}
+
+// auditEventsColumns are the columns of audit_events besides its ID, the
+// token scopes as JSON.
+const auditEventsColumns = `created_at TIMESTAMP NOT NULL,
+			action TEXT NOT NULL,
+			actor TEXT NOT NULL,
+			tenant TEXT NOT NULL,
+			repo_key TEXT NOT NULL,
+			owner TEXT NOT NULL,
+			repo TEXT NOT NULL,
+			ref TEXT NOT NULL,
+			analysis_id TEXT NOT NULL,
+			token_source TEXT NOT NULL,
+			token_scopes TEXT NOT NULL,
+			pull_request TEXT NOT NULL`
+
+// schedulesTable only uses types both databases understand alike.
+const schedulesTable = `CREATE TABLE IF NOT EXISTS schedules (
+	repo_key TEXT PRIMARY KEY,
+	owner TEXT NOT NULL,
+	repo TEXT NOT NULL,
+	cron TEXT NOT NULL,
+	scan TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	last_run_at TIMESTAMP,
+	last_analysis_id TEXT NOT NULL,
+	last_error TEXT NOT NULL
+)`
+
+// scheduleNotificationsTable keeps the notifications of each schedule, as
+// JSON, apart from schedules so existing databases get them without a
+// migration.
+const scheduleNotificationsTable = `CREATE TABLE IF NOT EXISTS schedule_notifications (
+	repo_key TEXT PRIMARY KEY,
+	notify TEXT NOT NULL
+)`
+
+// scheduleIssuesTable keeps the issue filing of each schedule, as JSON.
+const scheduleIssuesTable = `CREATE TABLE IF NOT EXISTS schedule_issues (
+	repo_key TEXT PRIMARY KEY,
+	issues TEXT NOT NULL
+)`
+
+// scheduleJiraTable keeps the Jira filing of each schedule, as JSON.
+const scheduleJiraTable = `CREATE TABLE IF NOT EXISTS schedule_jira (
+	repo_key TEXT PRIMARY KEY,
+	jira TEXT NOT NULL
+)`
+
+// scheduleTenantsTable keeps the tenant whose token each schedule scans
+// with.
+const scheduleTenantsTable = `CREATE TABLE IF NOT EXISTS schedule_tenants (
+	repo_key TEXT PRIMARY KEY,
+	tenant TEXT NOT NULL
+)`
+
+// scanOptionsTable keeps the hash of the options each scan ran with.
+const scanOptionsTable = `CREATE TABLE IF NOT EXISTS scan_options (
+	scan_id BIGINT PRIMARY KEY,
+	options_hash TEXT NOT NULL
+)`
+
+const apiKeysTable = `CREATE TABLE IF NOT EXISTS api_keys (
+	name TEXT PRIMARY KEY,
+	prefix TEXT NOT NULL,
+	hash TEXT NOT NULL UNIQUE,
+	rate_limit INTEGER NOT NULL,
+	created_at TIMESTAMP NOT NULL
+)`
+
+// apiKeyTenantsTable keeps the tenant each API key belongs to, if any.
+const apiKeyTenantsTable = `CREATE TABLE IF NOT EXISTS api_key_tenants (
+	name TEXT PRIMARY KEY,
+	tenant TEXT NOT NULL
+)`
+
+// subscribersTable keeps digest subscriptions by lowercased email address.
+const subscribersTable = `CREATE TABLE IF NOT EXISTS subscribers (
+	email_key TEXT PRIMARY KEY,
+	email TEXT NOT NULL,
+	repos TEXT NOT NULL,
+	top_n INTEGER NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	last_sent_at TIMESTAMP
+)`
+
+// tenantsTable keeps the tenants and the repositories they registered, as
+// JSON.
+const tenantsTable = `CREATE TABLE IF NOT EXISTS tenants (
+	name TEXT PRIMARY KEY,
+	repos TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL
+)`
+
+// tenantTokensTable keeps the sealed provider tokens of the tenants, the
+// ciphertext encoded in base64.
+const tenantTokensTable = `CREATE TABLE IF NOT EXISTS tenant_tokens (
+	tenant TEXT NOT NULL,
+	provider TEXT NOT NULL,
+	key_id TEXT NOT NULL,
+	ciphertext TEXT NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (tenant, provider)
+)`
+
+// sessionsTable keeps the sessions of users logged in with GitHub, by
+// hash of their ID, with the token GitHub granted sealed.
+const sessionsTable = `CREATE TABLE IF NOT EXISTS sessions (
+	hash TEXT PRIMARY KEY,
+	login TEXT NOT NULL,
+	scopes TEXT NOT NULL,
+	key_id TEXT NOT NULL,
+	ciphertext TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL
+)`
+
+// parsedFilesTable caches parsed files by content hash, see parseFile.
+const parsedFilesTable = `CREATE TABLE IF NOT EXISTS parsed_files (
+	key TEXT PRIMARY KEY,
+	file TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL
+)`
+
+// rebind rewrites the ? placeholders of query for the dialect.
+func (d sqlDialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// sqlStorage keeps every analysis in a SQLite or PostgreSQL database, with
+// its counts in columns and the full result as JSON.
+type sqlStorage struct {
+	db      *sql.DB
+	dialect sqlDialect
+}
+
+func openSQLStorage(ctx context.Context, dialect sqlDialect, dsn string) (*sqlStorage, error) {
+	db, err := sql.Open(dialect.driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %v", err)
+	}
+	if dialect.driver == "sqlite3" {
+		// SQLite allows a single writer; serializing avoids "database is locked".
+		db.SetMaxOpenConns(1)
+	}
+	for _, stmt := range dialect.schema {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("error creating database schema: %v", err)
+		}
+	}
+	return &sqlStorage{db: db, dialect: dialect}, nil
+}
+
+const scanSummaryColumns = `id, owner, repo, ref, created_at, used_count, unused_count, test_only_count, storybook_only_count, e2e_only_count`
+
+func (s *sqlStorage) Save(ctx context.Context, a *Analysis) error {
+	result, err := json.Marshal(a.Result)
+	if err != nil {
+		return fmt.Errorf("error encoding analysis: %v", err)
+	}
+	sum := a.summary()
+	var id int64
+	err = s.db.QueryRowContext(ctx, s.dialect.rebind(`INSERT INTO scans
+		(repo_key, owner, repo, ref, created_at, used_count, unused_count, test_only_count, storybook_only_count, e2e_only_count, result)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`),
+		repoKey(a.Owner, a.Repo), a.Owner, a.Repo, a.Ref, a.CreatedAt,
+		sum.UsedCount, sum.UnusedCount, sum.TestOnlyCount, sum.StorybookOnlyCount, sum.E2EOnlyCount,
+		string(result)).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("error saving analysis: %v", err)
+	}
+	if a.OptionsHash != "" {
+		_, err = s.db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO scan_options (scan_id, options_hash) VALUES (?, ?)`), id, a.OptionsHash)
+		if err != nil {
+			return fmt.Errorf("error saving analysis: %v", err)
+		}
+	}
+	a.ID = strconv.FormatInt(id, 10)
+	return nil
+}
+
+func (s *sqlStorage) Get(ctx context.Context, owner, repo, id string) (*Analysis, error) {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, errAnalysisNotFound
+	}
+	a, err := s.queryAnalysis(ctx, `WHERE repo_key = ? AND id = ?`, repoKey(owner, repo), n)
+	if err == nil && a == nil {
+		err = errAnalysisNotFound
+	}
+	return a, err
+}
+
+func (s *sqlStorage) After(ctx context.Context, since *Analysis) (*Analysis, error) {
+	n, _ := strconv.ParseInt(since.ID, 10, 64)
+	return s.queryAnalysis(ctx, `WHERE repo_key = ? AND id > ? ORDER BY id LIMIT 1`, repoKey(since.Owner, since.Repo), n)
+}
+
+func (s *sqlStorage) FindByRef(ctx context.Context, owner, repo, ref, optionsHash string) (*Analysis, error) {
+	return s.queryAnalysis(ctx, `WHERE repo_key = ? AND ref = ? AND o.options_hash = ? ORDER BY id DESC LIMIT 1`,
+		repoKey(owner, repo), ref, optionsHash)
+}
+
+// queryAnalysis returns the first analysis matching where, or nil. where
+// may look at the options hash as o.options_hash.
+func (s *sqlStorage) queryAnalysis(ctx context.Context, where string, args ...any) (*Analysis, error) {
+	row := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT id, owner, repo, ref, created_at, COALESCE(o.options_hash, ''), result
+		FROM scans LEFT JOIN scan_options o ON o.scan_id = scans.id `+where), args...)
+	var (
+		a      Analysis
+		id     int64
+		result []byte
+	)
+	if err := row.Scan(&id, &a.Owner, &a.Repo, &a.Ref, &a.CreatedAt, &a.OptionsHash, &result); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading analysis: %v", err)
+	}
+	a.ID = strconv.FormatInt(id, 10)
+	a.CreatedAt = a.CreatedAt.UTC()
+	if err := json.Unmarshal(result, &a.Result); err != nil {
+		return nil, fmt.Errorf("error decoding analysis %s: %v", a.ID, err)
+	}
+	return &a, nil
+}
+
+func (s *sqlStorage) List(ctx context.Context, owner, repo string, limit int) ([]ScanSummary, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(`SELECT `+scanSummaryColumns+` FROM scans
+		WHERE repo_key = ? ORDER BY id DESC LIMIT ?`), repoKey(owner, repo), limit)
+	if err != nil {
+		return nil, fmt.Errorf("error listing analyses: %v", err)
+	}
+	defer rows.Close()
+
+	scans := []ScanSummary{}
+	for rows.Next() {
+		var (
+			sum ScanSummary
+			id  int64
+		)
+		if err := rows.Scan(&id, &sum.Owner, &sum.Repo, &sum.Ref, &sum.CreatedAt, &sum.UsedCount, &sum.UnusedCount,
+			&sum.TestOnlyCount, &sum.StorybookOnlyCount, &sum.E2EOnlyCount); err != nil {
+			return nil, fmt.Errorf("error listing analyses: %v", err)
+		}
+		sum.ID = strconv.FormatInt(id, 10)
+		sum.CreatedAt = sum.CreatedAt.UTC()
+		scans = append(scans, sum)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing analyses: %v", err)
+	}
+	return scans, nil
+}
+
+func (s *sqlStorage) SaveSchedule(ctx context.Context, sched *Schedule) error {
+	scan, err := json.Marshal(sched.Scan)
+	if err != nil {
+		return fmt.Errorf("error encoding schedule: %v", err)
+	}
+	var lastRun sql.NullTime
+	if sched.LastRunAt != nil {
+		lastRun = sql.NullTime{Time: *sched.LastRunAt, Valid: true}
+	}
+	_, err = s.db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO schedules
+		(repo_key, owner, repo, cron, scan, created_at, last_run_at, last_analysis_id, last_error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (repo_key) DO UPDATE SET owner = excluded.owner, repo = excluded.repo, cron = excluded.cron,
+			scan = excluded.scan, created_at = excluded.created_at, last_run_at = excluded.last_run_at,
+			last_analysis_id = excluded.last_analysis_id, last_error = excluded.last_error`),
+		repoKey(sched.Owner, sched.Repo), sched.Owner, sched.Repo, sched.Cron, string(scan), sched.CreatedAt,
+		lastRun, sched.LastAnalysisID, sched.LastError)
+	if err != nil {
+		return fmt.Errorf("error saving schedule: %v", err)
+	}
+	if err := s.saveScheduleNotifications(ctx, sched); err != nil {
+		return fmt.Errorf("error saving schedule: %v", err)
+	}
+	if err := s.saveScheduleIssues(ctx, sched); err != nil {
+		return fmt.Errorf("error saving schedule: %v", err)
+	}
+	if err := s.saveScheduleJira(ctx, sched); err != nil {
+		return fmt.Errorf("error saving schedule: %v", err)
+	}
+	if err := s.saveScheduleTenant(ctx, sched); err != nil {
+		return fmt.Errorf("error saving schedule: %v", err)
+	}
+	return nil
+}
+
+func (s *sqlStorage) saveScheduleNotifications(ctx context.Context, sched *Schedule) error {
+	key := repoKey(sched.Owner, sched.Repo)
+	if len(sched.Notify) == 0 {
+		_, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM schedule_notifications WHERE repo_key = ?`), key)
+		return err
+	}
+	notify, err := json.Marshal(sched.Notify)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO schedule_notifications (repo_key, notify) VALUES (?, ?)
+		ON CONFLICT (repo_key) DO UPDATE SET notify = excluded.notify`), key, string(notify))
+	return err
+}
+
+func (s *sqlStorage) saveScheduleIssues(ctx context.Context, sched *Schedule) error {
+	key := repoKey(sched.Owner, sched.Repo)
+	if sched.Issues == nil {
+		_, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM schedule_issues WHERE repo_key = ?`), key)
+		return err
+	}
+	issues, err := json.Marshal(sched.Issues)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO schedule_issues (repo_key, issues) VALUES (?, ?)
+		ON CONFLICT (repo_key) DO UPDATE SET issues = excluded.issues`), key, string(issues))
+	return err
+}
+
+func (s *sqlStorage) saveScheduleJira(ctx context.Context, sched *Schedule) error {
+	key := repoKey(sched.Owner, sched.Repo)
+	if sched.Jira == nil {
+		_, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM schedule_jira WHERE repo_key = ?`), key)
+		return err
+	}
+	jira, err := json.Marshal(sched.Jira)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO schedule_jira (repo_key, jira) VALUES (?, ?)
+		ON CONFLICT (repo_key) DO UPDATE SET jira = excluded.jira`), key, string(jira))
+	return err
+}
+
+func (s *sqlStorage) saveScheduleTenant(ctx context.Context, sched *Schedule) error {
+	key := repoKey(sched.Owner, sched.Repo)
+	if sched.Tenant == "" {
+		_, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM schedule_tenants WHERE repo_key = ?`), key)
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO schedule_tenants (repo_key, tenant) VALUES (?, ?)
+		ON CONFLICT (repo_key) DO UPDATE SET tenant = excluded.tenant`), key, sched.Tenant)
+	return err
+}
+
+func (s *sqlStorage) DeleteSchedule(ctx context.Context, owner, repo string) error {
+	for _, table := range []string{"schedules", "schedule_notifications", "schedule_issues", "schedule_jira", "schedule_tenants"} {
+		if _, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM `+table+` WHERE repo_key = ?`), repoKey(owner, repo)); err != nil {
+			return fmt.Errorf("error deleting schedule: %v", err)
+		}
+	}
+	return nil
+}
+
+func (s *sqlStorage) Schedules(ctx context.Context) ([]*Schedule, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT s.owner, s.repo, s.cron, s.scan, s.created_at, s.last_run_at, s.last_analysis_id, s.last_error, n.notify, i.issues, j.jira, t.tenant
+		FROM schedules s LEFT JOIN schedule_notifications n ON n.repo_key = s.repo_key
+		LEFT JOIN schedule_issues i ON i.repo_key = s.repo_key
+		LEFT JOIN schedule_jira j ON j.repo_key = s.repo_key
+		LEFT JOIN schedule_tenants t ON t.repo_key = s.repo_key`)
+	if err != nil {
+		return nil, fmt.Errorf("error listing schedules: %v", err)
+	}
+	defer rows.Close()
+
+	var schedules []*Schedule
+	for rows.Next() {
+		var (
+			sched   Schedule
+			scan    []byte
+			lastRun sql.NullTime
+			notify  sql.NullString
+			issues  sql.NullString
+			jira    sql.NullString
+			tenant  sql.NullString
+		)
+		if err := rows.Scan(&sched.Owner, &sched.Repo, &sched.Cron, &scan, &sched.CreatedAt, &lastRun,
+			&sched.LastAnalysisID, &sched.LastError, &notify, &issues, &jira, &tenant); err != nil {
+			return nil, fmt.Errorf("error listing schedules: %v", err)
+		}
+		if err := json.Unmarshal(scan, &sched.Scan); err != nil {
+			return nil, fmt.Errorf("error decoding schedule of %s/%s: %v", sched.Owner, sched.Repo, err)
+		}
+		if notify.Valid {
+			if err := json.Unmarshal([]byte(notify.String), &sched.Notify); err != nil {
+				return nil, fmt.Errorf("error decoding schedule of %s/%s: %v", sched.Owner, sched.Repo, err)
+			}
+		}
+		if issues.Valid {
+			if err := json.Unmarshal([]byte(issues.String), &sched.Issues); err != nil {
+				return nil, fmt.Errorf("error decoding schedule of %s/%s: %v", sched.Owner, sched.Repo, err)
+			}
+		}
+		if jira.Valid {
+			if err := json.Unmarshal([]byte(jira.String), &sched.Jira); err != nil {
+				return nil, fmt.Errorf("error decoding schedule of %s/%s: %v", sched.Owner, sched.Repo, err)
+			}
+		}
+		sched.Tenant = tenant.String
+		sched.CreatedAt = sched.CreatedAt.UTC()
+		if lastRun.Valid {
+			t := lastRun.Time.UTC()
+			sched.LastRunAt = &t
+		}
+		schedules = append(schedules, &sched)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing schedules: %v", err)
+	}
+	return schedules, nil
+}
+
+func (s *sqlStorage) SaveAPIKey(ctx context.Context, k *APIKey) error {
+	_, err := s.db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO api_keys (name, prefix, hash, rate_limit, created_at)
+		VALUES (?, ?, ?, ?, ?)`), k.Name, k.Prefix, k.Hash, k.RateLimit, *k.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error saving API key: %v", err)
+	}
+	if k.Tenant != "" {
+		_, err := s.db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO api_key_tenants (name, tenant) VALUES (?, ?)
+			ON CONFLICT (name) DO UPDATE SET tenant = excluded.tenant`), k.Name, k.Tenant)
+		if err != nil {
+			return fmt.Errorf("error saving API key: %v", err)
+		}
+	}
+	return nil
+}
+
+func (s *sqlStorage) DeleteAPIKey(ctx context.Context, name string) error {
+	for _, table := range []string{"api_keys", "api_key_tenants"} {
+		if _, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM `+table+` WHERE name = ?`), name); err != nil {
+			return fmt.Errorf("error deleting API key: %v", err)
+		}
+	}
+	return nil
+}
+
+const apiKeyColumns = `k.name, k.prefix, k.hash, k.rate_limit, k.created_at, t.tenant
+	FROM api_keys k LEFT JOIN api_key_tenants t ON t.name = k.name`
+
+func (s *sqlStorage) APIKeys(ctx context.Context) ([]*APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+apiKeyColumns)
+	if err != nil {
+		return nil, fmt.Errorf("error listing API keys: %v", err)
+	}
+	defer rows.Close()
+
+	var keys []*APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error listing API keys: %v", err)
+		}
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing API keys: %v", err)
+	}
+	return keys, nil
+}
+
+func (s *sqlStorage) FindAPIKey(ctx context.Context, hash string) (*APIKey, error) {
+	k, err := scanAPIKey(s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT `+apiKeyColumns+` WHERE k.hash = ?`), hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error looking up API key: %v", err)
+	}
+	return k, nil
+}
+
+func scanAPIKey(row interface{ Scan(...any) error }) (*APIKey, error) {
+	var (
+		k         APIKey
+		createdAt time.Time
+		tenant    sql.NullString
+	)
+	if err := row.Scan(&k.Name, &k.Prefix, &k.Hash, &k.RateLimit, &createdAt, &tenant); err != nil {
+		return nil, err
+	}
+	k.Tenant = tenant.String
+	createdAt = createdAt.UTC()
+	k.CreatedAt, k.Source = &createdAt, "database"
+	return &k, nil
+}
+
+func (s *sqlStorage) SaveSubscriber(ctx context.Context, sub *Subscriber) error {
+	repos, err := json.Marshal(sub.Repos)
+	if err != nil {
+		return fmt.Errorf("error encoding subscriber: %v", err)
+	}
+	var lastSent sql.NullTime
+	if sub.LastSentAt != nil {
+		lastSent = sql.NullTime{Time: *sub.LastSentAt, Valid: true}
+	}
+	_, err = s.db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO subscribers (email_key, email, repos, top_n, created_at, last_sent_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (email_key) DO UPDATE SET email = excluded.email, repos = excluded.repos, top_n = excluded.top_n,
+			created_at = excluded.created_at, last_sent_at = excluded.last_sent_at`),
+		strings.ToLower(sub.Email), sub.Email, string(repos), sub.Top, sub.CreatedAt, lastSent)
+	if err != nil {
+		return fmt.Errorf("error saving subscriber: %v", err)
+	}
+	return nil
+}
+
+func (s *sqlStorage) DeleteSubscriber(ctx context.Context, email string) error {
+	if _, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM subscribers WHERE email_key = ?`), strings.ToLower(email)); err != nil {
+		return fmt.Errorf("error deleting subscriber: %v", err)
+	}
+	return nil
+}
+
+func (s *sqlStorage) Subscribers(ctx context.Context) ([]*Subscriber, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT email, repos, top_n, created_at, last_sent_at FROM subscribers`)
+	if err != nil {
+		return nil, fmt.Errorf("error listing subscribers: %v", err)
+	}
+	defer rows.Close()
+
+	subscribers := []*Subscriber{}
+	for rows.Next() {
+		var (
+			sub      Subscriber
+			repos    []byte
+			lastSent sql.NullTime
+		)
+		if err := rows.Scan(&sub.Email, &repos, &sub.Top, &sub.CreatedAt, &lastSent); err != nil {
+			return nil, fmt.Errorf("error listing subscribers: %v", err)
+		}
+		if err := json.Unmarshal(repos, &sub.Repos); err != nil {
+			return nil, fmt.Errorf("error decoding subscriber %s: %v", sub.Email, err)
+		}
+		sub.CreatedAt = sub.CreatedAt.UTC()
+		if lastSent.Valid {
+			t := lastSent.Time.UTC()
+			sub.LastSentAt = &t
+		}
+		subscribers = append(subscribers, &sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing subscribers: %v", err)
+	}
+	return subscribers, nil
+}
+
+func (s *sqlStorage) SaveTenant(ctx context.Context, t *Tenant) error {
+	repos, err := json.Marshal(t.Repos)
+	if err != nil {
+		return fmt.Errorf("error encoding tenant: %v", err)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error saving tenant: %v", err)
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, s.dialect.rebind(`INSERT INTO tenants (name, repos, created_at) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET repos = excluded.repos, created_at = excluded.created_at`),
+		t.Name, string(repos), t.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error saving tenant: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, s.dialect.rebind(`DELETE FROM tenant_tokens WHERE tenant = ?`), t.Name); err != nil {
+		return fmt.Errorf("error saving tenant: %v", err)
+	}
+	for provider, sealed := range t.Tokens {
+		_, err := tx.ExecContext(ctx, s.dialect.rebind(`INSERT INTO tenant_tokens (tenant, provider, key_id, ciphertext, updated_at)
+			VALUES (?, ?, ?, ?, ?)`), t.Name, provider, sealed.KeyID, base64.StdEncoding.EncodeToString(sealed.Ciphertext), sealed.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("error saving tenant: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error saving tenant: %v", err)
+	}
+	return nil
+}
+
+func (s *sqlStorage) DeleteTenant(ctx context.Context, name string) error {
+	if _, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM tenant_tokens WHERE tenant = ?`), name); err != nil {
+		return fmt.Errorf("error deleting tenant: %v", err)
+	}
+	if _, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM tenants WHERE name = ?`), name); err != nil {
+		return fmt.Errorf("error deleting tenant: %v", err)
+	}
+	return nil
+}
+
+func (s *sqlStorage) Tenants(ctx context.Context) ([]*Tenant, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT name, repos, created_at FROM tenants`)
+	if err != nil {
+		return nil, fmt.Errorf("error listing tenants: %v", err)
+	}
+	defer rows.Close()
+
+	tenants := []*Tenant{}
+	byName := make(map[string]*Tenant)
+	for rows.Next() {
+		var (
+			t     Tenant
+			repos []byte
+		)
+		if err := rows.Scan(&t.Name, &repos, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error listing tenants: %v", err)
+		}
+		if err := json.Unmarshal(repos, &t.Repos); err != nil {
+			return nil, fmt.Errorf("error decoding tenant %s: %v", t.Name, err)
+		}
+		t.CreatedAt = t.CreatedAt.UTC()
+		t.Tokens = make(map[string]*SealedToken)
+		tenants = append(tenants, &t)
+		byName[t.Name] = &t
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing tenants: %v", err)
+	}
+	rows.Close()
+
+	rows, err = s.db.QueryContext(ctx, `SELECT tenant, provider, key_id, ciphertext, updated_at FROM tenant_tokens`)
+	if err != nil {
+		return nil, fmt.Errorf("error listing tenant tokens: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			tenant, provider, ciphertext string
+			sealed                       SealedToken
+		)
+		if err := rows.Scan(&tenant, &provider, &sealed.KeyID, &ciphertext, &sealed.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("error listing tenant tokens: %v", err)
+		}
+		t, ok := byName[tenant]
+		if !ok {
+			continue
+		}
+		if sealed.Ciphertext, err = base64.StdEncoding.DecodeString(ciphertext); err != nil {
+			return nil, fmt.Errorf("error decoding the %s token of tenant %s: %v", provider, tenant, err)
+		}
+		sealed.UpdatedAt = sealed.UpdatedAt.UTC()
+		t.Tokens[provider] = &sealed
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing tenant tokens: %v", err)
+	}
+	return tenants, nil
+}
+
+// SaveSession also drops the expired sessions.
+func (s *sqlStorage) SaveSession(ctx context.Context, session *Session) error {
+	if _, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM sessions WHERE expires_at < ?`), time.Now().UTC()); err != nil {
+		return fmt.Errorf("error saving session: %v", err)
+	}
+	_, err := s.db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO sessions (hash, login, scopes, key_id, ciphertext, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`), session.Hash, session.Login, strings.Join(session.Scopes, ","), session.Token.KeyID,
+		base64.StdEncoding.EncodeToString(session.Token.Ciphertext), session.CreatedAt, session.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("error saving session: %v", err)
+	}
+	return nil
+}
+
+func (s *sqlStorage) FindSession(ctx context.Context, hash string) (*Session, error) {
+	var (
+		session    = Session{Hash: hash, Token: &SealedToken{}}
+		scopes     string
+		ciphertext string
+	)
+	err := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT login, scopes, key_id, ciphertext, created_at, expires_at
+		FROM sessions WHERE hash = ?`), hash).Scan(&session.Login, &scopes, &session.Token.KeyID, &ciphertext,
+		&session.CreatedAt, &session.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error looking up session: %v", err)
+	}
+	if session.Token.Ciphertext, err = base64.StdEncoding.DecodeString(ciphertext); err != nil {
+		return nil, fmt.Errorf("error decoding the token of %s's session: %v", session.Login, err)
+	}
+	session.Scopes = []string{}
+	if scopes != "" {
+		session.Scopes = strings.Split(scopes, ",")
+	}
+	session.CreatedAt, session.ExpiresAt = session.CreatedAt.UTC(), session.ExpiresAt.UTC()
+	session.Token.UpdatedAt = session.CreatedAt
+	return &session, nil
+}
+
+func (s *sqlStorage) DeleteSession(ctx context.Context, hash string) error {
+	if _, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM sessions WHERE hash = ?`), hash); err != nil {
+		return fmt.Errorf("error deleting session: %v", err)
+	}
+	return nil
+}
+
+func (s *sqlStorage) SaveAuditEvent(ctx context.Context, e *AuditEvent) error {
+	scopes, err := json.Marshal(e.TokenScopes)
+	if err != nil {
+		return fmt.Errorf("error encoding audit event: %v", err)
+	}
+	var id int64
+	err = s.db.QueryRowContext(ctx, s.dialect.rebind(`INSERT INTO audit_events
+		(created_at, action, actor, tenant, repo_key, owner, repo, ref, analysis_id, token_source, token_scopes, pull_request)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`),
+		e.Time, e.Action, e.Actor, e.Tenant, repoKey(e.Owner, e.Repo), e.Owner, e.Repo, e.Ref, e.AnalysisID,
+		e.TokenSource, string(scopes), e.PullRequest).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("error saving audit event: %v", err)
+	}
+	e.ID = strconv.FormatInt(id, 10)
+	return nil
+}
+
+func (s *sqlStorage) AuditEvents(ctx context.Context, q AuditQuery) ([]*AuditEvent, error) {
+	where, args := []string{"created_at >= ?"}, []any{q.Since}
+	if q.Owner != "" {
+		where, args = append(where, "repo_key = ?"), append(args, repoKey(q.Owner, q.Repo))
+	}
+	if q.Action != "" {
+		where, args = append(where, "action = ?"), append(args, q.Action)
+	}
+	if q.Actor != "" {
+		where, args = append(where, "actor = ?"), append(args, q.Actor)
+	}
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(`SELECT id, created_at, action, actor, tenant, owner, repo, ref,
+		analysis_id, token_source, token_scopes, pull_request FROM audit_events
+		WHERE `+strings.Join(where, " AND ")+` ORDER BY id DESC LIMIT ?`), append(args, q.Limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing audit events: %v", err)
+	}
+	defer rows.Close()
+
+	events := []*AuditEvent{}
+	for rows.Next() {
+		var (
+			e      AuditEvent
+			id     int64
+			scopes string
+		)
+		if err := rows.Scan(&id, &e.Time, &e.Action, &e.Actor, &e.Tenant, &e.Owner, &e.Repo, &e.Ref,
+			&e.AnalysisID, &e.TokenSource, &scopes, &e.PullRequest); err != nil {
+			return nil, fmt.Errorf("error listing audit events: %v", err)
+		}
+		if err := json.Unmarshal([]byte(scopes), &e.TokenScopes); err != nil {
+			return nil, fmt.Errorf("error decoding audit event %d: %v", id, err)
+		}
+		e.ID = strconv.FormatInt(id, 10)
+		e.Time = e.Time.UTC()
+		events = append(events, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing audit events: %v", err)
+	}
+	return events, nil
+}
+
+func (s *sqlStorage) SaveParsedFile(ctx context.Context, key string, file []byte) error {
+	_, err := s.db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO parsed_files (key, file, created_at)
+		VALUES (?, ?, ?) ON CONFLICT (key) DO NOTHING`), key, string(file), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("error saving parsed file: %v", err)
+	}
+	return nil
+}
+
+func (s *sqlStorage) ParsedFile(ctx context.Context, key string) ([]byte, error) {
+	var file []byte
+	err := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT file FROM parsed_files WHERE key = ?`), key).Scan(&file)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading parsed file: %v", err)
+	}
+	return file, nil
+}
+
+func (s *sqlStorage) Close() error { return s.db.Close() }
@@ -0,0 +1,66 @@
+package rgc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/go-github/v39/github"
+)
+
+// statusContext identifies RGC's commit status among a commit's checks.
+const statusContext = "rgc/unused-components"
+
+// statusDescription summarizes the unused component count for a commit
+// status, with the change since the previous analysis when there is one,
+// e.g. "14 unused components (+2)".
+func statusDescription(unused int, previous *ScanSummary) string {
+	desc := fmt.Sprintf("%d unused components", unused)
+	if unused == 1 {
+		desc = "1 unused component"
+	}
+	if previous != nil {
+		desc += fmt.Sprintf(" (%+d)", unused-previous.UnusedCount)
+	}
+	return desc
+}
+
+// reportURL links to an analysis on this server. It needs RGC_PUBLIC_URL,
+// the address the server is reachable at, and is empty without it.
+func reportURL(owner, repo, id string) string {
+	base := strings.TrimSuffix(os.Getenv("RGC_PUBLIC_URL"), "/")
+	if base == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/repos/%s/%s/scans/%s", base, owner, repo, id)
+}
+
+// postCommitStatus sets RGC's status on the analyzed commit, summarizing
+// the unused components against the analysis before it.
+func postCommitStatus(ctx context.Context, token string, a *Analysis, previous *ScanSummary) error {
+	if a.Ref == "" {
+		return fmt.Errorf("the analysis has no commit to post a status on")
+	}
+	client := newGitHubClient(token)
+	info, err := inspectToken(ctx, client)
+	if err != nil {
+		return err
+	}
+	if err := info.check(a.Result.Meta != nil && a.Result.Meta.Private, opWriteStatuses); err != nil {
+		return err
+	}
+
+	status := &github.RepoStatus{
+		State:       github.String("success"),
+		Description: github.String(statusDescription(a.Result.UnusedCount, previous)),
+		Context:     github.String(statusContext),
+	}
+	if url := reportURL(a.Owner, a.Repo, a.ID); url != "" {
+		status.TargetURL = github.String(url)
+	}
+	if _, _, err := client.Repositories.CreateStatus(ctx, a.Owner, a.Repo, a.Ref, status); err != nil {
+		return fmt.Errorf("error posting commit status: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package rgc
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/igorfelipeduca/rgc/internal/jsparse"
+	"golang.org/x/sync/errgroup"
+)
+
+// StyledEntry is a styled component declared in a module of the repository.
+type StyledEntry struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	// Base is the element or component it styles, e.g. "button".
+	Base string `json:"base"`
+	// Library is "styled-components" or "emotion".
+	Library string `json:"library"`
+	// UsedBy lists the files using it, its own file included.
+	UsedBy []string `json:"used_by,omitempty"`
+}
+
+// StyledReport tells which styled components are still used.
+type StyledReport struct {
+	UsedCount   int           `json:"used_count"`
+	UnusedCount int           `json:"unused_count"`
+	Used        []StyledEntry `json:"used"`
+	Unused      []StyledEntry `json:"unused"`
+}
+
+// styledUse records the names a file uses from a module.
+type styledUse struct {
+	importer string
+	names    []string
+}
+
+// styledReport finds the styled components declared in the shipped modules
+// and tells them apart by usage: rendered or referenced in their own file,
+// or imported by another one, by name, through a namespace (S.Wrapper) or
+// through a re-export. Only live files count: the reachable components and
+// the other modules they import, directly or not. Tests and stories don't.
+func (sc *scan) styledReport(ctx context.Context, reachable map[string]bool) (*StyledReport, error) {
+	declared := make(map[string][]jsparse.StyledComponent)
+	uses := make(map[string][]styledUse)
+	imports := make(map[string][]string)
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(sc.concurrency)
+	for _, p := range sc.files {
+		if !jsparse.Supported(p) || isSupportFile(p) || sc.isGenerated(p) {
+			continue
+		}
+		eg.Go(func() error {
+			content, err := sc.src.ReadFile(ctx, p)
+			if err != nil {
+				if err == errFileNotFound {
+					return nil
+				}
+				return err
+			}
+			file, err := parseFile(ctx, p, content)
+			if err != nil {
+				return &ParseError{Path: p, Err: err}
+			}
+			var styled []jsparse.StyledComponent
+			if strings.Contains(content, "styled") {
+				if styled, err = jsparse.StyledComponents(ctx, p, []byte(content)); err != nil {
+					return &ParseError{Path: p, Err: err}
+				}
+			}
+
+			sc.mu.Lock()
+			defer sc.mu.Unlock()
+			if len(styled) > 0 {
+				declared[p] = styled
+			}
+			for _, imp := range file.Imports {
+				if !imp.IsRuntime() {
+					continue
+				}
+				target := sc.resolveModule(p, imp.Specifier)
+				if target == "" {
+					continue
+				}
+				imports[p] = append(imports[p], target)
+				if imp.Kind == jsparse.SideEffect {
+					continue
+				}
+				var names []string
+				for _, name := range importedNames(imp) {
+					if name != "*" || imp.Kind != jsparse.Static {
+						names = append(names, name)
+						continue
+					}
+					// import * as S from './styles' uses what S.Name reaches.
+					for _, b := range imp.ValueBindings() {
+						if b.Imported == "*" {
+							names = append(names, namespaceMembers(content, b.Local)...)
+						}
+					}
+				}
+				uses[target] = append(uses[target], styledUse{importer: p, names: names})
+			}
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	live := sc.liveModules(reachable, imports)
+	report := &StyledReport{Used: []StyledEntry{}, Unused: []StyledEntry{}}
+	for p, styled := range declared {
+		for _, s := range styled {
+			entry := StyledEntry{Name: s.Name, Path: p, Line: s.Line, Base: s.Base, Library: s.Library}
+			if s.LocalUses > 0 && live[p] {
+				entry.UsedBy = append(entry.UsedBy, p)
+			}
+			if s.Exported {
+				for _, use := range uses[p] {
+					used := slices.Contains(use.names, s.Name) || slices.Contains(use.names, "*") ||
+						s.Default && slices.Contains(use.names, "default")
+					if used && live[use.importer] && !slices.Contains(entry.UsedBy, use.importer) {
+						entry.UsedBy = append(entry.UsedBy, use.importer)
+					}
+				}
+			}
+			if len(entry.UsedBy) > 0 {
+				report.Used = append(report.Used, entry)
+			} else {
+				report.Unused = append(report.Unused, entry)
+			}
+		}
+	}
+	for _, entries := range [][]StyledEntry{report.Used, report.Unused} {
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].Path != entries[j].Path {
+				return entries[i].Path < entries[j].Path
+			}
+			return entries[i].Line < entries[j].Line
+		})
+	}
+	report.UsedCount = len(report.Used)
+	report.UnusedCount = len(report.Unused)
+	return report, nil
+}
+
+// liveModules returns the reachable components and the modules that aren't
+// components they import, following imports from module to module. A dead
+// component imported by a live module stays dead.
+func (sc *scan) liveModules(reachable map[string]bool, imports map[string][]string) map[string]bool {
+	live := make(map[string]bool, len(reachable))
+	queue := make([]string, 0, len(reachable))
+	for p := range reachable {
+		live[p] = true
+		queue = append(queue, p)
+	}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		for _, target := range imports[next] {
+			if _, component := sc.createdComponents[target]; component || live[target] {
+				continue
+			}
+			live[target] = true
+			queue = append(queue, target)
+		}
+	}
+	return live
+}
+
+// namespaceMembers returns the names accessed on a namespace import in
+// content, e.g. Wrapper for S.Wrapper.
+func namespaceMembers(content, namespace string) []string {
+	re := regexp.MustCompile(`\b` + regexp.QuoteMeta(namespace) + `\.([A-Za-z_$][\w$]*)`)
+	var names []string
+	for _, m := range re.FindAllStringSubmatch(content, -1) {
+		if !slices.Contains(names, m[1]) {
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
@@ -0,0 +1,280 @@
+package rgc
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Submodule is a Git submodule of the analyzed repository.
+type Submodule struct {
+	Path   string `json:"path"`
+	URL    string `json:"url,omitempty"`
+	Commit string `json:"commit"`
+	// Scanned is set when the submodule's files are part of the analysis.
+	Scanned bool `json:"scanned"`
+}
+
+func validateSubmodules(mode string) error {
+	switch mode {
+	case "", "skip", "recurse":
+		return nil
+	}
+	return errBadRequest("submodules must be skip or recurse")
+}
+
+// parseGitmodules returns the URL of every submodule a .gitmodules file
+// declares, by path.
+func parseGitmodules(content string) map[string]string {
+	urls := make(map[string]string)
+	var p, u string
+	flush := func() {
+		if p != "" && u != "" {
+			urls[p] = u
+		}
+		p, u = "", ""
+	}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			flush()
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "path":
+			p = strings.Trim(strings.TrimSpace(value), "/")
+		case "url":
+			u = strings.TrimSpace(value)
+		}
+	}
+	flush()
+	return urls
+}
+
+// githubRepoOf returns the GitHub repository a submodule URL points to.
+// Relative URLs, such as "../ui.git", are resolved against the repository
+// owning the submodule.
+func githubRepoOf(owner, rawURL string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(rawURL, "../")
+	if !ok {
+		for _, prefix := range []string{"https://github.com/", "http://github.com/", "git@github.com:", "ssh://git@github.com/", "git://github.com/"} {
+			if rest, ok = strings.CutPrefix(rawURL, prefix); ok {
+				break
+			}
+		}
+		if !ok {
+			return "", "", false
+		}
+	}
+	rest = strings.TrimSuffix(strings.TrimSuffix(rest, "/"), ".git")
+	parts := strings.Split(rest, "/")
+	switch {
+	case len(parts) == 1 && strings.HasPrefix(rawURL, "../"):
+		return owner, parts[0], parts[0] != ""
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		return parts[0], parts[1], true
+	}
+	return "", "", false
+}
+
+// submodules pairs the submodule commits a listing found with the URLs of
+// the repository's .gitmodules.
+func submodules(ctx context.Context, src Source, commits map[string]string) ([]Submodule, error) {
+	if len(commits) == 0 {
+		return nil, nil
+	}
+	content, err := src.ReadFile(ctx, ".gitmodules")
+	if err != nil && err != errFileNotFound {
+		return nil, err
+	}
+	urls := parseGitmodules(content)
+	list := make([]Submodule, 0, len(commits))
+	for p, commit := range commits {
+		list = append(list, Submodule{Path: p, URL: urls[p], Commit: commit})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list, nil
+}
+
+// submoduleSource reads a repository through the GitHub API along with,
+// when recursing, the GitHub repositories its submodules point to, their
+// files listed under the submodule's path. Submodules of submodules aren't
+// followed.
+type submoduleSource struct {
+	*githubSource
+	recurse bool
+
+	mu         sync.Mutex
+	mounts     map[string]*githubSource
+	submodules []Submodule
+	warnings   []string
+}
+
+func newSubmoduleSource(src *githubSource, recurse bool) *submoduleSource {
+	return &submoduleSource{githubSource: src, recurse: recurse, mounts: make(map[string]*githubSource)}
+}
+
+func (s *submoduleSource) ListFiles(ctx context.Context) ([]string, error) {
+	files, err := s.githubSource.ListFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list, err := submodules(ctx, s.githubSource, s.githubSource.submoduleCommits())
+	if err != nil {
+		return nil, fmt.Errorf("error reading .gitmodules: %w", err)
+	}
+	if !s.recurse {
+		s.mu.Lock()
+		s.submodules = list
+		s.mu.Unlock()
+		return files, nil
+	}
+
+	var mu sync.Mutex
+	eg, ctx := errgroup.WithContext(ctx)
+	for i := range list {
+		sub := &list[i]
+		owner, repo, ok := githubRepoOf(s.owner, sub.URL)
+		if !ok {
+			s.warnf("submodule %s isn't on GitHub, its files weren't analyzed", sub.Path)
+			continue
+		}
+		child := &githubSource{client: s.client, owner: owner, repo: repo, ref: sub.Commit, concurrency: s.concurrency}
+		eg.Go(func() error {
+			childFiles, err := child.ListFiles(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				s.warnf("submodule %s couldn't be listed, its files weren't analyzed: %v", sub.Path, err)
+				return nil
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, f := range childFiles {
+				files = append(files, sub.Path+"/"+f)
+			}
+			sub.Scanned = true
+			s.mu.Lock()
+			s.mounts[sub.Path] = child
+			s.mu.Unlock()
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	s.mu.Lock()
+	s.submodules = list
+	s.mu.Unlock()
+	return files, nil
+}
+
+func (s *submoduleSource) ReadFile(ctx context.Context, p string) (string, error) {
+	s.mu.Lock()
+	var mount string
+	var child *githubSource
+	for dir, src := range s.mounts {
+		if strings.HasPrefix(p, dir+"/") && len(dir) > len(mount) {
+			mount, child = dir, src
+		}
+	}
+	s.mu.Unlock()
+	if child != nil {
+		return child.ReadFile(ctx, strings.TrimPrefix(p, mount+"/"))
+	}
+	return s.githubSource.ReadFile(ctx, p)
+}
+
+func (s *submoduleSource) warnf(format string, args ...interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.warnings = append(s.warnings, fmt.Sprintf(format, args...))
+}
+
+// Warnings passes on the warnings of the repository and of its submodules.
+func (s *submoduleSource) Warnings() []string {
+	warnings := s.githubSource.Warnings()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	warnings = append(warnings, s.warnings...)
+	dirs := make([]string, 0, len(s.mounts))
+	for dir := range s.mounts {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		for _, w := range s.mounts[dir].Warnings() {
+			warnings = append(warnings, "submodule "+dir+": "+w)
+		}
+	}
+	return warnings
+}
+
+// Submodules returns the submodules of the repository, once listed.
+func (s *submoduleSource) Submodules() []Submodule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.submodules
+}
+
+// initSubmodules finds the submodules of the clone and, when recursing,
+// checks them out with the token, shallowly. Failing to check them out
+// leaves them out of the analysis with a warning.
+func (s *cloneSource) initSubmodules(ctx context.Context, token string, recurse bool) error {
+	out, err := exec.CommandContext(ctx, "git", "-C", s.dir, "-c", "core.quotePath=false", "ls-files", "--stage").Output()
+	if err != nil {
+		return fmt.Errorf("error listing submodules: %v", err)
+	}
+	commits := make(map[string]string)
+	lines := bufio.NewScanner(strings.NewReader(string(out)))
+	for lines.Scan() {
+		// <mode> <sha> <stage>\t<path>, submodules having mode 160000.
+		meta, p, ok := strings.Cut(lines.Text(), "\t")
+		fields := strings.Fields(meta)
+		if ok && len(fields) == 3 && fields[0] == "160000" {
+			commits[p] = fields[1]
+		}
+	}
+	list, err := submodules(ctx, s, commits)
+	if err != nil {
+		return fmt.Errorf("error reading .gitmodules: %w", err)
+	}
+	s.submodules = list
+	if !recurse || len(list) == 0 {
+		return nil
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "-C", s.dir, "submodule", "update", "--init", "--depth", "1", "--quiet")
+	cmd.Env = s.env
+	if output, err := cmd.CombinedOutput(); err != nil {
+		msg := strings.ReplaceAll(string(output), token, "***")
+		s.warnings = append(s.warnings, fmt.Sprintf("submodules couldn't be checked out, their files weren't analyzed: %v: %s", err, strings.TrimSpace(msg)))
+		return nil
+	}
+	for i := range s.submodules {
+		s.submodules[i].Scanned = true
+	}
+	return nil
+}
+
+// Warnings explains what the clone is missing.
+func (s *cloneSource) Warnings() []string {
+	return s.warnings
+}
+
+// Submodules returns the submodules of the clone.
+func (s *cloneSource) Submodules() []Submodule {
+	return s.submodules
+}
@@ -0,0 +1,119 @@
+package rgc
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/igorfelipeduca/rgc/internal/jsparse"
+	"golang.org/x/sync/errgroup"
+)
+
+// isTestFile reports whether p is a test: a *.test.* or *.spec.* file, or
+// any file under a __tests__ directory.
+func isTestFile(p string) bool {
+	if strings.HasPrefix(p, "__tests__/") || strings.Contains(p, "/__tests__/") {
+		return true
+	}
+	name := strings.TrimSuffix(path.Base(p), path.Ext(p))
+	return strings.HasSuffix(name, ".test") || strings.HasSuffix(name, ".spec")
+}
+
+// e2eDirs are the directories end-to-end suites live in.
+var e2eDirs = map[string]bool{"e2e": true, "cypress": true, "playwright": true}
+
+var (
+	// componentTestIDRegex finds the test ids a component renders.
+	componentTestIDRegex = regexp.MustCompile(`\b(?:data-testid|data-test-id|data-test|data-cy|testID)\s*=\s*\{?\s*["'` + "`" + `]([^"'` + "`" + `]+)["'` + "`" + `]`)
+	// e2eTestIDRegexes find the test ids an end-to-end spec looks for, through
+	// Testing Library style queries or attribute selectors.
+	e2eTestIDRegexes = []*regexp.Regexp{
+		regexp.MustCompile(`(?:[gG]etBy|[gG]etAllBy|[fF]indBy|[fF]indAllBy|[qQ]ueryBy|[qQ]ueryAllBy)TestId\(\s*["'` + "`" + `]([^"'` + "`" + `]+)`),
+		regexp.MustCompile(`\[\s*data-(?:testid|test-id|test|cy)\s*[~^$*|]?=\s*["']?([^"'\]\s]+)`),
+	}
+)
+
+// isE2EFile reports whether p belongs to an end-to-end suite: a file under
+// an e2e, cypress or playwright directory, or a *.cy.* or *.e2e.* file.
+func isE2EFile(p string) bool {
+	for _, dir := range strings.Split(path.Dir(p), "/") {
+		if e2eDirs[dir] {
+			return true
+		}
+	}
+	name := strings.TrimSuffix(path.Base(p), path.Ext(p))
+	return strings.HasSuffix(name, ".cy") || strings.HasSuffix(name, ".e2e")
+}
+
+// isStoryFile reports whether p is a Storybook story, *.stories.* or *.story.*.
+func isStoryFile(p string) bool {
+	name := strings.TrimSuffix(path.Base(p), path.Ext(p))
+	return strings.HasSuffix(name, ".stories") || strings.HasSuffix(name, ".story")
+}
+
+// isSupportFile reports whether p supports development without shipping
+// with the application, so its imports don't make a component used.
+func isSupportFile(p string) bool {
+	return isTestFile(p) || isStoryFile(p) || isE2EFile(p)
+}
+
+// componentTestIDs returns the test ids rendered in a component's source.
+func componentTestIDs(content string) []string {
+	var ids []string
+	for _, m := range componentTestIDRegex.FindAllStringSubmatch(content, -1) {
+		ids = append(ids, m[1])
+	}
+	return ids
+}
+
+// findSupportImports reads every test, story and end-to-end file and records
+// the components they import, so components only tests or stories use can
+// be told apart from dead ones. End-to-end specs rarely import components,
+// so the test ids they query are recorded too.
+func (sc *scan) findSupportImports(ctx context.Context) error {
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(sc.concurrency)
+	for _, p := range sc.files {
+		if !isSupportFile(p) || !jsparse.Supported(p) {
+			continue
+		}
+		p := p
+		imports := sc.testImports
+		switch {
+		case isE2EFile(p):
+			imports = sc.e2eImports
+		case isStoryFile(p):
+			imports = sc.storyImports
+		}
+		eg.Go(func() error {
+			content, err := sc.src.ReadFile(ctx, p)
+			if err != nil {
+				if err == errFileNotFound {
+					return nil
+				}
+				return err
+			}
+			children, err := sc.findChildComponents(ctx, p, content)
+			if err != nil {
+				return err
+			}
+			sc.mu.Lock()
+			defer sc.mu.Unlock()
+			for _, child := range children {
+				if child := sc.childPath(p, child); child != "" {
+					imports[child] = true
+				}
+			}
+			if isE2EFile(p) {
+				for _, re := range e2eTestIDRegexes {
+					for _, m := range re.FindAllStringSubmatch(content, -1) {
+						sc.e2eTestIDs[m[1]] = true
+					}
+				}
+			}
+			return nil
+		})
+	}
+	return eg.Wait()
+}
@@ -0,0 +1,54 @@
+package rgc
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/igorfelipeduca/rgc/internal/jsparse"
+)
+
+var (
+	svelteScriptRe  = regexp.MustCompile(`(?s)<script\b[^>]*>(.*?)</script>`)
+	svelteBlockRe   = regexp.MustCompile(`(?s)<(script|style)\b[^>]*>.*?</(script|style)>`)
+	svelteCommentRe = regexp.MustCompile(`(?s)<!--.*?-->`)
+)
+
+// findSvelteChildComponents returns the components a .svelte file imports
+// and actually renders, either as a <Tag> or through
+// <svelte:component this={Tag}>. Imports only referenced from the script
+// block don't count as usage.
+func findSvelteChildComponents(ctx context.Context, content string) ([]childImport, error) {
+	markup := svelteBlockRe.ReplaceAllString(content, "")
+	markup = svelteCommentRe.ReplaceAllString(markup, "")
+
+	var childComponents []childImport
+	for _, script := range svelteScriptRe.FindAllStringSubmatch(content, -1) {
+		// The TypeScript grammar accepts plain JavaScript too.
+		file, err := parseFile(ctx, "script.ts", script[1])
+		if err != nil {
+			return nil, err
+		}
+		for _, imp := range file.Imports {
+			if imp.Kind != jsparse.Static || !strings.HasPrefix(imp.Specifier, ".") {
+				continue // Skip non-relative imports
+			}
+			for _, b := range imp.ValueBindings() {
+				if svelteRendersComponent(markup, b.Local) {
+					childComponents = append(childComponents, childImport{
+						Name: componentNameFromSpecifier(imp.Specifier),
+						Edge: ImportEdge{Kind: edgeKind(imp), Specifier: imp.Specifier, Line: imp.Line},
+					})
+					break
+				}
+			}
+		}
+	}
+	return childComponents, nil
+}
+
+func svelteRendersComponent(markup, local string) bool {
+	name := regexp.QuoteMeta(local)
+	re := regexp.MustCompile(`<` + name + `[\s/>]|this=\{\s*` + name + `\s*\}`)
+	return re.MatchString(markup)
+}
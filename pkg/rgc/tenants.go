@@ -0,0 +1,321 @@
+package rgc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tokenProviders are the providers a tenant can store a token for.
+var tokenProviders = map[string]bool{"github": true}
+
+// Tenant is an account of a hosted deployment. Its users register the
+// repositories they scan and store their provider tokens in the vault, so
+// scheduled scans and their own requests don't need to carry a token.
+type Tenant struct {
+	Name string `json:"name"`
+	// Repos are the owner/repo the tenant registered, sorted.
+	Repos []string `json:"repos"`
+	// Tokens maps each provider to the tenant's sealed token for it. The
+	// tokens themselves are never returned.
+	Tokens    map[string]*SealedToken `json:"tokens"`
+	CreatedAt time.Time               `json:"created_at"`
+}
+
+// registered reports whether the tenant registered owner/repo.
+func (t *Tenant) registered(owner, repo string) bool {
+	return slices.ContainsFunc(t.Repos, func(r string) bool { return strings.EqualFold(r, owner+"/"+repo) })
+}
+
+// token decrypts the tenant's token for provider, or returns "" when it
+// stored none.
+func (t *Tenant) token(provider string) (string, error) {
+	sealed, ok := t.Tokens[provider]
+	if !ok {
+		return "", nil
+	}
+	v, err := loadVault()
+	if err != nil {
+		return "", err
+	}
+	token, err := v.open(sealed, t.Name+"/"+provider)
+	if err != nil {
+		return "", fmt.Errorf("error opening the %s token of tenant %s: %v", provider, t.Name, err)
+	}
+	return token, nil
+}
+
+// repoToken returns the GitHub token tenant name stored, checking it
+// registered owner/repo.
+func repoToken(ctx context.Context, name, owner, repo string) (string, error) {
+	t, err := findTenant(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if t == nil {
+		return "", errNotFound("tenant %s not found", name)
+	}
+	if !t.registered(owner, repo) {
+		return "", errBadRequest("tenant %s didn't register %s/%s", name, owner, repo)
+	}
+	token, err := t.token("github")
+	if err != nil {
+		return "", err
+	}
+	if token == "" {
+		return "", errBadRequest("tenant %s has no GitHub token stored", name)
+	}
+	return token, nil
+}
+
+// withStoredToken fills in a stored token when the request brings none:
+// the token the logged in user granted, or else the token of the request's
+// tenant when it registered the repository.
+func (p *RequestPayload) withStoredToken(ctx context.Context) error {
+	if p.Token != "" {
+		return nil
+	}
+	if session, ok := ctx.Value(sessionContextKey{}).(*Session); ok {
+		var err error
+		p.Token, err = session.token()
+		p.tokenSource = "session"
+		return err
+	}
+	key, ok := ctx.Value(apiKeyContextKey{}).(*APIKey)
+	if !ok || key.Tenant == "" {
+		return nil
+	}
+	t, err := findTenant(ctx, key.Tenant)
+	if err != nil || t == nil || !t.registered(p.Username, p.Repo) {
+		return err
+	}
+	p.Token, err = t.token("github")
+	if p.Token != "" {
+		p.tokenSource = "tenant"
+	}
+	return err
+}
+
+func findTenant(ctx context.Context, name string) (*Tenant, error) {
+	stored, err := analyses.storage.Tenants(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range stored {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return nil, nil
+}
+
+// requireTenant lets through requests bearing the admin token, or an API
+// key of the tenant in the path.
+func requireTenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isAdminRequest(c) {
+			c.Next()
+			return
+		}
+		given := c.GetHeader(apiKeyHeader)
+		if given == "" {
+			respondError(c, &APIError{Status: http.StatusUnauthorized, Code: codeUnauthorized,
+				Message: "an API key of the tenant is required, pass it in the " + apiKeyHeader + " header"})
+			return
+		}
+		key, err := findAPIKey(c.Request.Context(), given)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		if key == nil || key.Tenant != c.Param("name") {
+			respondError(c, &APIError{Status: http.StatusUnauthorized, Code: codeUnauthorized, Message: "invalid API key for this tenant"})
+			return
+		}
+		c.Request = c.Request.WithContext(withAPIKey(c.Request.Context(), key))
+		c.Next()
+	}
+}
+
+// tenantFromPath returns the tenant named in the path, answering 404 when
+// there is none.
+func tenantFromPath(c *gin.Context) (*Tenant, bool) {
+	t, err := findTenant(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		respondError(c, err)
+		return nil, false
+	}
+	if t == nil {
+		respondError(c, errNotFound("tenant %s not found", c.Param("name")))
+		return nil, false
+	}
+	return t, true
+}
+
+// handleTenantsRequest lists the tenants.
+func handleTenantsRequest(c *gin.Context) {
+	stored, err := analyses.storage.Tenants(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].Name < stored[j].Name })
+	c.JSON(http.StatusOK, gin.H{"tenants": stored})
+}
+
+// handleCreateTenantRequest creates a tenant from {"name": "..."}.
+func handleCreateTenantRequest(c *gin.Context) {
+	var payload struct {
+		Name string `json:"name"`
+	}
+	if err := c.BindJSON(&payload); err != nil {
+		respondError(c, errBadRequest("%v", err))
+		return
+	}
+	if !apiKeyNamePattern.MatchString(payload.Name) {
+		respondError(c, errBadRequest("name must be letters, digits, '.', '-' and '_', at most 64 characters"))
+		return
+	}
+	if existing, err := findTenant(c.Request.Context(), payload.Name); err != nil {
+		respondError(c, err)
+		return
+	} else if existing != nil {
+		respondError(c, &APIError{Status: http.StatusConflict, Code: codeInvalidRequest,
+			Message: fmt.Sprintf("tenant %s already exists", payload.Name)})
+		return
+	}
+	t := &Tenant{Name: payload.Name, Repos: []string{}, Tokens: map[string]*SealedToken{}, CreatedAt: time.Now().UTC()}
+	if err := analyses.storage.SaveTenant(c.Request.Context(), t); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, t)
+}
+
+// handleDeleteTenantRequest removes a tenant along with its tokens.
+func handleDeleteTenantRequest(c *gin.Context) {
+	if err := analyses.storage.DeleteTenant(c.Request.Context(), c.Param("name")); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func handleTenantRequest(c *gin.Context) {
+	if t, ok := tenantFromPath(c); ok {
+		c.JSON(http.StatusOK, t)
+	}
+}
+
+// handlePutTenantRepoRequest registers a repository with the tenant, after
+// checking its token, or the server's, can read it.
+func handlePutTenantRepoRequest(c *gin.Context) {
+	t, ok := tenantFromPath(c)
+	if !ok {
+		return
+	}
+	owner, repo := c.Param("owner"), c.Param("repo")
+	if err := validateRepo("owner", owner, repo); err != nil {
+		respondError(c, err)
+		return
+	}
+	token, err := t.token("github")
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := checkRepoExists(c.Request.Context(), token, owner, repo); err != nil {
+		respondError(c, err)
+		return
+	}
+	if !t.registered(owner, repo) {
+		t.Repos = append(t.Repos, owner+"/"+repo)
+		sort.Strings(t.Repos)
+	}
+	if err := analyses.storage.SaveTenant(c.Request.Context(), t); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+func handleDeleteTenantRepoRequest(c *gin.Context) {
+	t, ok := tenantFromPath(c)
+	if !ok {
+		return
+	}
+	owner, repo := c.Param("owner"), c.Param("repo")
+	t.Repos = slices.DeleteFunc(t.Repos, func(r string) bool { return strings.EqualFold(r, owner+"/"+repo) })
+	if err := analyses.storage.SaveTenant(c.Request.Context(), t); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// handlePutTenantTokenRequest stores a provider token from {"token": "..."},
+// encrypted with the vault's master key, after checking GitHub accepts it.
+func handlePutTenantTokenRequest(c *gin.Context) {
+	provider := c.Param("provider")
+	if !tokenProviders[provider] {
+		respondError(c, errNotFound("unknown token provider %q", provider))
+		return
+	}
+	v, err := loadVault()
+	if err == errVaultDisabled {
+		respondError(c, errNotFound("%v", err))
+		return
+	} else if err != nil {
+		respondError(c, err)
+		return
+	}
+	t, ok := tenantFromPath(c)
+	if !ok {
+		return
+	}
+	var payload struct {
+		Token string `json:"token"`
+	}
+	if err := c.BindJSON(&payload); err != nil {
+		respondError(c, errBadRequest("%v", err))
+		return
+	}
+	if payload.Token == "" {
+		respondError(c, errBadRequest("token is required"))
+		return
+	}
+	if _, err := inspectToken(c.Request.Context(), newGitHubClient(payload.Token)); err != nil {
+		respondError(c, err)
+		return
+	}
+	sealed, err := v.seal(payload.Token, t.Name+"/"+provider)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	t.Tokens[provider] = sealed
+	if err := analyses.storage.SaveTenant(c.Request.Context(), t); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+func handleDeleteTenantTokenRequest(c *gin.Context) {
+	t, ok := tenantFromPath(c)
+	if !ok {
+		return
+	}
+	delete(t.Tokens, c.Param("provider"))
+	if err := analyses.storage.SaveTenant(c.Request.Context(), t); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
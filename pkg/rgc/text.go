@@ -0,0 +1,115 @@
+package rgc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// renderTextTree renders the result as an ASCII tree, like the output of
+// `tree`, marking used components with ✓ and unused ones with ✗. Top-level
+// entries are the components nothing imports. A component is expanded the
+// first time it appears and referenced afterwards, so shared subtrees are
+// only printed once.
+func renderTextTree(title string, result *ComponentsResult) string {
+	nodes := make(map[string]*ComponentNode)
+	used := make(map[string]bool)
+	imported := make(map[string]bool)
+	var all []*ComponentNode
+	for _, node := range result.Used {
+		used[node.Component.Path] = true
+		all = append(all, node)
+	}
+	all = append(all, result.Unused...)
+	for _, node := range all {
+		nodes[node.Component.Path] = node
+		for _, child := range node.Children {
+			imported[child.Component.Path] = true
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%d used, %d unused)\n", title, result.UsedCount, result.UnusedCount)
+
+	var tops []*ComponentNode
+	for _, node := range all {
+		if !imported[node.Component.Path] {
+			tops = append(tops, node)
+		}
+	}
+
+	expanded := make(map[string]bool)
+	var write func(node *ComponentNode, prefix string, last bool, ancestors map[string]bool)
+	write = func(node *ComponentNode, prefix string, last bool, ancestors map[string]bool) {
+		path := node.Component.Path
+		branch, indent := "├── ", "│   "
+		if last {
+			branch, indent = "└── ", "    "
+		}
+		mark := "✗"
+		if used[path] {
+			mark = "✓"
+		}
+
+		full := nodes[path]
+		var note string
+		switch {
+		case ancestors[path]:
+			note = " (cycle)"
+		case expanded[path] && full != nil && len(full.Children) > 0:
+			note = " (see above)"
+		}
+		fmt.Fprintf(&b, "%s%s%s %s (%s)%s\n", prefix, branch, mark, node.Component.Name, path, note)
+		if note != "" || full == nil {
+			return
+		}
+
+		expanded[path] = true
+		ancestors[path] = true
+		for i, child := range full.Children {
+			write(child, prefix+indent, i == len(full.Children)-1, ancestors)
+		}
+		delete(ancestors, path)
+	}
+
+	// Components that only sit in import cycles have no top-level entry,
+	// add one for each cycle so nothing is left out.
+	reached := make(map[string]bool)
+	for _, node := range tops {
+		markReachable(node, nodes, reached)
+	}
+	for _, node := range all {
+		if !reached[node.Component.Path] {
+			tops = append(tops, node)
+			markReachable(node, nodes, reached)
+		}
+	}
+
+	for i, node := range tops {
+		write(node, "", i == len(tops)-1, make(map[string]bool))
+	}
+
+	if len(result.OrphanedSubtrees) > 0 {
+		b.WriteString("\nOrphaned subtrees, deletable together:\n")
+		for _, s := range result.OrphanedSubtrees {
+			fmt.Fprintf(&b, "  %s: %d components, %d bytes\n", strings.Join(s.Roots, ", "), len(s.Components), s.Size)
+		}
+	}
+	return b.String()
+}
+
+// markReachable adds every component reachable from node to reached.
+func markReachable(node *ComponentNode, nodes map[string]*ComponentNode, reached map[string]bool) {
+	stack := []*ComponentNode{node}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		p := n.Component.Path
+		if reached[p] {
+			continue
+		}
+		reached[p] = true
+		if full := nodes[p]; full != nil {
+			stack = append(stack, full.Children...)
+		}
+	}
+}
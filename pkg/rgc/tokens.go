@@ -0,0 +1,119 @@
+package rgc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v39/github"
+)
+
+// errInvalidToken means GitHub rejected the token outright.
+var errInvalidToken = errors.New("GitHub token is invalid or expired")
+
+// tokenOperation is something rgc needs a token to be allowed to do.
+type tokenOperation int
+
+const (
+	opReadContents tokenOperation = iota
+	opWritePullRequests
+	opWriteStatuses
+	opWriteIssues
+)
+
+// MissingScopesError lists the OAuth scopes a token lacks for an operation.
+type MissingScopesError struct {
+	Operation string
+	// Missing holds alternatives: any one of them is enough.
+	Missing []string
+}
+
+func (e *MissingScopesError) Error() string {
+	return fmt.Sprintf("GitHub token is missing the scope needed to %s: grant one of %q and try again",
+		e.Operation, strings.Join(e.Missing, ", "))
+}
+
+// TokenInfo describes what GitHub reports about a token.
+type TokenInfo struct {
+	// Classic is true for classic personal access tokens and OAuth tokens,
+	// which report their scopes. Fine-grained tokens and GitHub App tokens
+	// don't, so their permissions can only be discovered by using them.
+	Classic bool
+	Scopes  []string
+}
+
+func (t *TokenInfo) hasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns a MissingScopesError if the token can't perform op on a
+// repository with the given visibility. Tokens without scopes are let
+// through, GitHub will reject the actual call if they lack permissions.
+func (t *TokenInfo) check(private bool, op tokenOperation) error {
+	if !t.Classic {
+		return nil
+	}
+
+	var accepted []string
+	var operation string
+	switch op {
+	case opReadContents:
+		operation = "read repository contents"
+		if !private {
+			return nil
+		}
+		accepted = []string{"repo"}
+	case opWritePullRequests:
+		operation = "open pull requests"
+		accepted = []string{"repo"}
+		if !private {
+			accepted = append(accepted, "public_repo")
+		}
+	case opWriteStatuses:
+		operation = "post commit statuses"
+		accepted = []string{"repo", "repo:status"}
+	case opWriteIssues:
+		operation = "file issues"
+		accepted = []string{"repo"}
+		if !private {
+			accepted = append(accepted, "public_repo")
+		}
+	}
+
+	for _, scope := range accepted {
+		if t.hasScope(scope) {
+			return nil
+		}
+	}
+	return &MissingScopesError{Operation: operation, Missing: accepted}
+}
+
+// inspectToken asks GitHub which scopes the client's token carries. The rate
+// limit endpoint works for every kind of token and doesn't count against the limit.
+func inspectToken(ctx context.Context, client *github.Client) (*TokenInfo, error) {
+	_, resp, err := client.RateLimits(ctx)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+			return nil, errInvalidToken
+		}
+		return nil, fmt.Errorf("error inspecting GitHub token: %w", err)
+	}
+
+	info := &TokenInfo{}
+	if header, ok := resp.Header["X-Oauth-Scopes"]; ok {
+		info.Classic = true
+		for _, scope := range strings.Split(strings.Join(header, ","), ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				info.Scopes = append(info.Scopes, scope)
+			}
+		}
+	}
+	return info, nil
+}
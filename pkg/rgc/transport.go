@@ -0,0 +1,97 @@
+package rgc
+
+import (
+	"crypto/sha256"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v39/github"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// maxIdleConnsPerHost keeps enough idle connections to GitHub for a few
+	// scans running at full concurrency. The default of 2 makes every burst
+	// of parallel requests open and close sockets.
+	maxIdleConnsPerHost = 4 * maxConcurrency
+	maxCachedClients    = 256
+)
+
+// githubTransport is the connection pool every GitHub request goes through.
+// It speaks HTTP/2 where possible, so parallel requests of a scan share a
+// single connection, and bounds every phase of a request so a stalled
+// connection can't hang a scan.
+var githubTransport = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          2 * maxIdleConnsPerHost,
+	MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ResponseHeaderTimeout: 30 * time.Second,
+	ExpectContinueTimeout: time.Second,
+}
+
+// tracingTransport traces every request sent to GitHub. Those the ETag
+// cache revalidates show up as 304s.
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	_, span := tracer.Start(req.Context(), "GitHub "+req.Method, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLPath(req.URL.Path),
+		))
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+		if resp.StatusCode >= 400 {
+			span.SetStatus(codes.Error, resp.Status)
+		}
+	}
+	endSpan(span, err)
+	return resp, err
+}
+
+// githubClients caches a client per token, so requests with the same token
+// reuse it instead of building a new one per analysis.
+var githubClients = struct {
+	mu      sync.Mutex
+	clients map[[sha256.Size]byte]*github.Client
+}{clients: make(map[[sha256.Size]byte]*github.Client)}
+
+// newGitHubClient returns a client authenticated with token that shares the
+// connection pool and the ETag cache with every other client.
+func newGitHubClient(token string) *github.Client {
+	key := sha256.Sum256([]byte(token))
+
+	githubClients.mu.Lock()
+	defer githubClients.mu.Unlock()
+	if client, ok := githubClients.clients[key]; ok {
+		return client
+	}
+	if len(githubClients.clients) >= maxCachedClients {
+		// Per-request tokens could otherwise grow the cache forever.
+		githubClients.clients = make(map[[sha256.Size]byte]*github.Client)
+	}
+
+	client := github.NewClient(&http.Client{
+		Transport: &oauth2.Transport{
+			Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}),
+			Base:   sharedETagTransport,
+		},
+	})
+	githubClients.clients[key] = client
+	return client
+}
@@ -0,0 +1,94 @@
+package rgc
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultTrendPoints = 100
+	maxTrendPoints     = 1000
+)
+
+// TrendPoint is the component counts of one analysis in a repository's
+// history.
+type TrendPoint struct {
+	AnalysisID         string    `json:"analysis_id"`
+	Ref                string    `json:"ref,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+	UsedCount          int       `json:"used_count"`
+	UnusedCount        int       `json:"unused_count"`
+	TestOnlyCount      int       `json:"test_only_count"`
+	StorybookOnlyCount int       `json:"storybook_only_count"`
+	E2EOnlyCount       int       `json:"e2e_only_count"`
+}
+
+// Trend is a time series of component counts, oldest first, with how the
+// counts changed from the first point to the last.
+type Trend struct {
+	Points       []TrendPoint `json:"points"`
+	UsedChange   int          `json:"used_change"`
+	UnusedChange int          `json:"unused_change"`
+}
+
+// buildTrend turns scans listed newest first into a trend, leaving out the
+// ones older than since.
+func buildTrend(scans []ScanSummary, since time.Time) *Trend {
+	trend := &Trend{Points: []TrendPoint{}}
+	for i := len(scans) - 1; i >= 0; i-- {
+		s := scans[i]
+		if s.CreatedAt.Before(since) {
+			continue
+		}
+		trend.Points = append(trend.Points, TrendPoint{
+			AnalysisID:         s.ID,
+			Ref:                s.Ref,
+			CreatedAt:          s.CreatedAt,
+			UsedCount:          s.UsedCount,
+			UnusedCount:        s.UnusedCount,
+			TestOnlyCount:      s.TestOnlyCount,
+			StorybookOnlyCount: s.StorybookOnlyCount,
+			E2EOnlyCount:       s.E2EOnlyCount,
+		})
+	}
+	if n := len(trend.Points); n > 1 {
+		first, last := trend.Points[0], trend.Points[n-1]
+		trend.UsedChange = last.UsedCount - first.UsedCount
+		trend.UnusedChange = last.UnusedCount - first.UnusedCount
+	}
+	return trend
+}
+
+// handleTrendsRequest returns the used and unused counts of a repository's
+// analyses over time, so dead code growth can be charted release over
+// release. ?limit= caps the number of points, counting from the most recent
+// analysis, and ?since= (RFC 3339) drops older ones.
+func handleTrendsRequest(c *gin.Context) {
+	limit := defaultTrendPoints
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			respondError(c, errBadRequest("limit must be a positive number"))
+			return
+		}
+		limit = min(n, maxTrendPoints)
+	}
+	var since time.Time
+	if v := c.Query("since"); v != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, v); err != nil {
+			respondError(c, errBadRequest("since must be an RFC 3339 timestamp, e.g. 2024-01-31T00:00:00Z"))
+			return
+		}
+	}
+
+	scans, err := analyses.list(c.Request.Context(), c.Param("owner"), c.Param("repo"), limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, buildTrend(scans, since))
+}
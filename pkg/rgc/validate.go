@@ -0,0 +1,77 @@
+package rgc
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// GitHub logins are letters, digits and hyphens, at most 39 characters.
+	// New ones can't repeat or end with a hyphen, but some old ones do.
+	ownerPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+	repoPattern  = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+)
+
+const (
+	maxOwnerLength = 39
+	maxRepoLength  = 100
+)
+
+// validateOwner checks that owner can be a GitHub user or organization.
+func validateOwner(field, owner string) error {
+	switch {
+	case owner == "":
+		return errBadRequest("%s is required", field)
+	case strings.Contains(owner, "/"):
+		return &APIError{Status: http.StatusBadRequest, Code: codeInvalidRequest,
+			Message: field + " must be a GitHub login, not a path or URL",
+			Details: gin.H{"field": field, "value": owner}}
+	case len(owner) > maxOwnerLength || !ownerPattern.MatchString(owner):
+		return &APIError{Status: http.StatusBadRequest, Code: codeInvalidRequest,
+			Message: field + " must be a GitHub login: letters, digits and hyphens, not starting with a hyphen, at most 39 characters",
+			Details: gin.H{"field": field, "value": owner}}
+	}
+	return nil
+}
+
+// validateRepo checks that owner and repo can name a GitHub repository,
+// ownerField being how the request calls the owner. It catches typos and
+// malformed input before any request reaches GitHub.
+func validateRepo(ownerField, owner, repo string) error {
+	if strings.Contains(repo, "/") {
+		return &APIError{Status: http.StatusBadRequest, Code: codeInvalidRequest,
+			Message: "repo must be the repository name alone, pass the owner as " + ownerField,
+			Details: gin.H{"field": "repo", "value": repo}}
+	}
+	if err := validateOwner(ownerField, owner); err != nil {
+		return err
+	}
+	switch {
+	case repo == "":
+		return errBadRequest("repo is required")
+	case repo == "." || repo == ".." || len(repo) > maxRepoLength || !repoPattern.MatchString(repo):
+		return &APIError{Status: http.StatusBadRequest, Code: codeInvalidRequest,
+			Message: "repo must be a repository name: letters, digits, '.', '-' and '_', at most 100 characters",
+			Details: gin.H{"field": "repo", "value": repo}}
+	}
+	return nil
+}
+
+// checkRepoExists looks the repository up with token, or GITHUB_TOKEN when
+// empty, for requests that would otherwise only find out in the background
+// that it doesn't exist.
+func checkRepoExists(ctx context.Context, token, owner, repo string) error {
+	if token == "" {
+		token = os.Getenv("GITHUB_TOKEN")
+	}
+	if token == "" {
+		return errTokenNotSet
+	}
+	_, err := fetchRepoMeta(ctx, newGitHubClient(token), owner, repo)
+	return err
+}
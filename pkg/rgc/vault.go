@@ -0,0 +1,110 @@
+package rgc
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errVaultDisabled means no master key is configured, so tokens can't be
+// stored.
+var errVaultDisabled = errors.New("the token vault is disabled, set RGC_VAULT_KEY or RGC_VAULT_KEY_FILE")
+
+// SealedToken is a provider token encrypted with the vault's master key.
+type SealedToken struct {
+	// KeyID identifies the master key the token was sealed with, so a
+	// rotated key is told apart from a corrupted token.
+	KeyID      string    `json:"key_id"`
+	Ciphertext []byte    `json:"-"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// vault seals tokens at rest with AES-256-GCM.
+type vault struct {
+	keyID string
+	aead  cipher.AEAD
+}
+
+// loadVault reads the master key: RGC_VAULT_KEY, 32 bytes encoded in
+// base64, or the file RGC_VAULT_KEY_FILE names holding the same, e.g. a
+// secret a KMS decrypts into a mounted volume. It returns errVaultDisabled
+// when neither is set.
+func loadVault() (*vault, error) {
+	encoded := os.Getenv("RGC_VAULT_KEY")
+	source := "RGC_VAULT_KEY"
+	if file := os.Getenv("RGC_VAULT_KEY_FILE"); encoded == "" && file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading RGC_VAULT_KEY_FILE: %v", err)
+		}
+		encoded, source = string(data), "RGC_VAULT_KEY_FILE"
+	}
+	encoded = strings.TrimSpace(encoded)
+	if encoded == "" {
+		return nil, errVaultDisabled
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s must be 32 bytes encoded in base64, generate one with `openssl rand -base64 32`", source)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(key)
+	return &vault{keyID: hex.EncodeToString(sum[:4]), aead: aead}, nil
+}
+
+func vaultSettings() gin.H {
+	v, err := loadVault()
+	if err == errVaultDisabled {
+		return gin.H{"enabled": false}
+	} else if err != nil {
+		return gin.H{"enabled": false, "error": err.Error()}
+	}
+	return gin.H{"enabled": true, "key_id": v.keyID}
+}
+
+// seal encrypts token. label is authenticated along with it, binding the
+// ciphertext to the tenant and provider it was stored for.
+func (v *vault) seal(token, label string) (*SealedToken, error) {
+	nonce := make([]byte, v.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return &SealedToken{
+		KeyID:      v.keyID,
+		Ciphertext: v.aead.Seal(nonce, nonce, []byte(token), []byte(label)),
+		UpdatedAt:  time.Now().UTC(),
+	}, nil
+}
+
+// open decrypts a token sealed with the same label.
+func (v *vault) open(t *SealedToken, label string) (string, error) {
+	if t.KeyID != v.keyID {
+		return "", fmt.Errorf("token was sealed with master key %s, not the configured %s", t.KeyID, v.keyID)
+	}
+	n := v.aead.NonceSize()
+	if len(t.Ciphertext) < n {
+		return "", errors.New("sealed token is truncated")
+	}
+	token, err := v.aead.Open(nil, t.Ciphertext[:n], t.Ciphertext[n:], []byte(label))
+	if err != nil {
+		return "", errors.New("sealed token doesn't decrypt, it was tampered with or stored for another tenant")
+	}
+	return string(token), nil
+}
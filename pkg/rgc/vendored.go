@@ -0,0 +1,104 @@
+package rgc
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// VendoredComponent is a component that was copied into the repository from
+// a third-party library rather than written for it, such as shadcn/ui
+// primitives under components/ui.
+type VendoredComponent struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Used   bool   `json:"used"`
+	Reason string `json:"reason"`
+}
+
+// vendorDirs are directory names conventionally holding third-party code.
+var vendorDirs = map[string]bool{
+	"vendor":       true,
+	"vendors":      true,
+	"vendored":     true,
+	"third_party":  true,
+	"third-party":  true,
+	"thirdparty":   true,
+	"node_modules": true,
+}
+
+// inVendorDir reports whether p is inside a directory conventionally holding
+// third-party code. Those are left out of scans unless asked for.
+func inVendorDir(p string) bool {
+	for _, dir := range strings.Split(path.Dir(p), "/") {
+		if vendorDirs[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	radixImportRegex   = regexp.MustCompile(`from\s+["']@radix-ui/`)
+	shadcnHelperRegex  = regexp.MustCompile(`from\s+["'](class-variance-authority|@/lib/utils)["']`)
+	provenanceRegex    = regexp.MustCompile(`(?i)(copied|vendored|adapted|ported) from|ui\.shadcn\.com`)
+	provenanceHeadSize = 1024
+)
+
+// findShadcnRoots returns the directories holding a components.json, the config
+// file the shadcn/ui CLI writes next to the project it copies components into.
+func (sc *scan) findShadcnRoots() []string {
+	var roots []string
+	for _, p := range sc.files {
+		if path.Base(p) == "components.json" {
+			roots = append(roots, path.Dir(p))
+		}
+	}
+	return roots
+}
+
+// vendoredReason tells why the component at p looks vendored, or returns ""
+// when nothing suggests it was copied from elsewhere.
+func vendoredReason(p, content string, shadcnRoots []string) string {
+	dirs := strings.Split(path.Dir(p), "/")
+	for _, dir := range dirs {
+		if vendorDirs[dir] {
+			return "inside a " + dir + " directory"
+		}
+	}
+
+	for _, root := range shadcnRoots {
+		rel := p
+		if root != "." {
+			if !strings.HasPrefix(p, root+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(p, root+"/")
+		}
+		if strings.HasPrefix(rel, "components/ui/") || strings.Contains(rel, "/components/ui/") {
+			return "shadcn/ui component (" + path.Join(root, "components.json") + ")"
+		}
+	}
+
+	if radixImportRegex.MatchString(content) && shadcnHelperRegex.MatchString(content) {
+		return "shadcn/ui style primitive (Radix UI with cva or cn)"
+	}
+
+	head := content
+	if len(head) > provenanceHeadSize {
+		head = head[:provenanceHeadSize]
+	}
+	if m := provenanceRegex.FindString(head); m != "" {
+		return "provenance comment (\"" + m + "\")"
+	}
+	return ""
+}
+
+func (sc *scan) markVendored(p, reason string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.vendored == nil {
+		sc.vendored = make(map[string]string)
+	}
+	sc.vendored[p] = reason
+}
@@ -0,0 +1,181 @@
+package rgc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultVerifyCommand = "npx --no-install tsc --noEmit"
+	defaultVerifyTimeout = 5 * time.Minute
+	maxVerifyOutput      = 64 * 1024
+)
+
+// Verification statuses. A check that couldn't run, for lack of the
+// toolchain or of the dependencies, is unverified rather than failed.
+const (
+	verificationPassed     = "passed"
+	verificationFailed     = "failed"
+	verificationUnverified = "unverified"
+)
+
+// VerificationResult records whether the repository still builds once the
+// components reported as unused have been deleted.
+type VerificationResult struct {
+	Command string `json:"command"`
+	// InstallCommand installed the dependencies before the check, with
+	// network access.
+	InstallCommand string   `json:"install_command,omitempty"`
+	DeletedFiles   []string `json:"deleted_files"`
+	Status         string   `json:"status"`
+	// Reason tells why the deletion is unverified.
+	Reason     string `json:"reason,omitempty"`
+	Passed     bool   `json:"passed"`
+	ExitCode   int    `json:"exit_code"`
+	Output     string `json:"output"`
+	Truncated  bool   `json:"truncated,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// verifyCommand returns the command run against the pruned clone. It is only
+// configurable on the server, never per request.
+func verifyCommand() string {
+	return envOr("RGC_VERIFY_COMMAND", defaultVerifyCommand)
+}
+
+// verifyInstallCommand returns the command installing the dependencies of
+// the clone before the check, empty when there's none.
+func verifyInstallCommand() string {
+	return os.Getenv("RGC_VERIFY_INSTALL_COMMAND")
+}
+
+func verifyTimeout() time.Duration {
+	if v := os.Getenv("RGC_VERIFY_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultVerifyTimeout
+}
+
+// verifyDeletion deletes the unused component files from the clone and runs
+// the verify command in its root, inside the configured sandbox. The
+// install command, if any, runs first in the same sandbox with network.
+func verifyDeletion(ctx context.Context, dir string, unused []*ComponentNode) (*VerificationResult, error) {
+	sandbox, err := newSandbox(false)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &VerificationResult{
+		Command:        verifyCommand(),
+		InstallCommand: verifyInstallCommand(),
+		DeletedFiles:   []string{},
+	}
+
+	for _, node := range unused {
+		path := filepath.Join(dir, filepath.FromSlash(node.Component.Path))
+		if err := os.Remove(path); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("error deleting %s: %v", node.Component.Path, err)
+		}
+		result.DeletedFiles = append(result.DeletedFiles, node.Component.Path)
+	}
+
+	// The command comes from, or runs code of, the repository: keep the
+	// git metadata, remotes and credentials included, out of its reach.
+	gitDir := filepath.Join(dir, ".git")
+	hidden := dir + ".git"
+	if err := os.Rename(gitDir, hidden); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error hiding the git directory: %v", err)
+	} else if err == nil {
+		defer os.Rename(hidden, gitDir)
+	}
+
+	var output bytes.Buffer
+	start := time.Now()
+	defer func() { result.DurationMs = time.Since(start).Milliseconds() }()
+	if result.InstallCommand != "" {
+		installer, err := newSandbox(true)
+		if err != nil {
+			return nil, err
+		}
+		exitCode, err := runVerifyStep(ctx, installer, dir, result.InstallCommand, &output)
+		if err != nil {
+			return nil, fmt.Errorf("error running install command: %v", err)
+		}
+		if exitCode != 0 {
+			result.ExitCode = exitCode
+			result.unverified("installing the dependencies failed", output.Bytes())
+			return result, nil
+		}
+	} else if result.Command == defaultVerifyCommand && !exists(filepath.Join(dir, "node_modules")) && exists(filepath.Join(dir, "package.json")) {
+		// Without its dependencies, tsc fails on every package import.
+		result.unverified("the dependencies aren't installed: set RGC_VERIFY_INSTALL_COMMAND", nil)
+		return result, nil
+	}
+
+	exitCode, err := runVerifyStep(ctx, sandbox, dir, result.Command, &output)
+	if err != nil {
+		return nil, fmt.Errorf("error running verify command: %v", err)
+	}
+	result.ExitCode = exitCode
+	switch {
+	case exitCode == 0:
+		result.Status, result.Passed = verificationPassed, true
+	case toolMissing(exitCode, output.String()):
+		result.unverified("the verify command isn't installed in the sandbox", output.Bytes())
+		return result, nil
+	default:
+		result.Status = verificationFailed
+	}
+	result.setOutput(output.Bytes())
+	return result, nil
+}
+
+// runVerifyStep runs command in the sandbox within the verify timeout and
+// returns its exit code.
+func runVerifyStep(ctx context.Context, sandbox Sandbox, dir, command string, output io.Writer) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, verifyTimeout())
+	defer cancel()
+	err := sandbox.Run(ctx, dir, command, output)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	return 0, err
+}
+
+// toolMissing reports whether the command failed because the shell or npx
+// couldn't find the program to run.
+func toolMissing(exitCode int, output string) bool {
+	return exitCode == 127 || strings.Contains(output, "could not determine executable to run")
+}
+
+func (r *VerificationResult) unverified(reason string, output []byte) {
+	r.Status, r.Reason = verificationUnverified, reason
+	r.setOutput(output)
+}
+
+func (r *VerificationResult) setOutput(out []byte) {
+	if len(out) > maxVerifyOutput {
+		out = out[len(out)-maxVerifyOutput:]
+		r.Truncated = true
+	}
+	r.Output = string(out)
+}
+
+func exists(p string) bool {
+	_, err := os.Stat(p)
+	return err == nil
+}
@@ -0,0 +1,125 @@
+package rgc
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// wsEvent is a message the server sends on a WebSocket connection. Type is
+// "job" once a scan starts, "progress" as it goes, then "result" or "error".
+type wsEvent struct {
+	Type       string            `json:"type"`
+	Job        *Job              `json:"job,omitempty"`
+	Progress   *ProgressSnapshot `json:"progress,omitempty"`
+	AnalysisID string            `json:"analysis_id,omitempty"`
+	Components *ComponentsResult `json:"components,omitempty"`
+	Error      *APIError         `json:"error,omitempty"`
+}
+
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// checkWebSocketOrigin refuses the upgrades a page of another site starts
+// with the session cookie of a logged in user, which browsers send along
+// whatever the page: the socket would run scans with the user's GitHub
+// token. Clients authenticating with an API key, and those sending no
+// Origin, aren't browsers acting on a user's behalf.
+func checkWebSocketOrigin(config *websocket.Config, r *http.Request) error {
+	if _, ok := r.Context().Value(sessionContextKey{}).(*Session); !ok {
+		return nil
+	}
+	if _, ok := r.Context().Value(apiKeyContextKey{}).(*APIKey); ok {
+		return nil
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return fmt.Errorf("invalid origin %q", origin)
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return nil
+	}
+	if public, err := url.Parse(os.Getenv("RGC_PUBLIC_URL")); err == nil && public.Host != "" && strings.EqualFold(u.Host, public.Host) {
+		return nil
+	}
+	return fmt.Errorf("cross-origin upgrade from %q refused", origin)
+}
+
+// handleWebSocketRequest runs scans over a WebSocket connection. Each
+// message the client sends is a scan request, with the payload of POST
+// /jobs; the scan's progress and result come back on the same connection,
+// after which the client may send another request. Scans run as jobs, so
+// one still finishes and is recorded when the client goes away.
+func handleWebSocketRequest(c *gin.Context) {
+	shape, err := parseResultShape(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	key := rateLimitKey(c.Request.Context())
+
+	server := websocket.Server{Handshake: checkWebSocketOrigin, Handler: func(conn *websocket.Conn) {
+		defer conn.Close()
+		for {
+			var payload RequestPayload
+			if err := websocket.JSON.Receive(conn, &payload); err != nil {
+				return
+			}
+			if err := runWebSocketScan(c, conn, key, payload, shape); err != nil {
+				return
+			}
+		}
+	}}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// runWebSocketScan runs one scan requested on conn, reporting its progress
+// and result. It only fails when conn does.
+func runWebSocketScan(c *gin.Context, conn *websocket.Conn, key *APIKey, payload RequestPayload, shape resultShape) error {
+	send := func(event wsEvent) error { return websocket.JSON.Send(conn, event) }
+	fail := func(err error) error { return send(wsEvent{Type: "error", Error: toAPIError(err)}) }
+
+	if draining.Load() {
+		return fail(errShuttingDown())
+	}
+	if key != nil {
+		// Each scan on the connection counts, as each POST /jobs does.
+		if _, _, apiErr := takeScan(key); apiErr != nil {
+			return fail(apiErr)
+		}
+	}
+	ctx := accessContext(c)
+	started, err := startJob(ctx, payload)
+	if err != nil {
+		return fail(err)
+	}
+	job, _ := jobs.get(started.ID)
+	if err := send(wsEvent{Type: "job", Job: job}); err != nil {
+		return err
+	}
+
+	job, err = jobs.watch(ctx, started.ID, func(progress *ProgressSnapshot) error {
+		return send(wsEvent{Type: "progress", Progress: progress})
+	})
+	if err != nil {
+		return err
+	}
+	if job.Status == jobFailed {
+		return send(wsEvent{Type: "error", Error: job.Error})
+	}
+	analysis, err := analyses.get(ctx, job.Owner, job.Repo, job.AnalysisID)
+	if err != nil {
+		return fail(err)
+	}
+	return send(wsEvent{Type: "result", AnalysisID: analysis.ID, Components: shape.apply(analysis.Result)})
+}
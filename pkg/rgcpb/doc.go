@@ -0,0 +1,5 @@
+// Package rgcpb is the Go code generated from the gRPC API of rgc,
+// proto/rgc/v1/scan.proto.
+package rgcpb
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=github.com/igorfelipeduca/rgc --go-grpc_out=../.. --go-grpc_opt=module=github.com/igorfelipeduca/rgc rgc/v1/scan.proto